@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// How the two clusters of an A/B comparison are loaded.
+const (
+	// abSimultaneous runs both sides at the same time, each with its own workers.
+	abSimultaneous = "simultaneous"
+	// abSequential runs cluster B after cluster A, for the same testing time.
+	abSequential = "sequential"
+)
+
+// abComparison runs the identical seeded workload against a second cluster (B) next to the one given by -dsn (A),
+// and compares both sides tenant by tenant, e.g. to validate a TiDB upgrade or parameter change under multi-tenant load.
+// Workers of both sides draw the same random values, as worker streams are derived from the tenant name and worker index.
+// A nil *abComparison runs cluster A only.
+type abComparison struct {
+	mode string
+	dsns *dsnResolver     // DSNs of cluster B
+	opts *workloadOptions // settings of the cluster B side, set up by Options
+}
+
+// newABComparison returns nil when dsnsB is nil.
+func newABComparison(mode string, dsnsB *dsnResolver) (*abComparison, error) {
+	if dsnsB == nil {
+		return nil, nil
+	}
+	if mode != abSimultaneous && mode != abSequential {
+		return nil, fmt.Errorf("unknown A/B mode %q (want %s or %s)", mode, abSimultaneous, abSequential)
+	}
+	return &abComparison{mode: mode, dsns: dsnsB}, nil
+}
+
+// Options sets up the options of the cluster B side from those of cluster A: the workload is shared,
+// while statistics, pools and connect failures are kept per side. Rate controllers (tiers, global QPS cap, AIMD,
+// backoff governor, circuit breaker) and the shared workers have to be set up again by the caller, so the sides don't limit each other.
+func (c *abComparison) Options(a *workloadOptions) *workloadOptions {
+	b := *a
+	b.readiness = nil
+	b.pools = newPoolRegistry()
+	b.connect, _ = newTenantConnectPolicy(a.connect.action, a.connect.retryInterval)
+	b.tiers, b.globalQPS, b.fair, b.aimd, b.governor, b.breaker = nil, nil, nil, nil, nil, nil
+	c.opts = &b
+	return c.opts
+}
+
+// Run runs the workload against both clusters and waits for both sides to finish.
+// The statistics of cluster B are collected from the start of its side, in a collector with the window of A's.
+func (c *abComparison) Run(ctx context.Context, tenantNames []string, dsnsA *dsnResolver, threadsPerDB int, churnOn, churnOff time.Duration, a *workloadOptions) {
+	b := c.opts
+	runB := func() {
+		b.startTime = time.Now()
+		b.exitTime = b.startTime.Add(a.exitTime.Sub(a.startTime))
+		b.stats = newStatsCollector(a.stats.window)
+		if b.aimd != nil {
+			go b.aimd.Run(ctx, b.stats)
+		}
+		if b.governor != nil {
+			go b.governor.Run(ctx, b.stats)
+		}
+		log.Printf("[INFO] A/B: running cluster B")
+		runTenants(ctx, tenantNames, c.dsns, threadsPerDB, churnOn, churnOff, b)
+	}
+	if c.mode == abSequential {
+		log.Printf("[INFO] A/B: running cluster A")
+		runTenants(ctx, tenantNames, dsnsA, threadsPerDB, churnOn, churnOff, a)
+		a.stats.Stop()
+		runB()
+		return
+	}
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		runB()
+	}()
+	runTenants(ctx, tenantNames, dsnsA, threadsPerDB, churnOn, churnOff, a)
+	wg.Wait()
+}
+
+// Snapshot returns the statistics of cluster B.
+func (c *abComparison) Snapshot() *statsSnapshot {
+	return c.opts.stats.Snapshot()
+}
+
+// printABComparison writes the side-by-side report: per tenant and in total, the throughput, p95 and p99 of
+// both clusters with the change from A to B, and the errors of both.
+func printABComparison(w io.Writer, a, b *runSummary) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "TENANT\tQPS(A)\tQPS(B)\tΔQPS\tP95(A)\tP95(B)\tΔP95\tP99(A)\tP99(B)\tΔP99\tERRORS(A)\tERRORS(B)\t")
+	row := func(name string, x, y tenantSummary) {
+		fmt.Fprintf(tw, "%s\t%.1f\t%.1f\t%s\t%.2f\t%.2f\t%s\t%.2f\t%.2f\t%s\t%d\t%d\t\n", name,
+			x.QPS, y.QPS, percentChange(x.QPS, y.QPS),
+			x.Latency.P95Ms, y.Latency.P95Ms, percentChange(x.Latency.P95Ms, y.Latency.P95Ms),
+			x.Latency.P99Ms, y.Latency.P99Ms, percentChange(x.Latency.P99Ms, y.Latency.P99Ms),
+			x.Errors, y.Errors)
+	}
+	sideB := make(map[string]tenantSummary, len(b.Tenants))
+	for _, t := range b.Tenants {
+		sideB[t.Tenant] = t
+	}
+	for _, t := range a.Tenants {
+		row(t.Tenant, t, sideB[t.Tenant])
+		delete(sideB, t.Tenant)
+	}
+	// Tenants that only ran on cluster B, e.g. because they were unreachable on A.
+	for _, t := range b.Tenants {
+		if _, ok := sideB[t.Tenant]; ok {
+			row(t.Tenant, tenantSummary{}, t)
+		}
+	}
+	row("TOTAL", a.Total, b.Total)
+	tw.Flush()
+}
+
+// percentChange formats the change from a to b in percent, "-" when a is 0.
+func percentChange(a, b float64) string {
+	if a == 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%+.1f%%", (b-a)/a*100)
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"log"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// aimdIdleInterval is how often a worker above its tenant's limit checks whether it may run again.
+const aimdIdleInterval = 100 * time.Millisecond
+
+// aimdController adapts the number of active workers of every tenant to keep its p99 latency under a target:
+// every interval the limit grows by one worker (additive increase) while the tenant's p99 of that interval is
+// under the target, and is multiplied by the decrease factor when it is above (multiplicative decrease).
+// Workers above their tenant's limit idle. Tenants start with one active worker, so a run discovers the
+// sustainable multi-tenant load of the cluster on its own.
+// A nil *aimdController is disabled.
+type aimdController struct {
+	target   time.Duration
+	interval time.Duration
+	decrease float64
+	max      int
+
+	mu      sync.Mutex
+	tenants map[string]*aimdTenant
+}
+
+// aimdTenant is the controller state of one tenant.
+type aimdTenant struct {
+	limit    int32 // active workers, read by the workers without locking
+	peak     int
+	lastP99  time.Duration
+	previous *latencyHistogram // latencies seen up to the last adjustment
+}
+
+// newAIMDController returns nil when target is 0. max is the number of workers of each tenant.
+func newAIMDController(target, interval time.Duration, decrease float64, max int) *aimdController {
+	if target <= 0 {
+		return nil
+	}
+	return &aimdController{target: target, interval: interval, decrease: decrease, max: max, tenants: make(map[string]*aimdTenant)}
+}
+
+func (a *aimdController) tenant(name string) *aimdTenant {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	t, ok := a.tenants[name]
+	if !ok {
+		t = &aimdTenant{limit: 1, peak: 1, previous: newLatencyHistogram()}
+		a.tenants[name] = t
+	}
+	return t
+}
+
+// Tenant returns the controller state of a tenant, nil if the controller is disabled.
+// Workers look their tenant up once and call Admit on it.
+func (a *aimdController) Tenant(name string) *aimdTenant {
+	if a == nil {
+		return nil
+	}
+	return a.tenant(name)
+}
+
+// Admit reports whether the worker-th worker (0-based) of the tenant may run a query now.
+// A nil *aimdTenant admits every worker.
+func (t *aimdTenant) Admit(worker int) bool {
+	return t == nil || int32(worker) < atomic.LoadInt32(&t.limit)
+}
+
+// Run adjusts the limits every interval from the latencies recorded in stats until ctx is done.
+func (a *aimdController) Run(ctx context.Context, stats *statsCollector) {
+	ticker := time.NewTicker(a.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		a.adjust(stats.Snapshot())
+	}
+}
+
+// adjust applies one AIMD step to every tenant of the snapshot.
+func (a *aimdController) adjust(s *statsSnapshot) {
+	total, over := 0, 0
+	for _, name := range s.TenantNames() {
+		t := a.tenant(name)
+		current := s.Tenants[name].Latency
+		p99 := current.Since(t.previous).Quantile(0.99)
+		t.previous = current
+
+		limit := int(atomic.LoadInt32(&t.limit))
+		if p99 > a.target {
+			over++
+			limit = int(float64(limit) * a.decrease)
+		} else {
+			limit++
+		}
+		if limit < 1 {
+			limit = 1
+		}
+		if limit > a.max {
+			limit = a.max
+		}
+		if limit > t.peak {
+			t.peak = limit
+		}
+		t.lastP99 = p99
+		atomic.StoreInt32(&t.limit, int32(limit))
+		total += limit
+	}
+	log.Printf("[INFO] aimd: active workers=%d tenants over p99 target %v=%d", total, a.target, over)
+}
+
+// logAIMDSummary logs the concurrency every tenant settled at.
+func (a *aimdController) logAIMDSummary() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	names := make([]string, 0, len(a.tenants))
+	for name := range a.tenants {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	total := 0
+	for _, name := range names {
+		t := a.tenants[name]
+		limit := int(atomic.LoadInt32(&t.limit))
+		total += limit
+		log.Printf("[INFO] aimd: DB=%s sustainable workers=%d peak=%d last p99=%v", name, limit, t.peak, t.lastP99)
+	}
+	log.Printf("[INFO] aimd: sustainable workers=%d over %d tenant(s) at p99 target %v", total, len(names), a.target)
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// annotationRule adds a routing comment to the queries of some tenants and query types.
+type annotationRule struct {
+	tenants    tenantSet // nil = every tenant
+	queryType  string    // "" = every query type
+	inline     bool      // after the leading keyword instead of before the statement
+	annotation string
+}
+
+// queryAnnotations are the routing comments and directives added to the generated queries per tenant, so the
+// workload can be driven through proxy layers that route on comments: ProxySQL query rules matching a marker, or
+// Vitess directives and shard targeting comments. A nil *queryAnnotations adds nothing.
+type queryAnnotations struct {
+	rules []annotationRule
+}
+
+// loadAnnotationFile reads an annotation file with one "tenants query_type position annotation" entry per line, e.g.
+//
+//	1-5  *             prefix  /* route=shard_a tenant={tenant} */
+//	*    point_select  inline  /*vt+ SCATTER_ERRORS_AS_WARNINGS */
+//	*    *             prefix  /* {rw} */
+//
+// tenants are names or 1-based ranges, "*" for every tenant; query_type is a -query-mix type, "join" or "*".
+// position is prefix (before the statement, e.g. for ProxySQL) or inline (after the leading SELECT, INSERT, UPDATE or
+// DELETE and its optimizer hints, e.g. for Vitess). In the annotation "{tenant}", "{tenant_id}", "{table}", "{type}"
+// and "{rw}" (read or write) are replaced. A query gets the annotations of every matching line, in file order.
+// MySQL/TiDB tenants only. Empty lines and lines starting with "#" are ignored.
+func loadAnnotationFile(path string) (*queryAnnotations, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	a := &queryAnnotations{}
+	scanner := bufio.NewScanner(f)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) < 4 {
+			return nil, fmt.Errorf("%s:%d: want \"tenants query_type position annotation\", got %q", path, lineNo, line)
+		}
+		rule := annotationRule{annotation: strings.Join(fields[3:], " ")}
+		if fields[0] != "*" {
+			if rule.tenants, err = parseTenantSet(fields[0]); err != nil {
+				return nil, fmt.Errorf("%s:%d: %v", path, lineNo, err)
+			}
+		}
+		if fields[1] != "*" {
+			if _, ok := queryTypes[fields[1]]; !ok && fields[1] != "join" {
+				return nil, fmt.Errorf("%s:%d: unknown query type %q (known: join, %s)", path, lineNo, fields[1], strings.Join(queryTypeNames(), ", "))
+			}
+			rule.queryType = fields[1]
+		}
+		switch fields[2] {
+		case "prefix":
+		case "inline":
+			rule.inline = true
+		default:
+			return nil, fmt.Errorf("%s:%d: unknown position %q (want prefix or inline)", path, lineNo, fields[2])
+		}
+		a.rules = append(a.rules, rule)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// Apply adds the annotations of the matching rules to a generated query of the type, on table (the first table of
+// the join query). write tells reads from writes for "{rw}".
+func (a *queryAnnotations) Apply(tenant, queryType string, write bool, t TableInfo, query string) string {
+	if a == nil || t.Dialect == dialectClickHouse {
+		return query
+	}
+	rw := "read"
+	if write {
+		rw = "write"
+	}
+	var prefix []string
+	for _, rule := range a.rules {
+		if !rule.tenants.Contains(tenant) || (rule.queryType != "" && rule.queryType != queryType) {
+			continue
+		}
+		annotation := strings.NewReplacer("{tenant}", tenant, "{tenant_id}", strconv.Itoa(tenantID(tenant)),
+			"{table}", t.Name, "{type}", queryType, "{rw}", rw).Replace(rule.annotation)
+		if rule.inline {
+			query = addInlineAnnotation(query, annotation)
+		} else {
+			prefix = append(prefix, annotation)
+		}
+	}
+	if len(prefix) > 0 {
+		query = strings.Join(prefix, " ") + " " + query
+	}
+	return query
+}
+
+// addInlineAnnotation adds an annotation after the leading keyword of a statement and its optimizer hint comment,
+// which has to follow the keyword directly. Statements without a known keyword get it in front.
+func addInlineAnnotation(query, annotation string) string {
+	for _, keyword := range []string{"SELECT ", "INSERT ", "UPDATE ", "DELETE ", "select "} {
+		rest, ok := strings.CutPrefix(query, keyword)
+		if !ok {
+			continue
+		}
+		if strings.HasPrefix(rest, "/*+ ") {
+			if end := strings.Index(rest, "*/"); end >= 0 {
+				keyword, rest = keyword+rest[:end+2]+" ", strings.TrimPrefix(rest[end+2:], " ")
+			}
+		}
+		return keyword + annotation + " " + rest
+	}
+	return annotation + " " + query
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"bufio"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// auditRecord is one line of the failed-query audit file.
+type auditRecord struct {
+	Time      string        `json:"time"`
+	Tenant    string        `json:"tenant"`
+	QueryType string        `json:"query_type"`
+	SQL       string        `json:"sql"`
+	Args      []interface{} `json:"args"`
+	ErrorCode uint16        `json:"error_code"` // MySQL error number, 0 for client-side errors (e.g. a dropped connection)
+	Error     string        `json:"error"`
+	LatencyUs int64         `json:"latency_us"`
+}
+
+// auditLog writes every failed query as one JSON object per line to a file of its own, so failure patterns
+// of long runs can be analyzed without searching the mixed log. The file is rotated at maxBytes:
+// file.1 is the previous file, file.2 the one before, and so on up to maxFiles old files.
+// A nil *auditLog records nothing.
+type auditLog struct {
+	path     string
+	maxBytes int64 // 0 = never rotate
+	maxFiles int
+
+	mu      sync.Mutex
+	file    *os.File
+	buf     *bufio.Writer
+	written int64
+	done    chan struct{}
+	once    sync.Once
+}
+
+// newAuditLog creates (or truncates) the audit file and starts its periodic flusher. It returns nil when path is "".
+func newAuditLog(path string, maxBytes int64, maxFiles int) (*auditLog, error) {
+	if path == "" {
+		return nil, nil
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	a := &auditLog{path: path, maxBytes: maxBytes, maxFiles: maxFiles, file: f,
+		buf: bufio.NewWriterSize(f, 64*1024), done: make(chan struct{})}
+	go a.flushLoop()
+	return a, nil
+}
+
+// Record appends a failed query; successful queries and empty results are ignored.
+func (a *auditLog) Record(tenant, queryType string, start time.Time, query string, args []interface{}, latency time.Duration, err error) {
+	if a == nil || err == nil || err == sql.ErrNoRows {
+		return
+	}
+	rec := auditRecord{
+		Time:      start.UTC().Format(time.RFC3339Nano),
+		Tenant:    tenant,
+		QueryType: queryType,
+		SQL:       query,
+		Args:      args,
+		Error:     err.Error(),
+		LatencyUs: latency.Microseconds(),
+	}
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) {
+		rec.ErrorCode = mysqlErr.Number
+	}
+	line, jsonErr := json.Marshal(rec)
+	if jsonErr != nil {
+		rec.Args = nil
+		line, _ = json.Marshal(rec)
+	}
+	line = append(line, '\n')
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.maxBytes > 0 && a.written > 0 && a.written+int64(len(line)) > a.maxBytes {
+		if rotateErr := a.rotate(); rotateErr != nil {
+			log.Printf("[ERROR] audit file rotation failed: %v", rotateErr)
+		}
+	}
+	n, writeErr := a.buf.Write(line)
+	a.written += int64(n)
+	if writeErr != nil {
+		log.Printf("[ERROR] audit write failed: %v", writeErr)
+	}
+}
+
+// rotate shifts the old files by one, moves the current file to file.1 and starts a new one.
+// The caller holds a.mu.
+func (a *auditLog) rotate() error {
+	if err := a.buf.Flush(); err != nil {
+		return err
+	}
+	if err := a.file.Close(); err != nil {
+		return err
+	}
+	if a.maxFiles > 0 {
+		os.Remove(fmt.Sprintf("%s.%d", a.path, a.maxFiles))
+		for i := a.maxFiles - 1; i >= 1; i-- {
+			os.Rename(fmt.Sprintf("%s.%d", a.path, i), fmt.Sprintf("%s.%d", a.path, i+1))
+		}
+		if err := os.Rename(a.path, a.path+".1"); err != nil {
+			return err
+		}
+	}
+	f, err := os.Create(a.path)
+	if err != nil {
+		return err
+	}
+	a.file = f
+	a.buf.Reset(f)
+	a.written = 0
+	return nil
+}
+
+// flushLoop flushes buffered records every second so an interrupted run still leaves a usable file.
+func (a *auditLog) flushLoop() {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-a.done:
+			return
+		case <-ticker.C:
+			a.mu.Lock()
+			a.buf.Flush()
+			a.mu.Unlock()
+		}
+	}
+}
+
+// Close flushes the remaining records and closes the file. Further calls do nothing.
+func (a *auditLog) Close() error {
+	if a == nil {
+		return nil
+	}
+	var err error
+	a.once.Do(func() {
+		close(a.done)
+		a.mu.Lock()
+		defer a.mu.Unlock()
+		if err = a.buf.Flush(); err != nil {
+			a.file.Close()
+			return
+		}
+		err = a.file.Close()
+	})
+	return err
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"math/rand/v2"
+	"sync/atomic"
+	"time"
+)
+
+// defaultBankMaxAmount is the largest amount moved by bank_transfer when it is used in -query-mix rather than by a
+// bank tenant.
+const defaultBankMaxAmount = 100
+
+// bankTransfer makes selected tenants move "balance" (the k column) between two random rows of a table in one
+// transaction, and checks the invariant of the tenant every check interval: the total balance and the number of rows
+// of every table never change. Run during chaos or failover tests, the simulator doubles as a correctness checker:
+// a lost or half-applied transfer shows up as a violated invariant. A bank tenant only runs bank_transfer.
+// A nil *bankTransfer is disabled.
+type bankTransfer struct {
+	tenants   tenantSet
+	tables    int // the first tables of the tenant are the accounts
+	maxAmount int
+	interval  time.Duration
+	mix       *queryMix
+
+	checks     int64
+	violations int64
+	failed     int64 // checks that couldn't be run
+}
+
+// newBankTransfer returns nil when no tenant is selected.
+func newBankTransfer(tenants tenantSet, tables, maxAmount int, interval time.Duration) (*bankTransfer, error) {
+	if tenants == nil {
+		return nil, nil
+	}
+	if tables < 1 || maxAmount < 1 {
+		return nil, fmt.Errorf("tables and the maximum amount must be at least 1")
+	}
+	if interval <= 0 {
+		return nil, fmt.Errorf("the check interval must be positive")
+	}
+	mix, err := parseQueryMix("bank_transfer")
+	if err != nil {
+		return nil, err
+	}
+	return &bankTransfer{tenants: tenants, tables: tables, maxAmount: maxAmount, interval: interval, mix: mix}, nil
+}
+
+// Applies reports whether the tenant is a bank tenant.
+func (b *bankTransfer) Applies(tenant string) bool {
+	return b != nil && b.tenants.Contains(tenant)
+}
+
+// Tables returns the tables a bank tenant transfers between the rows of.
+func (b *bankTransfer) Tables(tables []TableInfo) []TableInfo {
+	if len(tables) > b.tables {
+		return tables[:b.tables]
+	}
+	return tables
+}
+
+// transferArgs appends the arguments of a transfer of a random amount between two distinct random rows of the table:
+// the amount, the tenant_id in the row tenancy model, and the ids of the debited and the credited row.
+func (b *bankTransfer) transferArgs(t TableInfo, rng *rand.Rand, args []interface{}) []interface{} {
+	maxAmount := defaultBankMaxAmount
+	if b != nil {
+		maxAmount = b.maxAmount
+	}
+	from, to := randomID(t, rng), randomID(t, rng)
+	for highestID(t) > 1 && to == from {
+		to = randomID(t, rng)
+	}
+	return append(t.appendWhereArgs(append(args, rng.IntN(maxAmount)+1)), from, to)
+}
+
+// runBankTransfer runs the script of the bank_transfer query type on the connection: in one transaction it debits the
+// amount from one row and credits it to the other with the statement, the row with the lower id first so concurrent
+// transfers lock their rows in the same order. The statement's arguments are the amount, the tenant_id in the row
+// tenancy model, and the ids of the debited and the credited row.
+func runBankTransfer(ctx context.Context, conn queryExecutor, t TableInfo, query string, args []interface{}) error {
+	amount, tenantArgs := args[0].(int), args[1:len(args)-2]
+	from, to := args[len(args)-2].(int), args[len(args)-1].(int)
+	if from == to {
+		return nil
+	}
+	first, second := []interface{}{-amount}, []interface{}{amount}
+	if to < from {
+		first, second = second, first
+		from, to = to, from
+	}
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.ExecContext(ctx, query, append(append(first, tenantArgs...), from)...); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, query, append(append(second, tenantArgs...), to)...); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// bankTotals are the row count and total balance of a table.
+type bankTotals struct {
+	rows    int64
+	balance int64
+}
+
+// Run checks the invariant of a bank tenant on a connection of its write pool every check interval, and a last time
+// at exitTime, until ctx is done. The totals of the first successful check of a table are its baseline.
+func (b *bankTransfer) Run(ctx context.Context, pool *tenantPool, dbName string, opts *workloadOptions) {
+	conn, err := retryMakeActiveConn(pool.writes(), dbName, ctx)
+	if err != nil {
+		return
+	}
+	defer func() { conn.Close() }()
+
+	tables := b.Tables(opts.tenantTables(dbName))
+	baseline := make(map[string]bankTotals)
+	for {
+		more := sleepUntilExit(ctx, b.interval, opts.exitTime)
+		if ctx.Err() != nil {
+			return
+		}
+		for _, t := range tables {
+			totals, err := b.check(ctx, conn, dbName, t, opts)
+			if err != nil {
+				atomic.AddInt64(&b.failed, 1)
+				if ctx.Err() != nil {
+					return
+				}
+				log.Printf("[WARNING] bank: DB=%s table=%s check failed: %v", dbName, t.Name, err)
+				if conn.PingContext(ctx) != nil {
+					conn.Close()
+					newConn, err := retryMakeActiveConn(pool.writes(), dbName, ctx)
+					if err != nil {
+						return
+					}
+					conn = newConn
+				}
+				continue
+			}
+			atomic.AddInt64(&b.checks, 1)
+			want, ok := baseline[t.Name]
+			if !ok {
+				baseline[t.Name] = totals
+				log.Printf("[INFO] bank: DB=%s table=%s baseline rows=%d balance=%d", dbName, t.Name, totals.rows, totals.balance)
+				continue
+			}
+			if totals != want {
+				atomic.AddInt64(&b.violations, 1)
+				log.Printf("[ERROR] bank: DB=%s table=%s invariant violated: rows=%d balance=%d, want rows=%d balance=%d",
+					dbName, t.Name, totals.rows, totals.balance, want.rows, want.balance)
+			}
+		}
+		if !more {
+			return
+		}
+	}
+}
+
+// check reads the totals of a table in one statement, a consistent snapshot of the table.
+func (b *bankTransfer) check(ctx context.Context, conn workerConn, dbName string, t TableInfo, opts *workloadOptions) (bankTotals, error) {
+	query := opts.tags.Tag("SELECT COUNT(*), SUM(k) FROM "+t.Name+" WHERE "+t.whereSQL("id > 0"), dbName, -1, "bank_check")
+	var totals bankTotals
+	var balance sql.NullInt64
+	err := conn.QueryRowContext(ctx, query, t.appendWhereArgs(nil)...).Scan(&totals.rows, &balance)
+	totals.balance = balance.Int64
+	return totals, err
+}
+
+// Violations returns the number of checks that found the invariant violated.
+func (b *bankTransfer) Violations() int64 {
+	if b == nil {
+		return 0
+	}
+	return atomic.LoadInt64(&b.violations)
+}
+
+// logBankSummary logs the checks of the invariant of the bank tenants and their outcome.
+func (b *bankTransfer) logBankSummary() {
+	level := "[INFO]"
+	if b.Violations() > 0 {
+		level = "[ERROR]"
+	}
+	log.Printf("%s Summary: bank checks=%d violations=%d failed=%d", level,
+		atomic.LoadInt64(&b.checks), b.Violations(), atomic.LoadInt64(&b.failed))
+}
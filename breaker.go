@@ -0,0 +1,211 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+)
+
+// breakerIdleInterval is how often a worker of a tenant with an open circuit checks whether it may send again.
+const breakerIdleInterval = 100 * time.Millisecond
+
+// Circuit states of a tenant.
+const (
+	circuitClosed   = "closed"
+	circuitOpen     = "open"
+	circuitHalfOpen = "half-open"
+)
+
+// circuitBreaker stops sending queries of a tenant once the error rate of its last queries reaches a threshold,
+// like the circuit breakers of client libraries, and keeps a broken tenant from flooding the log and skewing the
+// statistics. After the open time it lets single probe queries through (half-open); enough successful probes close
+// the circuit again, a failed one opens it for another open time. Timeouts count as errors.
+// A nil *circuitBreaker lets every query through.
+type circuitBreaker struct {
+	tenants   tenantSet
+	errorRate float64
+	window    int
+	open      time.Duration
+	probes    int
+
+	mu       sync.Mutex
+	circuits map[string]*tenantCircuit
+}
+
+// tenantCircuit is the circuit of one tenant, shared by its workers.
+type tenantCircuit struct {
+	b      *circuitBreaker
+	tenant string
+
+	mu       sync.Mutex
+	state    string
+	outcomes []bool // failures of the last queries, a ring of the window size
+	next     int
+	seen     int
+	failures int
+	openedAt time.Time
+	probing  bool // a probe query is in flight
+	probed   int  // successful probes since the circuit became half-open
+	trips    int
+	rejected uint64
+}
+
+// newCircuitBreaker returns nil when errorRate is 0.
+func newCircuitBreaker(tenants tenantSet, errorRate float64, window int, open time.Duration, probes int) (*circuitBreaker, error) {
+	if errorRate <= 0 {
+		return nil, nil
+	}
+	if errorRate > 1 {
+		return nil, fmt.Errorf("the error rate must be at most 1")
+	}
+	if window < 1 || probes < 1 {
+		return nil, fmt.Errorf("the window and the probes must be at least 1")
+	}
+	if open <= 0 {
+		return nil, fmt.Errorf("the open time must be positive")
+	}
+	return &circuitBreaker{tenants: tenants, errorRate: errorRate, window: window, open: open, probes: probes,
+		circuits: make(map[string]*tenantCircuit)}, nil
+}
+
+// Tenant returns the circuit of a tenant, nil if the breaker is disabled or doesn't apply to the tenant.
+// Workers look their tenant up once and call Allow and Record on it.
+func (b *circuitBreaker) Tenant(name string) *tenantCircuit {
+	if b == nil || !b.tenants.Contains(name) {
+		return nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	c, ok := b.circuits[name]
+	if !ok {
+		c = &tenantCircuit{b: b, tenant: name, state: circuitClosed, outcomes: make([]bool, b.window)}
+		b.circuits[name] = c
+	}
+	return c
+}
+
+// Allow reports whether a worker of the tenant may send a query now. Once the open time has passed it lets one probe
+// query through at a time; the worker must Record its outcome. A nil *tenantCircuit allows every query.
+func (c *tenantCircuit) Allow() bool {
+	if c == nil {
+		return true
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	switch c.state {
+	case circuitOpen:
+		if time.Since(c.openedAt) < c.b.open {
+			c.rejected++
+			return false
+		}
+		c.state, c.probed = circuitHalfOpen, 0
+		log.Printf("[INFO] circuit breaker: DB=%s half-open, probing", c.tenant)
+		fallthrough
+	case circuitHalfOpen:
+		if c.probing {
+			c.rejected++
+			return false
+		}
+		c.probing = true
+	}
+	return true
+}
+
+// Open reports whether Allow would reject a query now: the circuit is open and its open time hasn't passed, or a probe
+// is in flight. Unlike Allow it doesn't start a probe. A nil *tenantCircuit is never open.
+func (c *tenantCircuit) Open() bool {
+	if c == nil {
+		return false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	switch c.state {
+	case circuitOpen:
+		return time.Since(c.openedAt) < c.b.open
+	case circuitHalfOpen:
+		return c.probing
+	}
+	return false
+}
+
+// Record counts the outcome of a query that was allowed.
+func (c *tenantCircuit) Record(err error) {
+	if c == nil {
+		return
+	}
+	failed := err != nil && err != sql.ErrNoRows
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	switch c.state {
+	case circuitHalfOpen:
+		if !c.probing {
+			// A query allowed before the circuit opened.
+			return
+		}
+		c.probing = false
+		if failed {
+			c.trip("probe failed")
+			return
+		}
+		if c.probed++; c.probed >= c.b.probes {
+			c.state = circuitClosed
+			c.reset()
+			log.Printf("[INFO] circuit breaker: DB=%s closed after %d successful probe(s)", c.tenant, c.probed)
+		}
+	case circuitClosed:
+		if c.outcomes[c.next] {
+			c.failures--
+		}
+		c.outcomes[c.next] = failed
+		if failed {
+			c.failures++
+		}
+		c.next = (c.next + 1) % len(c.outcomes)
+		if c.seen < len(c.outcomes) {
+			c.seen++
+		}
+		if c.seen == len(c.outcomes) && float64(c.failures) >= c.b.errorRate*float64(c.seen) {
+			c.trip(fmt.Sprintf("%d of the last %d queries failed", c.failures, c.seen))
+		}
+	}
+}
+
+// trip opens the circuit for the open time.
+func (c *tenantCircuit) trip(reason string) {
+	c.state, c.openedAt, c.probing = circuitOpen, time.Now(), false
+	c.trips++
+	c.reset()
+	log.Printf("[WARNING] circuit breaker: DB=%s open for %v: %s", c.tenant, c.b.open, reason)
+}
+
+func (c *tenantCircuit) reset() {
+	for i := range c.outcomes {
+		c.outcomes[i] = false
+	}
+	c.next, c.seen, c.failures = 0, 0, 0
+}
+
+// logBreakerSummary logs the trips and rejected queries of every tenant whose circuit opened.
+func (b *circuitBreaker) logBreakerSummary() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	names := make([]string, 0, len(b.circuits))
+	for name := range b.circuits {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	tripped := 0
+	for _, name := range names {
+		c := b.circuits[name]
+		c.mu.Lock()
+		if c.trips > 0 {
+			tripped++
+			log.Printf("[INFO] Summary: circuit breaker DB=%s trips=%d rejected=%d state=%s", name, c.trips, c.rejected, c.state)
+		}
+		c.mu.Unlock()
+	}
+	log.Printf("[INFO] Summary: circuit breaker opened for %d of %d tenant(s)", tripped, len(names))
+}
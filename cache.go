@@ -0,0 +1,149 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"math"
+	"math/rand/v2"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// cacheRule gives some tenants a cache hit ratio.
+type cacheRule struct {
+	tenants tenantSet // nil = every tenant
+	ratio   float64
+	warmup  time.Duration
+}
+
+// clientCache simulates a cache in front of the database (e.g. Redis) on the application side: that fraction of
+// the reads a tenant's workers would run are served by the cache, counted as cache hits and not sent. With a warmup the
+// hit ratio grows linearly from 0 (a cold cache) to its value, to study how a cache warming up changes the load on
+// the database. Writes always go to the database. A nil *clientCache serves nothing.
+type clientCache struct {
+	rules []cacheRule
+}
+
+// newClientCache returns the cache of the tenants listed in the file at path, every other tenant getting ratio and
+// warmup, or nil when no tenant has a cache. The file has one "tenants ratio [warmup_seconds]" entry per line, e.g.
+//
+//	1-3   0.9  300
+//	4-6   0.5
+//	*     0
+//
+// tenants are names or 1-based ranges, "*" for every tenant; a tenant gets the ratio of the first matching line.
+// Empty lines and lines starting with "#" are ignored.
+func newClientCache(ratio float64, warmup time.Duration, path string) (*clientCache, error) {
+	c := &clientCache{}
+	if path != "" {
+		if err := c.loadFile(path); err != nil {
+			return nil, err
+		}
+	}
+	if ratio < 0 || ratio >= 1 {
+		return nil, fmt.Errorf("invalid cache hit ratio %g (want [0, 1))", ratio)
+	}
+	if warmup < 0 {
+		return nil, fmt.Errorf("the cache warmup must not be negative")
+	}
+	c.rules = append(c.rules, cacheRule{ratio: ratio, warmup: warmup})
+	for _, rule := range c.rules {
+		if rule.ratio > 0 {
+			return c, nil
+		}
+	}
+	return nil, nil
+}
+
+func (c *clientCache) loadFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 2 && len(fields) != 3 {
+			return fmt.Errorf("%s:%d: want \"tenants ratio [warmup_seconds]\", got %q", path, lineNo, line)
+		}
+		var rule cacheRule
+		if rule.ratio, err = strconv.ParseFloat(fields[1], 64); err != nil || rule.ratio < 0 || rule.ratio >= 1 {
+			return fmt.Errorf("%s:%d: invalid hit ratio %q (want [0, 1))", path, lineNo, fields[1])
+		}
+		if len(fields) == 3 {
+			seconds, err := strconv.Atoi(fields[2])
+			if err != nil || seconds < 0 {
+				return fmt.Errorf("%s:%d: invalid warmup %q", path, lineNo, fields[2])
+			}
+			rule.warmup = time.Duration(seconds) * time.Second
+		}
+		if fields[0] != "*" {
+			if rule.tenants, err = parseTenantSet(fields[0]); err != nil {
+				return fmt.Errorf("%s:%d: %v", path, lineNo, err)
+			}
+		}
+		c.rules = append(c.rules, rule)
+	}
+	return scanner.Err()
+}
+
+// tenantCache is the cache of one tenant. A nil *tenantCache serves nothing.
+type tenantCache struct {
+	ratio  float64
+	warmup time.Duration
+	start  time.Time // the cache is cold at start
+}
+
+// Tenant returns the cache of a tenant whose cache is cold at start, nil if it has none.
+func (c *clientCache) Tenant(name string, start time.Time) *tenantCache {
+	if c == nil {
+		return nil
+	}
+	for _, rule := range c.rules {
+		if rule.tenants.Contains(name) {
+			if rule.ratio == 0 {
+				return nil
+			}
+			return &tenantCache{ratio: rule.ratio, warmup: rule.warmup, start: start}
+		}
+	}
+	return nil
+}
+
+// Ratio returns the current hit ratio of the cache.
+func (t *tenantCache) Ratio() float64 {
+	if t == nil {
+		return 0
+	}
+	if warm := time.Since(t.start); t.warmup > 0 && warm < t.warmup {
+		return t.ratio * warm.Seconds() / t.warmup.Seconds()
+	}
+	return t.ratio
+}
+
+// Hit reports whether the next read is served by the cache, drawn from the worker's rng.
+func (t *tenantCache) Hit(rng *rand.Rand) bool {
+	return t != nil && rng.Float64() < t.Ratio()
+}
+
+// logCacheSummary logs the cache hits of every tenant next to the queries that reached the database.
+func logCacheSummary(s *statsSnapshot) {
+	for _, name := range s.TenantNames() {
+		t := s.Tenants[name]
+		if t.CacheHits == 0 {
+			continue
+		}
+		log.Printf("[INFO] cache: DB=%s hits=%d queries=%d hit ratio=%.1f%% database qps=%.1f",
+			name, t.CacheHits, t.Queries, 100*float64(t.CacheHits)/float64(t.CacheHits+t.Queries),
+			float64(t.Queries)/math.Max(s.ElapsedSeconds, 1e-9))
+	}
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand/v2"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// killQueryTimeout bounds a KILL QUERY statement of the query canceller.
+const killQueryTimeout = 5 * time.Second
+
+// mysqlErrQueryInterrupted is the error of a query stopped by KILL QUERY (ER_QUERY_INTERRUPTED).
+const mysqlErrQueryInterrupted = 1317
+
+// queryCanceller cancels a fraction of the in-flight queries of selected tenants a fixed time after they were sent,
+// through the context of the query or, with KILL QUERY, from a control connection of the tenant's pool, like
+// applications giving up on slow requests. Cancelled queries are left out of the statistics and counted on their own,
+// so the summary shows how the cancellations affect the tail latency of the other tenants.
+// A nil *queryCanceller cancels nothing.
+type queryCanceller struct {
+	tenants  tenantSet
+	fraction float64
+	after    time.Duration
+	kill     bool
+
+	cancelled  int64
+	killErrors int64
+
+	mu      sync.Mutex
+	latency *latencyHistogram
+}
+
+// armedCancel is the pending cancellation of one query.
+type armedCancel struct {
+	timer  *time.Timer
+	cancel context.CancelFunc
+	fired  int32         // 1 once the context was cancelled or KILL QUERY succeeded
+	done   chan struct{} // closed when the cancellation has been carried out
+}
+
+// newQueryCanceller returns nil when fraction is 0.
+func newQueryCanceller(tenants tenantSet, fraction float64, after time.Duration, kill bool) (*queryCanceller, error) {
+	if fraction <= 0 {
+		return nil, nil
+	}
+	if fraction > 1 {
+		return nil, fmt.Errorf("the fraction must be at most 1")
+	}
+	if after < 0 {
+		return nil, fmt.Errorf("the delay must not be negative")
+	}
+	return &queryCanceller{tenants: tenants, fraction: fraction, after: after, kill: kill, latency: newLatencyHistogram()}, nil
+}
+
+// Arm picks whether the next query of a worker of the tenant on conn is cancelled and, if so, returns the context
+// to run it with and the pending cancellation, which the worker must Disarm once the query returned. With KILL QUERY
+// it first looks the connection id up on conn; the KILL is sent through control.
+func (c *queryCanceller) Arm(ctx context.Context, tenant string, conn, control queryExecutor, rng *rand.Rand) (context.Context, *armedCancel) {
+	if c == nil || !c.tenants.Contains(tenant) || rng.Float64() >= c.fraction {
+		return ctx, nil
+	}
+	var id uint64
+	if c.kill {
+		if err := conn.QueryRowContext(ctx, "SELECT CONNECTION_ID()").Scan(&id); err != nil {
+			c.killFailed(ctx, "the connection id lookup", err)
+			return ctx, nil
+		}
+	}
+	queryCtx, cancel := context.WithCancel(ctx)
+	a := &armedCancel{cancel: cancel, done: make(chan struct{})}
+	a.timer = time.AfterFunc(c.after, func() {
+		defer close(a.done)
+		if !c.kill {
+			cancel()
+			atomic.StoreInt32(&a.fired, 1)
+			return
+		}
+		killCtx, stop := context.WithTimeout(ctx, killQueryTimeout)
+		defer stop()
+		if _, err := control.ExecContext(killCtx, "KILL QUERY "+strconv.FormatUint(id, 10)); err != nil {
+			c.killFailed(ctx, "KILL QUERY", err)
+			return
+		}
+		atomic.StoreInt32(&a.fired, 1)
+	})
+	return queryCtx, a
+}
+
+// killFailed counts a failure of KILL QUERY or of its connection id lookup. The first one is logged.
+func (c *queryCanceller) killFailed(ctx context.Context, what string, err error) {
+	if ctx.Err() == nil && atomic.AddInt64(&c.killErrors, 1) == 1 {
+		log.Printf("[WARNING] cancel: %s failed (logged once): %v", what, err)
+	}
+}
+
+// Disarm stops the pending cancellation, waiting for it when it is being carried out, and reports whether the query
+// was cancelled: the cancellation was carried out and the query returned err because of it, the context error or
+// "Query execution was interrupted" of KILL QUERY. A query that returned before the cancellation took effect was not
+// cancelled. A nil *armedCancel was never armed.
+func (a *armedCancel) Disarm(err error) bool {
+	if a == nil {
+		return false
+	}
+	if !a.timer.Stop() {
+		<-a.done
+	}
+	a.cancel()
+	if atomic.LoadInt32(&a.fired) == 0 || err == nil {
+		return false
+	}
+	var mysqlErr *mysql.MySQLError
+	return errors.Is(err, context.Canceled) || errors.As(err, &mysqlErr) && mysqlErr.Number == mysqlErrQueryInterrupted
+}
+
+// Record counts a cancelled query and its latency up to the cancellation.
+func (c *queryCanceller) Record(latency time.Duration) {
+	atomic.AddInt64(&c.cancelled, 1)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.latency.Record(latency)
+}
+
+// logCancelSummary logs the cancelled queries and how long they took to return.
+func (c *queryCanceller) logCancelSummary() {
+	how := "context"
+	if c.kill {
+		how = "KILL QUERY"
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	log.Printf("[INFO] Summary: cancel by %s after %v cancelled=%d kill errors=%d returned p50=%v p99=%v max=%v",
+		how, c.after, atomic.LoadInt64(&c.cancelled), atomic.LoadInt64(&c.killErrors),
+		c.latency.Quantile(0.50), c.latency.Quantile(0.99), c.latency.Quantile(1))
+}
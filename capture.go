@@ -0,0 +1,104 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// captureWriter records executed queries in the replay tsv format:
+//
+//	timestamp \t tenant \t sql \t args(JSON array) \t latency_us \t error
+//
+// The first four columns are what replay mode consumes; the rest is kept for comparisons.
+// A nil *captureWriter is valid and records nothing.
+type captureWriter struct {
+	mu   sync.Mutex
+	file *os.File
+	buf  *bufio.Writer
+	done chan struct{}
+	once sync.Once
+}
+
+// newCaptureWriter creates (or truncates) the capture file and starts its periodic flusher.
+func newCaptureWriter(path string) (*captureWriter, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	w := &captureWriter{file: f, buf: bufio.NewWriterSize(f, 256*1024), done: make(chan struct{})}
+	fmt.Fprintln(w.buf, "# timestamp\ttenant\tsql\targs\tlatency_us\terror")
+	go w.flushLoop()
+	return w, nil
+}
+
+// Record appends one executed query. err may be nil.
+func (w *captureWriter) Record(tenant string, start time.Time, query string, args []interface{}, latency time.Duration, err error) {
+	if w == nil {
+		return
+	}
+	argsJSON, jsonErr := json.Marshal(args)
+	if jsonErr != nil {
+		argsJSON = []byte("[]")
+	}
+	errText := ""
+	if err != nil {
+		errText = escapeTSV(err.Error())
+	}
+
+	var line strings.Builder
+	line.WriteString(start.UTC().Format(time.RFC3339Nano))
+	line.WriteByte('\t')
+	line.WriteString(tenant)
+	line.WriteByte('\t')
+	line.WriteString(escapeTSV(query))
+	line.WriteByte('\t')
+	line.Write(argsJSON)
+	fmt.Fprintf(&line, "\t%d\t%s\n", latency.Microseconds(), errText)
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if _, err := w.buf.WriteString(line.String()); err != nil {
+		log.Printf("[ERROR] capture write failed: %v", err)
+	}
+}
+
+// flushLoop flushes buffered records every second so an interrupted run still leaves a usable file.
+func (w *captureWriter) flushLoop() {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-w.done:
+			return
+		case <-ticker.C:
+			w.mu.Lock()
+			w.buf.Flush()
+			w.mu.Unlock()
+		}
+	}
+}
+
+// Close flushes the remaining records and closes the file. Further calls do nothing.
+func (w *captureWriter) Close() error {
+	if w == nil {
+		return nil
+	}
+	var err error
+	w.once.Do(func() {
+		close(w.done)
+		w.mu.Lock()
+		defer w.mu.Unlock()
+		if err = w.buf.Flush(); err != nil {
+			w.file.Close()
+			return
+		}
+		err = w.file.Close()
+	})
+	return err
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"io"
+	"log"
+	"math/rand/v2"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// killSwitch is a worker's handle in the connection killer.
+// A nil *killSwitch is never triggered.
+type killSwitch struct {
+	pending int32
+}
+
+// Take reports whether the worker's connection should be killed now, and resets the switch.
+func (s *killSwitch) Take() bool {
+	return s != nil && atomic.CompareAndSwapInt32(&s.pending, 1, 0)
+}
+
+// connKiller periodically kills a random fraction of the tool's own connections, simulating
+// proxy failovers, so the reconnect path (retryMakeActiveConn) is exercised systematically.
+// A nil *connKiller does nothing.
+type connKiller struct {
+	fraction float64
+
+	mu       sync.Mutex
+	switches map[*killSwitch]struct{}
+}
+
+// newConnKiller returns nil when the chaos mode is disabled.
+func newConnKiller(fraction float64) *connKiller {
+	if fraction <= 0 {
+		return nil
+	}
+	return &connKiller{fraction: fraction, switches: make(map[*killSwitch]struct{})}
+}
+
+// Register adds a worker to the set of connections that may be killed.
+func (k *connKiller) Register() *killSwitch {
+	if k == nil {
+		return nil
+	}
+	s := &killSwitch{}
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.switches[s] = struct{}{}
+	return s
+}
+
+// Unregister removes a worker that stopped.
+func (k *connKiller) Unregister(s *killSwitch) {
+	if k == nil {
+		return
+	}
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	delete(k.switches, s)
+}
+
+// Run triggers the kill switches of a random fraction of the registered workers every interval.
+func (k *connKiller) Run(ctx context.Context, interval time.Duration) {
+	if k == nil {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		k.mu.Lock()
+		killed := 0
+		for s := range k.switches {
+			if rand.Float64() < k.fraction {
+				atomic.StoreInt32(&s.pending, 1)
+				killed++
+			}
+		}
+		total := len(k.switches)
+		k.mu.Unlock()
+		log.Printf("[INFO] chaos: killing %d of %d connection(s)", killed, total)
+	}
+}
+
+// killConn closes the driver connection underneath conn without telling database/sql,
+// like a proxy dropping the session. The next query on conn fails with a bad connection error.
+func killConn(conn workerConn) {
+	err := conn.Raw(func(driverConn interface{}) error {
+		if c, ok := driverConn.(io.Closer); ok {
+			return c.Close()
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("[WARNING] chaos: failed to kill connection: %v", err)
+	}
+}
+
+// logChaosSummary logs the per-tenant impact of the killed connections.
+func logChaosSummary(s *statsSnapshot) {
+	for _, name := range s.TenantNames() {
+		t := s.Tenants[name]
+		log.Printf("[INFO] chaos: DB=%s kills=%d reconnects=%d reconnect avg=%v p99=%v max=%v errors=%d",
+			name, t.Kills, t.Reconnects, t.ReconnectTime.Mean(), t.ReconnectTime.Quantile(0.99),
+			time.Duration(t.ReconnectTime.MaxUs)*time.Microsecond, t.Errors)
+	}
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+// runChurningTenant alternates one tenant between active and idle phases until exitTime.
+// During an active phase the tenant opens its own *sql.DB and runs threadsPerDB workers.
+// At the end of the phase the workers are stopped and the DB handle is closed,
+// so every connection of the tenant is released on the server side while it is idle.
+func runChurningTenant(ctx context.Context, dsns *dsnResolver, dbName string, threadsPerDB int, onDuration, offDuration time.Duration, opts *workloadOptions) {
+	exitTime := opts.exitTime
+
+	// Start each tenant with a random idle delay so tenants don't churn in lockstep.
+	rng := newWorkerRand(dbName+"/churn", 0)
+	if !sleepUntilExit(ctx, time.Duration(rng.Int64N(int64(offDuration)+1)), exitTime) {
+		return
+	}
+
+	for cycle := 1; time.Now().Before(exitTime); cycle++ {
+		log.Printf("[INFO] churn: DB %s active for %v (cycle %d)", dbName, onDuration, cycle)
+		runActivePhase(ctx, dsns, dbName, threadsPerDB, onDuration, opts)
+
+		log.Printf("[INFO] churn: DB %s idle for %v (cycle %d)", dbName, offDuration, cycle)
+		if !sleepUntilExit(ctx, offDuration, exitTime) {
+			return
+		}
+	}
+}
+
+// runActivePhase opens the tenant DB, runs its workers, DDL churn, scan hog and retention job for the given duration and
+// closes the DB again.
+func runActivePhase(ctx context.Context, dsns *dsnResolver, dbName string, threadsPerDB int, duration time.Duration, opts *workloadOptions) {
+	pool, err := openTenantPool(dsns, dbName, opts.stats.Tenant(dbName))
+	if err != nil {
+		log.Printf("[ERROR] churn: failed to open DB %s: %v", dbName, err)
+		return
+	}
+	// Closing the handle closes all pooled connections of this tenant.
+	defer pool.Close()
+
+	if err := pool.Ping(); err != nil {
+		log.Printf("[ERROR] churn: failed to ping DB %s, skipping this cycle: %v", dbName, err)
+		return
+	}
+	opts.readiness.MarkPinged(dbName)
+	opts.pools.Add(dbName, pool)
+	defer opts.pools.Remove(dbName, pool)
+
+	ctx, cancel := context.WithTimeout(ctx, duration)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	for i := 0; i < threadsPerDB; i++ {
+		wg.Add(1)
+		go func(worker int) {
+			defer wg.Done()
+			runWorker(ctx, pool.reads(), pool.writes(), dbName, worker, opts)
+		}(i)
+	}
+	// A DDL churn tenant alters its own tables while it is active.
+	if opts.ddl.Applies(dbName) && !opts.clickhouse.Applies(dbName) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			opts.ddl.Run(ctx, pool, dbName, opts)
+		}()
+	}
+	// A scan hog tenant runs its full table scans while it is active.
+	if opts.scanHog.Applies(dbName) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			opts.scanHog.Run(ctx, pool, dbName, opts)
+		}()
+	}
+	// A range delete tenant runs its retention job while it is active, from the lowest id again in every phase.
+	if opts.retention.Applies(dbName) && !opts.clickhouse.Applies(dbName) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			opts.retention.Run(ctx, pool, dbName, opts)
+		}()
+	}
+	wg.Wait()
+}
+
+// sleepUntilExit sleeps for d, cut short at exitTime or when ctx is cancelled.
+// It reports whether there is still time left to run after sleeping.
+func sleepUntilExit(ctx context.Context, d time.Duration, exitTime time.Time) bool {
+	if remaining := time.Until(exitTime); d > remaining {
+		d = remaining
+	}
+	if d > 0 {
+		timer := time.NewTimer(d)
+		defer timer.Stop()
+		select {
+		case <-ctx.Done():
+			return false
+		case <-timer.C:
+		}
+	}
+	return ctx.Err() == nil && time.Now().Before(exitTime)
+}
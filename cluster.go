@@ -0,0 +1,271 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+)
+
+// clusterStartDelay is how long after the last follower registered the run starts,
+// leaving every node time to fetch its assignment.
+const clusterStartDelay = 3 * time.Second
+
+// clusterAssignment tells a node which tenants to run and when.
+// Times are relative so that clock skew between client machines doesn't matter.
+type clusterAssignment struct {
+	NodeID             string   `json:"node_id"`
+	Tenants            []string `json:"tenants"`
+	StartInMs          int64    `json:"start_in_ms"`
+	TestingTimeSeconds int      `json:"testing_time_seconds"`
+}
+
+// clusterLeader coordinates a run across several simulator processes.
+// Followers register over HTTP, receive a share of the tenants and a common start time,
+// and post their statistics back when their run is over.
+type clusterLeader struct {
+	mu          sync.Mutex
+	followers   int
+	nodes       []string // registered follower node ids, in registration order
+	ready       chan struct{}
+	startAt     time.Time
+	assignments map[string][]string
+	testingTime int
+	reports     map[string]*statsSnapshot
+	reported    chan struct{}
+}
+
+// startClusterLeader starts the coordination HTTP endpoints and waits for followers in the background.
+func startClusterLeader(listen string, followers int) (*clusterLeader, error) {
+	l := &clusterLeader{
+		followers:   followers,
+		ready:       make(chan struct{}),
+		assignments: make(map[string][]string),
+		reports:     make(map[string]*statsSnapshot),
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/cluster/register", l.handleRegister)
+	mux.HandleFunc("/cluster/assignment", l.handleAssignment)
+	mux.HandleFunc("/cluster/report", l.handleReport)
+	ln, err := net.Listen("tcp", listen)
+	if err != nil {
+		return nil, err
+	}
+	go http.Serve(ln, mux)
+	log.Printf("[INFO] cluster: leader listening on %s, waiting for %d follower(s)", listen, followers)
+	return l, nil
+}
+
+// Assign waits until all followers registered, splits the tenants among the leader and the followers,
+// and returns the leader's own share together with the common start time.
+func (l *clusterLeader) Assign(tenants []string, testingTimeSeconds int) ([]string, time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for len(l.nodes) < l.followers {
+		l.mu.Unlock()
+		time.Sleep(200 * time.Millisecond)
+		l.mu.Lock()
+	}
+
+	nodeCount := len(l.nodes) + 1
+	shares := splitTenants(tenants, nodeCount)
+	for i, node := range l.nodes {
+		l.assignments[node] = shares[i+1]
+		log.Printf("[INFO] cluster: %s runs %d tenant(s)", node, len(shares[i+1]))
+	}
+	l.testingTime = testingTimeSeconds
+	// One slot per follower, so report handlers never block.
+	l.reported = make(chan struct{}, len(l.nodes))
+	l.startAt = time.Now().Add(clusterStartDelay)
+	close(l.ready)
+	log.Printf("[INFO] cluster: leader runs %d tenant(s), run starts in %v", len(shares[0]), clusterStartDelay)
+	return shares[0], l.startAt
+}
+
+// CollectReports waits up to timeout for every follower's statistics and merges them into local.
+func (l *clusterLeader) CollectReports(local *statsSnapshot, timeout time.Duration) *statsSnapshot {
+	deadline := time.After(timeout)
+	l.mu.Lock()
+	expected := len(l.nodes)
+	l.mu.Unlock()
+	for received := 0; received < expected; received++ {
+		select {
+		case <-l.reported:
+		case <-deadline:
+			log.Printf("[WARNING] cluster: only %d of %d follower report(s) received", received, expected)
+			received = expected
+		}
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for node, report := range l.reports {
+		log.Printf("[INFO] cluster: merging report of %s (%d tenant(s))", node, len(report.Tenants))
+		local.Merge(report)
+	}
+	return local
+}
+
+func (l *clusterLeader) handleRegister(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "POST only", http.StatusMethodNotAllowed)
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	select {
+	case <-l.ready:
+		http.Error(w, "run already assigned", http.StatusConflict)
+		return
+	default:
+	}
+	nodeID := fmt.Sprintf("node-%d", len(l.nodes)+1)
+	l.nodes = append(l.nodes, nodeID)
+	log.Printf("[INFO] cluster: %s registered from %s (%s)", nodeID, r.RemoteAddr, r.URL.Query().Get("name"))
+	writeJSON(w, map[string]string{"node_id": nodeID})
+}
+
+// handleAssignment long-polls until the run is assigned.
+func (l *clusterLeader) handleAssignment(w http.ResponseWriter, r *http.Request) {
+	select {
+	case <-l.ready:
+	case <-time.After(30 * time.Second):
+		http.Error(w, "not ready", http.StatusServiceUnavailable)
+		return
+	case <-r.Context().Done():
+		return
+	}
+	nodeID := r.URL.Query().Get("node_id")
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	tenants, ok := l.assignments[nodeID]
+	if !ok {
+		http.Error(w, "unknown node", http.StatusNotFound)
+		return
+	}
+	writeJSON(w, clusterAssignment{
+		NodeID:             nodeID,
+		Tenants:            tenants,
+		StartInMs:          time.Until(l.startAt).Milliseconds(),
+		TestingTimeSeconds: l.testingTime,
+	})
+}
+
+func (l *clusterLeader) handleReport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "POST only", http.StatusMethodNotAllowed)
+		return
+	}
+	var report statsSnapshot
+	if err := json.NewDecoder(r.Body).Decode(&report); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	nodeID := r.URL.Query().Get("node_id")
+	l.mu.Lock()
+	// Only the followers the run was assigned to report, so there is a slot in reported for every first report.
+	if _, ok := l.assignments[nodeID]; !ok {
+		l.mu.Unlock()
+		http.Error(w, "unknown node", http.StatusBadRequest)
+		return
+	}
+	_, dup := l.reports[nodeID]
+	l.reports[nodeID] = &report
+	reported := l.reported
+	l.mu.Unlock()
+	w.WriteHeader(http.StatusNoContent)
+	if !dup {
+		select {
+		case reported <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// splitTenants splits tenants into n contiguous, nearly equal shares.
+func splitTenants(tenants []string, n int) [][]string {
+	shares := make([][]string, n)
+	for i := 0; i < n; i++ {
+		shares[i] = tenants[i*len(tenants)/n : (i+1)*len(tenants)/n]
+	}
+	return shares
+}
+
+// joinCluster registers this process with the leader and waits for its assignment.
+func joinCluster(leaderURL string) (*clusterAssignment, error) {
+	name, _ := os.Hostname()
+	var registered struct {
+		NodeID string `json:"node_id"`
+	}
+	for {
+		resp, err := http.Post(leaderURL+"/cluster/register?name="+url.QueryEscape(name), "application/json", nil)
+		if err == nil {
+			err = decodeJSONResponse(resp, &registered)
+		}
+		if err == nil {
+			break
+		}
+		log.Printf("[WARNING] cluster: register with leader %s failed, retrying: %v", leaderURL, err)
+		time.Sleep(2 * time.Second)
+	}
+	log.Printf("[INFO] cluster: registered as %s, waiting for assignment", registered.NodeID)
+
+	for {
+		resp, err := http.Get(leaderURL + "/cluster/assignment?node_id=" + url.QueryEscape(registered.NodeID))
+		if err != nil {
+			log.Printf("[WARNING] cluster: waiting for assignment: %v", err)
+			time.Sleep(2 * time.Second)
+			continue
+		}
+		if resp.StatusCode == http.StatusServiceUnavailable {
+			resp.Body.Close()
+			continue
+		}
+		var a clusterAssignment
+		if err := decodeJSONResponse(resp, &a); err != nil {
+			return nil, err
+		}
+		return &a, nil
+	}
+}
+
+// sendClusterReport posts this node's final statistics to the leader.
+func sendClusterReport(leaderURL, nodeID string, s *statsSnapshot) error {
+	body, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	resp, err := http.Post(leaderURL+"/cluster/report?node_id="+url.QueryEscape(nodeID), "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	return decodeJSONResponse(resp, nil)
+}
+
+// decodeJSONResponse checks the status of resp and decodes its body into v (if not nil).
+func decodeJSONResponse(resp *http.Response, v interface{}) error {
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		var msg bytes.Buffer
+		msg.ReadFrom(resp.Body)
+		return fmt.Errorf("%s: %s", resp.Status, bytes.TrimSpace(msg.Bytes()))
+	}
+	if v == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// writeJSON writes v as a JSON response.
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("[ERROR] write JSON response: %v", err)
+	}
+}
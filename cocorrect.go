@@ -0,0 +1,38 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// coCorrection corrects the latencies of the closed-loop workers for coordinated omission. A worker paced by
+// -sleep-after-query-ms intends to start a query every interval; while it waits on a slow query it starts none, so
+// a stall is recorded as one slow query although every query the schedule meant to start during the stall would have
+// waited for it too, and the percentiles hide the stall. Like HdrHistogram's recordValueWithExpectedInterval, the
+// corrected latencies add, for every intended start passed during a query, the time from that intended start to the
+// end of the query. A nil *coCorrection records no corrected latencies.
+type coCorrection struct {
+	interval time.Duration // intended time between the starts of two queries of a worker
+}
+
+// newCOCorrection returns nil when the correction is disabled. interval 0 takes the pacing sleep.
+func newCOCorrection(enabled bool, interval, pacing time.Duration) (*coCorrection, error) {
+	if !enabled {
+		return nil, nil
+	}
+	if interval <= 0 {
+		interval = pacing
+	}
+	if interval <= 0 {
+		return nil, fmt.Errorf("the correction needs a positive interval when queries are not paced by a sleep")
+	}
+	return &coCorrection{interval: interval}, nil
+}
+
+// Record records the corrected latencies of a query that took latency, failed queries not included.
+func (c *coCorrection) Record(stats *tenantStats, latency time.Duration, err error) {
+	if c == nil {
+		return
+	}
+	stats.RecordCorrected(latency, c.interval, err)
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+)
+
+// collationRule gives some tenants a collation.
+type collationRule struct {
+	tenants   tenantSet // nil = every tenant
+	collation string
+}
+
+// tenantCollations gives tenants their own character set and collation, e.g. utf8mb4_general_ci for some tenants,
+// utf8mb4_unicode_ci for others and utf8mb4_bin or binary for the rest, to compare the cost of the collations under
+// the same workload. Prepare mode creates the tenant databases and tables with the tenant's collation, and the
+// tenant's connections use it. A nil *tenantCollations keeps the server's and the driver's defaults.
+type tenantCollations struct {
+	rules []collationRule
+}
+
+// loadCollationFile reads a collation file with one "tenants collation" entry per line, e.g.
+//
+//	1-3   utf8mb4_general_ci
+//	4-6   utf8mb4_unicode_ci
+//	*     utf8mb4_bin
+//
+// tenants are names or 1-based ranges, "*" for every tenant; a tenant gets the collation of the first matching line.
+// The character set is the collation's prefix (utf8mb4 for utf8mb4_bin), except for binary, which is both.
+// Empty lines and lines starting with "#" are ignored.
+func loadCollationFile(path string) (*tenantCollations, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	c := &tenantCollations{}
+	scanner := bufio.NewScanner(f)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("%s:%d: want \"tenants collation\", got %q", path, lineNo, line)
+		}
+		rule := collationRule{collation: strings.ToLower(fields[1])}
+		if !validCollationName(rule.collation) {
+			return nil, fmt.Errorf("%s:%d: invalid collation %q", path, lineNo, fields[1])
+		}
+		if fields[0] != "*" {
+			if rule.tenants, err = parseTenantSet(fields[0]); err != nil {
+				return nil, fmt.Errorf("%s:%d: %v", path, lineNo, err)
+			}
+		}
+		c.rules = append(c.rules, rule)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// validCollationName reports whether name looks like a collation name, which is put into DDL statements as it is.
+func validCollationName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, r := range name {
+		if (r < 'a' || r > 'z') && (r < '0' || r > '9') && r != '_' {
+			return false
+		}
+	}
+	return true
+}
+
+// Collation returns the collation of the tenant, "" when it keeps the defaults.
+func (c *tenantCollations) Collation(tenant string) string {
+	if c == nil {
+		return ""
+	}
+	for _, rule := range c.rules {
+		if rule.tenants.Contains(tenant) {
+			return rule.collation
+		}
+	}
+	return ""
+}
+
+// Charset returns the character set of the tenant's collation, "" when it keeps the defaults.
+func (c *tenantCollations) Charset(tenant string) string {
+	collation := c.Collation(tenant)
+	charset, _, _ := strings.Cut(collation, "_")
+	return charset
+}
+
+// SessionParam returns the DSN parameter making the driver use the tenant's collation on its connections, "" for none.
+func (c *tenantCollations) SessionParam(tenant string) string {
+	if collation := c.Collation(tenant); collation != "" {
+		return "collation=" + collation
+	}
+	return ""
+}
+
+// DatabaseOptions returns the options of the CREATE DATABASE statement of the tenant, "" for none.
+func (c *tenantCollations) DatabaseOptions(tenant string) string {
+	if collation := c.Collation(tenant); collation != "" {
+		return fmt.Sprintf(" DEFAULT CHARACTER SET %s COLLATE %s", c.Charset(tenant), collation)
+	}
+	return ""
+}
+
+// TableOptions returns the table options of the tenant's CREATE TABLE statements, "" for none. The shared tables of
+// the row tenancy model and ClickHouse tables keep their defaults.
+func (c *tenantCollations) TableOptions(tenant string, t TableInfo) string {
+	if t.TenantID != 0 || t.Dialect == dialectClickHouse {
+		return ""
+	}
+	if collation := c.Collation(tenant); collation != "" {
+		return fmt.Sprintf(" DEFAULT CHARSET=%s COLLATE=%s", c.Charset(tenant), collation)
+	}
+	return ""
+}
+
+// logCollationSummary logs the queries, QPS and latency of the tenants of every collation.
+func (c *tenantCollations) logCollationSummary(snapshot *statsSnapshot) {
+	type collationTotal struct {
+		tenants int
+		all     *queryTypeStats
+	}
+	totals := make(map[string]*collationTotal)
+	for _, name := range snapshot.TenantNames() {
+		collation := c.Collation(name)
+		if collation == "" {
+			collation = "(default)"
+		}
+		total, ok := totals[collation]
+		if !ok {
+			total = &collationTotal{all: newQueryTypeStats()}
+			totals[collation] = total
+		}
+		total.tenants++
+		for _, q := range snapshot.Tenants[name].Types {
+			total.all.merge(q)
+		}
+	}
+	names := make([]string, 0, len(totals))
+	for name := range totals {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		t := totals[name]
+		qps := 0.0
+		if snapshot.ElapsedSeconds > 0 {
+			qps = float64(t.all.Queries) / snapshot.ElapsedSeconds
+		}
+		log.Printf("[INFO] collation=%s tenants=%d queries=%d errors=%d qps=%.1f avg=%v p50=%v p99=%v", name, t.tenants,
+			t.all.Queries, t.all.Errors, qps, t.all.Latency.Mean(), t.all.Latency.Quantile(0.50), t.all.Latency.Quantile(0.99))
+	}
+}
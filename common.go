@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"math"
+	"math/rand/v2"
+	"strings"
+	"time"
+)
+
+// Row counts of the lookup tables of the common database.
+const (
+	commonLookupRows = 1000
+	commonConfigRows = 100
+)
+
+// commonDB is the database shared by all tenants holding reference tables, like the global lookup tables of a SaaS
+// schema, set by -common-db. Tenants read it across databases, qualifying its tables with the database name.
+// It is empty when there is no common database.
+var commonDB string
+
+// commonTableSpec describes one lookup table of the common database.
+type commonTableSpec struct {
+	name    string
+	create  string // column definitions
+	columns string
+	rows    int
+	row     func(i int, rng *rand.Rand) []interface{} // values of the i-th row, 1-based
+}
+
+// commonTables are the lookup tables of the common database: a reference table joined by the tenants' rows
+// and a global configuration table read by key and as a whole.
+var commonTables = []commonTableSpec{
+	{
+		name:    "ref_lookup",
+		create:  "  id     INT NOT NULL,\n  name   VARCHAR(64) NOT NULL,\n  weight INT NOT NULL,\n  PRIMARY KEY (`id`)\n",
+		columns: "id, name, weight",
+		rows:    commonLookupRows,
+		row: func(i int, rng *rand.Rand) []interface{} {
+			return []interface{}{i, fmt.Sprintf("ref-%04d-%s", i, sysbenchString(rng, 11)), rng.IntN(100)}
+		},
+	},
+	{
+		name:    "global_config",
+		create:  "  name  VARCHAR(64) NOT NULL,\n  value VARCHAR(255) NOT NULL,\n  PRIMARY KEY (`name`)\n",
+		columns: "name, value",
+		rows:    commonConfigRows,
+		row: func(i int, rng *rand.Rand) []interface{} {
+			return []interface{}{commonConfigName(i), sysbenchString(rng, 60)}
+		},
+	},
+}
+
+// commonTable returns the qualified name of a table of the common database.
+func commonTable(table string) string {
+	return "`" + commonDB + "`." + table
+}
+
+// commonConfigName returns the name of the i-th (1-based) entry of the configuration table.
+func commonConfigName(i int) string {
+	return fmt.Sprintf("setting.%04d", i)
+}
+
+// prepareCommonDB creates the common database and its lookup tables on every server of the tenants, and loads the rows
+// of tables that are still empty. ClickHouse tenants don't read it.
+func prepareCommonDB(ctx context.Context, dsns *dsnResolver, tenantNames []string) error {
+	prepared := make(map[string]bool)
+	for _, tenant := range tenantNames {
+		if dsns.Driver(tenant) == "clickhouse" {
+			continue
+		}
+		dsn, err := serverDSN(dsns.DSN(tenant))
+		if err != nil {
+			return err
+		}
+		if prepared[dsn] {
+			continue
+		}
+		prepared[dsn] = true
+		if err := prepareCommonServer(ctx, dsns.Driver(tenant), dsn); err != nil {
+			return fmt.Errorf("common database %s: %v", commonDB, err)
+		}
+	}
+	return nil
+}
+
+// prepareCommonServer creates and loads the common database on one server.
+func prepareCommonServer(ctx context.Context, driverName, dsn string) error {
+	db, err := sql.Open(driverName, dsn)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	if _, err := db.ExecContext(ctx, fmt.Sprintf("CREATE DATABASE IF NOT EXISTS `%s`", commonDB)); err != nil {
+		return err
+	}
+	rng := newWorkerRand("prepare-common", 0)
+	for _, spec := range commonTables {
+		table := commonTable(spec.name)
+		if _, err := db.ExecContext(ctx, "CREATE TABLE IF NOT EXISTS "+table+" (\n"+spec.create+")"); err != nil {
+			return err
+		}
+		var existing int
+		if err := db.QueryRowContext(ctx, "SELECT COUNT(*) FROM "+table).Scan(&existing); err != nil {
+			return err
+		}
+		if existing > 0 {
+			log.Printf("[INFO] prepare: %s already has %d row(s), skipping load", table, existing)
+			continue
+		}
+		var args []interface{}
+		for i := 1; i <= spec.rows; i++ {
+			args = append(args, spec.row(i, rng)...)
+		}
+		row := "(" + strings.TrimSuffix(strings.Repeat("?,", len(args)/spec.rows), ",") + "),"
+		query := "INSERT INTO " + table + " (" + spec.columns + ") VALUES " + strings.TrimSuffix(strings.Repeat(row, spec.rows), ",")
+		if _, err := db.ExecContext(ctx, query, args...); err != nil {
+			return err
+		}
+		log.Printf("[INFO] prepare: %s loaded %d row(s)", table, spec.rows)
+	}
+	return nil
+}
+
+// logCommonSummary logs the load all tenants put on the common database: the queries of the common_* query types
+// of every tenant together, and how many tenants read it.
+func logCommonSummary(s *statsSnapshot) {
+	all := newQueryTypeStats()
+	readers := 0
+	for _, name := range s.TenantNames() {
+		read := false
+		for typeName, q := range s.Tenants[name].Types {
+			if qt, ok := queryTypes[typeName]; ok && qt.common {
+				all.merge(q)
+				read = true
+			}
+		}
+		if read {
+			readers++
+		}
+	}
+	if all.Queries == 0 {
+		return
+	}
+	log.Printf("[INFO] Summary: common database %s read by %d tenant(s) queries=%d errors=%d qps=%.1f avg=%v p50=%v p95=%v p99=%v max=%v",
+		commonDB, readers, all.Queries, all.Errors, float64(all.Queries)/math.Max(s.ElapsedSeconds, 1e-9),
+		all.Latency.Mean(), all.Latency.Quantile(0.50), all.Latency.Quantile(0.95), all.Latency.Quantile(0.99),
+		time.Duration(all.Latency.MaxUs)*time.Microsecond)
+}
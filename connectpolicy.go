@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// What to do when a tenant DB can't be opened or pinged at the start of the run.
+const (
+	// connectAbort stops the whole run, as if every tenant were required.
+	connectAbort = "abort"
+	// connectSkip runs without the tenant.
+	connectSkip = "skip"
+	// connectRetry keeps pinging the tenant in the background and starts its workers once it is reachable.
+	connectRetry = "retry"
+)
+
+// tenantConnectPolicy decides what happens to tenants whose DB is unreachable when the run starts,
+// so partial environments can still be exercised while the failures are clearly reported.
+type tenantConnectPolicy struct {
+	action        string
+	retryInterval time.Duration
+
+	mu      sync.Mutex
+	failed  map[string]error // tenants that never connected, with their last error
+	retried map[string]int   // tenants that connected late, with their failed attempts
+}
+
+func newTenantConnectPolicy(action string, retryInterval time.Duration) (*tenantConnectPolicy, error) {
+	switch action {
+	case connectAbort, connectSkip:
+	case connectRetry:
+		if retryInterval <= 0 {
+			return nil, fmt.Errorf("the %s policy needs a positive retry interval", connectRetry)
+		}
+	default:
+		return nil, fmt.Errorf("unknown tenant connect failure policy %q (want %s, %s or %s)", action, connectAbort, connectSkip, connectRetry)
+	}
+	return &tenantConnectPolicy{action: action, retryInterval: retryInterval,
+		failed: make(map[string]error), retried: make(map[string]int)}, nil
+}
+
+// Connect opens and pings the pools of a tenant. It returns nil when the tenant doesn't take part in the run (yet):
+// with the skip policy the tenant is left out, with the retry policy start is called with the pools
+// from a background goroutine counted in wg as soon as the tenant becomes reachable.
+// With the abort policy a failure ends the process.
+func (p *tenantConnectPolicy) Connect(ctx context.Context, wg *sync.WaitGroup, dsns *dsnResolver, tenant string, stats *tenantStats, exitTime time.Time, start func(*tenantPool)) *tenantPool {
+	pool, err := pingTenantPool(dsns, tenant, stats)
+	if err == nil {
+		return pool
+	}
+	switch p.action {
+	case connectSkip:
+		log.Printf("[ERROR] DB %s unreachable, skipping the tenant: %v", tenant, err)
+		p.fail(tenant, err)
+	case connectRetry:
+		log.Printf("[ERROR] DB %s unreachable, retrying every %v: %v", tenant, p.retryInterval, err)
+		p.fail(tenant, err)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for attempt := 1; sleepUntilExit(ctx, p.retryInterval, exitTime); attempt++ {
+				pool, err := pingTenantPool(dsns, tenant, stats)
+				if err != nil {
+					p.fail(tenant, err)
+					continue
+				}
+				log.Printf("[INFO] DB %s connected after %d failed attempt(s)", tenant, attempt)
+				p.recover(tenant, attempt)
+				start(pool)
+				return
+			}
+		}()
+	default:
+		log.Fatalf("[ERROR] Failed to connect to DB %s: %v", tenant, err)
+	}
+	return nil
+}
+
+// pingTenantPool opens the pools of a tenant and checks that they reach their server.
+func pingTenantPool(dsns *dsnResolver, tenant string, stats *tenantStats) (*tenantPool, error) {
+	pool, err := openTenantPool(dsns, tenant, stats)
+	if err != nil {
+		return nil, err
+	}
+	if err := pool.Ping(); err != nil {
+		pool.Close()
+		return nil, err
+	}
+	return pool, nil
+}
+
+func (p *tenantConnectPolicy) fail(tenant string, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.failed[tenant] = err
+}
+
+func (p *tenantConnectPolicy) recover(tenant string, attempts int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.failed, tenant)
+	p.retried[tenant] = attempts
+}
+
+// logConnectSummary reports the tenants that never connected and those that connected late.
+func (p *tenantConnectPolicy) logConnectSummary() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(p.failed) == 0 && len(p.retried) == 0 {
+		return
+	}
+	failed := make([]string, 0, len(p.failed))
+	for tenant := range p.failed {
+		failed = append(failed, tenant)
+	}
+	sort.Strings(failed)
+	for _, tenant := range failed {
+		log.Printf("[ERROR] Connect summary: DB %s never connected: %v", tenant, p.failed[tenant])
+	}
+	late := make([]string, 0, len(p.retried))
+	for tenant, attempts := range p.retried {
+		late = append(late, fmt.Sprintf("%s(%d)", tenant, attempts))
+	}
+	sort.Strings(late)
+	if len(late) > 0 {
+		log.Printf("[WARNING] Connect summary: DB(s) connected late, failed attempts in parentheses: %s", strings.Join(late, " "))
+	}
+	log.Printf("[WARNING] Connect summary: %d tenant(s) did not run, %d started late (-on-tenant-connect-failure=%s)",
+		len(failed), len(late), p.action)
+}
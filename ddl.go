@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+const (
+	// ddlChurnIndex and ddlChurnColumn are the objects the DDL churn tenant adds and drops.
+	ddlChurnIndex  = "idx_ddl_churn"
+	ddlChurnColumn = "ddl_churn_col"
+
+	// MySQL error codes meaning the object to add already exists.
+	errDupKeyName   = 1061
+	errDupFieldName = 1060
+)
+
+// ddlChurn makes selected tenants periodically run online DDL on their own tables
+// while all tenants keep querying, so the impact of DDL on neighboring tenants can be measured.
+// A nil *ddlChurn is disabled.
+type ddlChurn struct {
+	tenants  tenantSet
+	interval time.Duration
+	ops      []string // "index" and/or "column"
+}
+
+// newDDLChurn returns nil when interval is 0.
+func newDDLChurn(tenants tenantSet, interval time.Duration, ops string) (*ddlChurn, error) {
+	if interval <= 0 {
+		return nil, nil
+	}
+	d := &ddlChurn{tenants: tenants, interval: interval}
+	for _, op := range strings.Split(ops, ",") {
+		switch op = strings.TrimSpace(op); op {
+		case "index", "column":
+			d.ops = append(d.ops, op)
+		case "":
+		default:
+			return nil, fmt.Errorf("unknown DDL operation %q (want index or column)", op)
+		}
+	}
+	if len(d.ops) == 0 {
+		return nil, fmt.Errorf("no DDL operation given")
+	}
+	return d, nil
+}
+
+// Applies reports whether the tenant runs DDL churn.
+func (d *ddlChurn) Applies(tenant string) bool {
+	return d != nil && d.tenants.Contains(tenant)
+}
+
+// Run executes one DDL every interval on a random table of the tenant until ctx is done or exitTime is reached.
+// Each table toggles between having and not having the churn index / column: the DDL adds the object,
+// or drops it if it is already there.
+func (d *ddlChurn) Run(ctx context.Context, pool *tenantPool, dbName string, opts *workloadOptions) {
+	stats := opts.stats.Tenant(dbName)
+	conn, err := retryMakeActiveConn(pool.writes(), dbName, ctx)
+	if err != nil {
+		return
+	}
+	defer func() { conn.Close() }()
+
+	// In the row tenancy model the tables, and so the DDL, are shared by every tenant.
+	tables := opts.tenantTables(dbName)
+	rng := newWorkerRand(dbName+"/ddl", 0)
+	for sleepUntilExit(ctx, d.interval, opts.exitTime) {
+		table := tables[rng.IntN(len(tables))].Name
+		op := d.ops[rng.IntN(len(d.ops))]
+
+		start := time.Now()
+		stmt, err := d.toggle(ctx, conn, table, op)
+		took := time.Since(start)
+		stats.RecordDDL(took, err)
+		if err != nil {
+			log.Printf("[ERROR] ddl: DB=%s %s failed after %v: %v", dbName, stmt, took, err)
+			if conn.PingContext(ctx) != nil {
+				conn.Close()
+				newConn, err := retryMakeActiveConn(pool.writes(), dbName, ctx)
+				if err != nil {
+					return
+				}
+				conn = newConn
+			}
+			continue
+		}
+		log.Printf("[INFO] ddl: DB=%s %s took %v", dbName, stmt, took)
+	}
+}
+
+// toggle adds the churn object of the given kind to table, or drops it if it already exists.
+// It returns the statement that was executed last.
+func (d *ddlChurn) toggle(ctx context.Context, conn workerConn, table, op string) (string, error) {
+	var add, drop string
+	var dupCode uint16
+	switch op {
+	case "index":
+		add = fmt.Sprintf("ALTER TABLE %s ADD INDEX %s (c)", table, ddlChurnIndex)
+		drop = fmt.Sprintf("ALTER TABLE %s DROP INDEX %s", table, ddlChurnIndex)
+		dupCode = errDupKeyName
+	default:
+		add = fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s INT NOT NULL DEFAULT 0", table, ddlChurnColumn)
+		drop = fmt.Sprintf("ALTER TABLE %s DROP COLUMN %s", table, ddlChurnColumn)
+		dupCode = errDupFieldName
+	}
+
+	_, err := conn.ExecContext(ctx, add)
+	var myErr *mysql.MySQLError
+	if errors.As(err, &myErr) && myErr.Number == dupCode {
+		_, err = conn.ExecContext(ctx, drop)
+		return drop, err
+	}
+	return add, err
+}
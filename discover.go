@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// discoverTablesSQL lists the sysbench-like tables (with id, k, c and pad columns) of a database,
+// with their estimated row count and whether they are partitioned.
+const discoverTablesSQL = `SELECT t.TABLE_NAME, COALESCE(t.TABLE_ROWS, 0),
+  EXISTS (SELECT 1 FROM information_schema.PARTITIONS p
+    WHERE p.TABLE_SCHEMA = t.TABLE_SCHEMA AND p.TABLE_NAME = t.TABLE_NAME AND p.PARTITION_NAME IS NOT NULL)
+FROM information_schema.TABLES t
+WHERE t.TABLE_SCHEMA = ? AND t.TABLE_TYPE = 'BASE TABLE'
+  AND (SELECT COUNT(*) FROM information_schema.COLUMNS c
+    WHERE c.TABLE_SCHEMA = t.TABLE_SCHEMA AND c.TABLE_NAME = t.TABLE_NAME AND c.COLUMN_NAME IN ('id', 'k', 'c', 'pad')) = 4`
+
+// discoverTables builds the table list of every tenant from the tables named by the table name template that its
+// database actually holds, instead of the table count and row flags, so the workload adapts to pre-existing datasets.
+// The k range and the highest id of every table are read from the table itself. Unreachable tenants keep the table
+// list of the flags, and are left to -on-tenant-connect-failure; ClickHouse tenants are not discovered.
+func discoverTables(ctx context.Context, dsns *dsnResolver, opts *workloadOptions, tenantNames []string) (map[string][]TableInfo, error) {
+	var (
+		mu         sync.Mutex
+		discovered = make(map[string][]TableInfo)
+		empty      []string
+		wg         sync.WaitGroup
+		sem        = make(chan struct{}, verifyConcurrency)
+	)
+	for _, tenant := range tenantNames {
+		if opts.clickhouse.Applies(tenant) {
+			continue
+		}
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			tables, err := discoverTenantTables(ctx, dsns, opts.tenancy, opts.tableNames, tenant)
+			if err != nil {
+				log.Printf("[WARNING] table discovery: DB %s not discovered, using the table flags: %v", tenant, err)
+				return
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			if len(tables) == 0 {
+				empty = append(empty, tenant)
+				return
+			}
+			discovered[tenant] = tables
+			rows := 0
+			for _, t := range tables {
+				rows += t.Rows
+			}
+			log.Printf("[INFO] table discovery: DB %s has %d table(s) with about %d rows", tenant, len(tables), rows)
+		}()
+	}
+	wg.Wait()
+	if len(empty) > 0 {
+		sort.Strings(empty)
+		return nil, fmt.Errorf("no sysbench-like tables (with id, k, c and pad columns) found for %d tenant(s): %s",
+			len(empty), abbreviate(empty, 5))
+	}
+	return discovered, nil
+}
+
+// discoverTenantTables returns the tables of one tenant as the tenant sees them.
+func discoverTenantTables(ctx context.Context, dsns *dsnResolver, tenancy *tenancyModel, names tableNameTemplate, tenant string) ([]TableInfo, error) {
+	db, err := sql.Open(dsns.Driver(tenant), dsns.DSN(tenant))
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	query, args := discoverTablesSQL, []interface{}{tenancy.Database(tenant)}
+	if tenancy.kind == "schema" {
+		// The shared database holds the tables of all tenants; only take this tenant's.
+		query += " AND t.TABLE_NAME LIKE ?"
+		args = append(args, strings.NewReplacer(`\`, `\\`, `_`, `\_`, `%`, `\%`).Replace(tenant+"_")+"%")
+	}
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	var tables []TableInfo
+	for rows.Next() {
+		var t TableInfo
+		if err := rows.Scan(&t.Name, &t.Rows, &t.Partitioned); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		// Only the tables named by the template are part of the workload.
+		if !names.Match(strings.TrimPrefix(t.Name, tenancy.TableName(tenant, ""))) {
+			continue
+		}
+		if tenancy.kind == "row" {
+			t.TenantID = tenantID(tenant)
+		}
+		tables = append(tables, t)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	sort.Slice(tables, func(i, j int) bool { return naturalLess(tables[i].Name, tables[j].Name) })
+
+	// The k range and highest id come from the indexes on k and id, so this is cheap even for large tables.
+	own := tables[:0]
+	for _, t := range tables {
+		var minK, maxK, maxID sql.NullInt64
+		query, args := t.where("1=1")
+		err := db.QueryRowContext(ctx, fmt.Sprintf("SELECT MIN(k), MAX(k), MAX(id) FROM %s WHERE %s", t.Name, query), args...).
+			Scan(&minK, &maxK, &maxID)
+		if err != nil {
+			return nil, fmt.Errorf("table %s: %v", t.Name, err)
+		}
+		if !maxID.Valid {
+			continue // no rows (of this tenant), nothing to query
+		}
+		t.MinK, t.MaxK, t.MaxID = int(minK.Int64), int(maxK.Int64), int(maxID.Int64)
+		own = append(own, t)
+	}
+	return own, nil
+}
+
+// naturalLess orders names with a numeric suffix by number, so sbtest2 comes before sbtest10.
+func naturalLess(a, b string) bool {
+	ap, an := splitNumericSuffix(a)
+	bp, bn := splitNumericSuffix(b)
+	if ap != bp || an == bn {
+		return a < b
+	}
+	return an < bn
+}
+
+// splitNumericSuffix splits a name into its prefix and the number it ends with, -1 if it doesn't.
+func splitNumericSuffix(s string) (string, int) {
+	i := len(s)
+	for i > 0 && s[i-1] >= '0' && s[i-1] <= '9' {
+		i--
+	}
+	if i == len(s) || len(s)-i > 9 {
+		return s, -1
+	}
+	n := 0
+	for _, c := range s[i:] {
+		n = n*10 + int(c-'0')
+	}
+	return s[:i], n
+}
@@ -0,0 +1,313 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// dsnResolver decides which DSN each tenant database is opened with.
+// Tenants listed in the mapping file use their own DSN, tenants of a region with its own DSN use the region's DSN
+// prefix, all others use the DSN prefix plus the tenant name.
+// Reads may go to a separate DSN (read replicas or the read port of a read/write splitting proxy).
+// Driver parameters and a unix socket given on the command line are applied on top of either.
+// In the schema and row tenancy models every tenant connects to the shared database instead of its own.
+// ClickHouse tenants use the ClickHouse DSN prefix (an HTTP URL) as it is.
+type dsnResolver struct {
+	prefix        string
+	readPrefix    string // "" = reads use the same DSN as writes
+	perTenant     map[string]string
+	perTenantRead map[string]string
+	params        []string // "key=value" driver parameters
+	socket        string   // unix socket path replacing the network address
+	sharedDB      string   // "" = every tenant has its own database
+	clickhouse    *clickhouseTenants
+	regions       *tenantRegions    // nil = no tenant is served by a regional DSN
+	tiflash       *tiflashIsolation // nil = connections may read from any storage engine
+	users         *tenantUsers      // nil = the credentials of the DSNs are used as they are
+	collations    *tenantCollations // nil = connections use the driver's default collation
+	password      string            // "" = the DSNs are used with the password they have
+}
+
+func newDSNResolver(prefix string) *dsnResolver {
+	return &dsnResolver{prefix: prefix, perTenant: make(map[string]string), perTenantRead: make(map[string]string)}
+}
+
+// SetReadPrefix sends reads of tenants without their own read DSN to this DSN prefix.
+func (r *dsnResolver) SetReadPrefix(prefix string) {
+	r.readPrefix = prefix
+}
+
+// SetSharedDatabase makes tenants connect to the shared database name instead of a database named after the tenant.
+func (r *dsnResolver) SetSharedDatabase(name string) {
+	r.sharedDB = name
+}
+
+// SetClickHouse makes the ClickHouse tenants connect to their ClickHouse DSN prefix.
+func (r *dsnResolver) SetClickHouse(c *clickhouseTenants) {
+	r.clickhouse = c
+}
+
+// SetTiFlash restricts the connections of every tenant to its storage engine when the isolation is per session.
+func (r *dsnResolver) SetTiFlash(i *tiflashIsolation) {
+	r.tiflash = i
+}
+
+// SetRegions makes the tenants of a region with its own DSNs connect to them.
+func (r *dsnResolver) SetRegions(regions *tenantRegions) {
+	r.regions = regions
+}
+
+// SetPassword sets the password of the DSNs that have none, so it doesn't have to be part of the DSN flags.
+func (r *dsnResolver) SetPassword(password string) {
+	r.password = password
+}
+
+// SetCollations makes the connections of every tenant use its collation.
+func (r *dsnResolver) SetCollations(c *tenantCollations) {
+	r.collations = c
+}
+
+// SetUsers makes the tenants with their own user connect as that user, unless they are in the mapping file.
+func (r *dsnResolver) SetUsers(u *tenantUsers) {
+	r.users = u
+}
+
+// WithPrefix returns a copy of the resolver connecting every tenant to the DSN prefix, for reads and writes alike.
+// The mapping file, region DSNs and read DSN prefix don't apply to the copy; parameters, socket, users and the other
+// settings do.
+func (r *dsnResolver) WithPrefix(prefix string) *dsnResolver {
+	c := *r
+	c.prefix, c.readPrefix, c.regions = prefix, "", nil
+	c.perTenant, c.perTenantRead = make(map[string]string), make(map[string]string)
+	return &c
+}
+
+// Driver returns the database/sql driver the tenant's pools are opened with.
+func (r *dsnResolver) Driver(tenant string) string {
+	if sqlDriverName == "mysql" && r.clickhouse.Applies(tenant) {
+		return "clickhouse"
+	}
+	return sqlDriverName
+}
+
+// database returns the name of the database the tenant connects to.
+func (r *dsnResolver) database(tenant string) string {
+	if r.sharedDB != "" {
+		return r.sharedDB
+	}
+	return tenant
+}
+
+// LoadMapping reads a DSN mapping file with one "tenant dsn [read_dsn]" entry per line.
+// The optional third column is the DSN reads of that tenant are sent to.
+// A DSN ending in "/" is treated as a prefix and gets the tenant's database name appended.
+// Empty lines and lines starting with "#" are ignored.
+func (r *dsnResolver) LoadMapping(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 2 && len(fields) != 3 {
+			return fmt.Errorf("%s:%d: want \"tenant dsn [read_dsn]\", got %q", path, lineNo, line)
+		}
+		tenant := fields[0]
+		r.perTenant[tenant] = fields[1]
+		if len(fields) == 3 {
+			r.perTenantRead[tenant] = fields[2]
+		}
+	}
+	return scanner.Err()
+}
+
+// SetParams sets driver parameters ("key=value", e.g. "interpolateParams=true") added to every DSN,
+// replacing parameters of the same name already present in the DSN.
+func (r *dsnResolver) SetParams(params []string) error {
+	for _, p := range params {
+		if key, _, ok := strings.Cut(p, "="); !ok || key == "" {
+			return fmt.Errorf("invalid DSN parameter %q, want key=value", p)
+		}
+	}
+	r.params = params
+	return nil
+}
+
+// SetSocket makes every tenant connect through the unix socket at path instead of its network address.
+func (r *dsnResolver) SetSocket(path string) {
+	r.socket = path
+}
+
+// Validate builds the DSNs of every tenant and reports the first one the driver rejects.
+func (r *dsnResolver) Validate(tenants []string) error {
+	for _, tenant := range tenants {
+		if r.clickhouse.Applies(tenant) {
+			if u, err := url.Parse(r.baseDSN(tenant)); err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+				return fmt.Errorf("tenant %s: ClickHouse DSN %q is not an http(s) URL", tenant, r.baseDSN(tenant))
+			}
+			continue
+		}
+		if _, err := r.build(tenant, r.baseDSN(tenant), 0); err != nil {
+			return fmt.Errorf("tenant %s: %v", tenant, err)
+		}
+		if _, err := r.build(tenant, r.baseReadDSN(tenant), 0); err != nil {
+			return fmt.Errorf("tenant %s read DSN: %v", tenant, err)
+		}
+	}
+	return nil
+}
+
+// DSN returns the DSN of a tenant database, used for writes and everything not known to be a read.
+// With several accounts it connects as the first one. Invalid DSNs are reported by Validate; here they are returned
+// as they are.
+func (r *dsnResolver) DSN(tenant string) string {
+	if r.clickhouse.Applies(tenant) {
+		return r.baseDSN(tenant)
+	}
+	return r.buildOrBase(tenant, r.baseDSN(tenant), 0)
+}
+
+// ReadDSN returns the DSN reads of a tenant are sent to.
+func (r *dsnResolver) ReadDSN(tenant string) string {
+	if r.clickhouse.Applies(tenant) {
+		return r.DSN(tenant)
+	}
+	return r.buildOrBase(tenant, r.baseReadDSN(tenant), 0)
+}
+
+// AccountDSNs returns the DSNs of every account of a tenant, for writes or for reads, in the order of the accounts.
+// Tenants without several accounts have the one DSN of DSN or ReadDSN.
+func (r *dsnResolver) AccountDSNs(tenant string, read bool) []string {
+	dsn, base := r.DSN(tenant), r.baseDSN(tenant)
+	if read {
+		dsn, base = r.ReadDSN(tenant), r.baseReadDSN(tenant)
+	}
+	if _, mapped := r.perTenant[tenant]; mapped || !r.users.Applies(tenant) || r.clickhouse.Applies(tenant) {
+		return []string{dsn}
+	}
+	dsns := []string{dsn}
+	for i := 1; i < r.users.Accounts(tenant); i++ {
+		dsns = append(dsns, r.buildOrBase(tenant, base, i))
+	}
+	return dsns
+}
+
+func (r *dsnResolver) buildOrBase(tenant, base string, account int) string {
+	dsn, err := r.build(tenant, base, account)
+	if err != nil {
+		return base
+	}
+	return dsn
+}
+
+// baseDSN returns the DSN of a tenant before parameters and socket are applied.
+func (r *dsnResolver) baseDSN(tenant string) string {
+	if dsn, ok := r.perTenant[tenant]; ok {
+		return prefixedDSN(dsn, r.database(tenant))
+	}
+	if r.clickhouse.Applies(tenant) {
+		return r.clickhouse.prefix + r.database(tenant)
+	}
+	if dsn, _ := r.regions.DSN(tenant); dsn != "" {
+		return prefixedDSN(dsn, r.database(tenant))
+	}
+	return r.prefix + r.database(tenant)
+}
+
+// baseReadDSN returns the read DSN of a tenant before parameters and socket are applied.
+func (r *dsnResolver) baseReadDSN(tenant string) string {
+	if dsn, ok := r.perTenantRead[tenant]; ok {
+		return prefixedDSN(dsn, r.database(tenant))
+	}
+	if _, ok := r.perTenant[tenant]; ok {
+		return r.baseDSN(tenant)
+	}
+	if _, readDSN := r.regions.DSN(tenant); readDSN != "" {
+		return prefixedDSN(readDSN, r.database(tenant))
+	}
+	if r.readPrefix == "" {
+		return r.baseDSN(tenant)
+	}
+	return r.readPrefix + r.database(tenant)
+}
+
+// prefixedDSN appends the database name to a DSN prefix ending in "/".
+func prefixedDSN(dsn, database string) string {
+	if strings.HasSuffix(dsn, "/") {
+		return dsn + database
+	}
+	return dsn
+}
+
+// build applies the driver parameters, the tenant's session variables, the user of its account (0-based) and the
+// socket to a DSN and checks it with the driver's DSN parser.
+func (r *dsnResolver) build(tenant, base string, account int) (string, error) {
+	params := r.params
+	if p := r.tiflash.SessionParam(tenant); p != "" {
+		params = append(params[:len(params):len(params)], p)
+	}
+	if p := r.collations.SessionParam(tenant); p != "" {
+		params = append(params[:len(params):len(params)], p)
+	}
+	dsn := withDSNParams(base, params)
+	cfg, err := mysql.ParseDSN(dsn)
+	if err != nil {
+		return "", err
+	}
+	changed := false
+	if r.socket != "" {
+		cfg.Net = "unix"
+		cfg.Addr = r.socket
+		changed = true
+	}
+	if _, mapped := r.perTenant[tenant]; r.users.Applies(tenant) && !mapped {
+		cfg.User = r.users.User(tenant, account)
+		cfg.Passwd = r.users.Password(tenant, account)
+		changed = true
+	} else if r.password != "" && cfg.Passwd == "" {
+		cfg.Passwd = r.password
+		changed = true
+	}
+	if changed {
+		dsn = cfg.FormatDSN()
+	}
+	return dsn, nil
+}
+
+// withDSNParams adds "key=value" parameters to the query part of a DSN, replacing existing keys.
+func withDSNParams(dsn string, params []string) string {
+	if len(params) == 0 {
+		return dsn
+	}
+	base, query, _ := strings.Cut(dsn, "?")
+	override := make(map[string]bool, len(params))
+	for _, p := range params {
+		key, _, _ := strings.Cut(p, "=")
+		override[key] = true
+	}
+
+	var kept []string
+	for _, kv := range strings.Split(query, "&") {
+		key, _, _ := strings.Cut(kv, "=")
+		if kv != "" && !override[key] {
+			kept = append(kept, kv)
+		}
+	}
+	for _, p := range params {
+		key, value, _ := strings.Cut(p, "=")
+		kept = append(kept, key+"="+url.QueryEscape(value))
+	}
+	return base + "?" + strings.Join(kept, "&")
+}
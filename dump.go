@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"runtime"
+	"sort"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// poolRegistry tracks the open pools of the tenants, for the connection state of statistics dumps.
+// A nil *poolRegistry tracks nothing.
+type poolRegistry struct {
+	mu    sync.Mutex
+	pools map[string]*tenantPool
+}
+
+func newPoolRegistry() *poolRegistry {
+	return &poolRegistry{pools: make(map[string]*tenantPool)}
+}
+
+// Add records the open pool of a tenant.
+func (r *poolRegistry) Add(tenant string, pool *tenantPool) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.pools[tenant] = pool
+}
+
+// Remove forgets the pool of a tenant once it is closed, unless it has been replaced since.
+func (r *poolRegistry) Remove(tenant string, pool *tenantPool) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.pools[tenant] == pool {
+		delete(r.pools, tenant)
+	}
+}
+
+// writeStatsDump writes the current statistics of the run, the process state and the connection pool state
+// of every tenant to w, without interrupting the run.
+func writeStatsDump(w io.Writer, opts *workloadOptions) {
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+	snapshot := opts.stats.Snapshot()
+	fmt.Fprintf(w, "# statistics dump at %s, %.0fs into the run\n", time.Now().Format(time.RFC3339), snapshot.ElapsedSeconds)
+	fmt.Fprintf(w, "# goroutines=%d heap_alloc_mb=%.1f gc_cycles=%d\n", runtime.NumGoroutine(), float64(mem.HeapAlloc)/(1<<20), mem.NumGC)
+	if paused := opts.pauses.List(); len(paused) > 0 {
+		fmt.Fprintf(w, "# paused tenants: %v\n", paused)
+	}
+	printTenantTable(w, summarize(snapshot))
+
+	if opts.pools == nil {
+		return
+	}
+	opts.pools.mu.Lock()
+	tenants := make([]string, 0, len(opts.pools.pools))
+	pools := make(map[string]*tenantPool, len(opts.pools.pools))
+	for tenant, pool := range opts.pools.pools {
+		tenants = append(tenants, tenant)
+		pools[tenant] = pool
+	}
+	opts.pools.mu.Unlock()
+	sort.Strings(tenants)
+
+	fmt.Fprintln(w)
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "TENANT\tPOOL\tOPEN\tIN USE\tIDLE\tWAITS\tWAIT(ms)\t")
+	for _, tenant := range tenants {
+		pool := pools[tenant]
+		row := func(name string, s sql.DBStats) {
+			fmt.Fprintf(tw, "%s\t%s\t%d\t%d\t%d\t%d\t%d\t\n", tenant, name, s.OpenConnections, s.InUse, s.Idle, s.WaitCount, s.WaitDuration.Milliseconds())
+		}
+		if pool.split() {
+			row("write", pool.write.Stats())
+			row("read", pool.read.Stats())
+		} else {
+			row("rw", pool.write.Stats())
+		}
+	}
+	tw.Flush()
+}
+
+// dumpStats writes a statistics dump to path, or to stdout when path is "". The file is overwritten by every dump.
+func dumpStats(path string, opts *workloadOptions) {
+	if path == "" {
+		writeStatsDump(os.Stdout, opts)
+		return
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		log.Printf("[ERROR] Failed to create statistics dump %s: %v", path, err)
+		return
+	}
+	writeStatsDump(f, opts)
+	if err := f.Close(); err != nil {
+		log.Printf("[ERROR] Failed to write statistics dump %s: %v", path, err)
+		return
+	}
+	log.Printf("[INFO] Statistics dumped to %s", path)
+}
+
+// runDumpOnSignal dumps the statistics every time the process receives the dump signal (SIGUSR1), until ctx is done.
+func runDumpOnSignal(ctx context.Context, path string, opts *workloadOptions) {
+	signals, stop := notifyDumpSignal()
+	if signals == nil {
+		return
+	}
+	defer stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-signals:
+			dumpStats(path, opts)
+		}
+	}
+}
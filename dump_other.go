@@ -0,0 +1,10 @@
+//go:build !unix
+
+package main
+
+import "os"
+
+// notifyDumpSignal returns nil: there is no SIGUSR1 on this platform, dumps are only available through the HTTP server.
+func notifyDumpSignal() (<-chan os.Signal, func()) {
+	return nil, func() {}
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// minErrorRateQueries is the number of queries a run needs before the error rate is judged,
+// so a single early failure doesn't abort it.
+const minErrorRateQueries = 100
+
+// errorGuard aborts the run when errors exceed the configured thresholds,
+// so broken environments fail fast with a non-zero exit code instead of logging errors until the end.
+// Failed attempts to establish a worker connection count like failed queries, so an outage in which workers only
+// retry connecting aborts the run too. A nil *errorGuard never aborts.
+type errorGuard struct {
+	maxErrorRate      float64 // fraction of failed queries, 0 = disabled
+	maxConsecutive    int64   // failed queries in a row across all workers, 0 = disabled
+	consecutiveErrors int64   // atomic
+	connectFailures   int64   // atomic, failed connection attempts
+	cancel            context.CancelFunc
+
+	mu     sync.Mutex
+	reason string
+}
+
+// connErrorGuard is the error guard failed connection attempts are reported to, the one of the run. It is nil when
+// no abort threshold is set.
+var connErrorGuard *errorGuard
+
+// newErrorGuard returns nil when both thresholds are disabled.
+func newErrorGuard(maxErrorRate float64, maxConsecutive int, cancel context.CancelFunc) *errorGuard {
+	if maxErrorRate <= 0 && maxConsecutive <= 0 {
+		return nil
+	}
+	return &errorGuard{maxErrorRate: maxErrorRate, maxConsecutive: int64(maxConsecutive), cancel: cancel}
+}
+
+// Observe counts the outcome of one query.
+func (g *errorGuard) Observe(err error) {
+	if g == nil {
+		return
+	}
+	if err == nil || err == sql.ErrNoRows {
+		atomic.StoreInt64(&g.consecutiveErrors, 0)
+		return
+	}
+	g.failed()
+}
+
+// ObserveConnect counts a failed attempt to establish a connection. A connection established doesn't reset the errors
+// in a row, only a successful query does.
+func (g *errorGuard) ObserveConnect(err error) {
+	if g == nil || err == nil {
+		return
+	}
+	atomic.AddInt64(&g.connectFailures, 1)
+	g.failed()
+}
+
+// failed counts one more error in a row.
+func (g *errorGuard) failed() {
+	n := atomic.AddInt64(&g.consecutiveErrors, 1)
+	if g.maxConsecutive > 0 && n >= g.maxConsecutive {
+		g.abort(fmt.Sprintf("%d consecutive query or connection errors (-max-consecutive-errors=%d)", n, g.maxConsecutive))
+	}
+}
+
+// WatchErrorRate checks the overall error rate of the run every interval until ctx is done.
+func (g *errorGuard) WatchErrorRate(ctx context.Context, stats *statsCollector, interval time.Duration) {
+	if g == nil || g.maxErrorRate <= 0 {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		total := stats.Snapshot().Total()
+		connectFailures := uint64(atomic.LoadInt64(&g.connectFailures))
+		attempts, failures := total.Queries+connectFailures, total.Errors+connectFailures
+		if attempts < minErrorRateQueries {
+			continue
+		}
+		if rate := float64(failures) / float64(attempts); rate > g.maxErrorRate {
+			g.abort(fmt.Sprintf("error rate %.2f%% (%d of %d queries and connection attempts) exceeds -max-error-rate=%g",
+				rate*100, failures, attempts, g.maxErrorRate))
+			return
+		}
+	}
+}
+
+// abort stops the run; only the first reason is kept.
+func (g *errorGuard) abort(reason string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.reason != "" {
+		return
+	}
+	g.reason = reason
+	log.Printf("[ERROR] Aborting run: %s", reason)
+	g.cancel()
+}
+
+// AbortReason returns why the run was aborted, or "" if it wasn't.
+func (g *errorGuard) AbortReason() string {
+	if g == nil {
+		return ""
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.reason
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+)
+
+// queryExecutor is what generated queries and transaction scripts run on: the worker's *sql.Conn, a *sql.DB, or in
+// tests a fake backed by a scripted driver, so the query paths can be exercised without a database. Scripts using
+// session state (e.g. temporary tables) need an executor that stays on one connection, like *sql.Conn.
+type queryExecutor interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+	BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error)
+}
+
+// workerConn is a dedicated connection held by a worker or a background job: a *sql.Conn of a connSource.
+type workerConn interface {
+	queryExecutor
+	PingContext(ctx context.Context) error
+	// Raw gives access to the driver connection, e.g. to drop it underneath database/sql.
+	Raw(f func(driverConn interface{}) error) error
+	Close() error
+}
+
+// connSource is where workers take their dedicated connections from, and run statements outside of them (e.g. KILL
+// QUERY): one of a tenant's pools, or in tests a fake, so the worker loop runs without a database.
+type connSource interface {
+	queryExecutor
+	Conn(ctx context.Context) (workerConn, error)
+}
+
+// dbConns is the connSource of a *sql.DB.
+type dbConns struct {
+	*sql.DB
+}
+
+// Conn takes a connection from the pool.
+func (d dbConns) Conn(ctx context.Context) (workerConn, error) {
+	conn, err := d.DB.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return conn, nil
+}
+
+var (
+	_ queryExecutor = (*sql.Conn)(nil)
+	_ queryExecutor = (*sql.DB)(nil)
+	_ workerConn    = (*sql.Conn)(nil)
+	_ connSource    = dbConns{}
+)
@@ -0,0 +1,314 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// mockDBSeq numbers the mock databases of the tests.
+var mockDBSeq atomic.Int64
+
+// mockDB is a queryExecutor on the mock backend: a *sql.DB on the mock driver on one connection, following a script
+// of its own.
+type mockDB struct {
+	*sql.DB
+	*mockScript
+}
+
+func newMockDB(t testing.TB) *mockDB {
+	dsn := fmt.Sprintf("mock-test-%d", mockDBSeq.Add(1))
+	script, unregister := registerMockScript(dsn)
+	db, err := sql.Open("mock", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() {
+		db.Close()
+		unregister()
+	})
+	return &mockDB{DB: db, mockScript: script}
+}
+
+// testTable is the table the tests query, in the database tenancy model.
+var testTable = TableInfo{Name: "sbtest1", MinK: 1, MaxK: 1000}
+
+func TestRunQueryRead(t *testing.T) {
+	db := newMockDB(t)
+	db.Rows("FROM sbtest1", []string{"c"}, []driver.Value{[]byte("12345")}, []driver.Value{[]byte("678")})
+	qt := queryTypes["point_select"]
+	var volume resultVolume
+	if err := runQuery(context.Background(), db, qt, testTable, qt.sql(testTable), []interface{}{7}, &volume); err != nil {
+		t.Fatal(err)
+	}
+	if got := db.Statements(); len(got) != 1 || got[0] != "query SELECT c FROM sbtest1 WHERE k=? LIMIT 1" {
+		t.Errorf("statements = %q", got)
+	}
+	if volume.Rows != 2 || volume.BytesIn != 8 {
+		t.Errorf("volume = %+v, want 2 rows and 8 bytes", volume)
+	}
+}
+
+func TestRunQueryWrite(t *testing.T) {
+	db := newMockDB(t)
+	qt := queryTypes["payload_update"]
+	if err := runQuery(context.Background(), db, qt, testTable, qt.sql(testTable), []interface{}{"c", "pad", 7}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if got := db.Statements(); len(got) != 1 || !strings.HasPrefix(got[0], "exec UPDATE sbtest1 ") {
+		t.Errorf("statements = %q", got)
+	}
+}
+
+func TestRunQueryScript(t *testing.T) {
+	qt := queryTypes["bank_transfer"]
+	query := qt.sql(testTable)
+
+	db := newMockDB(t)
+	if err := runQuery(context.Background(), db, qt, testTable, query, []interface{}{5, 9, 3}, nil); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"begin", "exec " + query, "exec " + query, "commit"}
+	if got := db.Statements(); strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("statements = %q, want %q", got, want)
+	}
+
+	// A failed statement rolls the transaction back and is the script's error.
+	db = newMockDB(t)
+	deadlock := &mysql.MySQLError{Number: 1213, Message: "Deadlock found"}
+	db.Fail("UPDATE", deadlock, 1)
+	if err := runQuery(context.Background(), db, qt, testTable, query, []interface{}{5, 9, 3}, nil); err != deadlock {
+		t.Fatalf("err = %v, want the deadlock", err)
+	}
+	if got := db.Statements(); got[len(got)-1] != "rollback" {
+		t.Errorf("statements = %q, want a rollback at the end", got)
+	}
+}
+
+func TestScanJoinRows(t *testing.T) {
+	db := newMockDB(t)
+	db.Rows("LEFT JOIN", []string{"id", "k", "c", "pad"},
+		[]driver.Value{int64(1), int64(10), []byte("ccc"), []byte("pp")},
+		[]driver.Value{int64(2), int64(20), []byte("c"), []byte("")})
+	query, args := joinSelectQuery(&tenancyModel{kind: "db"}, tableNameTemplate{format: "sbtest%d", prefix: "sbtest"}, "test0001", 1)
+	var result SysbenchRow
+	var volume resultVolume
+	if err := scanJoinRows(db, context.Background(), query, args, &result, &volume); err != nil {
+		t.Fatal(err)
+	}
+	if result.ID != 2 || volume.Rows != 2 || volume.BytesIn != 2*16+3+2+1 {
+		t.Errorf("last row %+v, volume %+v", result, volume)
+	}
+}
+
+// TestTxnRetry runs a write like the worker loop does with -txn-retries: retryable errors are retried and counted,
+// the final outcome is recorded once.
+func TestTxnRetry(t *testing.T) {
+	db := newMockDB(t)
+	db.Fail("UPDATE", &mysql.MySQLError{Number: 1213, Message: "Deadlock found"}, 2)
+	retry, err := newTxnRetry(3, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stats := newStatsCollector(0)
+	tenant := stats.Tenant("test0001")
+	qt := queryTypes["payload_update"]
+	err = retry.Run(context.Background(), tenant, func() error {
+		return runQuery(context.Background(), db, qt, testTable, qt.sql(testTable), []interface{}{"c", "pad", 7}, nil)
+	})
+	tenant.RecordQuery(qt.name, time.Millisecond, err)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := len(db.Statements()); got != 3 {
+		t.Errorf("%d statements, want 3", got)
+	}
+	total := stats.Snapshot().Total()
+	if total.Queries != 1 || total.Errors != 0 || total.Retries != 2 {
+		t.Errorf("queries=%d errors=%d retries=%d, want 1, 0 and 2", total.Queries, total.Errors, total.Retries)
+	}
+
+	// A non-retryable error is returned at once.
+	db.Fail("UPDATE", errMockInjected, 1)
+	if err := retry.Run(context.Background(), tenant, func() error {
+		return runQuery(context.Background(), db, qt, testTable, qt.sql(testTable), []interface{}{"c", "pad", 7}, nil)
+	}); err != errMockInjected {
+		t.Errorf("err = %v, want the injected error", err)
+	}
+}
+
+// TestCircuitBreaker drives a tenant's circuit with the outcomes of queries on a failing executor: it opens when the
+// window is full of errors, lets one probe through after the open time and closes after the probes succeed.
+func TestCircuitBreaker(t *testing.T) {
+	db := newMockDB(t)
+	db.Fail("SELECT", errMockInjected, 4)
+	breaker, err := newCircuitBreaker(nil, 0.5, 4, 20*time.Millisecond, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	circuit := breaker.Tenant("test0001")
+	qt := queryTypes["point_select"]
+	query := func() error {
+		return runQuery(context.Background(), db, qt, testTable, qt.sql(testTable), []interface{}{7}, nil)
+	}
+	for i := 0; i < 4; i++ {
+		if !circuit.Allow() {
+			t.Fatalf("query %d rejected by a closed circuit", i)
+		}
+		circuit.Record(query())
+	}
+	if circuit.Allow() {
+		t.Fatal("the circuit allowed a query after the window filled with errors")
+	}
+	time.Sleep(30 * time.Millisecond)
+	if !circuit.Allow() {
+		t.Fatal("the circuit rejected the probe after the open time")
+	}
+	if circuit.Allow() {
+		t.Error("the circuit allowed a second query while probing")
+	}
+	circuit.Record(query())
+	if !circuit.Allow() {
+		t.Error("the circuit rejected a query after a successful probe")
+	}
+}
+
+// TestGovernedRatePace checks the pacing of a worker at a reduced rate: at half the rate, a worker busy for d waits d.
+func TestGovernedRatePace(t *testing.T) {
+	rate := &governedRate{rate: math.Float64bits(0.5)}
+	start := time.Now()
+	if !rate.Pace(context.Background(), 20*time.Millisecond) {
+		t.Fatal("Pace was interrupted")
+	}
+	if waited := time.Since(start); waited < 20*time.Millisecond || waited > 500*time.Millisecond {
+		t.Errorf("waited %v at half the rate after 20ms of work, want about 20ms", waited)
+	}
+}
+
+// Conn makes the mock database a connSource: workers take their connections from it.
+func (db *mockDB) Conn(ctx context.Context) (workerConn, error) {
+	return dbConns{db.DB}.Conn(ctx)
+}
+
+// TestRunWorker drives the worker loop on the mock backend for a short run: the queries it sends are paced by the
+// sleep after every query and recorded in the tenant's statistics, and a failing query takes the reconnect path.
+func TestRunWorker(t *testing.T) {
+	db := newMockDB(t)
+	db.Fail("WHERE k=?", errMockInjected, 2)
+	mix, err := parseQueryMix("point_select:1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	const run, sleep = 400 * time.Millisecond, 20 * time.Millisecond
+	opts := &workloadOptions{
+		mix:        mix,
+		tenancy:    &tenancyModel{kind: "db"},
+		tableNames: tableNameTemplate{format: "sbtest%d", prefix: "sbtest"},
+		discovered: map[string][]TableInfo{"test0001": {testTable}},
+		stats:      newStatsCollector(0),
+		sleepMs:    int(sleep / time.Millisecond),
+		exitTime:   time.Now().Add(run),
+	}
+
+	start := time.Now()
+	runWorker(context.Background(), db, db, "test0001", 0, opts)
+	if took := time.Since(start); took < run || took > run+500*time.Millisecond {
+		t.Errorf("the worker ran for %v, want it to stop at exitTime after %v", took, run)
+	}
+
+	stats := opts.stats.Snapshot().Tenants["test0001"]
+	// Every query is followed by the sleep, the join at the start and the reconnects are not paced.
+	if max := uint64(run/sleep) + 2; stats.Queries < max/2 || stats.Queries > max {
+		t.Errorf("recorded %d queries in %v at one per %v, want between %d and %d", stats.Queries, run, sleep, max/2, max)
+	}
+	if stats.Errors != 2 || stats.Reconnects != 2 {
+		t.Errorf("recorded errors=%d reconnects=%d, want 2 failed queries each followed by a reconnect", stats.Errors, stats.Reconnects)
+	}
+	var selects int
+	for _, s := range db.Statements() {
+		if strings.HasPrefix(s, "query SELECT c FROM sbtest1 WHERE k=?") {
+			selects++
+		}
+	}
+	// The join at the start is recorded too.
+	if uint64(selects)+1 != stats.Queries {
+		t.Errorf("the mock database ran %d point selects, the statistics recorded %d queries with the join", selects, stats.Queries)
+	}
+}
+
+// downSource is a connSource in an outage: every connection attempt fails.
+type downSource struct {
+	*sql.DB
+}
+
+func (downSource) Conn(ctx context.Context) (workerConn, error) {
+	return nil, errors.New("dial tcp: connection refused")
+}
+
+// TestErrorGuardConnectFailures checks that workers only retrying to connect during an outage abort the run once
+// -max-consecutive-errors attempts failed.
+func TestErrorGuardConnectFailures(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	guard := newErrorGuard(0, 3, cancel)
+	connErrorGuard = guard
+	defer func() { connErrorGuard = nil }()
+
+	if _, err := retryMakeActiveConn(downSource{}, "test0001", ctx); err == nil {
+		t.Fatal("got a connection from a source in an outage")
+	}
+	if reason := guard.AbortReason(); !strings.Contains(reason, "3 consecutive") {
+		t.Errorf("abort reason %q, want 3 consecutive errors", reason)
+	}
+}
+
+// TestGrowthStart checks that growth continues after the ids following the highest loaded id.
+func TestGrowthStart(t *testing.T) {
+	db := newMockDB(t)
+	table := testTable
+	table.MaxID = 1000
+	db.Rows("last_id", []string{"last_id"}, []driver.Value{int64(1250)})
+	if id, err := growthStart(context.Background(), db, table); err != nil || id != 1251 {
+		t.Errorf("grown table: start %d (%v), want 1251", id, err)
+	}
+	want := "query SELECT MIN(a.id) AS last_id FROM (SELECT ? AS id UNION ALL SELECT id FROM sbtest1 WHERE id > ?) a " +
+		"WHERE NOT EXISTS (SELECT 1 FROM sbtest1 b WHERE b.id = a.id + 1)"
+	if got := db.Statements(); len(got) != 1 || got[0] != want {
+		t.Errorf("statements = %q", got)
+	}
+}
+
+// TestArmedCancelDisarm checks that only a query stopped by its cancellation counts as cancelled.
+func TestArmedCancelDisarm(t *testing.T) {
+	interrupted := &mysql.MySQLError{Number: 1317, Message: "Query execution was interrupted"}
+	for _, c := range []struct {
+		fired bool
+		err   error
+		want  bool
+	}{
+		{true, context.Canceled, true},
+		{true, fmt.Errorf("query: %w", interrupted), true},
+		{true, nil, false},               // returned before the cancellation took effect
+		{true, errMockInjected, false},   // failed on its own
+		{false, context.Canceled, false}, // KILL QUERY failed, the worker's context was cancelled
+		{false, interrupted, false},      // killed by someone else
+	} {
+		a := &armedCancel{timer: time.NewTimer(time.Hour), cancel: func() {}, done: make(chan struct{})}
+		if c.fired {
+			a.fired = 1
+		}
+		if got := a.Disarm(c.err); got != c.want {
+			t.Errorf("fired=%v err=%v: cancelled=%v, want %v", c.fired, c.err, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"math/rand/v2"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// explainSampler runs EXPLAIN (or EXPLAIN ANALYZE) for a random sample of the generated queries
+// and appends the plans to a file, so plan changes under multi-tenant load can be spotted from the client side.
+// A nil *explainSampler samples nothing.
+type explainSampler struct {
+	rate    float64
+	analyze bool
+
+	mu   sync.Mutex
+	file *os.File
+}
+
+// newExplainSampler creates (or truncates) the plan file. It returns nil when rate is 0.
+func newExplainSampler(path string, rate float64, analyze bool) (*explainSampler, error) {
+	if rate <= 0 {
+		return nil, nil
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	return &explainSampler{rate: rate, analyze: analyze, file: f}, nil
+}
+
+// Sample reports whether the next query of the worker drawing from rng should be explained.
+func (e *explainSampler) Sample(rng *rand.Rand) bool {
+	return e != nil && rng.Float64() < e.rate
+}
+
+// Explain runs EXPLAIN for a query on conn and appends the plan to the file.
+// Writes are never run with EXPLAIN ANALYZE, since that would execute them a second time.
+func (e *explainSampler) Explain(ctx context.Context, conn queryExecutor, tenant string, qt *queryType, query string, args []interface{}) error {
+	explain := "EXPLAIN "
+	if e.analyze && !qt.write {
+		explain = "EXPLAIN ANALYZE "
+	}
+	rows, err := conn.QueryContext(ctx, explain+query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	columns, err := rows.Columns()
+	if err != nil {
+		return err
+	}
+
+	argsJSON, _ := json.Marshal(args)
+	var out strings.Builder
+	fmt.Fprintf(&out, "# %s tenant=%s type=%s\n# %s%s\n# args=%s\n%s\n",
+		time.Now().UTC().Format(time.RFC3339Nano), tenant, qt.name, explain, strings.Join(strings.Fields(query), " "),
+		argsJSON, strings.Join(columns, "\t"))
+	values := make([]sql.NullString, len(columns))
+	dest := make([]interface{}, len(columns))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+	for rows.Next() {
+		if err := rows.Scan(dest...); err != nil {
+			return err
+		}
+		for i, v := range values {
+			if i > 0 {
+				out.WriteByte('\t')
+			}
+			out.WriteString(escapeTSV(v.String))
+		}
+		out.WriteByte('\n')
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	out.WriteByte('\n')
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	_, err = e.file.WriteString(out.String())
+	return err
+}
+
+// Close closes the plan file.
+func (e *explainSampler) Close() error {
+	if e == nil {
+		return nil
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.file.Close()
+}
@@ -0,0 +1,353 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// fairIdleInterval is how often a shared worker checks for the end of the run, and for resumed tenants or circuits
+// ready for a probe, while no tenant can be served.
+const fairIdleInterval = 100 * time.Millisecond
+
+// fairTenant is a tenant served by the shared workers.
+type fairTenant struct {
+	name   string
+	pool   *tenantPool
+	weight float64
+	mix    *queryMix
+	tables []TableInfo
+	stats  *tenantStats
+
+	// Guarded by the scheduler's mutex.
+	pass    float64 // virtual time: seconds of worker time charged to the tenant, divided by its weight
+	cost    float64 // moving average of the seconds a query of the tenant takes, the provisional charge of a pick
+	active  bool
+	running int // picks not yet given back with Done or Skip, i.e. queries in flight on the tenant's pool
+	queries uint64
+	busy    time.Duration
+}
+
+// fairScheduler models platform-level connection multiplexing: a fixed pool of shared workers serves all tenants
+// instead of dedicated workers per tenant, and a weighted fair scheduler picks whose query a free worker runs next.
+// Every tenant is charged the worker time its queries take, divided by its weight, and the tenant charged the least
+// goes next (start-time fair queuing), so busy tenants share the workers in proportion to their weights whatever the
+// cost of their queries. Shared workers take a connection of the tenant's pool for every query. A nil *fairScheduler
+// leaves every tenant its dedicated workers.
+type fairScheduler struct {
+	workers int
+	rules   []tenantRule[float64] // weights
+
+	mu      sync.Mutex
+	tenants map[string]*fairTenant // every tenant served during the run
+	vtime   float64                // pass of the last picked tenant, where joining tenants start
+	wake    chan struct{}          // signalled when a tenant joins, for a worker waiting on an idle scheduler
+	idle    *sync.Cond             // on mu, broadcast when a tenant's running count drops
+}
+
+// newFairScheduler returns nil when workers is 0. The weight file has one "tenants weight" entry per line, e.g.
+//
+//	1-2   4
+//	3-5   2
+//	*     1
+//
+// tenants are names or 1-based ranges, "*" for every tenant; a tenant gets the weight of the first matching line and
+// 1 without one. Empty lines and lines starting with "#" are ignored.
+func newFairScheduler(workers int, path string) (*fairScheduler, error) {
+	if workers == 0 {
+		if path != "" {
+			return nil, fmt.Errorf("a weight file needs shared workers")
+		}
+		return nil, nil
+	}
+	if workers < 0 {
+		return nil, fmt.Errorf("the shared workers must not be negative")
+	}
+	s := &fairScheduler{workers: workers, tenants: make(map[string]*fairTenant), wake: make(chan struct{}, 1)}
+	s.idle = sync.NewCond(&s.mu)
+	if path != "" {
+		var err error
+		if s.rules, err = parseTenantRules(path, "weight", parseWeight); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// parseWeight parses a weight of the weight file.
+func parseWeight(text string) (float64, error) {
+	weight, err := strconv.ParseFloat(text, 64)
+	if err == nil && weight <= 0 {
+		err = fmt.Errorf("the weight must be positive")
+	}
+	return weight, err
+}
+
+// Weight returns the weight of a tenant.
+func (s *fairScheduler) Weight(tenant string) float64 {
+	if weight, ok := matchTenantRule(s.rules, tenant); ok {
+		return weight
+	}
+	return 1
+}
+
+// Serve makes the shared workers run the queries of a connected tenant until exitTime or until ctx is done, e.g. at
+// the end of its lifetime window, and returns once the queries the workers are running for it are finished, so its
+// pool can be closed. The tenant runs the -query-mix (its ClickHouse mix on ClickHouse) on its tables.
+func (s *fairScheduler) Serve(ctx context.Context, pool *tenantPool, dbName string, opts *workloadOptions) {
+	s.mu.Lock()
+	t, ok := s.tenants[dbName]
+	if !ok {
+		class := opts.tenantClass(dbName)
+		t = &fairTenant{name: dbName, weight: s.Weight(dbName), stats: opts.stats.Tenant(dbName), cost: 0.001,
+			tables: class.tables, mix: class.mix}
+		s.tenants[dbName] = t
+	}
+	// A joining tenant starts at the current virtual time, so it doesn't catch up on the time it was away.
+	t.pool, t.active = pool, true
+	if t.pass < s.vtime {
+		t.pass = s.vtime
+	}
+	s.mu.Unlock()
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+
+	timer := time.NewTimer(time.Until(opts.exitTime))
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+	case <-timer.C:
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t.active = false
+	for t.running > 0 {
+		s.idle.Wait()
+	}
+}
+
+// next picks the active tenant charged the least that held doesn't hold back, and charges it the expected cost of one
+// query, which Done corrects. Like a joining tenant, a held tenant is kept at the current virtual time, so it doesn't
+// catch up on the time it was held once it is released. It returns nil while no tenant can be served.
+func (s *fairScheduler) next(held func(tenant string) bool) (*fairTenant, *tenantPool, float64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var best *fairTenant
+	for _, t := range s.tenants {
+		if !t.active {
+			continue
+		}
+		if held(t.name) {
+			if t.pass < s.vtime {
+				t.pass = s.vtime
+			}
+			continue
+		}
+		if best == nil || t.pass < best.pass || (t.pass == best.pass && t.name < best.name) {
+			best = t
+		}
+	}
+	if best == nil {
+		return nil, nil, 0
+	}
+	s.vtime = best.pass
+	charge := best.cost / best.weight
+	best.pass += charge
+	best.running++
+	return best, best.pool, charge
+}
+
+// Next waits for a tenant to serve and returns it with its pool and the provisional charge to pass to Done or Skip.
+// Paused tenants and tenants whose circuit is open are passed over, so they don't hold up the others. It returns nil
+// at exitTime or when ctx is done.
+func (s *fairScheduler) Next(ctx context.Context, opts *workloadOptions) (*fairTenant, *tenantPool, float64) {
+	held := func(tenant string) bool {
+		return opts.pauses.Paused(tenant) || opts.breaker.Tenant(tenant).Open()
+	}
+	for {
+		if t, pool, charge := s.next(held); t != nil {
+			return t, pool, charge
+		}
+		wait := min(fairIdleInterval, time.Until(opts.exitTime))
+		if wait <= 0 {
+			return nil, nil, 0
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, nil, 0
+		case <-s.wake:
+			timer.Stop()
+		case <-timer.C:
+		}
+	}
+}
+
+// Done charges the tenant the worker time its query took, in place of the provisional charge of Next.
+func (s *fairScheduler) Done(t *fairTenant, charge float64, took time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t.pass += took.Seconds()/t.weight - charge
+	t.cost = 0.9*t.cost + 0.1*took.Seconds()
+	t.queries++
+	t.busy += took
+	s.release(t)
+}
+
+// Skip gives back the turn of a tenant that turned out not to be servable, e.g. paused after Next picked it. It keeps
+// the provisional charge, so the tenant goes behind the others instead of being picked again right away.
+func (s *fairScheduler) Skip(t *fairTenant) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if t.pass < s.vtime {
+		t.pass = s.vtime
+	}
+	s.release(t)
+}
+
+// release ends a pick of the tenant and wakes Serve waiting for its queries in flight. The mutex is held.
+func (s *fairScheduler) release(t *fairTenant) {
+	t.running--
+	if t.running == 0 {
+		s.idle.Broadcast()
+	}
+}
+
+// StartWorkers launches the shared workers, counted in wg.
+func (s *fairScheduler) StartWorkers(ctx context.Context, wg *sync.WaitGroup, opts *workloadOptions) {
+	log.Printf("[INFO] %d shared worker(s) serve all tenants by weighted fair scheduling", s.workers)
+	for i := 0; i < s.workers; i++ {
+		wg.Add(1)
+		go func(worker int) {
+			defer wg.Done()
+			runSharedWorker(ctx, worker, opts)
+		}(i)
+	}
+}
+
+// runSharedWorker runs the query of the tenant the scheduler picks, one at a time, until exitTime or until ctx is
+// cancelled. Reads run on the tenant's read pool and writes on its write pool.
+func runSharedWorker(ctx context.Context, worker int, opts *workloadOptions) {
+	rng := newWorkerRand("shared", worker)
+	queries := make(map[string]queryCache)
+	var args []interface{}
+	for {
+		t, pool, charge := opts.fair.Next(ctx, opts)
+		if t == nil {
+			return
+		}
+		dbName := t.name
+		qt := t.mix.Pick(rng)
+		tableIndex := rng.IntN(len(t.tables))
+		tableInfo := t.tables[tableIndex]
+		cache, ok := queries[dbName]
+		if !ok {
+			cache = make(queryCache)
+			queries[dbName] = cache
+		}
+		query := cache.Get(qt, tableIndex, func() string {
+			query := qt.sql(opts.views.Table(qt, tableInfo))
+			query = opts.tiflash.Hint(dbName, qt, tableInfo, query)
+			query = opts.hints.Apply(dbName, qt, tableInfo, query)
+			query = opts.annotate.Apply(dbName, qt.name, qt.write, tableInfo, query)
+			return opts.tags.Tag(query, dbName, worker, qt.name)
+		})
+		args = qt.args(tableInfo, opts, rng, args[:0])
+
+		// A tenant paused, or whose circuit opened, since Next picked it gives its turn back.
+		circuit := opts.breaker.Tenant(dbName)
+		if opts.pauses.Paused(dbName) || !circuit.Allow() {
+			opts.fair.Skip(t)
+			continue
+		}
+		// The tenant's tier limit holds the worker, like a multiplexer waiting on the tenant's quota.
+		if !opts.tiers.Limiter(dbName).Wait(ctx) || !opts.globalQPS.Wait(ctx) {
+			opts.fair.Done(t, charge, 0)
+			return
+		}
+		if !opts.inflight.Acquire(ctx) {
+			opts.fair.Done(t, charge, 0)
+			return
+		}
+
+		src := pool.reads()
+		if qt.write {
+			src = pool.writes()
+		}
+		volume := resultVolume{BytesOut: statementBytes(query, args)}
+		start := time.Now()
+		if start.After(opts.exitTime) {
+			opts.inflight.Release()
+			opts.fair.Done(t, charge, 0)
+			return
+		}
+		var exec queryExecutor = src
+		var conn workerConn
+		var err error
+		// A historical read sets the tenant's snapshot on a connection of its own, right before the read.
+		if asOf := opts.historicalStatement(dbName); asOf != "" && opts.historical.Applies(qt) {
+			if conn, err = src.Conn(ctx); err == nil {
+				exec = conn
+				err = opts.historical.Set(ctx, conn, asOf)
+			}
+			start = time.Now()
+		}
+		if err == nil {
+			err = opts.retries.Run(ctx, t.stats, func() error {
+				return runQuery(ctx, exec, qt, tableInfo, query, args, &volume)
+			})
+		}
+		duration := time.Since(start)
+		if conn != nil {
+			conn.Close()
+		}
+		opts.inflight.Release()
+		opts.fair.Done(t, charge, duration)
+		if ctx.Err() != nil {
+			return
+		}
+		opts.observeQuery(t.stats, dbName, qt.name, start, query, args, duration, err)
+		circuit.Record(err)
+		t.stats.RecordVolume(qt.name, volume)
+		if err != nil && err != sql.ErrNoRows {
+			workerLog.Printf(logClass("query failed", err), "[ERROR] DB=%s table=%s type=%s query failed: %v", dbName, tableInfo.Name, qt.name, err)
+		}
+
+		if !sleepCtx(ctx, time.Duration(opts.sleepMs)*time.Millisecond) {
+			return
+		}
+	}
+}
+
+// logFairSummary logs the weight, queries and share of the shared workers' time of every tenant, against the share
+// its weight entitles it to among the tenants served.
+func (s *fairScheduler) logFairSummary() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	names := make([]string, 0, len(s.tenants))
+	var busy time.Duration
+	var weights float64
+	for name, t := range s.tenants {
+		names = append(names, name)
+		busy += t.busy
+		weights += t.weight
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		t := s.tenants[name]
+		share := 0.0
+		if busy > 0 {
+			share = 100 * float64(t.busy) / float64(busy)
+		}
+		log.Printf("[INFO] fair share: DB=%s weight=%g queries=%d worker time=%v share=%.1f%% entitled=%.1f%%", name, t.weight,
+			t.queries, t.busy.Round(time.Millisecond), share, 100*t.weight/weights)
+	}
+}
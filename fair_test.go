@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// TestFairSchedulerSkipsPausedTenant serves two tenants with one of them paused: the other one must get every turn,
+// and the paused one its share again once resumed.
+func TestFairSchedulerSkipsPausedTenant(t *testing.T) {
+	s, err := newFairScheduler(2, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tenants := []string{"test0001", "test0002"}
+	for _, name := range tenants {
+		s.tenants[name] = &fairTenant{name: name, weight: 1, cost: 0.001, active: true}
+	}
+	opts := &workloadOptions{pauses: newTenantPauses(tenants), exitTime: time.Now().Add(time.Minute)}
+	if _, err := opts.pauses.Pause("test0001"); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	serve := func(n int) map[string]int {
+		picked := make(map[string]int)
+		for i := 0; i < n; i++ {
+			tenant, _, charge := s.Next(ctx, opts)
+			if tenant == nil {
+				t.Fatalf("turn %d: no tenant served", i)
+			}
+			picked[tenant.name]++
+			s.Done(tenant, charge, time.Millisecond)
+		}
+		return picked
+	}
+	if picked := serve(20); picked["test0002"] != 20 {
+		t.Errorf("turns with test0001 paused: %v, want all 20 for test0002", picked)
+	}
+
+	// Resumed, the tenant doesn't catch up on the turns it missed: it is served about as often as the other one.
+	if _, err := opts.pauses.Resume("test0001"); err != nil {
+		t.Fatal(err)
+	}
+	if picked := serve(20); picked["test0001"] < 9 || picked["test0001"] > 11 {
+		t.Errorf("turns after test0001 was resumed: %v, want about 10 each", picked)
+	}
+
+	// A tenant paused after Next picked it gives its turn back and the other one goes next.
+	tenant, _, _ := s.Next(ctx, opts)
+	other := "test0001"
+	if tenant.name == other {
+		other = "test0002"
+	}
+	if _, err := opts.pauses.Pause(tenant.name); err != nil {
+		t.Fatal(err)
+	}
+	s.Skip(tenant)
+	if next, _, _ := s.Next(ctx, opts); next == nil || next.name != other {
+		t.Errorf("the turn after %s gave it back went to %v, want %s", tenant.name, next, other)
+	}
+}
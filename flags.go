@@ -0,0 +1,54 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// envPrefix is the prefix of environment variables that configure flags,
+// e.g. WORKLOAD_THREADS_PRE_DB=17 for -threads-pre-db=17.
+const envPrefix = "WORKLOAD_"
+
+// envName returns the environment variable that configures a flag.
+func envName(flagName string) string {
+	return envPrefix + strings.ToUpper(strings.ReplaceAll(flagName, "-", "_"))
+}
+
+// applyEnvFlags sets every flag that was not given on the command line from its environment variable,
+// so the tool can be configured entirely through the environment (e.g. in a Kubernetes Deployment).
+// Command-line flags take precedence over the environment.
+func applyEnvFlags(fs *flag.FlagSet) error {
+	explicit := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) {
+		explicit[f.Name] = true
+	})
+
+	var firstErr error
+	fs.VisitAll(func(f *flag.Flag) {
+		if explicit[f.Name] || firstErr != nil {
+			return
+		}
+		value, ok := os.LookupEnv(envName(f.Name))
+		if !ok {
+			return
+		}
+		if err := fs.Set(f.Name, value); err != nil {
+			firstErr = fmt.Errorf("invalid %s=%q: %v", envName(f.Name), value, err)
+		}
+	})
+	return firstErr
+}
+
+// stringList is a flag that can be given several times, collecting every value.
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
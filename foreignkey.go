@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// fkChildRows is the number of child rows the fk_parent_child query type inserts per parent.
+const fkChildRows = 3
+
+// childTableName returns the name of the child table of a table, e.g. sbtest1_child.
+func childTableName(t TableInfo) string {
+	return t.Name + "_child"
+}
+
+// createChildTableSQL returns the CREATE TABLE statement of the child table of a table, whose rows reference a row
+// of the table with a foreign key deleting them along with it. Partitioned tables can't be referenced by foreign keys,
+// so the child tables of the small partition tables have the same columns but no constraint.
+func (s *schemaOptions) createChildTableSQL(t TableInfo, tableOptions string) string {
+	tenantColumn, tenantKey := "", ""
+	if t.TenantID != 0 {
+		tenantColumn, tenantKey = "  tenant_id INT NOT NULL,\n", "`tenant_id`,"
+	}
+	constraint := ""
+	if !t.Partitioned {
+		constraint = fmt.Sprintf(",\n  CONSTRAINT `%s_fk` FOREIGN KEY (%s`parent_id`) REFERENCES %s (%s`id`) ON DELETE CASCADE",
+			childTableName(t), tenantKey, t.Name, tenantKey)
+	}
+	return fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (\n  id        BIGINT NOT NULL AUTO_INCREMENT,\n%s  parent_id INT NOT NULL,\n  note      VARCHAR(64) NOT NULL DEFAULT '',\n  PRIMARY KEY (`id`),\n  KEY `parent_1` (%s`parent_id`)%s\n)%s",
+		childTableName(t), tenantColumn, tenantKey, constraint, tableOptions)
+}
+
+// runParentChild runs the script of the fk_parent_child query type on the connection: in one transaction it inserts
+// a new parent row (the statement) and its child rows, checked against the parent by the foreign key, and then deletes
+// the parent, which cascades to the children (deleted explicitly for the partitioned tables). The statement's
+// arguments are the tenant_id in the row tenancy model, followed by id, k, c and pad.
+func runParentChild(ctx context.Context, conn queryExecutor, t TableInfo, query string, args []interface{}) error {
+	tenantArgs, id := args[:len(args)-4], args[len(args)-4]
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.ExecContext(ctx, query, args...); err != nil {
+		return err
+	}
+
+	columns, row := "parent_id, note", "?,?"
+	if t.TenantID != 0 {
+		columns, row = "tenant_id, "+columns, "?,"+row
+	}
+	children := make([]interface{}, 0, fkChildRows*3)
+	for i := 0; i < fkChildRows; i++ {
+		children = append(append(children, tenantArgs...), id, fmt.Sprintf("child-%d", i))
+	}
+	insert := fmt.Sprintf("INSERT INTO %s (%s) VALUES %s", childTableName(t), columns,
+		strings.TrimSuffix(strings.Repeat("("+row+"),", fkChildRows), ","))
+	if _, err := tx.ExecContext(ctx, insert, children...); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	key := append(tenantArgs[:len(tenantArgs):len(tenantArgs)], id)
+	if t.Partitioned {
+		// Without the constraint the children don't go with their parent.
+		if _, err := conn.ExecContext(ctx, "DELETE FROM "+childTableName(t)+" WHERE "+t.whereSQL("parent_id=?"), key...); err != nil {
+			return err
+		}
+	}
+	_, err = conn.ExecContext(ctx, "DELETE FROM "+t.Name+" WHERE "+t.whereSQL("id=?"), key...)
+	return err
+}
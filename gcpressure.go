@@ -0,0 +1,92 @@
+package main
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"sync"
+)
+
+// Hot key range of mvcc_rewrite when it is used in -query-mix rather than by a GC-pressure tenant.
+const (
+	defaultGCPressureRows      = 100
+	defaultGCPressureBatchRows = 10
+)
+
+// gcPressure makes selected tenants constantly rewrite the same few rows of a few tables, piling up MVCC versions
+// of the same keys, so the interference of TiKV GC and compaction with the neighbor tenants can be studied.
+// A GC-pressure tenant only runs mvcc_rewrite, at a fixed rate per tenant instead of -sleep-after-query-ms.
+// A nil *gcPressure is disabled.
+type gcPressure struct {
+	tenants   tenantSet
+	tables    int     // the first tables of the tenant are rewritten
+	rows      int     // the hot key range: ids 1..rows
+	batchRows int     // rows rewritten by one statement
+	rate      float64 // statements per second per tenant, 0 = unlimited
+	mix       *queryMix
+
+	mu      sync.Mutex
+	buckets map[string]*tokenBucket
+}
+
+// newGCPressure returns nil when no tenant is selected.
+func newGCPressure(tenants tenantSet, tables, rows, batchRows int, rate float64) (*gcPressure, error) {
+	if tenants == nil {
+		return nil, nil
+	}
+	if tables < 1 || rows < 1 || batchRows < 1 {
+		return nil, fmt.Errorf("tables, rows and batch rows must be at least 1")
+	}
+	if batchRows > rows {
+		return nil, fmt.Errorf("batch rows (%d) must not exceed the hot rows (%d)", batchRows, rows)
+	}
+	if rate < 0 {
+		return nil, fmt.Errorf("the rewrite rate must not be negative")
+	}
+	mix, err := parseQueryMix("mvcc_rewrite")
+	if err != nil {
+		return nil, err
+	}
+	return &gcPressure{tenants: tenants, tables: tables, rows: rows, batchRows: batchRows, rate: rate,
+		mix: mix, buckets: make(map[string]*tokenBucket)}, nil
+}
+
+// Applies reports whether the tenant is a GC-pressure tenant.
+func (g *gcPressure) Applies(tenant string) bool {
+	return g != nil && g.tenants.Contains(tenant)
+}
+
+// Tables returns the tables a GC-pressure tenant rewrites.
+func (g *gcPressure) Tables(tables []TableInfo) []TableInfo {
+	if len(tables) > g.tables {
+		return tables[:g.tables]
+	}
+	return tables
+}
+
+// Limiter returns the bucket shared by the workers of a tenant, nil when the rate is unlimited.
+func (g *gcPressure) Limiter(tenant string) *tokenBucket {
+	if g.rate == 0 {
+		return nil
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	b, ok := g.buckets[tenant]
+	if !ok {
+		b = newTokenBucket(g.rate, int(g.rate/10)) // up to 100ms worth of statements at once
+		g.buckets[tenant] = b
+	}
+	return b
+}
+
+// hotRange returns a random batch of consecutive ids within the hot key range of the table.
+func (g *gcPressure) hotRange(t TableInfo, rng *rand.Rand) (int, int) {
+	rows, batch := defaultGCPressureRows, defaultGCPressureBatchRows
+	if g != nil {
+		rows, batch = g.rows, g.batchRows
+	}
+	// Tables smaller than the hot range are rewritten as a whole.
+	rows = min(rows, highestID(t))
+	batch = min(batch, rows)
+	lo := rng.IntN(rows-batch+1) + 1
+	return lo, lo + batch - 1
+}
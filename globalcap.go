@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync/atomic"
+	"time"
+)
+
+// globalRateCap holds the queries of all tenants together to one aggregate QPS ceiling, on top of the per-tenant
+// rates, so the total load stays constant while the tenant distribution underneath it changes. Unlike the admission
+// of -admission-qps the queries are admitted in the order they arrive, whatever their tier. A nil *globalRateCap
+// doesn't limit.
+type globalRateCap struct {
+	qps    float64
+	bucket *tokenBucket
+
+	waited   atomic.Uint64
+	waitTime atomic.Int64 // nanoseconds
+}
+
+// newGlobalRateCap returns nil when qps is 0.
+func newGlobalRateCap(qps float64) (*globalRateCap, error) {
+	if qps == 0 {
+		return nil, nil
+	}
+	if qps < 0 {
+		return nil, fmt.Errorf("the QPS must not be negative")
+	}
+	// Up to 100ms worth of queries may pass at once, as with the platform-wide admission.
+	return &globalRateCap{qps: qps, bucket: newTokenBucket(qps, int(qps/10))}, nil
+}
+
+// Wait blocks until the aggregate rate allows the next query and reports whether ctx is still active.
+func (c *globalRateCap) Wait(ctx context.Context) bool {
+	if c == nil {
+		return true
+	}
+	d := c.bucket.reserve()
+	if d > 0 {
+		c.waited.Add(1)
+		c.waitTime.Add(int64(d))
+	}
+	return sleepCtx(ctx, d)
+}
+
+// logGlobalCapSummary logs the achieved total QPS against the cap and how long queries waited for it.
+func (c *globalRateCap) logGlobalCapSummary(snapshot *statsSnapshot) {
+	qps := 0.0
+	if snapshot.ElapsedSeconds > 0 {
+		qps = float64(snapshot.Total().Queries) / snapshot.ElapsedSeconds
+	}
+	log.Printf("[INFO] Global QPS cap %g: achieved %.1f (%.1f%%), %d queries waited for it, total %v", c.qps, qps,
+		100*qps/c.qps, c.waited.Load(), time.Duration(c.waitTime.Load()).Round(time.Millisecond))
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// backoffGovernor simulates applications with client-side backpressure: every interval it compares the p99 latency
+// of the interval with a threshold, globally or per tenant, and multiplies the issue rate of the workers by the backoff
+// factor while it is above, down to a minimum, then restores it step by step while latency stays under. Unlike the
+// AIMD controller it keeps every worker running and only stretches the time between queries, so soak tests back off
+// instead of spiraling into timeouts. A nil *backoffGovernor never slows workers down.
+type backoffGovernor struct {
+	threshold time.Duration
+	interval  time.Duration
+	perTenant bool
+	backoff   float64
+	recovery  float64
+	minRate   float64
+
+	mu      sync.Mutex
+	tenants map[string]*governedRate // "" when the scope is global
+}
+
+// governedRate is the issue rate of a tenant, or of all tenants, as a fraction of the ungoverned rate.
+type governedRate struct {
+	rate     uint64 // math.Float64bits of the fraction, read by the workers without locking
+	backoffs int
+	lowest   float64
+	lastP99  time.Duration
+	previous *latencyHistogram // latencies seen up to the last adjustment
+}
+
+// newBackoffGovernor returns nil when threshold is 0. scope is "global" or "tenant".
+func newBackoffGovernor(threshold, interval time.Duration, scope string, backoff, recovery, minRate float64) (*backoffGovernor, error) {
+	if threshold <= 0 {
+		return nil, nil
+	}
+	if scope != "global" && scope != "tenant" {
+		return nil, fmt.Errorf("unknown scope %q (want global or tenant)", scope)
+	}
+	if interval <= 0 {
+		return nil, fmt.Errorf("the interval must be positive")
+	}
+	if backoff <= 0 || backoff >= 1 {
+		return nil, fmt.Errorf("the backoff factor must be between 0 and 1")
+	}
+	if recovery <= 0 {
+		return nil, fmt.Errorf("the recovery step must be positive")
+	}
+	if minRate <= 0 || minRate > 1 {
+		return nil, fmt.Errorf("the minimum rate must be between 0 and 1")
+	}
+	return &backoffGovernor{threshold: threshold, interval: interval, perTenant: scope == "tenant", backoff: backoff,
+		recovery: recovery, minRate: minRate, tenants: make(map[string]*governedRate)}, nil
+}
+
+func (g *backoffGovernor) rate(name string) *governedRate {
+	if !g.perTenant {
+		name = ""
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	r, ok := g.tenants[name]
+	if !ok {
+		r = &governedRate{rate: math.Float64bits(1), lowest: 1, previous: newLatencyHistogram()}
+		g.tenants[name] = r
+	}
+	return r
+}
+
+// Tenant returns the governed rate of a tenant, shared by all tenants when the scope is global, nil if the governor
+// is disabled. Workers look their tenant up once and call Pace on it.
+func (g *backoffGovernor) Tenant(name string) *governedRate {
+	if g == nil {
+		return nil
+	}
+	return g.rate(name)
+}
+
+// Pace stretches the time a worker took for one query, busy, to the governed rate by sleeping the difference,
+// and reports whether ctx is still active. A nil *governedRate doesn't sleep.
+func (r *governedRate) Pace(ctx context.Context, busy time.Duration) bool {
+	if r == nil {
+		return true
+	}
+	rate := r.Rate()
+	if rate >= 1 {
+		return true
+	}
+	return sleepCtx(ctx, time.Duration(float64(busy)*(1/rate-1)))
+}
+
+// Run adjusts the rates every interval from the latencies recorded in stats until ctx is done.
+func (g *backoffGovernor) Run(ctx context.Context, stats *statsCollector) {
+	ticker := time.NewTicker(g.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		g.adjust(stats.Snapshot())
+	}
+}
+
+// adjust applies one step to the global rate or to the rate of every tenant of the snapshot.
+func (g *backoffGovernor) adjust(s *statsSnapshot) {
+	if !g.perTenant {
+		r := g.rate("")
+		if changed, p99 := g.step(r, s.Total().Latency); changed {
+			log.Printf("[INFO] governor: rate=%.2f p99=%v threshold=%v", r.Rate(), p99, g.threshold)
+		}
+		return
+	}
+	changes, over := 0, 0
+	for _, name := range s.TenantNames() {
+		changed, p99 := g.step(g.rate(name), s.Tenants[name].Latency)
+		if changed {
+			changes++
+		}
+		if p99 > g.threshold {
+			over++
+		}
+	}
+	if changes > 0 {
+		log.Printf("[INFO] governor: tenants over p99 threshold %v=%d, rate changed for %d tenant(s)", g.threshold, over, changes)
+	}
+}
+
+// step backs the rate off when the p99 of the latencies since the last step is over the threshold and restores it
+// by the recovery step otherwise. It reports whether the rate changed, and the p99.
+func (g *backoffGovernor) step(r *governedRate, current *latencyHistogram) (bool, time.Duration) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	p99 := current.Since(r.previous).Quantile(0.99)
+	r.previous = current
+	r.lastP99 = p99
+	old := r.Rate()
+	rate := math.Min(1, old+g.recovery)
+	if p99 > g.threshold {
+		rate = math.Max(g.minRate, old*g.backoff)
+		r.backoffs++
+	}
+	if rate < r.lowest {
+		r.lowest = rate
+	}
+	atomic.StoreUint64(&r.rate, math.Float64bits(rate))
+	return rate != old, p99
+}
+
+// Rate returns the current rate as a fraction of the ungoverned rate.
+func (r *governedRate) Rate() float64 {
+	return math.Float64frombits(atomic.LoadUint64(&r.rate))
+}
+
+// logGovernorSummary logs how often and how far the governor backed off, globally or per tenant.
+func (g *backoffGovernor) logGovernorSummary() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	names := make([]string, 0, len(g.tenants))
+	for name := range g.tenants {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		r := g.tenants[name]
+		scope := "DB=" + name
+		if name == "" {
+			scope = "global"
+		}
+		log.Printf("[INFO] Summary: governor %s backoffs=%d lowest rate=%.2f final rate=%.2f last p99=%v",
+			scope, r.backoffs, r.lowest, r.Rate(), r.lastP99)
+	}
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// dataGrowth makes selected tenants' tables grow steadily during the run: a background job appends rows above the
+// highest id of every table in turn, at a fixed rate of rows per second, with k spread over the loaded range so the
+// secondary index grows with the table. Optionally it runs ANALYZE TABLE on the tenant's tables at an interval, so
+// statistics maintenance and plan stability (see -plan-drift-interval-seconds) can be evaluated under growing data.
+// The inserts and analyzes are recorded as growth_insert and analyze queries of the tenant. A nil *dataGrowth is
+// disabled.
+type dataGrowth struct {
+	tenants tenantSet
+	rate    float64       // rows per second per tenant
+	batch   int           // rows per INSERT
+	analyze time.Duration // between two ANALYZE TABLE rounds, 0 = never
+
+	inserted int64 // rows appended, over all tenants
+	analyzed int64 // tables analyzed
+}
+
+// newDataGrowth returns nil when rate is 0.
+func newDataGrowth(tenants tenantSet, rate float64, batch int, analyze time.Duration) (*dataGrowth, error) {
+	if rate == 0 {
+		return nil, nil
+	}
+	if rate < 0 {
+		return nil, fmt.Errorf("the growth rate can't be negative")
+	}
+	if batch < 1 {
+		return nil, fmt.Errorf("the rows per INSERT must be at least 1")
+	}
+	if analyze < 0 {
+		return nil, fmt.Errorf("the analyze interval can't be negative")
+	}
+	return &dataGrowth{tenants: tenants, rate: rate, batch: batch, analyze: analyze}, nil
+}
+
+// Applies reports whether the tenant's tables grow.
+func (g *dataGrowth) Applies(tenant string) bool {
+	return g != nil && g.tenants.Contains(tenant)
+}
+
+// Run appends rows on a connection of the tenant's write pool until ctx is done or exitTime is reached, and analyzes the
+// tables on a second connection, so the growth keeps its rate while the tables are analyzed. Every table grows on from
+// the rows appended by earlier runs, see growthStart. A batch that fails on a working connection, e.g. on a duplicate
+// key, is skipped, so the table grows on above it. Tables partitioned by id range are left alone, as new ids would fall
+// beyond their last partition.
+func (g *dataGrowth) Run(ctx context.Context, pool *tenantPool, dbName string, opts *workloadOptions) {
+	conn, err := retryMakeActiveConn(pool.writes(), dbName, ctx)
+	if err != nil {
+		return
+	}
+	defer func() { conn.Close() }()
+
+	stats := opts.stats.Tenant(dbName)
+	var tables []TableInfo
+	var nextID []int
+	for _, t := range opts.tenantTables(dbName) {
+		if t.Partitioned && opts.schema.rangeParts {
+			continue
+		}
+		id, err := growthStart(ctx, conn, t)
+		if err != nil {
+			if ctx.Err() == nil {
+				log.Printf("[ERROR] growth: DB %s: first id to append to %s: %v", dbName, t.Name, err)
+			}
+			return
+		}
+		tables = append(tables, t)
+		nextID = append(nextID, id)
+	}
+	if len(tables) == 0 {
+		return
+	}
+
+	if g.analyze > 0 {
+		var wg sync.WaitGroup
+		defer wg.Wait()
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			g.runAnalyze(ctx, pool, dbName, tables, opts)
+		}()
+	}
+
+	rng := newWorkerRand(dbName+"/growth", 0)
+	interval := time.Duration(float64(g.batch) / g.rate * float64(time.Second))
+	next := time.Now()
+	for table := 0; ctx.Err() == nil && time.Now().Before(opts.exitTime); table = (table + 1) % len(tables) {
+		t := tables[table]
+		query, args := opts.schema.insertRows(t, nextID[table], g.batch, rng)
+		query = opts.tags.Tag(query, dbName, -1, "growth_insert")
+		start := time.Now()
+		_, err := conn.ExecContext(ctx, query, args...)
+		opts.observeQuery(stats, dbName, "growth_insert", start, query, args, time.Since(start), err)
+		switch {
+		case err == nil:
+			nextID[table] += g.batch
+			atomic.AddInt64(&g.inserted, int64(g.batch))
+		case ctx.Err() != nil:
+			return
+		default:
+			workerLog.Printf(logClass("query failed", err), "[ERROR] DB=%s table=%s type=growth_insert query failed: %v", dbName, t.Name, err)
+			if conn.PingContext(ctx) == nil {
+				// The batch itself failed, e.g. on a row of another query type: retrying its ids would fail forever.
+				nextID[table] += g.batch
+			} else {
+				conn.Close()
+				newConn, err := retryMakeActiveConn(pool.writes(), dbName, ctx)
+				if err != nil {
+					return
+				}
+				conn = newConn
+			}
+		}
+
+		next = next.Add(interval)
+		if !sleepUntilExit(ctx, time.Until(next), opts.exitTime) {
+			return
+		}
+	}
+}
+
+// growthStart returns the first id to append to the table: the one after the ids following the table's highest
+// loaded id, which earlier runs appended. Unlike MAX(id) it doesn't jump to the rows other query types insert far above
+// the loaded ids, e.g. fk_parent_child parents of an interrupted run, and a batch an earlier run skipped leaves a gap
+// that is filled first.
+func growthStart(ctx context.Context, conn queryExecutor, t TableInfo) (int, error) {
+	// The highest loaded id counts as present, so the answer is the end of the ids following it.
+	highest := highestID(t)
+	query := fmt.Sprintf("SELECT MIN(a.id) AS last_id FROM (SELECT ? AS id UNION ALL SELECT id FROM %s WHERE %s) a "+
+		"WHERE NOT EXISTS (SELECT 1 FROM %s b WHERE %s)", t.Name, t.whereSQL("id > ?"), t.Name, t.whereSQL("b.id = a.id + 1"))
+	args := append(t.appendWhereArgs([]interface{}{highest}), highest)
+	args = t.appendWhereArgs(args)
+	var lastID int
+	if err := conn.QueryRowContext(ctx, query, args...).Scan(&lastID); err != nil {
+		return 0, err
+	}
+	return lastID + 1, nil
+}
+
+// runAnalyze runs ANALYZE TABLE on the tables, one after the other, every analyze interval until ctx is done or
+// exitTime is reached. A round that fails is given up until the next one.
+func (g *dataGrowth) runAnalyze(ctx context.Context, pool *tenantPool, dbName string, tables []TableInfo, opts *workloadOptions) {
+	conn, err := retryMakeActiveConn(pool.writes(), dbName, ctx)
+	if err != nil {
+		return
+	}
+	defer func() { conn.Close() }()
+
+	stats := opts.stats.Tenant(dbName)
+	for sleepUntilExit(ctx, g.analyze, opts.exitTime) {
+		for _, t := range tables {
+			query := opts.tags.Tag("ANALYZE TABLE "+t.Name, dbName, -1, "analyze")
+			start := time.Now()
+			err := execAndDrain(ctx, conn, query)
+			opts.observeQuery(stats, dbName, "analyze", start, query, nil, time.Since(start), err)
+			if err != nil {
+				if ctx.Err() != nil {
+					return
+				}
+				workerLog.Printf(logClass("query failed", err), "[ERROR] DB=%s table=%s type=analyze query failed: %v", dbName, t.Name, err)
+				if conn.PingContext(ctx) != nil {
+					conn.Close()
+					newConn, err := retryMakeActiveConn(pool.writes(), dbName, ctx)
+					if err != nil {
+						return
+					}
+					conn = newConn
+				}
+				break
+			}
+			atomic.AddInt64(&g.analyzed, 1)
+			if !time.Now().Before(opts.exitTime) {
+				return
+			}
+		}
+	}
+}
+
+// logGrowthSummary logs the rows appended by the growth jobs and the tables they analyzed.
+func (g *dataGrowth) logGrowthSummary() {
+	log.Printf("[INFO] Summary: data growth inserted=%d row(s), analyzed %d table(s)",
+		atomic.LoadInt64(&g.inserted), atomic.LoadInt64(&g.analyzed))
+}
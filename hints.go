@@ -0,0 +1,96 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// hintRule adds an optimizer hint to the queries of some tenants and query types.
+type hintRule struct {
+	tenants   tenantSet // nil = every tenant
+	queryType string    // "" = every query type
+	hint      string    // "{table}" is replaced by the queried table
+}
+
+// queryHints are the optimizer hints added to the generated queries, e.g. to A/B test a forced index
+// on some tenants against the optimizer's choice on the others. A nil *queryHints adds nothing.
+type queryHints struct {
+	rules []hintRule
+}
+
+// loadHintFile reads a hint file with one "tenants query_type hint" entry per line, e.g.
+//
+//	1-5  point_select   USE_INDEX({table}, k_1)
+//	*    analytic_agg   HASH_AGG() MAX_EXECUTION_TIME(5000)
+//
+// tenants are names or 1-based ranges, "*" for every tenant; query_type is a -query-mix type or "*".
+// A query gets the hints of every matching line, in file order. MySQL/TiDB tenants only.
+// Empty lines and lines starting with "#" are ignored.
+func loadHintFile(path string) (*queryHints, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	h := &queryHints{}
+	scanner := bufio.NewScanner(f)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) < 3 {
+			return nil, fmt.Errorf("%s:%d: want \"tenants query_type hint\", got %q", path, lineNo, line)
+		}
+		rule := hintRule{hint: strings.Join(fields[2:], " ")}
+		if fields[0] != "*" {
+			if rule.tenants, err = parseTenantSet(fields[0]); err != nil {
+				return nil, fmt.Errorf("%s:%d: %v", path, lineNo, err)
+			}
+		}
+		if fields[1] != "*" {
+			if _, ok := queryTypes[fields[1]]; !ok {
+				return nil, fmt.Errorf("%s:%d: unknown query type %q (known: %s)", path, lineNo, fields[1], strings.Join(queryTypeNames(), ", "))
+			}
+			rule.queryType = fields[1]
+		}
+		h.rules = append(h.rules, rule)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+// Apply adds the hints of the matching rules to a generated query.
+func (h *queryHints) Apply(tenant string, qt *queryType, t TableInfo, query string) string {
+	if h == nil || t.Dialect == dialectClickHouse {
+		return query
+	}
+	for _, rule := range h.rules {
+		if rule.tenants.Contains(tenant) && (rule.queryType == "" || rule.queryType == qt.name) {
+			query = addHint(query, strings.ReplaceAll(rule.hint, "{table}", t.Name))
+		}
+	}
+	return query
+}
+
+// addHint adds an optimizer hint after the leading SELECT, UPDATE or DELETE of a statement,
+// into its hint comment if it already has one: only the first hint comment of a query block is honored.
+func addHint(query, hint string) string {
+	for _, keyword := range []string{"SELECT ", "UPDATE ", "DELETE "} {
+		rest, ok := strings.CutPrefix(query, keyword)
+		if !ok {
+			continue
+		}
+		if inner, ok := strings.CutPrefix(rest, "/*+ "); ok {
+			return keyword + "/*+ " + hint + " " + inner
+		}
+		return keyword + "/*+ " + hint + " */ " + rest
+	}
+	return query
+}
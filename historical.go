@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync/atomic"
+	"time"
+)
+
+// historicalSnapshot is the snapshot the historical reads of a tenant read.
+type historicalSnapshot struct {
+	staleness time.Duration
+	atStart   bool // read the snapshot of the start of the run instead
+}
+
+// historicalReads makes selected tenants read from snapshots of the past, like reporting tenants do, next to tenants
+// reading fresh data: every read of such a tenant is preceded by SET TRANSACTION READ ONLY AS OF TIMESTAMP on its
+// connection, so it runs on the data of its staleness ago, or of the start of the run. Writes and multi-statement
+// query types are not affected. Needs TiDB, and snapshots within tidb_gc_life_time. A nil *historicalReads lets
+// every tenant read fresh data.
+type historicalReads struct {
+	rules []tenantRule[historicalSnapshot]
+
+	reads int64 // reads run as of a snapshot, over all tenants
+}
+
+// loadHistoricalFile reads a historical read file with one "tenants staleness" entry per line, e.g.
+//
+//	1-5  5s
+//	6    start
+//
+// tenants are names or 1-based ranges, "*" for every tenant; the staleness is a duration (90s, 5m) or seconds, or
+// "start" for the snapshot of the start of the run. The first matching line applies; other tenants read fresh data.
+// Empty lines and lines starting with "#" are ignored.
+func loadHistoricalFile(path string) (*historicalReads, error) {
+	rules, err := parseTenantRules(path, "staleness", parseHistoricalSnapshot)
+	if err != nil {
+		return nil, err
+	}
+	return &historicalReads{rules: rules}, nil
+}
+
+// parseHistoricalSnapshot parses the staleness of a historical read file.
+func parseHistoricalSnapshot(text string) (historicalSnapshot, error) {
+	if text == "start" {
+		return historicalSnapshot{atStart: true}, nil
+	}
+	staleness, err := parseOffset(text)
+	if err == nil && staleness <= 0 {
+		err = fmt.Errorf("the staleness must be positive")
+	}
+	return historicalSnapshot{staleness: staleness}, err
+}
+
+// Statement returns the statement setting the snapshot of the tenant's next read in a run started at start, "" when
+// it reads fresh data. The statement doesn't change during the run, so callers look it up once.
+func (h *historicalReads) Statement(tenant string, start time.Time) string {
+	if h == nil {
+		return ""
+	}
+	snapshot, ok := matchTenantRule(h.rules, tenant)
+	switch {
+	case !ok:
+		return ""
+	case snapshot.atStart:
+		return fmt.Sprintf("SET TRANSACTION READ ONLY AS OF TIMESTAMP FROM_UNIXTIME(%d.%06d)",
+			start.Unix(), start.Nanosecond()/1000)
+	}
+	return fmt.Sprintf("SET TRANSACTION READ ONLY AS OF TIMESTAMP NOW(6) - INTERVAL %d MICROSECOND", snapshot.staleness.Microseconds())
+}
+
+// Applies reports whether a query of the type reads from the snapshot: single-statement reads do.
+func (h *historicalReads) Applies(qt *queryType) bool {
+	return h != nil && !qt.write && qt.run == nil
+}
+
+// Set runs the statement of Statement on the connection of the next read.
+func (h *historicalReads) Set(ctx context.Context, conn queryExecutor, statement string) error {
+	if _, err := conn.ExecContext(ctx, statement); err != nil {
+		return err
+	}
+	atomic.AddInt64(&h.reads, 1)
+	return nil
+}
+
+// logHistoricalSummary logs the number of reads run on snapshots.
+func (h *historicalReads) logHistoricalSummary() {
+	log.Printf("[INFO] Summary: historical reads=%d", atomic.LoadInt64(&h.reads))
+}
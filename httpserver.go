@@ -0,0 +1,132 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// readiness tracks whether every tenant DB has been pinged successfully at least once.
+// A nil *readiness is valid and ignores all updates.
+type readiness struct {
+	mu       sync.Mutex
+	expected int
+	pinged   map[string]bool
+}
+
+func newReadiness() *readiness {
+	return &readiness{pinged: make(map[string]bool)}
+}
+
+// Expect sets the number of tenants that must be pinged before the run is ready.
+func (r *readiness) Expect(n int) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.expected = n
+}
+
+// Skip lowers the number of expected tenants by one, for a tenant left out of the run.
+func (r *readiness) Skip() {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.expected--
+}
+
+// MarkPinged records a successful ping of a tenant DB.
+func (r *readiness) MarkPinged(tenant string) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.pinged[tenant] = true
+}
+
+// Status reports whether the run is ready, with the number of pinged and expected tenants.
+func (r *readiness) Status() (ready bool, pinged, expected int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.expected > 0 && len(r.pinged) >= r.expected, len(r.pinged), r.expected
+}
+
+// startHTTPServer serves the health, readiness and control endpoints on listen.
+//
+//	/healthz                    200 while the process is running
+//	/readyz                     200 once every tenant DB has been pinged, 503 before
+//	/tenants/paused             the paused tenants, one per line
+//	/tenants/pause?tenants=1-3  POST: pause the selected tenants (names or 1-based ranges)
+//	/tenants/resume[?tenants=]  POST: resume the selected tenants, all of them without selector
+//	/stats                      statistics dump: per-tenant statistics, process and connection pool state
+//	/debug/pprof/               profiles of the load generator (net/http/pprof)
+func startHTTPServer(listen string, opts *workloadOptions) error {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, "ok")
+	})
+	mux.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) {
+		ready, pinged, expected := opts.readiness.Status()
+		if !ready {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		fmt.Fprintf(w, "%d/%d tenant DB(s) pinged\n", pinged, expected)
+	})
+
+	mux.HandleFunc("/tenants/paused", func(w http.ResponseWriter, r *http.Request) {
+		for _, name := range opts.pauses.List() {
+			fmt.Fprintln(w, name)
+		}
+	})
+	for action, apply := range map[string]func(string) ([]string, error){
+		"pause":  opts.pauses.Pause,
+		"resume": opts.pauses.Resume,
+	} {
+		action, apply := action, apply
+		mux.HandleFunc("/tenants/"+action, func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodPost {
+				http.Error(w, "use POST", http.StatusMethodNotAllowed)
+				return
+			}
+			spec := r.URL.Query().Get("tenants")
+			if spec == "" && action == "pause" {
+				http.Error(w, "missing tenants parameter", http.StatusBadRequest)
+				return
+			}
+			names, err := apply(spec)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			fmt.Fprintf(w, "%sd: %s\n", action, strings.Join(names, ","))
+		})
+	}
+
+	mux.HandleFunc("/stats", func(w http.ResponseWriter, r *http.Request) {
+		if opts.stats == nil {
+			http.Error(w, "the run has not started yet", http.StatusServiceUnavailable)
+			return
+		}
+		writeStatsDump(w, opts)
+	})
+	registerPprof(mux)
+
+	ln, err := net.Listen("tcp", listen)
+	if err != nil {
+		return err
+	}
+	go func() {
+		if err := http.Serve(ln, mux); err != nil {
+			log.Printf("[ERROR] HTTP server on %s stopped: %v", listen, err)
+		}
+	}()
+	log.Printf("[INFO] HTTP server listening on %s", listen)
+	return nil
+}
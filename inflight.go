@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync/atomic"
+	"time"
+)
+
+// inflightLimiter caps the queries outstanding at the same time across all tenants and workers, independent of the
+// thread counts, so large tenant counts can be configured without overwhelming the client host or the server. Workers
+// wait for a free slot before they send a query. A nil *inflightLimiter doesn't limit.
+type inflightLimiter struct {
+	slots chan struct{}
+
+	acquired atomic.Uint64
+	waited   atomic.Uint64
+	waitTime atomic.Int64 // nanoseconds
+	maxWait  atomic.Int64 // nanoseconds
+}
+
+// newInflightLimiter returns nil when max is 0.
+func newInflightLimiter(max int) (*inflightLimiter, error) {
+	if max == 0 {
+		return nil, nil
+	}
+	if max < 0 {
+		return nil, fmt.Errorf("the limit must not be negative")
+	}
+	return &inflightLimiter{slots: make(chan struct{}, max)}, nil
+}
+
+// Acquire waits for a free slot and reports whether it got one before ctx was done. Every acquired slot must be
+// given back with Release.
+func (l *inflightLimiter) Acquire(ctx context.Context) bool {
+	if l == nil {
+		return true
+	}
+	select {
+	case l.slots <- struct{}{}:
+		l.acquired.Add(1)
+		return true
+	default:
+	}
+	start := time.Now()
+	select {
+	case l.slots <- struct{}{}:
+	case <-ctx.Done():
+		return false
+	}
+	wait := int64(time.Since(start))
+	l.acquired.Add(1)
+	l.waited.Add(1)
+	l.waitTime.Add(wait)
+	for {
+		max := l.maxWait.Load()
+		if wait <= max || l.maxWait.CompareAndSwap(max, wait) {
+			break
+		}
+	}
+	return true
+}
+
+// Release gives back a slot taken by Acquire.
+func (l *inflightLimiter) Release() {
+	if l != nil {
+		<-l.slots
+	}
+}
+
+// logInflightSummary logs how many queries had to wait for a slot and for how long.
+func (l *inflightLimiter) logInflightSummary() {
+	acquired, waited := l.acquired.Load(), l.waited.Load()
+	share := 0.0
+	if acquired > 0 {
+		share = 100 * float64(waited) / float64(acquired)
+	}
+	log.Printf("[INFO] In-flight limit %d: %d of %d queries (%.1f%%) waited for a slot, total %v, max %v", cap(l.slots),
+		waited, acquired, share, time.Duration(l.waitTime.Load()).Round(time.Millisecond), time.Duration(l.maxWait.Load()).Round(time.Microsecond))
+}
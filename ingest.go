@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"math/rand/v2"
+	"strings"
+	"time"
+)
+
+// ingestTableName is the base name of the events table of an ingestion tenant: ts_events in the db and row tenancy
+// models, test0001_ts_events in the schema model.
+const ingestTableName = "ts_events"
+
+// Defaults of the ingest_* query types when they run without ingestion settings.
+const (
+	defaultIngestSeries        = 1000
+	defaultIngestWindowSeconds = 60
+)
+
+// ingestBatchRows is the number of rows inserted by one ingest_insert statement, set by -ingest-batch-rows.
+// It is part of the statement text, so it is the same for all workers.
+var ingestBatchRows = 10
+
+// ingestion makes selected tenants behave like logging or metrics tenants: they append timestamped rows to a table
+// partitioned by day, created by prepare mode, and now and then aggregate a recent time window. Their write-heavy,
+// ever-growing pattern, always inserting at the end of the time range, differs sharply from the sbtest tenants.
+// A nil *ingestion is disabled.
+type ingestion struct {
+	tenants       tenantSet
+	series        int // distinct series ids the rows belong to
+	window        time.Duration
+	partitionDays int // daily partitions created ahead of the day of prepare
+	mix           *queryMix
+}
+
+// newIngestion returns nil when no tenant is selected. mix is the weighted mix of the ingest_* query types.
+func newIngestion(tenants tenantSet, series int, window time.Duration, partitionDays int, mix string) (*ingestion, error) {
+	if tenants == nil {
+		return nil, nil
+	}
+	if series < 1 || partitionDays < 1 {
+		return nil, fmt.Errorf("series and partition days must be at least 1")
+	}
+	if window < time.Second {
+		return nil, fmt.Errorf("the query window must be at least one second")
+	}
+	m, err := parseQueryMix(mix)
+	if err != nil {
+		return nil, err
+	}
+	for _, qt := range m.types {
+		if !qt.ingest {
+			return nil, fmt.Errorf("query type %s is not an ingestion query type (want ingest_insert or ingest_recent)", qt.name)
+		}
+	}
+	return &ingestion{tenants: tenants, series: series, window: window, partitionDays: partitionDays, mix: m}, nil
+}
+
+// Applies reports whether the tenant is an ingestion tenant.
+func (g *ingestion) Applies(tenant string) bool {
+	return g != nil && g.tenants.Contains(tenant)
+}
+
+// Tables returns the events table of a tenant, the one table the workers of the tenant pick.
+func (g *ingestion) Tables(tenancy *tenancyModel, tenant string) []TableInfo {
+	return tenancy.Tables(tenant, []TableInfo{{Name: ingestTableName, Partitioned: true}})
+}
+
+// insertArgs appends the arguments of ingestBatchRows rows of random series: for every row the tenant_id in the row
+// tenancy model, the series id, the value and the tags.
+func (g *ingestion) insertArgs(t TableInfo, rng *rand.Rand, args []interface{}) []interface{} {
+	series := defaultIngestSeries
+	if g != nil {
+		series = g.series
+	}
+	for i := 0; i < ingestBatchRows; i++ {
+		args = append(t.appendWhereArgs(args), rng.IntN(series)+1, rng.Float64()*100, sysbenchString(rng, 32))
+	}
+	return args
+}
+
+// windowArgs appends the arguments of a query of the recent window: the tenant_id in the row tenancy model and the
+// window in seconds.
+func (g *ingestion) windowArgs(t TableInfo, args []interface{}) []interface{} {
+	seconds := defaultIngestWindowSeconds
+	if g != nil {
+		seconds = int(g.window / time.Second)
+	}
+	return append(t.appendWhereArgs(args), seconds)
+}
+
+// ingestInsertSQL returns the ingest_insert statement of the table, appending ingestBatchRows rows stamped with the
+// current time.
+func ingestInsertSQL(t TableInfo) string {
+	columns, row := "ts, series_id, value, tags", "(NOW(3), ?, ?, ?)"
+	if t.TenantID != 0 {
+		columns, row = "tenant_id, "+columns, "(?, NOW(3), ?, ?, ?)"
+	}
+	return "INSERT INTO " + t.Name + " (" + columns + ") VALUES " + strings.TrimSuffix(strings.Repeat(row+",", ingestBatchRows), ",")
+}
+
+// createIngestTableSQL returns the CREATE TABLE statement of the events table, partitioned by day from the day before
+// day to partitionDays days after it, and a last partition for everything later.
+func createIngestTableSQL(t TableInfo, day time.Time, partitionDays int, tableOptions string) string {
+	tenantColumn, tenantKey := "", ""
+	if t.TenantID != 0 {
+		tenantColumn, tenantKey = "  tenant_id INT NOT NULL,\n", "`tenant_id`,"
+	}
+	var partitions []string
+	day = time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, time.UTC)
+	for d := -1; d <= partitionDays; d++ {
+		start := day.AddDate(0, 0, d)
+		partitions = append(partitions, fmt.Sprintf("  PARTITION p%s VALUES LESS THAN (TO_DAYS('%s'))",
+			start.Format("20060102"), start.AddDate(0, 0, 1).Format("2006-01-02")))
+	}
+	partitions = append(partitions, "  PARTITION pmax VALUES LESS THAN MAXVALUE")
+	return fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (\n  id        BIGINT NOT NULL AUTO_INCREMENT,\n%s  ts        DATETIME(3) NOT NULL,\n"+
+		"  series_id INT NOT NULL,\n  value     DOUBLE NOT NULL,\n  tags      VARCHAR(64) NOT NULL DEFAULT '',\n"+
+		"  PRIMARY KEY (`id`, `ts`),\n  KEY `series_ts` (%s`series_id`, `ts`),\n  KEY `ts_1` (%s`ts`)\n)%s\nPARTITION BY RANGE (TO_DAYS(ts)) (\n%s\n)",
+		t.Name, tenantColumn, tenantKey, tenantKey, tableOptions, strings.Join(partitions, ",\n"))
+}
+
+// prepareIngest runs a prepare job of an events table: it creates the table, which starts empty.
+func prepareIngest(ctx context.Context, db *sql.DB, job prepareJob, schema *schemaOptions, batchRows int, rng *rand.Rand) error {
+	if _, err := db.ExecContext(ctx, createIngestTableSQL(job.table, time.Now().UTC(), job.ingest.partitionDays, job.options)); err != nil {
+		return err
+	}
+	log.Printf("[INFO] prepare: %s.%s ready for ingestion, partitioned by day", job.tenant, job.table.Name)
+	return nil
+}
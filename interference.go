@@ -0,0 +1,188 @@
+package main
+
+import (
+	"log"
+	"sort"
+	"time"
+)
+
+// interferenceBurstFactor: a window is a burst window for a tenant when the other tenants together run
+// at least this many times their median number of queries per window. Windows at or below the median are quiet.
+const interferenceBurstFactor = 1.5
+
+// statsWindow holds the queries of one tenant in one time window.
+// Latencies are kept as a sparse log-scale histogram (bucket -> count) since most windows use few buckets.
+type statsWindow struct {
+	Queries uint64         `json:"queries"`
+	Buckets map[int]uint64 `json:"buckets,omitempty"`
+	MaxUs   uint64         `json:"max_us,omitempty"`
+}
+
+// Record adds one successful query latency.
+func (w *statsWindow) Record(d time.Duration) {
+	us := uint64(0)
+	if d > 0 {
+		us = uint64(d.Microseconds())
+	}
+	if w.Buckets == nil {
+		w.Buckets = make(map[int]uint64)
+	}
+	w.Buckets[histBucket(us)]++
+	if us > w.MaxUs {
+		w.MaxUs = us
+	}
+}
+
+// Merge adds the queries of o to w.
+func (w *statsWindow) Merge(o *statsWindow) {
+	if o == nil {
+		return
+	}
+	w.Queries += o.Queries
+	for b, c := range o.Buckets {
+		if w.Buckets == nil {
+			w.Buckets = make(map[int]uint64)
+		}
+		w.Buckets[b] += c
+	}
+	if o.MaxUs > w.MaxUs {
+		w.MaxUs = o.MaxUs
+	}
+}
+
+// addTo adds the latencies of w to a full histogram. Only the quantiles of the result are meaningful.
+func (w *statsWindow) addTo(h *latencyHistogram) {
+	for b, c := range w.Buckets {
+		h.Buckets[b] += c
+		h.Count += c
+	}
+	if w.MaxUs > h.MaxUs {
+		h.MaxUs = w.MaxUs
+	}
+}
+
+// currentWindow returns the window of the current time, or nil if windows are not recorded.
+// The caller must hold t.mu.
+func (t *tenantStats) currentWindow() *statsWindow {
+	if t.window <= 0 {
+		return nil
+	}
+	return t.windowAt(int(time.Since(t.start) / t.window))
+}
+
+// windowAt returns the i-th window, growing the list as needed. The caller must hold t.mu if t is shared.
+func (t *tenantStats) windowAt(i int) *statsWindow {
+	for len(t.Windows) <= i {
+		t.Windows = append(t.Windows, &statsWindow{})
+	}
+	return t.Windows[i]
+}
+
+// tenantInterference is the interference report of one tenant.
+type tenantInterference struct {
+	Tenant       string
+	BurstWindows int
+	QuietWindows int
+	BurstP99     time.Duration
+	QuietP99     time.Duration
+	// Degradation is BurstP99 / QuietP99, 0 when there were no burst or no quiet windows.
+	Degradation float64
+}
+
+// computeInterference compares every tenant's latency in windows where the other tenants burst
+// with its latency in windows where they are quiet. The last window is usually cut short by the end of the run and is ignored.
+func computeInterference(s *statsSnapshot) []tenantInterference {
+	windows := 0
+	for _, t := range s.Tenants {
+		if len(t.Windows) > windows {
+			windows = len(t.Windows)
+		}
+	}
+	windows-- // drop the partial last window
+	if windows < 2 {
+		return nil
+	}
+
+	// Queries of all tenants per window; a tenant's "others" load is this minus its own.
+	total := make([]uint64, windows)
+	for _, t := range s.Tenants {
+		for i := 0; i < windows && i < len(t.Windows); i++ {
+			total[i] += t.Windows[i].Queries
+		}
+	}
+
+	var report []tenantInterference
+	for _, name := range s.TenantNames() {
+		t := s.Tenants[name]
+		own := func(i int) *statsWindow {
+			if i < len(t.Windows) {
+				return t.Windows[i]
+			}
+			return &statsWindow{}
+		}
+		others := make([]uint64, windows)
+		for i := range others {
+			others[i] = total[i] - own(i).Queries
+		}
+		median := medianUint64(others)
+
+		burst, quiet := newLatencyHistogram(), newLatencyHistogram()
+		r := tenantInterference{Tenant: name}
+		for i, load := range others {
+			switch {
+			case median > 0 && float64(load) >= interferenceBurstFactor*float64(median):
+				r.BurstWindows++
+				own(i).addTo(burst)
+			case load <= median:
+				r.QuietWindows++
+				own(i).addTo(quiet)
+			}
+		}
+		r.BurstP99, r.QuietP99 = burst.Quantile(0.99), quiet.Quantile(0.99)
+		if r.BurstP99 > 0 && r.QuietP99 > 0 {
+			r.Degradation = float64(r.BurstP99) / float64(r.QuietP99)
+		}
+		report = append(report, r)
+	}
+	return report
+}
+
+// medianUint64 returns the median of values without modifying them.
+func medianUint64(values []uint64) uint64 {
+	sorted := append([]uint64(nil), values...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	return sorted[len(sorted)/2]
+}
+
+// logInterference logs the interference report: one line per tenant and the interference index,
+// the mean and worst degradation factor over the tenants that saw both burst and quiet windows.
+func logInterference(s *statsSnapshot) {
+	report := computeInterference(s)
+	if report == nil {
+		log.Printf("[INFO] Interference: not enough windows for a report")
+		return
+	}
+	var sum float64
+	var rated int
+	worst := tenantInterference{}
+	for _, r := range report {
+		if r.Degradation == 0 {
+			log.Printf("[INFO] Interference: DB=%s burst_windows=%d quiet_windows=%d degradation=n/a",
+				r.Tenant, r.BurstWindows, r.QuietWindows)
+			continue
+		}
+		log.Printf("[INFO] Interference: DB=%s burst_windows=%d quiet_windows=%d p99 burst=%v quiet=%v degradation=%.2fx",
+			r.Tenant, r.BurstWindows, r.QuietWindows, r.BurstP99, r.QuietP99, r.Degradation)
+		sum += r.Degradation
+		rated++
+		if r.Degradation > worst.Degradation {
+			worst = r
+		}
+	}
+	if rated == 0 {
+		log.Printf("[INFO] Interference index: n/a (no tenant saw other tenants burst)")
+		return
+	}
+	log.Printf("[INFO] Interference index: mean degradation=%.2fx worst=%.2fx (DB=%s) over %d tenant(s)",
+		sum/float64(rated), worst.Degradation, worst.Tenant, rated)
+}
@@ -0,0 +1,235 @@
+// Package harness runs the workload tool against a real MySQL or TiDB server in a throwaway Docker container, for
+// integration tests of the SQL generation and the statistics: prepare mode, a short run and cleanup mode, with the
+// summary of the run parsed for assertions. Containers are managed through the docker CLI, so the harness needs no
+// dependency beyond the MySQL driver the tool already uses.
+package harness
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	_ "github.com/go-sql-driver/mysql"
+)
+
+// Server is a database server image the harness can start.
+type Server struct {
+	Image string
+	Port  int      // port the server listens on inside the container
+	Env   []string // "KEY=value" environment of the container
+	// Ready is how long the server may take to accept connections after the container started.
+	Ready time.Duration
+}
+
+// The servers the harness knows; both accept root without a password.
+var (
+	TiDB  = Server{Image: "pingcap/tidb:v8.5.1", Port: 4000, Ready: 2 * time.Minute}
+	MySQL = Server{Image: "mysql:8.0", Port: 3306, Env: []string{"MYSQL_ALLOW_EMPTY_PASSWORD=yes"}, Ready: 3 * time.Minute}
+)
+
+// Container is a running server container.
+type Container struct {
+	ID string
+	// DSN is the DSN prefix of the server for -dsn, e.g. root:@tcp(127.0.0.1:49153)/
+	DSN string
+}
+
+// DockerAvailable reports whether the docker CLI is installed and its daemon answers.
+func DockerAvailable(ctx context.Context) bool {
+	if _, err := exec.LookPath("docker"); err != nil {
+		return false
+	}
+	_, err := docker(ctx, "info", "--format", "{{.ServerVersion}}")
+	return err == nil
+}
+
+// Start starts a container of the server on a random local port and waits until the server accepts connections.
+// The container is removed by Stop, or by Docker when it stops.
+func Start(ctx context.Context, s Server) (*Container, error) {
+	args := []string{"run", "-d", "--rm", "-p", fmt.Sprintf("127.0.0.1::%d", s.Port)}
+	for _, env := range s.Env {
+		args = append(args, "-e", env)
+	}
+	out, err := docker(ctx, append(args, s.Image)...)
+	if err != nil {
+		return nil, err
+	}
+	c := &Container{ID: strings.TrimSpace(out)}
+	addr, err := docker(ctx, "port", c.ID, strconv.Itoa(s.Port))
+	if err != nil {
+		c.Stop(context.Background())
+		return nil, err
+	}
+	// "127.0.0.1:49153", one line per address family.
+	addr, _, _ = strings.Cut(strings.TrimSpace(addr), "\n")
+	c.DSN = "root:@tcp(" + addr + ")/"
+	if err := c.waitReady(ctx, s.Ready); err != nil {
+		c.Stop(context.Background())
+		return nil, err
+	}
+	return c, nil
+}
+
+// waitReady pings the server until it answers or the timeout has passed.
+func (c *Container) waitReady(ctx context.Context, timeout time.Duration) error {
+	db, err := sql.Open("mysql", c.DSN)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	deadline := time.Now().Add(timeout)
+	for {
+		pingCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		err = db.PingContext(pingCtx)
+		cancel()
+		if err == nil {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("server in container %.12s not ready after %v: %v", c.ID, timeout, err)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(time.Second):
+		}
+	}
+}
+
+// Stop removes the container.
+func (c *Container) Stop(ctx context.Context) error {
+	_, err := docker(ctx, "rm", "-f", c.ID)
+	return err
+}
+
+// Databases returns the databases of the server whose name starts with prefix.
+func (c *Container) Databases(ctx context.Context, prefix string) ([]string, error) {
+	db, err := sql.Open("mysql", c.DSN)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+	rows, err := db.QueryContext(ctx, "SHOW DATABASES LIKE ?", prefix+"%")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var names []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+	}
+	return names, rows.Err()
+}
+
+func docker(ctx context.Context, args ...string) (string, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "docker", args...)
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("docker %s: %v: %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.String(), nil
+}
+
+// Build builds the tool from the module in dir into a binary in the directory out and returns its path.
+func Build(ctx context.Context, dir, out string) (string, error) {
+	binary := filepath.Join(out, "tidb-workload")
+	cmd := exec.CommandContext(ctx, "go", "build", "-o", binary, ".")
+	cmd.Dir = dir
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return "", fmt.Errorf("go build: %v: %s", err, output)
+	}
+	return binary, nil
+}
+
+// Workload runs the tool binary against a server.
+type Workload struct {
+	Binary string
+	DSN    string
+	// Args are passed to every mode, e.g. the number of tenants and the table sizes.
+	Args []string
+}
+
+// Run runs the tool in a mode (prepare, run or cleanup) with the common and the extra arguments and returns its log.
+func (w *Workload) Run(ctx context.Context, mode string, args ...string) ([]byte, error) {
+	cmdArgs := append([]string{"-dsn", w.DSN, "-mode", mode}, w.Args...)
+	cmd := exec.CommandContext(ctx, w.Binary, append(cmdArgs, args...)...)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return output, fmt.Errorf("-mode %s: %v\n%s", mode, err, lastLines(output, 20))
+	}
+	return output, nil
+}
+
+// RunSummary runs the workload with the extra arguments and returns its summary, written to a file in dir.
+func (w *Workload) RunSummary(ctx context.Context, dir string, args ...string) (*Summary, []byte, error) {
+	path := filepath.Join(dir, "summary.json")
+	output, err := w.Run(ctx, "run", append(args, "-summary-json-file", path)...)
+	if err != nil {
+		return nil, output, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, output, err
+	}
+	s := &Summary{}
+	if err := json.Unmarshal(data, s); err != nil {
+		return nil, output, fmt.Errorf("%s: %v", path, err)
+	}
+	return s, output, nil
+}
+
+func lastLines(output []byte, n int) string {
+	lines := strings.Split(strings.TrimRight(string(output), "\n"), "\n")
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Summary is the part of the -summary-json-file report the tests assert on.
+type Summary struct {
+	Run struct {
+		RunID string `json:"run_id"`
+	} `json:"run"`
+	ElapsedSeconds float64         `json:"elapsed_seconds"`
+	Total          TenantSummary   `json:"total"`
+	Tenants        []TenantSummary `json:"tenants"`
+}
+
+// TenantSummary is the summary of one tenant, or of all tenants.
+type TenantSummary struct {
+	Tenant     string                      `json:"tenant"`
+	Queries    uint64                      `json:"queries"`
+	Errors     uint64                      `json:"errors"`
+	QPS        float64                     `json:"qps"`
+	Latency    Latency                     `json:"latency"`
+	Rows       uint64                      `json:"rows"`
+	QueryTypes map[string]QueryTypeSummary `json:"query_types"`
+}
+
+// QueryTypeSummary is the summary of one query type of a tenant.
+type QueryTypeSummary struct {
+	Queries uint64  `json:"queries"`
+	Errors  uint64  `json:"errors"`
+	Latency Latency `json:"latency"`
+}
+
+// Latency is a latency summary in milliseconds.
+type Latency struct {
+	P50Ms float64 `json:"p50_ms"`
+	P99Ms float64 `json:"p99_ms"`
+	MaxMs float64 `json:"max_ms"`
+}
@@ -0,0 +1,95 @@
+//go:build integration
+
+package harness
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+)
+
+// queryMix is the -query-mix of the integration run: point and range reads and a write. Every worker also runs the join.
+const queryMix = "point_select:4,payload_read:2,sum_range:1,payload_update:2"
+
+// queryTypes are the query types every tenant has to run.
+var queryTypes = []string{"point_select", "payload_read", "sum_range", "payload_update", "join"}
+
+// TestPrepareRunCleanup prepares two tenants on a fresh server, runs a short mixed workload with the join and checks
+// that every tenant ran every query type without errors and that the report adds up, then drops the tenants again.
+// It runs on TiDB, or on MySQL with HARNESS_SERVER=mysql:
+//
+//	go test -tags integration ./internal/harness/
+func TestPrepareRunCleanup(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Minute)
+	defer cancel()
+	if !DockerAvailable(ctx) {
+		t.Skip("docker is not available")
+	}
+	server := TiDB
+	if os.Getenv("HARNESS_SERVER") == "mysql" {
+		server = MySQL
+	}
+
+	binary, err := Build(ctx, "../..", t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := Start(ctx, server)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Stop(context.Background())
+
+	w := &Workload{Binary: binary, DSN: c.DSN, Args: []string{
+		"-db-num", "2",
+		"-big-table-num", "4", "-rows-per-big-table", "1000",
+		"-small-table-num", "2", "-rows-per-small-table", "100",
+		"-small-partition-table-num", "1", "-rows-pre-small-partition-tables", "300",
+	}}
+	if _, err := w.Run(ctx, "prepare"); err != nil {
+		t.Fatal(err)
+	}
+	if names, err := c.Databases(ctx, "test"); err != nil || len(names) != 2 {
+		t.Fatalf("databases after prepare: %v %v, want test0001 and test0002", names, err)
+	}
+
+	s, output, err := w.RunSummary(ctx, t.TempDir(), "-threads-pre-db", "2", "-testing-time-seconds", "10",
+		"-sleep-after-query-ms", "5", "-query-mix", queryMix, "-interference-window-seconds", "0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Run.RunID == "" {
+		t.Error("the summary has no run ID")
+	}
+	if len(s.Tenants) != 2 {
+		t.Fatalf("%d tenants in the summary, want 2\n%s", len(s.Tenants), lastLines(output, 20))
+	}
+	var queries uint64
+	for _, tenant := range s.Tenants {
+		queries += tenant.Queries
+		if tenant.Errors != 0 {
+			t.Errorf("tenant %s: %d errors\n%s", tenant.Tenant, tenant.Errors, lastLines(output, 20))
+		}
+		if tenant.Rows == 0 {
+			t.Errorf("tenant %s: no rows read", tenant.Tenant)
+		}
+		for _, name := range queryTypes {
+			if q := tenant.QueryTypes[name]; q.Queries == 0 {
+				t.Errorf("tenant %s: no %s queries", tenant.Tenant, name)
+			} else if q.Latency.P50Ms <= 0 || q.Latency.P99Ms < q.Latency.P50Ms {
+				t.Errorf("tenant %s: %s latency %+v", tenant.Tenant, name, q.Latency)
+			}
+		}
+	}
+	if s.Total.Queries != queries || queries == 0 {
+		t.Errorf("total queries %d, the tenants ran %d", s.Total.Queries, queries)
+	}
+
+	if _, err := w.Run(ctx, "cleanup"); err != nil {
+		t.Fatal(err)
+	}
+	if names, err := c.Databases(ctx, "test"); err != nil || len(names) != 0 {
+		t.Errorf("databases after cleanup: %v %v, want none", names, err)
+	}
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"math/rand/v2"
+	"time"
+)
+
+// latencyInjector delays queries of selected tenants on the client side before they are sent,
+// simulating tenants in remote regions or slow application servers that hold their connections longer.
+// A nil *latencyInjector injects nothing.
+type latencyInjector struct {
+	fixed   time.Duration
+	jitter  time.Duration
+	tenants tenantSet
+}
+
+// newLatencyInjector returns nil when no delay is configured.
+func newLatencyInjector(fixed, jitter time.Duration, tenants tenantSet) *latencyInjector {
+	if fixed <= 0 && jitter <= 0 {
+		return nil
+	}
+	return &latencyInjector{fixed: fixed, jitter: jitter, tenants: tenants}
+}
+
+// Applies reports whether queries of the tenant are delayed.
+func (l *latencyInjector) Applies(tenant string) bool {
+	return l != nil && l.tenants.Contains(tenant)
+}
+
+// Delay returns the delay for the next query: the fixed part plus a uniform random jitter drawn from the worker's rng.
+func (l *latencyInjector) Delay(rng *rand.Rand) time.Duration {
+	d := l.fixed
+	if l.jitter > 0 {
+		d += time.Duration(rng.Int64N(int64(l.jitter) + 1))
+	}
+	return d
+}
+
+// sleepCtx sleeps for d or until ctx is cancelled, and reports whether ctx is still active.
+func sleepCtx(ctx context.Context, d time.Duration) bool {
+	if d <= 0 {
+		return ctx.Err() == nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
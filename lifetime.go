@@ -0,0 +1,143 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// lifetimeWindow is the part of the run a tenant exists in, as offsets from the start of the run.
+type lifetimeWindow struct {
+	start time.Duration
+	end   time.Duration // 0 = until the end of the run
+}
+
+// lifetimeRule gives the tenants of a set the same lifetime window.
+type lifetimeRule struct {
+	tenants tenantSet // nil = every tenant
+	window  lifetimeWindow
+}
+
+// tenantLifetimes lets tenants exist only for part of the run, e.g. trial customers that sign up late and leave early,
+// for staggered-lifetime experiments. A nil *tenantLifetimes keeps every tenant for the whole run.
+type tenantLifetimes struct {
+	rules []lifetimeRule
+}
+
+// loadLifetimeFile reads a lifetime file with one "tenants start end" entry per line, e.g.
+//
+//	9-10  60s  5m
+//	8     10m  -
+//
+// tenants are names or 1-based ranges, "*" for every tenant; start and end are offsets from the start of the run,
+// as durations (90s, 5m) or seconds, and end "-" means the end of the run. The first matching line applies;
+// tenants without one run for the whole run. Empty lines and lines starting with "#" are ignored.
+func loadLifetimeFile(path string) (*tenantLifetimes, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	l := &tenantLifetimes{}
+	scanner := bufio.NewScanner(f)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("%s:%d: want \"tenants start end\", got %q", path, lineNo, line)
+		}
+		var rule lifetimeRule
+		if fields[0] != "*" {
+			if rule.tenants, err = parseTenantSet(fields[0]); err != nil {
+				return nil, fmt.Errorf("%s:%d: %v", path, lineNo, err)
+			}
+		}
+		if rule.window.start, err = parseOffset(fields[1]); err != nil {
+			return nil, fmt.Errorf("%s:%d: invalid start %q", path, lineNo, fields[1])
+		}
+		if fields[2] != "-" {
+			if rule.window.end, err = parseOffset(fields[2]); err != nil || rule.window.end <= rule.window.start {
+				return nil, fmt.Errorf("%s:%d: invalid end %q, must be after the start", path, lineNo, fields[2])
+			}
+		}
+		l.rules = append(l.rules, rule)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// parseOffset parses a non-negative offset given as a duration or in seconds.
+func parseOffset(text string) (time.Duration, error) {
+	if seconds, err := strconv.Atoi(text); err == nil {
+		text = strconv.Itoa(seconds) + "s"
+	}
+	d, err := time.ParseDuration(text)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid offset %q", text)
+	}
+	return d, nil
+}
+
+// Window returns the lifetime window of a tenant, if it has one.
+func (l *tenantLifetimes) Window(tenant string) (lifetimeWindow, bool) {
+	if l == nil {
+		return lifetimeWindow{}, false
+	}
+	for _, rule := range l.rules {
+		if rule.tenants.Contains(tenant) {
+			return rule.window, true
+		}
+	}
+	return lifetimeWindow{}, false
+}
+
+// runTenantWindow runs a tenant only within its lifetime window: it connects at the start offset and,
+// at the end offset, stops the workers and closes the tenant's pools, so its connections disappear from the server.
+func runTenantWindow(ctx context.Context, dsns *dsnResolver, dbName string, threadsPerDB int, window lifetimeWindow, opts *workloadOptions) {
+	if !sleepUntilExit(ctx, time.Until(opts.startTime.Add(window.start)), opts.exitTime) {
+		return
+	}
+	if window.end > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithDeadline(ctx, opts.startTime.Add(window.end))
+		defer cancel()
+	}
+	log.Printf("[INFO] lifetime: DB %s joins the run at %v", dbName, window.start)
+	runConnectedTenant(ctx, dsns, dbName, threadsPerDB, opts)
+	if window.end > 0 && time.Now().Before(opts.exitTime) {
+		log.Printf("[INFO] lifetime: DB %s leaves the run at %v", dbName, window.end)
+	}
+}
+
+// runConnectedTenant connects a tenant and runs its workers until exitTime or until ctx is done, then closes the
+// tenant's pools, so its connections disappear from the server.
+func runConnectedTenant(ctx context.Context, dsns *dsnResolver, dbName string, threadsPerDB int, opts *workloadOptions) {
+	var (
+		wg   sync.WaitGroup
+		pool *tenantPool
+	)
+	start := func(p *tenantPool) {
+		pool = p
+		startTenantWorkers(ctx, &wg, p, dbName, threadsPerDB, opts)
+	}
+	if p := opts.connect.Connect(ctx, &wg, dsns, dbName, opts.stats.Tenant(dbName), opts.exitTime, start); p != nil {
+		start(p)
+	}
+	wg.Wait()
+	if pool != nil {
+		pool.Close()
+		opts.pools.Remove(dbName, pool)
+	}
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// logSampler rate limits the messages workers log on every iteration, which would otherwise flood the log
+// with megabytes per second during an outage or failure injection: per interval, only the first limit
+// messages of each class are logged, and the number of suppressed messages of each class is logged at the end of it.
+type logSampler struct {
+	mu         sync.Mutex
+	limit      int // messages per class and interval, 0 = log everything
+	interval   time.Duration
+	counts     map[string]int
+	suppressed int64 // over the whole run
+}
+
+// workerLog is the sampler of the messages logged by workers, configured from the command line.
+var workerLog = &logSampler{counts: make(map[string]int)}
+
+// Configure sets the messages logged per class and interval; limit 0 disables sampling.
+func (s *logSampler) Configure(limit int, interval time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.limit, s.interval = limit, interval
+}
+
+// Printf logs a message of the given class unless the class has used up its messages of the current interval.
+func (s *logSampler) Printf(class, format string, v ...interface{}) {
+	s.mu.Lock()
+	if s.limit > 0 {
+		s.counts[class]++
+		if s.counts[class] > s.limit {
+			s.suppressed++
+			s.mu.Unlock()
+			return
+		}
+	}
+	s.mu.Unlock()
+	log.Printf(format, v...)
+}
+
+// Run starts a new interval every interval until ctx is done, reporting the messages suppressed in the last one.
+func (s *logSampler) Run(ctx context.Context) {
+	s.mu.Lock()
+	limit, interval := s.limit, s.interval
+	s.mu.Unlock()
+	if limit <= 0 || interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			s.Flush()
+			return
+		case <-ticker.C:
+			s.Flush()
+		}
+	}
+}
+
+// Flush logs the number of suppressed messages per class and starts a new interval.
+func (s *logSampler) Flush() {
+	s.mu.Lock()
+	counts := s.counts
+	s.counts = make(map[string]int)
+	limit := s.limit
+	s.mu.Unlock()
+
+	classes := make([]string, 0, len(counts))
+	for class, n := range counts {
+		if n > limit {
+			classes = append(classes, class)
+		}
+	}
+	sort.Strings(classes)
+	for _, class := range classes {
+		log.Printf("[WARNING] log sampling: suppressed %d more message(s) of class %q", counts[class]-limit, class)
+	}
+}
+
+// Suppressed returns the number of messages suppressed over the whole run.
+func (s *logSampler) Suppressed() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.suppressed
+}
+
+// logClass returns the class of a message about err: the kind of message plus the MySQL error number,
+// or the error text for other errors.
+func logClass(kind string, err error) string {
+	var mysqlErr *mysql.MySQLError
+	switch {
+	case err == nil:
+		return kind
+	case errors.As(err, &mysqlErr):
+		return fmt.Sprintf("%s: Error %d", kind, mysqlErr.Number)
+	case errors.Is(err, driver.ErrBadConn):
+		return kind + ": bad connection"
+	}
+	text := err.Error()
+	if len(text) > 80 {
+		text = text[:80]
+	}
+	return kind + ": " + text
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync/atomic"
+	"time"
+)
+
+// longTxn makes selected tenants keep a transaction open in the background, simulating a badly-behaved application:
+// the transaction reads some rows, optionally locking them, sits idle for the hold time and then commits or rolls back,
+// and the next one starts right away. While it is open, the GC safe point of TiDB can't advance past its start,
+// so the impact of a stuck GC safe point and of held locks on the other tenants can be observed.
+// A nil *longTxn is disabled.
+type longTxn struct {
+	tenants   tenantSet
+	hold      time.Duration
+	rows      int
+	forUpdate bool
+	commit    bool // commit at the end of the hold time, else roll back
+
+	held   int64 // transactions held for the whole hold time
+	failed int64
+	heldNs int64 // total time the transactions were open
+}
+
+// newLongTxn returns nil when hold is 0.
+func newLongTxn(tenants tenantSet, hold time.Duration, rows int, forUpdate bool, end string) (*longTxn, error) {
+	if hold <= 0 {
+		return nil, nil
+	}
+	if rows < 1 {
+		return nil, fmt.Errorf("rows must be at least 1")
+	}
+	if end != "commit" && end != "rollback" {
+		return nil, fmt.Errorf("unknown transaction end %q (want commit or rollback)", end)
+	}
+	return &longTxn{tenants: tenants, hold: hold, rows: rows, forUpdate: forUpdate, commit: end == "commit"}, nil
+}
+
+// Applies reports whether the tenant keeps a long transaction open.
+func (l *longTxn) Applies(tenant string) bool {
+	return l != nil && l.tenants.Contains(tenant)
+}
+
+// Run holds one long transaction after the other on a connection of the tenant's write pool
+// until ctx is done or exitTime is reached. A transaction still open then is rolled back.
+func (l *longTxn) Run(ctx context.Context, pool *tenantPool, dbName string, opts *workloadOptions) {
+	conn, err := retryMakeActiveConn(pool.writes(), dbName, ctx)
+	if err != nil {
+		return
+	}
+	defer func() { conn.Close() }()
+
+	rng := newWorkerRand(dbName+"/long-txn", 0)
+	tables := opts.tenantTables(dbName)
+	for ctx.Err() == nil && time.Now().Before(opts.exitTime) {
+		t := tables[rng.IntN(len(tables))]
+		query := "SELECT id, c FROM " + t.Name + " WHERE " + t.whereSQL("id BETWEEN ? AND ?")
+		if l.forUpdate {
+			query += " FOR UPDATE"
+		}
+		lo := randomID(t, rng)
+		query = opts.tags.Tag(query, dbName, -1, "long_txn")
+		args := append(t.appendWhereArgs(nil), lo, lo+l.rows-1)
+
+		start := time.Now()
+		n, end, err := l.hold1(ctx, conn, query, args, opts.exitTime)
+		took := time.Since(start)
+		atomic.AddInt64(&l.heldNs, int64(took))
+		if err != nil {
+			atomic.AddInt64(&l.failed, 1)
+			if ctx.Err() != nil {
+				return
+			}
+			log.Printf("[ERROR] long txn: DB=%s failed after %v: %v", dbName, took, err)
+			if conn.PingContext(ctx) != nil {
+				conn.Close()
+				newConn, err := retryMakeActiveConn(pool.writes(), dbName, ctx)
+				if err != nil {
+					return
+				}
+				conn = newConn
+			}
+			sleepUntilExit(ctx, time.Second, opts.exitTime)
+			continue
+		}
+		if took >= l.hold {
+			atomic.AddInt64(&l.held, 1)
+		}
+		log.Printf("[INFO] long txn: DB=%s read %d row(s) of %s, %s after %v", dbName, n, t.Name, end, took.Round(time.Millisecond))
+	}
+}
+
+// hold1 runs one long transaction: it reads the rows, stays open for the hold time (or until exitTime)
+// and ends the transaction. It returns the rows read and how the transaction ended.
+func (l *longTxn) hold1(ctx context.Context, conn workerConn, query string, args []interface{}, exitTime time.Time) (int, string, error) {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, "", err
+	}
+	defer tx.Rollback()
+	rows, err := tx.QueryContext(ctx, query, args...)
+	if err != nil {
+		return 0, "", err
+	}
+	n := 0
+	for rows.Next() {
+		n++
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return n, "", err
+	}
+
+	hold := l.hold
+	if left := time.Until(exitTime); left < hold {
+		hold = left
+	}
+	if !sleepCtx(ctx, hold) {
+		return n, "", ctx.Err()
+	}
+	if l.commit {
+		return n, "committed", tx.Commit()
+	}
+	return n, "rolled back", tx.Rollback()
+}
+
+// logLongTxnSummary logs how many long transactions were held and for how long they were open in total.
+func (l *longTxn) logLongTxnSummary() {
+	log.Printf("[INFO] Summary: long transactions held=%d failed=%d open for %v in total",
+		atomic.LoadInt64(&l.held), atomic.LoadInt64(&l.failed), time.Duration(atomic.LoadInt64(&l.heldNs)).Round(time.Second))
+}
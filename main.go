@@ -6,7 +6,8 @@ import (
 	"flag"
 	"fmt"
 	"log"
-	"math/rand"
+	"math/rand/v2"
+	"os"
 	"sync"
 	"time"
 
@@ -14,10 +15,122 @@ import (
 )
 
 // TableInfo holds the metadata of a table, including name and the value range of column 'k'.
+// Prepare mode loads ids 1..MaxK, so MaxK is also the number of rows.
 type TableInfo struct {
-	Name string
-	MinK int
-	MaxK int
+	Name        string
+	MinK        int
+	MaxK        int
+	MaxID       int // highest id of a discovered table, 0 = MaxK
+	Rows        int // estimated rows of a discovered table
+	Partitioned bool
+	TenantID    int    // row tenancy model: the tenant whose rows are queried, 0 otherwise
+	Dialect     string // SQL dialect of the table's server: "" for MySQL/TiDB, or dialectClickHouse
+	CSize       int    // length of c by the tenant's schema profile, 0 = -c-size
+	PadSize     int    // length of pad by the tenant's schema profile, 0 = -pad-size
+	Indexes     int    // extra secondary indexes by the tenant's schema profile
+}
+
+// workloadOptions holds the settings shared by all workers of a run.
+type workloadOptions struct {
+	tables     []TableInfo
+	tableNames tableNameTemplate
+	discovered map[string][]TableInfo // tables found in each tenant's database, nil = the tables of the flags
+	tenancy    *tenancyModel
+	mix        *queryMix
+	schema     *schemaOptions
+	sleepMs    int
+	rangeSize  int       // width of the k range of the range aggregate query types
+	startTime  time.Time // start of the run, the origin of tenant lifetime windows
+	exitTime   time.Time
+	capture    *captureWriter // nil when capture is disabled
+	audit      *auditLog      // nil when failed queries are not audited
+	stats      *statsCollector
+	readiness  *readiness
+	connect    *tenantConnectPolicy // what happens to tenants unreachable at the start
+	pauses     *tenantPauses        // tenants paused through the HTTP control API
+	lifetimes  *tenantLifetimes     // nil when every tenant runs for the whole run
+	onboard    *tenantOnboarding    // nil when every tenant exists from the start
+	offboard   *tenantOffboarding   // nil when every tenant exists until the end
+	pools      *poolRegistry        // open tenant pools, for statistics dumps
+	guard      *errorGuard          // nil when no abort threshold is set
+	breaker    *circuitBreaker      // nil when no tenant circuit opens
+	cancels    *queryCanceller      // nil when no query is cancelled
+	retries    *txnRetry            // nil when failed queries are not retried
+	inflight   *inflightLimiter     // nil when in-flight queries are not limited
+	globalQPS  *globalRateCap       // nil when the total QPS is not capped
+	fair       *fairScheduler       // nil when every tenant has its own workers
+	killer     *connKiller          // nil when the connection-kill chaos mode is disabled
+	recycler   *connRecycler        // nil when connections live for the whole run
+	latency    *latencyInjector     // nil when no client-side delay is injected
+	ddl        *ddlChurn            // nil when no tenant runs DDL churn
+	aimd       *aimdController      // nil when concurrency is not adapted
+	governor   *backoffGovernor     // nil when the issue rate is not governed
+	explain    *explainSampler      // nil when no plans are sampled
+	tiers      *tierScheduler       // nil when tenants are not rate limited
+	clickhouse *clickhouseTenants   // nil when no tenant is served by ClickHouse
+	tiflash    *tiflashIsolation    // nil when reads are not pinned to a storage engine
+	hints      *queryHints          // nil when no optimizer hints are added
+	annotate   *queryAnnotations    // nil when no routing comments are added
+	historical *historicalReads     // nil when every tenant reads fresh data
+	collations *tenantCollations    // nil when tenants use the default collation
+	users      *tenantUsers         // nil when tenants connect with the credentials of their DSN
+	sweep      *sweepController     // nil when the run is not a load sweep
+	scenario   *scenarioRunner      // nil when the run has no phases
+	targets    *targetTracker       // nil when no tenant's QPS is limited
+	coCorrect  *coCorrection        // nil when latencies are not corrected for coordinated omission
+	cache      *clientCache         // nil when no tenant has a client-side cache
+	tags       *sqlTagger           // nil when statements are not tagged with a comment
+	quiet      *quietSchedule       // nil when the run has no quiet windows
+	gcPressure *gcPressure          // nil when no tenant rewrites a hot key range
+	bank       *bankTransfer        // nil when no tenant runs bank transfers
+	orderEntry *orderEntry          // nil when no tenant runs order-entry transactions
+	ingest     *ingestion           // nil when no tenant ingests time series
+	ttl        *ttlTables           // nil when no tenant writes to TTL tables
+	views      *viewQueries         // nil when reads use the base tables
+	pkKinds    *primaryKeys         // nil when tables get the default primary key kind
+	profiles   *schemaProfiles      // nil when every tenant has the schema of the flags
+	longTxn    *longTxn             // nil when no tenant keeps a transaction open
+	pagination *pagination          // nil when no tenant pages through its tables
+	scanHog    *scanHog             // nil when no tenant runs full table scans
+	retention  *rangeDelete         // nil when no tenant runs a retention job
+	growth     *dataGrowth          // nil when no tenant's tables grow
+	partRoll   *partitionRoller     // nil when no tenant rolls its partitions
+}
+
+// tenantTables returns the tables as seen by a tenant: the tables discovered in its database,
+// or else those of its schema profile named and tagged for the tenancy model, and in the ClickHouse dialect for
+// ClickHouse tenants.
+func (o *workloadOptions) tenantTables(tenant string) []TableInfo {
+	if tables, ok := o.discovered[tenant]; ok {
+		return tables
+	}
+	tables := o.tenancy.Tables(tenant, o.profiles.Tables(tenant, o.tables))
+	if !o.clickhouse.Applies(tenant) {
+		return tables
+	}
+	own := make([]TableInfo, len(tables))
+	for i, t := range tables {
+		t.Dialect = dialectClickHouse
+		own[i] = t
+	}
+	return own
+}
+
+// historicalStatement returns the statement setting the snapshot of a tenant's historical reads, "" when it reads
+// fresh data. ClickHouse tenants always do.
+func (o *workloadOptions) historicalStatement(tenant string) string {
+	if o.clickhouse.Applies(tenant) {
+		return ""
+	}
+	return o.historical.Statement(tenant, o.startTime)
+}
+
+// observeQuery records the outcome of one executed query in the capture and audit files, the statistics and the error guard.
+func (o *workloadOptions) observeQuery(stats *tenantStats, tenant, queryType string, start time.Time, query string, args []interface{}, latency time.Duration, err error) {
+	o.capture.Record(tenant, start, query, args, latency, err)
+	o.audit.Record(tenant, queryType, start, query, args, latency, err)
+	stats.RecordQuery(queryType, latency, err)
+	o.guard.Observe(err)
 }
 
 type SysbenchRow struct {
@@ -32,6 +145,8 @@ func main() {
 	var (
 		// Number of databases (default: 10): test0001 ~ test0010
 		dbNum = flag.Int("db-num", 10, "Number of databases (default: 10)")
+		// Tenants driven by this process, names or 1-based ranges (default: "" = all -db-num tenants)
+		tenantSelection = flag.String("tenants", "", "Only drive these tenants, names or 1-based ranges, e.g. 3-7 or test0001,test0004 (default: all)")
 
 		// Number of rows per big table (default: 10000)
 		rowsPerBigTable = flag.Int("rows-per-big-table", 10000, "Rows per big table (default: 10000)")
@@ -46,88 +161,1530 @@ func main() {
 		// Total rows of each small partition table (default: 334800)
 		// e.g. 372 partitions * 900 rows each = 334800
 		rowsPerSmallPartitionTable = flag.Int("rows-pre-small-partition-tables", 334800, "Rows per small partition table in total (default: 334800)")
+		// Name of the i-th table, e.g. sbtest%03d for data prepared by a sysbench padding the table numbers (default: sbtest%d)
+		tableNameFormat = flag.String("table-name-template", "sbtest%d", "Name of the i-th table, with one %d verb, e.g. sbtest%03d for sbtest001 (default: sbtest%d)")
 		// Number of small partition tables (default: 3)
 		smallPartitionTableNum = flag.Int("small-partition-table-num", 3, "Number of small partition tables (default: 3)")
 
 		// Number of threads per DB (default: 17)
 		threadsPerDB = flag.Int("threads-pre-db", 17, "Threads (long connections) per DB (default: 17)")
 
+		// Seed of the random values generated by all workers (default: 0 = random, logged at start)
+		seed = flag.Uint64("seed", 0, "Seed of the per-worker random sources, to reproduce the generated values of a run (default: 0, random)")
+
+		// Identifier of the run, e.g. in the comments of -sql-comment (default: "" = random)
+		runID = flag.String("run-id", "", "Identifier of the run, e.g. in the statement comments of -sql-comment (default: random)")
+		// Tag every statement with a comment naming the run, tenant, worker and query type (default: false)
+		sqlComment = flag.Bool("sql-comment", false, "Prepend /* run=... tenant=... worker=... qtype=... */ to every workload statement (default: false)")
+
+		// Log sampling: per class of worker message (e.g. query errors by error code), only the first N per interval are logged
+		logSampleLimit           = flag.Int("log-sample-limit", 10, "Worker messages of each class (e.g. query errors by error code) logged per interval, 0 = all (default: 10)")
+		logSampleIntervalSeconds = flag.Int("log-sample-interval-seconds", 10, "Interval of -log-sample-limit in seconds (default: 10)")
+
 		// Sleep duration in milliseconds after each query (default: 359)
 		sleepAfterQueryMs = flag.Int("sleep-after-query-ms", 359, "Sleep duration in ms after each query (default: 359)")
+		// Correct the latencies for coordinated omission, for intended query starts every -co-interval-ms (default: false)
+		coCorrect    = flag.Bool("co-correct", false, "Also record latencies corrected for coordinated omission of the sleep-paced workers (default: false)")
+		coIntervalMs = flag.Int("co-interval-ms", 0, "Intended time between the query starts of a worker for -co-correct (default: 0, -sleep-after-query-ms)")
+		// Simulated client-side cache serving a fraction of the reads of every tenant (default: 0 = no cache)
+		cacheHitRatio      = flag.Float64("cache-hit-ratio", 0, "Fraction of the reads served by a simulated client-side cache instead of the database, in [0, 1) (default: 0)")
+		cacheWarmupSeconds = flag.Int("cache-warmup-seconds", 0, "Seconds over which the cache hit ratio grows from 0 to its value (default: 0, warm from the start)")
+		cacheFile          = flag.String("cache-file", "", "File of per-tenant cache hit ratios, one \"tenants ratio [warmup_seconds]\" per line (default: none)")
 
 		// DSN prefix, e.g. root:@tcp(127.0.0.1:4000)/
 		// The actual dbName will be appended when opening a specific DB.
 		dsn = flag.String("dsn", "root:@tcp(127.0.0.1:4000)/", "Data Source Name prefix for MySQL/TiDB")
+		// Per-tenant DSN mapping file, one "tenant dsn [read_dsn]" entry per line (default: "" = use -dsn for all tenants)
+		dsnMapFile = flag.String("dsn-map-file", "", "File mapping tenant databases to their own DSN, one \"tenant dsn [read_dsn]\" entry per line")
+		// Geo-distributed tenants: regions, their DSNs and per-region statistics (default: "" = no regions)
+		regionFile = flag.String("region-file", "", "File of tenant regions, one \"tenants region [dsn [read_dsn]]\" entry per line (default: none)")
+		// Read/write splitting: reads go to -read-dsn, writes to -write-dsn (default: "" = both use -dsn)
+		readDSN  = flag.String("read-dsn", "", "DSN prefix for reads, e.g. a read replica or the read port of a proxy (default: -dsn)")
+		writeDSN = flag.String("write-dsn", "", "DSN prefix for writes, e.g. the primary (default: -dsn)")
+		// A/B comparison: run the same seeded workload against a second cluster and compare both (default: "" = disabled)
+		dsnA   = flag.String("dsn-a", "", "DSN prefix of cluster A of an A/B comparison, same as -dsn (default: -dsn)")
+		dsnB   = flag.String("dsn-b", "", "DSN prefix of cluster B, runs the identical workload against it and reports both side by side (default: disabled)")
+		abMode = flag.String("ab-mode", "simultaneous", "How clusters A and B are loaded: simultaneous or sequential (B after A) (default: simultaneous)")
+		// Unix socket path used instead of the network address of the DSN (default: "" = use the DSN address)
+		socket = flag.String("socket", "", "Connect through this unix socket instead of the DSN's network address")
+		// MySQL protocol compression (zlib) on every MySQL/TiDB connection (default: false)
+		// Password of the DSNs without one, read from a file or $WORKLOAD_DB_PASSWORD rather than given on the command line
+		passwordFile = flag.String("password-file", "", "File whose first line is the password of every DSN without one (default: $WORKLOAD_DB_PASSWORD)")
+		compress     = flag.Bool("compress", false, "Compress the client/server protocol of every MySQL/TiDB connection (default: false)")
 
 		// testing time seconds (default: 600 seconds)
 		testingTimeSeconds = flag.Int("testing-time-seconds", 600, "testing time seconds (default: 600 seconds)")
+
+		// Tenant churn: each tenant alternates between an active phase and an idle phase
+		// in which all of its connections are closed (default: 0 = churn disabled)
+		churnOnSeconds = flag.Int("churn-on-seconds", 0, "Seconds a tenant stays active in churn mode (default: 0, churn disabled)")
+		// Idle phase length of churn mode (default: 60)
+		churnOffSeconds = flag.Int("churn-off-seconds", 60, "Seconds a tenant stays idle with all connections closed in churn mode (default: 60)")
+
+		// Replay mode: replay a captured query log instead of generating queries (default: "" = disabled)
+		replayFile = flag.String("replay-file", "", "Replay statements from this file instead of generating the workload")
+		// Replay file format: tsv or general-log (default: tsv)
+		replayFormat = flag.String("replay-format", "tsv", "Replay file format: tsv or general-log (default: tsv)")
+		// Replay speed factor relative to the original timeline, 0 = as fast as possible (default: 1.0)
+		replaySpeed = flag.Float64("replay-speed", 1.0, "Replay speed factor, 2 = twice as fast, 0 = as fast as possible (default: 1.0)")
+
+		// Capture file: record every generated query in the replay tsv format (default: "" = disabled)
+		captureFile = flag.String("capture-file", "", "Record every generated query (tenant, timestamp, args, latency) to this file in replay tsv format")
+
+		// Audit file of failed queries, one JSON object per line, rotated by size (default: "" = disabled)
+		auditFile     = flag.String("audit-file", "", "Write every failed query (tenant, type, SQL, args, error code) as JSON lines to this file (default: none)")
+		auditMaxSize  = flag.Int("audit-max-size-mb", 100, "Rotate the audit file when it reaches this size in MB, 0 = never (default: 100)")
+		auditMaxFiles = flag.Int("audit-max-files", 5, "Rotated audit files kept as <file>.1 .. <file>.N (default: 5)")
+
+		// Push metrics per tenant and query type to StatsD or InfluxDB (default: "" = disabled)
+		metricsSink            = flag.String("metrics-sink", "", "Push metrics to a statsd or influxdb sink (default: none)")
+		metricsAddr            = flag.String("metrics-addr", "127.0.0.1:8125", "Sink address: host:port (UDP), tcp://host:port, or an InfluxDB HTTP write URL (default: 127.0.0.1:8125)")
+		metricsPrefix          = flag.String("metrics-prefix", "workload", "StatsD bucket prefix or InfluxDB measurement name (default: workload)")
+		metricsIntervalSeconds = flag.Int("metrics-interval-seconds", 10, "Seconds between metric pushes (default: 10)")
+
+		// Tenant lifetime windows: tenants that join and leave during the run (default: "" = all tenants run the whole time)
+		lifetimeFile = flag.String("lifetime-file", "", "File of tenant lifetime windows, one \"tenants start end\" entry per line, offsets from the start of the run (default: none)")
+		// Tenant onboarding: tenants created, loaded and started during the run (default: "" = all tenants exist from the start)
+		onboardFile = flag.String("onboard-file", "", "File of tenants onboarded during the run, one \"tenants offset\" entry per line: prepare mode skips them, the run creates and loads them at the offset (default: none)")
+		// Tenant offboarding: tenants drained and dropped during the run (default: "" = all tenants run until the end)
+		offboardFile           = flag.String("offboard-file", "", "File of tenants offboarded during the run, one \"tenants offset\" entry per line: at the offset their workers are drained and their database is dropped (default: none)")
+		offboardObserveSeconds = flag.Int("offboard-observe-seconds", 60, "Seconds the other tenants are observed before an offboarding and after its drop (default: 60)")
+
+		// Multi-node coordination: leader or follower (default: "" = single node)
+		clusterRole = flag.String("cluster-role", "", "Multi-node role: leader or follower (default: single node)")
+		// Address the leader listens on for followers (default: :7070)
+		clusterListen = flag.String("cluster-listen", ":7070", "Leader: address to listen on for followers (default: :7070)")
+		// Number of followers the leader waits for before starting (default: 1)
+		clusterFollowers = flag.Int("cluster-followers", 1, "Leader: number of followers to wait for (default: 1)")
+		// Leader URL used by followers, e.g. http://10.0.0.1:7070
+		clusterLeaderURL = flag.String("cluster-leader", "", "Follower: leader URL, e.g. http://10.0.0.1:7070")
+
+		// What to do when a tenant DB is unreachable at the start: abort the run, skip the tenant or retry it (default: abort)
+		onTenantConnectFailure    = flag.String("on-tenant-connect-failure", "abort", "When a tenant DB is unreachable at the start: abort the run, skip the tenant, or retry it in the background (default: abort)")
+		tenantConnectRetrySeconds = flag.Int("tenant-connect-retry-seconds", 5, "Seconds between connection attempts of unreachable tenants with -on-tenant-connect-failure=retry (default: 5)")
+
+		// HTTP server for health/readiness endpoints and the control API, e.g. :8080 (default: "" = disabled)
+		httpListen = flag.String("http-listen", "", "Address of the HTTP server for /healthz, /readyz, the /tenants control API, /stats and /debug/pprof/, e.g. :8080 (default: disabled)")
+
+		// Statistics dump on SIGUSR1 (or GET /stats), without stopping the run (default: "" = stdout)
+		dumpFile = flag.String("dump-file", "", "File a statistics dump is written to on SIGUSR1, overwritten by every dump (default: stdout)")
+
+		// Self-profiling of the load generator: CPU profile of the run and heap profile at its end (default: "" = disabled)
+		cpuProfile = flag.String("cpu-profile", "", "Write a CPU profile of the load generator during the run to this file (default: none)")
+		memProfile = flag.String("mem-profile", "", "Write a heap profile of the load generator at the end of the run to this file (default: none)")
+
+		// Abort thresholds: the run stops with exit code 2 when exceeded (default: 0 = disabled)
+		maxErrorRate         = flag.Float64("max-error-rate", 0, "Abort the run when the fraction of failed queries and connection attempts exceeds this, e.g. 0.05 (default: 0, disabled)")
+		maxConsecutiveErrors = flag.Int("max-consecutive-errors", 0, "Abort the run after this many failed queries or connection attempts in a row across all workers (default: 0, disabled)")
+
+		// Per-tenant circuit breaker: stop sending a tenant's queries while its error rate is too high (default: 0 = disabled)
+		breakerErrorRate   = flag.Float64("breaker-error-rate", 0, "Open a tenant's circuit when this fraction of its last -breaker-window queries failed, e.g. 0.5 (default: 0, disabled)")
+		breakerWindow      = flag.Int("breaker-window", 20, "Number of a tenant's last queries the -breaker-error-rate is judged on (default: 20)")
+		breakerOpenSeconds = flag.Int("breaker-open-seconds", 5, "Seconds an open circuit rejects the tenant's queries before probing (default: 5)")
+		breakerProbes      = flag.Int("breaker-probes", 3, "Successful half-open probe queries that close a tenant's circuit again (default: 3)")
+		breakerTenants     = flag.String("breaker-tenants", "", "Tenants with a circuit breaker, e.g. 1-10,test0042 (default: all)")
+
+		// Process-wide cap on the queries outstanding at the same time (default: 0 = unlimited)
+		maxInflight = flag.Int("max-inflight", 0, "Most queries in flight at the same time over all tenants and workers, independent of the thread counts (default: 0, unlimited)")
+
+		// Connection multiplexing: a fixed pool of workers shared by all tenants instead of -threads-pre-db per tenant (default: 0 = dedicated workers)
+		sharedWorkers  = flag.Int("shared-workers", 0, "Workers shared by all tenants, picking the next tenant by weighted fair scheduling instead of -threads-pre-db dedicated workers per tenant (default: 0, dedicated workers)")
+		fairWeightFile = flag.String("fair-weight-file", "", "File of tenant weights for -shared-workers, one \"tenants weight\" per line (default: none, every tenant weighs 1)")
+
+		// Cancellation exercise: cancel a fraction of the in-flight queries (default: 0 = disabled)
+		cancelFraction  = flag.Float64("cancel-fraction", 0, "Fraction of the queries cancelled -cancel-after-ms after they were sent, e.g. 0.01 (default: 0, disabled)")
+		cancelAfterMs   = flag.Int("cancel-after-ms", 5, "Milliseconds after which a query picked by -cancel-fraction is cancelled (default: 5)")
+		cancelKillQuery = flag.Bool("cancel-kill-query", false, "Cancel with KILL QUERY from a control connection instead of the query's context (default: false)")
+		cancelTenants   = flag.String("cancel-tenants", "", "Tenants whose queries are cancelled, e.g. 1-10,test0042 (default: all)")
+
+		// Retries of deadlocks, lock wait timeouts and TiDB write conflicts (default: 0 = not retried)
+		txnRetries        = flag.Int("txn-retries", 0, "Run a statement or transaction again up to N times after a deadlock, lock wait timeout or TiDB write conflict/region error (default: 0, no retries)")
+		txnRetryBackoffMs = flag.Int("txn-retry-backoff-ms", 10, "Wait this many ms times the retry number before every -txn-retries retry (default: 10)")
+
+		// Chaos: every N seconds the tool kills a fraction of its own connections (default: 0 = disabled)
+		chaosKillIntervalSeconds = flag.Int("chaos-kill-interval-seconds", 0, "Kill a fraction of the tool's own connections every N seconds (default: 0, disabled)")
+		// Fraction of connections killed per chaos round (default: 0.1)
+		chaosKillFraction = flag.Float64("chaos-kill-fraction", 0.1, "Fraction of connections killed per chaos round (default: 0.1)")
+
+		// Connection max lifetime: workers drop their connection after it and establish a new one (default: 0 = never)
+		connMaxLifetimeSeconds = flag.Int("conn-max-lifetime-seconds", 0, "Drop every worker connection after N seconds and establish a new one, like a pool's maxLifetime (default: 0, never)")
+		connLifetimeJitter     = flag.Float64("conn-lifetime-jitter", 0.1, "Spread of the connection lifetimes as a fraction of -conn-max-lifetime-seconds (default: 0.1)")
+
+		// Client-side delay before sending each query, fixed part plus uniform jitter (default: 0 = disabled)
+		injectLatencyMs       = flag.Int("inject-latency-ms", 0, "Fixed client-side delay in ms before each query (default: 0)")
+		injectLatencyJitterMs = flag.Int("inject-latency-jitter-ms", 0, "Random extra client-side delay of 0..N ms before each query (default: 0)")
+		// Tenants the delay applies to, names or 1-based ranges, e.g. "1-3,test0007" (default: "" = all)
+		injectLatencyTenants = flag.String("inject-latency-tenants", "", "Tenants the injected delay applies to, e.g. 1-3,test0007 (default: all)")
+
+		// Online DDL churn: selected tenants periodically add/drop an index or column on their own tables
+		ddlIntervalSeconds = flag.Int("ddl-interval-seconds", 0, "Seconds between DDL statements of DDL churn tenants (default: 0, disabled)")
+		ddlTenants         = flag.String("ddl-tenants", "1", "Tenants running DDL churn, names or 1-based ranges (default: 1)")
+		ddlOps             = flag.String("ddl-ops", "index,column", "DDL churn operations: index and/or column (default: index,column)")
+
+		// Build every tenant's table list from information_schema instead of the table flags (default: false)
+		discoverTablesFlag = flag.Bool("discover-tables", false, "Query every tenant's tables, row counts and k ranges from its database instead of using the table flags (default: false)")
+		// Check the tenant tables in information_schema before starting: off, tables (exist) or rows (exist and are loaded) (default: rows)
+		verifySchemaLevel = flag.String("verify-schema", verifyRows, "Check the tenant tables before the run: off, tables (they exist) or rows (they exist and hold about the prepared rows) (default: rows)")
+		// What to do: run the workload, prepare (create and load) the tenant databases, or drop them (default: run)
+		mode = flag.String("mode", "run", "run, prepare (create and load tenant databases) or cleanup (drop them) (default: run)")
+		// Weighted query types of the workload, e.g. "point_select:90,payload_update:10" (default: point_select)
+		queryMixSpec = flag.String("query-mix", "point_select", "Weighted query types, e.g. point_select:90,payload_read:5,payload_update:5 (default: point_select)")
+		// Width of the k range scanned by the sum_range and group_by_prefix query types (default: 100, as sysbench)
+		rangeSize = flag.Int("range-size", 100, "Width of the k range of the sum_range and group_by_prefix query types (default: 100)")
+		// Ids per statement of the batch_point_get query type (default: 10)
+		batchPointGetIDs = flag.Int("batch-point-get-size", 10, "Ids looked up by one batch_point_get statement, SELECT ... WHERE id IN (...) (default: 10)")
+		// Sizes of the c and pad payload columns (default: 120 and 60, as sysbench)
+		cSize   = flag.Int("c-size", 120, "Length of column c; above 2048 a TEXT/BLOB type is used (default: 120)")
+		padSize = flag.Int("pad-size", 60, "Length of column pad; above 2048 a TEXT/BLOB type is used (default: 60)")
+		// Column type family of c and pad: text (VARCHAR/TEXT) or blob (VARBINARY/BLOB) (default: text)
+		payloadType = flag.String("payload-type", "text", "Column type family of c and pad: text or blob (default: text)")
+		// Add a JSON document column with an indexed generated column, for the json_* query types (default: false)
+		jsonColumn = flag.Bool("json-column", false, "Add a JSON column doc (and an indexed generated column) in prepare mode, required by json_* query types (default: false)")
+		// Add an indexed generated column derived from c, for the gen_* query types (default: false)
+		generatedColumn = flag.Bool("generated-column", false, "Add the indexed generated column c_prefix = LEFT(c, 4) in prepare mode, required by gen_* query types (default: false)")
+		// Child table of every table with a foreign key, used by the fk_parent_child query type (default: false)
+		foreignKeys = flag.Bool("foreign-keys", false, "Create a child table with a foreign key to every table in prepare mode, required by the fk_parent_child query type (default: false)")
+		// Stored procedure of every table, called by the proc_call query type (default: false)
+		storedProcedures = flag.Bool("stored-procedures", false, "Create the stored procedure of every table in prepare mode, required by the proc_call query type (default: false)")
+		// Primary key kinds of the tenants' tables in TiDB, to compare clustered and non-clustered keys in one run (default: "" = server default)
+		clusteredTenants    = flag.String("clustered-tenants", "", "Tenants whose tables get a clustered primary key in prepare mode, names or 1-based ranges (default: none)")
+		nonclusteredTenants = flag.String("nonclustered-tenants", "", "Tenants whose tables get a non-clustered primary key in prepare mode, names or 1-based ranges (default: none)")
+		// Views over every table and a join view per tenant, read through instead of the base tables by -query-views (default: false)
+		views      = flag.Bool("views", false, "Create the view sbtestN_v of every table and the join view sbtest_join_v of every tenant in prepare mode (default: false)")
+		queryViews = flag.Bool("query-views", false, "Read through the views created by -views instead of the base tables (default: false)")
+		// Adaptive concurrency: keep every tenant's p99 under this target by adjusting its active workers (default: 0 = disabled)
+		aimdTargetP99Ms = flag.Int("aimd-target-p99-ms", 0, "Adapt each tenant's active workers (1..threads-pre-db) to keep its p99 under this many ms (default: 0, disabled)")
+		// Adjustment interval and multiplicative decrease of the adaptive concurrency controller
+		aimdIntervalSeconds = flag.Int("aimd-interval-seconds", 5, "Seconds between adaptive concurrency adjustments (default: 5)")
+		aimdDecreaseFactor  = flag.Float64("aimd-decrease-factor", 0.5, "Factor applied to a tenant's active workers when its p99 is over the target (default: 0.5)")
+		// Latency-feedback backoff: slow the workers down while p99 is over a threshold (default: 0 = disabled)
+		governorP99Ms           = flag.Int("governor-p99-ms", 0, "Back the issue rate off while the p99 latency is over this many ms, and restore it as latency recovers (default: 0, disabled)")
+		governorScope           = flag.String("governor-scope", "tenant", "Scope of -governor-p99-ms: tenant (every tenant by its own p99) or global (all tenants by the overall p99) (default: tenant)")
+		governorIntervalSeconds = flag.Int("governor-interval-seconds", 5, "Seconds between backoff governor adjustments (default: 5)")
+		governorBackoffFactor   = flag.Float64("governor-backoff-factor", 0.5, "Factor applied to the issue rate when the p99 is over -governor-p99-ms (default: 0.5)")
+		governorRecoveryStep    = flag.Float64("governor-recovery-step", 0.1, "Fraction of the full issue rate restored per interval while the p99 is under -governor-p99-ms (default: 0.1)")
+		governorMinRate         = flag.Float64("governor-min-rate", 0.05, "Lowest issue rate the governor backs off to, as a fraction of the full rate (default: 0.05)")
+		// EXPLAIN a random fraction of the generated queries and append the plans to -explain-file (default: 0 = disabled)
+		explainSampleRate = flag.Float64("explain-sample-rate", 0, "Fraction of generated queries to EXPLAIN, e.g. 0.001 (default: 0, disabled)")
+		explainFile       = flag.String("explain-file", "explain.log", "File the sampled plans are written to (default: explain.log)")
+		explainAnalyze    = flag.Bool("explain-analyze", false, "Use EXPLAIN ANALYZE for sampled reads (default: false)")
+		// Tenant tiers with per-tenant QPS ceilings, bursts and priorities (default: "" = no tiers)
+		tierFile = flag.String("tier-file", "", "File of tenant tiers, one \"name qps burst priority tenants\" per line (default: none)")
+		// Platform-wide admission rate shared by all tenants, served by tier priority (default: 0 = unlimited)
+		admissionQPS = flag.Float64("admission-qps", 0, "Platform-wide QPS admitted to the database, higher priority tiers first (default: 0, unlimited)")
+		// Aggregate QPS ceiling of all tenants together, on top of their own rates (default: 0 = unlimited)
+		globalQPS = flag.Float64("global-qps", 0, "Total QPS of all tenants together, admitted in arrival order on top of the per-tenant rates (default: 0, unlimited)")
+		// Fraction below its QPS target (tier, QPS sweep step or scenario cap) at which a tenant misses the target
+		targetTolerance = flag.Float64("target-tolerance", 0.05, "Fraction below its QPS target at which a rate limited tenant counts as missing it (default: 0.05)")
+		// Tenants served by ClickHouse (HTTP interface) with their own analytical query mix (default: "" = none)
+		clickhouseTenantSpec = flag.String("clickhouse-tenants", "", "Tenants served by ClickHouse, names or 1-based ranges (default: none)")
+		clickhouseDSN        = flag.String("clickhouse-dsn", "http://default:@127.0.0.1:8123/", "ClickHouse HTTP DSN prefix, the tenant's database is appended (default: http://default:@127.0.0.1:8123/)")
+		clickhouseQueryMix   = flag.String("clickhouse-query-mix", "analytic_agg:1,analytic_topn:1", "Weighted query types of ClickHouse tenants (default: analytic_agg:1,analytic_topn:1)")
+		// HTAP isolation on TiDB: AP tenants read from TiFlash, all others from TiKV (default: "" = disabled)
+		tiflashTenants   = flag.String("tiflash-tenants", "", "AP tenants reading from TiFlash while all others read from TiKV, names or 1-based ranges (default: none)")
+		tiflashIsolation = flag.String("tiflash-isolation", "hint", "How reads are pinned to the engine: hint (READ_FROM_STORAGE on analytic queries) or session (tidb_isolation_read_engines) (default: hint)")
+		// Historical reads: tenants reading snapshots of the past with SET TRANSACTION READ ONLY AS OF TIMESTAMP (default: "" = none)
+		historicalReadFile = flag.String("historical-read-file", "", "File of historical-read tenants, one \"tenants staleness\" entry per line, staleness a duration or \"start\" for the start of the run (default: none)")
+		// Optimizer hints added to the generated queries per tenant and query type (default: "" = none)
+		hintFile = flag.String("hint-file", "", "File of optimizer hints, one \"tenants query_type hint\" per line (default: none)")
+		// Routing comments for proxies (ProxySQL query rules, Vitess directives) per tenant and query type (default: "" = none)
+		annotationFile = flag.String("annotation-file", "", "File of routing comments, one \"tenants query_type prefix|inline annotation\" per line (default: none)")
+		// Statements run on every connection a worker takes, for all tenants and per tenant from a file (default: none)
+		sessionInitFile = flag.String("session-init-file", "", "File of per-tenant session init statements, one \"tenants statement\" per line (default: none)")
+		// Per-tenant character sets and collations of the tenant databases, tables and connections (default: "" = server defaults)
+		collationFile = flag.String("collation-file", "", "File of tenant collations, one \"tenants collation\" per line, e.g. \"1-3 utf8mb4_bin\" (default: none)")
+		// Per-tenant database users from a template and/or a "tenant user [password]" file (default: "" = the DSN's user)
+		tenantUser         = flag.String("tenant-user", "", "User name template of every tenant, \"{tenant}\" is replaced by the tenant name, e.g. {tenant}_app (default: the DSN's user)")
+		tenantPassword     = flag.String("tenant-password", "", "Password template of the tenant users, \"{tenant}\" is replaced by the tenant name (default: empty)")
+		tenantUserFile     = flag.String("tenant-user-file", "", "File of tenant users, one \"tenant user [password]\" entry per line, overriding -tenant-user (default: none)")
+		tenantUserAccounts = flag.Int("tenant-user-accounts", 1, "Accounts of every tenant of -tenant-user, \"{n}\" in the templates is replaced by 1..N; connections rotate among them (default: 1)")
+		tenantMaxUserConns = flag.Int("tenant-max-user-connections", 0, "MAX_USER_CONNECTIONS of the tenant users created by -prepare-users (default: 0, unlimited)")
+		prepareUsers       = flag.Bool("prepare-users", false, "Create the tenant users in prepare mode, granted only their tenant's database, and drop them in cleanup mode (default: false)")
+		// Database backend: mysql (MySQL/TiDB), or mock to run without any database (default: mysql)
+		backend = flag.String("backend", "mysql", "Backend: mysql, or mock to simulate a database for offline development (default: mysql)")
+		// Simulated latency and error rate of the mock backend
+		mockLatencyMs       = flag.Int("mock-latency-ms", 1, "Latency in ms of every statement on the mock backend (default: 1)")
+		mockLatencyJitterMs = flag.Int("mock-latency-jitter-ms", 2, "Random extra latency of 0..N ms on the mock backend (default: 2)")
+		mockErrorRate       = flag.Float64("mock-error-rate", 0, "Fraction of statements failing on the mock backend (default: 0)")
+		// Log throughput and latency per query type every N seconds (default: 0 = disabled)
+		reportIntervalSeconds = flag.Int("report-interval-seconds", 0, "Log throughput and latency per query type every N seconds (default: 0, disabled)")
+		// Per-second time series of every tenant as CSV (default: "" = not written)
+		timeSeriesFile            = flag.String("timeseries-file", "", "Append the QPS, errors and p50/p95/p99 of every tenant per interval to this CSV file (default: none)")
+		timeSeriesIntervalSeconds = flag.Int("timeseries-interval-seconds", 1, "Interval of the -timeseries-file rows in seconds (default: 1)")
+		// Server-side values added to every -timeseries-file row (default: "" = none)
+		serverStatus       = flag.String("server-status", "", "Comma-separated SHOW GLOBAL STATUS variables and TiDB metrics_schema tables sampled into -timeseries-file, e.g. Threads_running,metrics_schema.tidb_qps (default: none)")
+		serverStatusTenant = flag.String("server-status-tenant", "", "Tenant whose server is sampled by -server-status, -processlist-file and -plan-drift-interval-seconds (default: the first tenant)")
+		// Processlist snapshots of the server as CSV (default: "" = not written)
+		processlistFile            = flag.String("processlist-file", "", "Write the non-idle sessions of the server every -processlist-interval-seconds to this CSV file (default: none)")
+		processlistIntervalSeconds = flag.Int("processlist-interval-seconds", 10, "Interval of the -processlist-file snapshots in seconds (default: 10)")
+		processlistCluster         = flag.Bool("processlist-cluster", false, "Snapshot information_schema.cluster_processlist, the sessions of every TiDB instance, instead of processlist (default: false)")
+		// Plan drift detection from the TiDB statement summary (default: 0 = disabled)
+		planDriftIntervalSeconds = flag.Int("plan-drift-interval-seconds", 0, "Sample the TiDB statement summary every N seconds and flag digests whose plan changed during the run (default: 0, disabled)")
+		// Quiet windows: all tenants drop to minimal load at the end of every period, as a latency baseline (default: 0 = none)
+		quietSeconds       = flag.Int("quiet-seconds", 0, "Length of the quiet window at the end of every -quiet-period-seconds, with minimal load on all tenants (default: 0, none)")
+		quietPeriodSeconds = flag.Int("quiet-period-seconds", 300, "Period of the quiet windows in seconds (default: 300)")
+		quietWorkers       = flag.Int("quiet-workers", 1, "Active workers per tenant during a quiet window (default: 1)")
+		// GC pressure: tenants constantly rewriting the same few rows to pile up MVCC versions (default: "" = none)
+		gcPressureTenants   = flag.String("gc-pressure-tenants", "", "Tenants only rewriting a hot key range to pile up MVCC versions, names or 1-based ranges (default: none)")
+		gcPressureRate      = flag.Float64("gc-pressure-rate", 200, "Rewrite statements per second of every GC-pressure tenant, 0 = unlimited (default: 200)")
+		gcPressureTables    = flag.Int("gc-pressure-tables", 1, "Tables rewritten by a GC-pressure tenant, its first ones (default: 1)")
+		gcPressureRows      = flag.Int("gc-pressure-rows", 100, "Hot key range of the rewritten tables, ids 1..N (default: 100)")
+		gcPressureBatchRows = flag.Int("gc-pressure-batch-rows", 10, "Consecutive rows rewritten by one statement (default: 10)")
+		// Bank transfers: tenants moving balance between rows in transactions and checking that the total never changes (default: "" = none)
+		bankTenants              = flag.String("bank-tenants", "", "Tenants only running bank transfers and checking their invariant, names or 1-based ranges (default: none)")
+		bankTables               = flag.Int("bank-tables", 1, "Tables of the accounts of a bank tenant, its first ones (default: 1)")
+		bankMaxAmount            = flag.Int("bank-max-amount", 100, "Largest amount moved by one bank transfer (default: 100)")
+		bankCheckIntervalSeconds = flag.Int("bank-check-interval-seconds", 10, "Seconds between the checks of the total balance of a bank tenant (default: 10)")
+		// Order entry: tenants running TPC-C-like transactions on their own schema created by prepare mode (default: "" = none)
+		orderEntryTenants   = flag.String("order-entry-tenants", "", "Tenants only running order-entry transactions, names or 1-based ranges (default: none)")
+		orderEntryDistricts = flag.Int("order-entry-districts", 10, "Districts of the order-entry schema of a tenant (default: 10)")
+		orderEntryCustomers = flag.Int("order-entry-customers", 300, "Customers per district of the order-entry schema (default: 300)")
+		orderEntryItems     = flag.Int("order-entry-items", 10000, "Items of the order-entry schema of a tenant (default: 10000)")
+		orderEntryMix       = flag.String("order-entry-mix", "oe_new_order:45,oe_payment:43,oe_order_status:12", "Weighted transactions of order-entry tenants (default: oe_new_order:45,oe_payment:43,oe_order_status:12)")
+		// Ingestion: tenants appending timestamped rows to a table partitioned by day, like logging or metrics tenants (default: "" = none)
+		ingestTenants       = flag.String("ingest-tenants", "", "Tenants only ingesting time-series rows and querying recent windows, names or 1-based ranges (default: none)")
+		ingestBatchRowsFlag = flag.Int("ingest-batch-rows", 10, "Rows appended by one ingest_insert statement (default: 10)")
+		ingestSeries        = flag.Int("ingest-series", 1000, "Distinct series ids of the ingested rows (default: 1000)")
+		ingestWindowSeconds = flag.Int("ingest-window-seconds", 60, "Recent window aggregated by ingest_recent, in seconds (default: 60)")
+		ingestPartitionDays = flag.Int("ingest-partition-days", 7, "Daily partitions created ahead by prepare mode for ingestion tenants (default: 7)")
+		ingestMix           = flag.String("ingest-mix", "ingest_insert:9,ingest_recent:1", "Weighted query types of ingestion tenants (default: ingest_insert:9,ingest_recent:1)")
+		// TTL tables: tenants inserting rows TiDB deletes once they expire, to evaluate TTL job interference (default: "" = none)
+		ttlTenants         = flag.String("ttl-tenants", "", "Tenants only inserting expiring rows into TiDB TTL tables, names or 1-based ranges (default: none)")
+		ttlTableNum        = flag.Int("ttl-tables", 1, "TTL tables created per TTL tenant by prepare mode (default: 1)")
+		ttlLifetimeSeconds = flag.Int("ttl-lifetime-seconds", 600, "Mean lifetime of the rows inserted by TTL tenants in seconds, spread over ±50% (default: 600)")
+		ttlJobInterval     = flag.String("ttl-job-interval", "1h", "TTL_JOB_INTERVAL of the TTL tables, e.g. 10m, 1h or 1d (default: 1h)")
+		// Pagination: tenants walking their tables page by page with OFFSET or keyset pagination (default: "" = none)
+		paginationTenants  = flag.String("pagination-tenants", "", "Tenants paging through their tables, names or 1-based ranges (default: none)")
+		paginationMode     = flag.String("pagination-mode", "offset", "How pagination tenants page: offset (LIMIT x OFFSET y) or keyset (WHERE id > ? LIMIT x) (default: offset)")
+		paginationPageSize = flag.Int("pagination-page-size", 100, "Rows per page of pagination tenants (default: 100)")
+		// Scan hogs: tenants periodically running unindexed full scans of their big tables (default: 0 = none)
+		scanHogIntervalSeconds = flag.Int("scan-hog-interval-seconds", 0, "Seconds between the full table scans of every scanner of the scan hog tenants (default: 0, disabled)")
+		scanHogTenants         = flag.String("scan-hog-tenants", "1", "Scan hog tenants, names or 1-based ranges (default: 1)")
+		scanHogConcurrency     = flag.Int("scan-hog-concurrency", 1, "Concurrent scanners per scan hog tenant (default: 1)")
+		// Range deletes: tenants running a data retention job deleting id ranges in chunks (default: 0 = none)
+		rangeDeleteChunkRows = flag.Int("range-delete-chunk-rows", 0, "Rows deleted by one DELETE ... LIMIT n of the retention job of range delete tenants (default: 0, disabled)")
+		rangeDeleteTenants   = flag.String("range-delete-tenants", "1", "Tenants running a retention job, names or 1-based ranges (default: 1)")
+		rangeDeleteSpanRows  = flag.Int("range-delete-span-rows", 10000, "Ids of the range the retention job deletes chunk by chunk before moving on (default: 10000)")
+		rangeDeletePaceMs    = flag.Int("range-delete-pace-ms", 1000, "Pause between two chunks of the retention job in milliseconds (default: 1000)")
+		// Data growth: tenants whose tables grow during the run, optionally re-analyzed at an interval (default: 0 = none)
+		growthRowsPerSecond  = flag.Float64("growth-rows-per-second", 0, "Rows per second appended to the tables of every growth tenant, one table after the other (default: 0, disabled)")
+		growthTenants        = flag.String("growth-tenants", "1", "Tenants whose tables grow, names or 1-based ranges (default: 1)")
+		growthBatchRows      = flag.Int("growth-batch-rows", 100, "Rows per INSERT of the growth job (default: 100)")
+		growthAnalyzeSeconds = flag.Int("growth-analyze-interval-seconds", 0, "Seconds between two ANALYZE TABLE rounds over the tables of every growth tenant (default: 0, never)")
+		// Partition roll: tenants adding a range partition and dropping the oldest of their small partition tables (default: 0 = none)
+		partitionRollIntervalSeconds = flag.Int("partition-roll-interval-seconds", 0, "Seconds between the partition rolls of partition roll tenants, which need -partition-scheme range (default: 0, disabled)")
+		partitionRollTenants         = flag.String("partition-roll-tenants", "1", "Tenants rolling the partitions of their small partition tables, names or 1-based ranges (default: 1)")
+		// Long transactions: tenants keeping a transaction open in the background, like a badly-behaved app (default: 0 = none)
+		longTxnHoldSeconds = flag.Int("long-txn-hold-seconds", 0, "Seconds the transactions of long transaction tenants are held open (default: 0, disabled)")
+		longTxnTenants     = flag.String("long-txn-tenants", "1", "Tenants keeping a long transaction open, names or 1-based ranges (default: 1)")
+		longTxnRows        = flag.Int("long-txn-rows", 100, "Consecutive rows read by a long transaction (default: 100)")
+		longTxnForUpdate   = flag.Bool("long-txn-for-update", false, "Lock the rows read by a long transaction with SELECT ... FOR UPDATE (default: false)")
+		longTxnEnd         = flag.String("long-txn-end", "rollback", "How a long transaction ends after the hold time: commit or rollback (default: rollback)")
+		// Sweep mode: repeat the workload at increasing load steps and report a throughput-vs-latency curve (default: "" = disabled)
+		sweepSteps       = flag.String("sweep-steps", "", "Run the workload at these percentages of the maximum load in turn, e.g. 10,25,50,100, and report each step (default: disabled)")
+		sweepBy          = flag.String("sweep-by", "concurrency", "What a sweep step limits: concurrency (of -threads-pre-db) or qps (of -sweep-max-qps) (default: concurrency)")
+		sweepMaxQPS      = flag.Float64("sweep-max-qps", 0, "QPS per tenant at 100% of a -sweep-by=qps sweep (default: 0)")
+		sweepStepSeconds = flag.Int("sweep-step-seconds", 60, "Seconds of every sweep step; the sweep replaces -testing-time-seconds (default: 60)")
+		sweepFile        = flag.String("sweep-file", "", "Write the throughput-vs-latency curve of a sweep to this CSV file (default: none)")
+		// Named phases with per-tenant load overrides, run in order and reported per phase (default: "" = one plain run)
+		scenarioFile = flag.String("scenario-file", "", "File of named phases (warmup, steady, burst, ...) with per-tenant overrides; replaces -testing-time-seconds (default: none)")
+		// Final per-tenant report as JSON (default: "" = not written)
+		summaryJSONFile = flag.String("summary-json-file", "", "Write the final per-tenant summary as JSON to this file (default: none)")
+		// Resolved flags and input files written at start (default: "" = next to -summary-json-file, if any)
+		configSnapshotFile = flag.String("config-snapshot-file", "", "Write the resolved flags and the content of the input files as JSON to this file at start (default: <summary>.config.json with -summary-json-file)")
+		// Window length of the interference report, comparing latency while other tenants burst vs. are quiet (default: 5, 0 = disabled)
+		interferenceWindowSeconds = flag.Int("interference-window-seconds", 5, "Window length in seconds of the tenant interference report (default: 5, 0 = disabled)")
+		// Tenancy model: own database per tenant (db), own tables in a shared database (schema)
+		// or shared tables with a tenant_id column (row) (default: db)
+		tenancyKind = flag.String("tenancy-model", "db", "Tenancy model: db (database per tenant), schema (tables per tenant) or row (tenant_id column) (default: db)")
+		// Database of reference tables read by all tenants, created by prepare mode (default: "" = none)
+		commonDBName = flag.String("common-db", "", "Database of reference tables shared by all tenants, e.g. common, created by prepare mode and read by the common_* query types (default: none)")
+		// Database shared by all tenants in the schema and row tenancy models (default: tenants)
+		sharedDB = flag.String("shared-db", "tenants", "Database shared by all tenants in the schema and row tenancy models (default: tenants)")
+		// Schema profiles of tenant classes: row widths, extra indexes and table counts (default: "" = the schema of the flags)
+		schemaProfileFile = flag.String("schema-profile-file", "", "File of tenant schema profiles, one \"tenants setting...\" per line, e.g. \"1-10 c_size=1024 extra_indexes=2\" (default: none)")
+		// Partitions of each small partition table (default: 372)
+		partitionsPerTable = flag.Int("partitions-per-table", 372, "Partitions of each small partition table in prepare mode (default: 372)")
+		// Partitioning of the small partition tables: hash of k, or id ranges that can be rolled (default: hash)
+		partitionScheme = flag.String("partition-scheme", "hash", "Partitioning of the small partition tables: hash (HASH (k)) or range (RANGE (id), needed by -partition-roll-interval-seconds) (default: hash)")
+		// Concurrent table loaders and rows per INSERT in prepare mode, also used for onboarded tenants
+		prepareThreads   = flag.Int("prepare-threads", 8, "Concurrent table loaders in prepare mode and of an onboarded tenant (default: 8)")
+		prepareBatchRows = flag.Int("prepare-batch-rows", 1000, "Rows per INSERT statement in prepare mode and of an onboarded tenant (default: 1000)")
 	)
+	// Driver parameters added to every DSN, e.g. -dsn-param interpolateParams=true -dsn-param timeout=5s
+	var dsnParams stringList
+	flag.Var(&dsnParams, "dsn-param", "Driver parameter key=value added to every tenant DSN, may be repeated")
+	// Statements run on every connection of every tenant, e.g. -session-init "SET SESSION time_zone = '+00:00'"
+	var sessionInitStatements stringList
+	flag.Var(&sessionInitStatements, "session-init", "Statement run on every connection of every tenant, e.g. \"SET SESSION time_zone = '+00:00'\", may be repeated")
 	flag.Parse()
+	// Flags not given on the command line may come from WORKLOAD_* environment variables.
+	if err := applyEnvFlags(flag.CommandLine); err != nil {
+		log.Fatalf("[ERROR] %v", err)
+	}
+	// Credentials are scrubbed from everything logged from now on.
+	scrubber := scrubLogs()
 
-	var exitTime = time.Now().Add(time.Second * time.Duration(*testingTimeSeconds))
+	log.Printf("[INFO] Random seed %d", setRunSeed(*seed))
+	if *runID == "" {
+		*runID = newRunID()
+	}
+	run := newRunMetadata(*runID, flag.CommandLine)
+	log.Printf("[INFO] tidb-workload %s, run ID %s, started %s", run.Version, run.RunID, run.StartTime.Format(time.RFC3339))
+	if flags := run.ChangedFlags(); flags != "" {
+		log.Printf("[INFO] Flags: %s", flags)
+	}
+	if path := configSnapshotPath(*configSnapshotFile, *summaryJSONFile); path != "" {
+		if err := writeConfigSnapshot(path, run, flag.CommandLine); err != nil {
+			log.Fatalf("[ERROR] Failed to write configuration snapshot %s: %v", path, err)
+		}
+		log.Printf("[INFO] Configuration written to %s", path)
+	}
+	workerLog.Configure(*logSampleLimit, time.Duration(*logSampleIntervalSeconds)*time.Second)
+	logCtx, stopLogSampling := context.WithCancel(context.Background())
+	defer stopLogSampling()
+	go workerLog.Run(logCtx)
+
+	switch *backend {
+	case "mysql":
+	case "mock":
+		// Offline development: no database is contacted, statements only take the simulated latency.
+		sqlDriverName = "mock"
+		mockSettings.latency = time.Duration(*mockLatencyMs) * time.Millisecond
+		mockSettings.jitter = time.Duration(*mockLatencyJitterMs) * time.Millisecond
+		mockSettings.errorRate = *mockErrorRate
+		log.Printf("[INFO] Using the mock backend, no database is contacted")
+	default:
+		log.Fatalf("[ERROR] Unknown -backend %q (want mysql or mock)", *backend)
+	}
+
+	// Tenants in the mapping file get their own DSN, the others use the -dsn prefix.
+	if *dsnA != "" {
+		*dsn = *dsnA
+	}
+	if *writeDSN != "" {
+		*dsn = *writeDSN
+	}
+	dsns := newDSNResolver(*dsn)
+	dsns.SetReadPrefix(*readDSN)
+	if *dsnMapFile != "" {
+		if err := dsns.LoadMapping(*dsnMapFile); err != nil {
+			log.Fatalf("[ERROR] Failed to load DSN mapping file: %v", err)
+		}
+	}
+	var regions *tenantRegions
+	if *regionFile != "" {
+		var err error
+		if regions, err = loadRegionFile(*regionFile); err != nil {
+			log.Fatalf("[ERROR] Failed to load region file: %v", err)
+		}
+		dsns.SetRegions(regions)
+	}
+	// Protocol compression is the driver's compress parameter, after -dsn-param so it wins over compress=false.
+	if *compress {
+		dsnParams = append(dsnParams, "compress=true")
+	}
+	if err := dsns.SetParams(dsnParams); err != nil {
+		log.Fatalf("[ERROR] %v", err)
+	}
+	dsns.SetSocket(*socket)
+	password, err := loadPassword(*passwordFile)
+	if err != nil {
+		log.Fatalf("[ERROR] Failed to read -password-file: %v", err)
+	}
+	scrubber.AddSecret(password)
+	dsns.SetPassword(password)
+
+	// Tenants with their own user connect as that user while running; prepare and cleanup
+	// keep the DSN's (administrative) user, which creates and drops the tenant users.
+	users, err := newTenantUsers(*tenantUser, *tenantPassword, *tenantUserFile, *tenantUserAccounts, *tenantMaxUserConns, *prepareUsers)
+	if err != nil {
+		log.Fatalf("[ERROR] Invalid tenant user settings: %v", err)
+	}
+	if *mode == "run" {
+		dsns.SetUsers(users)
+	}
+
+	if *replayFile != "" {
+		exitTime := time.Now().Add(time.Second * time.Duration(*testingTimeSeconds))
+		log.Printf("[INFO] Replaying %s (%s) at speed %g with %d thread(s) per DB ...\n", *replayFile, *replayFormat, *replaySpeed, *threadsPerDB)
+		if err := runReplay(*replayFile, *replayFormat, *replaySpeed, dsns, *threadsPerDB, exitTime); err != nil {
+			log.Fatalf("[ERROR] Replay failed: %v", err)
+		}
+		return
+	}
+
+	// Where the tenants' data lives: own databases (default), own tables or own rows in a shared database.
+	tenancy, err := newTenancyModel(*tenancyKind, *sharedDB)
+	if err != nil {
+		log.Fatalf("[ERROR] %v", err)
+	}
+	if tenancy.kind != "db" {
+		dsns.SetSharedDatabase(tenancy.sharedDB)
+	}
+
+	// Tenant databases: test0001 ~ test0010 by default.
+	tenantNames := make([]string, 0, *dbNum)
+	for dbIndex := 1; dbIndex <= *dbNum; dbIndex++ {
+		tenantNames = append(tenantNames, tenantName(dbIndex)) // e.g. test0001, test0002, etc.
+	}
+	for _, tenant := range tenantNames {
+		if users.Applies(tenant) {
+			for i := 0; i < users.Accounts(tenant); i++ {
+				scrubber.AddSecret(users.Password(tenant, i))
+			}
+		}
+	}
+	// Several processes can split a fleet, or a single tenant can be debugged on its own.
+	selected, err := parseTenantSet(*tenantSelection)
+	if err != nil {
+		log.Fatalf("[ERROR] Invalid -tenants: %v", err)
+	}
+	if selected != nil {
+		var unknown []string
+		tenantNames, unknown = selected.Select(tenantNames)
+		if len(unknown) > 0 {
+			log.Fatalf("[ERROR] -tenants selects tenant(s) beyond -db-num %d: %s", *dbNum, abbreviate(unknown, 5))
+		}
+		log.Printf("[INFO] Driving %d of %d tenant(s): %s", len(tenantNames), *dbNum, abbreviate(tenantNames, 5))
+	}
+
+	// Tenants served by ClickHouse get their own DSN prefix and query mix.
+	var clickhouse *clickhouseTenants
+	if *clickhouseTenantSpec != "" {
+		chTenants, err := parseTenantSet(*clickhouseTenantSpec)
+		if err != nil {
+			log.Fatalf("[ERROR] Invalid -clickhouse-tenants: %v", err)
+		}
+		chMix, err := parseQueryMix(*clickhouseQueryMix)
+		if err == nil {
+			err = chMix.CheckClickHouse()
+		}
+		if err != nil {
+			log.Fatalf("[ERROR] Invalid -clickhouse-query-mix: %v", err)
+		}
+		clickhouse = &clickhouseTenants{tenants: chTenants, prefix: *clickhouseDSN, mix: chMix}
+		dsns.SetClickHouse(clickhouse)
+	}
+
+	// HTAP isolation: prepare mode only creates the TiFlash replicas, the engines are pinned while running.
+	tiflash, err := newTiFlashIsolation(*tiflashTenants, *tiflashIsolation)
+	if err != nil {
+		log.Fatalf("[ERROR] Invalid -tiflash-tenants/-tiflash-isolation: %v", err)
+	}
+	if *mode == "run" {
+		dsns.SetTiFlash(tiflash)
+	}
+
+	// Session initialization: the statements run on every connection the workers take.
+	if connSessionInit, err = newSessionInit(sessionInitStatements, *sessionInitFile); err != nil {
+		log.Fatalf("[ERROR] Failed to load session init file: %v", err)
+	}
+
+	// Collations: prepare mode creates the databases and tables with them, every mode connects with them.
+	var collations *tenantCollations
+	if *collationFile != "" {
+		if collations, err = loadCollationFile(*collationFile); err != nil {
+			log.Fatalf("[ERROR] Failed to load collation file: %v", err)
+		}
+		dsns.SetCollations(collations)
+	}
+
+	// Order entry: prepare mode creates the schema of the order-entry tenants, run mode runs their transactions.
+	orderEntryTenantSet, err := parseTenantSet(*orderEntryTenants)
+	if err != nil {
+		log.Fatalf("[ERROR] Invalid -order-entry-tenants: %v", err)
+	}
+	orderEntry, err := newOrderEntry(orderEntryTenantSet, *orderEntryDistricts, *orderEntryCustomers, *orderEntryItems, *orderEntryMix)
+	if err != nil {
+		log.Fatalf("[ERROR] Invalid order-entry settings: %v", err)
+	}
+
+	// Ingestion: prepare mode creates the events table of the ingestion tenants, run mode appends to it.
+	ingestTenantSet, err := parseTenantSet(*ingestTenants)
+	if err != nil {
+		log.Fatalf("[ERROR] Invalid -ingest-tenants: %v", err)
+	}
+	if *ingestBatchRowsFlag < 1 {
+		log.Fatalf("[ERROR] Invalid -ingest-batch-rows %d (want at least 1)", *ingestBatchRowsFlag)
+	}
+	ingestBatchRows = *ingestBatchRowsFlag
+	ingest, err := newIngestion(ingestTenantSet, *ingestSeries, time.Duration(*ingestWindowSeconds)*time.Second, *ingestPartitionDays, *ingestMix)
+	if err != nil {
+		log.Fatalf("[ERROR] Invalid ingestion settings: %v", err)
+	}
+
+	// TTL tables: prepare mode creates the TTL tables of the TTL tenants, run mode inserts expiring rows.
+	ttlTenantSet, err := parseTenantSet(*ttlTenants)
+	if err != nil {
+		log.Fatalf("[ERROR] Invalid -ttl-tenants: %v", err)
+	}
+	ttl, err := newTTLTables(ttlTenantSet, *ttlTableNum, time.Duration(*ttlLifetimeSeconds)*time.Second, *ttlJobInterval)
+	if err != nil {
+		log.Fatalf("[ERROR] Invalid TTL settings: %v", err)
+	}
+
+	if err := dsns.Validate(tenantNames); err != nil {
+		log.Fatalf("[ERROR] Invalid DSN: %v", err)
+	}
+
+	// A/B comparison: cluster B gets the same tenants and settings under its own DSN prefix.
+	var dsnsB *dsnResolver
+	if *dsnB != "" {
+		dsnsB = dsns.WithPrefix(*dsnB)
+		if err := dsnsB.Validate(tenantNames); err != nil {
+			log.Fatalf("[ERROR] Invalid -dsn-b: %v", err)
+		}
+	}
+	ab, err := newABComparison(*abMode, dsnsB)
+	if err != nil {
+		log.Fatalf("[ERROR] Invalid -ab-mode: %v", err)
+	}
+	if ab != nil && *clusterRole != "" {
+		log.Fatalf("[ERROR] -dsn-b can't be combined with -cluster-role")
+	}
+	if ab != nil && *sweepSteps != "" {
+		log.Fatalf("[ERROR] -dsn-b can't be combined with -sweep-steps")
+	}
+	if ab != nil && *scenarioFile != "" {
+		log.Fatalf("[ERROR] -dsn-b can't be combined with -scenario-file")
+	}
 
 	// Prepare table information (big tables, small tables, small partition tables).
-	tables := prepareTables(*bigTableNum, *rowsPerBigTable,
+	tableNames, err := parseTableNameTemplate(*tableNameFormat)
+	if err != nil {
+		log.Fatalf("[ERROR] Invalid -table-name-template: %v", err)
+	}
+	tables := prepareTables(tableNames, *bigTableNum, *rowsPerBigTable,
 		*smallTableNum, *rowsPerSmallTable,
 		*smallPartitionTableNum, *rowsPerSmallPartitionTable)
+	var profiles *schemaProfiles
+	if *schemaProfileFile != "" {
+		if tenancy.kind == "row" {
+			log.Fatalf("[ERROR] -schema-profile-file can't be used with the row tenancy model, whose tables are shared")
+		}
+		counts := [3]int{*bigTableNum, *smallTableNum, *smallPartitionTableNum}
+		if profiles, err = loadSchemaProfileFile(*schemaProfileFile, counts, func(big, small, partition int) []TableInfo {
+			return prepareTables(tableNames, big, *rowsPerBigTable, small, *rowsPerSmallTable, partition, *rowsPerSmallPartitionTable)
+		}); err != nil {
+			log.Fatalf("[ERROR] Invalid -schema-profile-file: %v", err)
+		}
+	}
+
+	mix, err := parseQueryMix(*queryMixSpec)
+	if err != nil {
+		log.Fatalf("[ERROR] Invalid -query-mix: %v", err)
+	}
+	if mix.NeedsJSON() && !*jsonColumn {
+		log.Fatalf("[ERROR] -query-mix %q uses json_* query types, which require -json-column", *queryMixSpec)
+	}
+	if mix.NeedsGenerated() && !*generatedColumn {
+		log.Fatalf("[ERROR] -query-mix %q uses gen_* query types, which require -generated-column", *queryMixSpec)
+	}
+	if *batchPointGetIDs < 1 {
+		log.Fatalf("[ERROR] Invalid -batch-point-get-size %d (want at least 1)", *batchPointGetIDs)
+	}
+	batchPointGetSize = *batchPointGetIDs
+	commonDB = *commonDBName
+	if mix.NeedsCommon() && commonDB == "" {
+		log.Fatalf("[ERROR] -query-mix %q uses common_* query types, which require -common-db", *queryMixSpec)
+	}
+	if mix.NeedsForeignKeys() && !*foreignKeys {
+		log.Fatalf("[ERROR] -query-mix %q uses fk_parent_child, which requires -foreign-keys", *queryMixSpec)
+	}
+	if mix.NeedsOrderEntry() {
+		log.Fatalf("[ERROR] -query-mix %q uses oe_* query types, which only order-entry tenants run (-order-entry-tenants)", *queryMixSpec)
+	}
+	if mix.NeedsIngest() {
+		log.Fatalf("[ERROR] -query-mix %q uses ingest_* query types, which only ingestion tenants run (-ingest-tenants)", *queryMixSpec)
+	}
+	if mix.NeedsTTL() {
+		log.Fatalf("[ERROR] -query-mix %q uses ttl_* query types, which only TTL tenants run (-ttl-tenants)", *queryMixSpec)
+	}
+	if mix.NeedsProcedures() && !*storedProcedures {
+		log.Fatalf("[ERROR] -query-mix %q uses proc_call, which requires -stored-procedures", *queryMixSpec)
+	}
+	if *payloadType != "text" && *payloadType != "blob" {
+		log.Fatalf("[ERROR] Invalid -payload-type %q (want text or blob)", *payloadType)
+	}
+	if *partitionScheme != "hash" && *partitionScheme != "range" {
+		log.Fatalf("[ERROR] Invalid -partition-scheme %q (want hash or range)", *partitionScheme)
+	}
+
+	primaryKeys, err := newPrimaryKeys(*clusteredTenants, *nonclusteredTenants)
+	if err != nil {
+		log.Fatalf("[ERROR] Invalid primary key settings: %v", err)
+	}
+
+	sweep, err := newSweepController(*sweepBy, *sweepSteps, time.Duration(*sweepStepSeconds)*time.Second, *threadsPerDB, *sweepMaxQPS)
+	if err != nil {
+		log.Fatalf("[ERROR] Invalid sweep settings: %v", err)
+	}
+	if sweep != nil {
+		*testingTimeSeconds = int(sweep.Duration() / time.Second)
+		log.Printf("[INFO] Sweep of %d step(s) by %s, the run takes %ds", len(sweep.steps), sweep.by, *testingTimeSeconds)
+	}
+
+	var scenario *scenarioRunner
+	if *scenarioFile != "" {
+		if sweep != nil {
+			log.Fatalf("[ERROR] -scenario-file can't be combined with -sweep-steps")
+		}
+		if scenario, err = loadScenarioFile(*scenarioFile, *threadsPerDB); err != nil {
+			log.Fatalf("[ERROR] Failed to load scenario file: %v", err)
+		}
+		*testingTimeSeconds = int(scenario.Duration() / time.Second)
+		log.Printf("[INFO] Scenario of %d phase(s), the run takes %ds", len(scenario.phases), *testingTimeSeconds)
+	}
+
+	connect, err := newTenantConnectPolicy(*onTenantConnectFailure, time.Duration(*tenantConnectRetrySeconds)*time.Second)
+	if err != nil {
+		log.Fatalf("[ERROR] Invalid -on-tenant-connect-failure: %v", err)
+	}
+
+	opts := &workloadOptions{
+		tables:     tables,
+		tableNames: tableNames,
+		tenancy:    tenancy,
+		clickhouse: clickhouse,
+		tiflash:    tiflash,
+		users:      users,
+		mix:        mix,
+		schema: &schemaOptions{
+			cSize:       *cSize,
+			padSize:     *padSize,
+			payloadType: *payloadType,
+			partitions:  *partitionsPerTable,
+			rangeParts:  *partitionScheme == "range",
+			jsonColumn:  *jsonColumn,
+			generated:   *generatedColumn,
+			procedures:  *storedProcedures,
+			foreignKeys: *foreignKeys,
+			views:       *views,
+		},
+		sleepMs:    *sleepAfterQueryMs,
+		rangeSize:  *rangeSize,
+		readiness:  newReadiness(),
+		pools:      newPoolRegistry(),
+		connect:    connect,
+		pauses:     newTenantPauses(tenantNames),
+		sweep:      sweep,
+		scenario:   scenario,
+		tags:       newSQLTagger(*sqlComment, *runID),
+		collations: collations,
+		orderEntry: orderEntry,
+		ingest:     ingest,
+		ttl:        ttl,
+		views:      newViewQueries(*queryViews),
+		pkKinds:    primaryKeys,
+		profiles:   profiles,
+	}
+
+	// Onboarded tenants are only created during the run; cleanup mode drops them with the others.
+	if *onboardFile != "" {
+		if opts.onboard, err = loadOnboardFile(*onboardFile, *prepareThreads, *prepareBatchRows); err != nil {
+			log.Fatalf("[ERROR] Failed to load onboarding file: %v", err)
+		}
+		if *churnOnSeconds > 0 {
+			log.Fatalf("[ERROR] -onboard-file can't be combined with -churn-on-seconds")
+		}
+	}
+	initialTenants := opts.onboard.Initial(tenantNames)
+	if *offboardFile != "" {
+		if opts.offboard, err = loadOffboardFile(*offboardFile, time.Duration(*offboardObserveSeconds)*time.Second); err != nil {
+			log.Fatalf("[ERROR] Failed to load offboarding file: %v", err)
+		}
+		// Dropping the shared database would offboard every tenant.
+		if opts.tenancy.kind != "db" {
+			log.Fatalf("[ERROR] -offboard-file needs -tenancy-model db")
+		}
+		if *churnOnSeconds > 0 {
+			log.Fatalf("[ERROR] -offboard-file can't be combined with -churn-on-seconds")
+		}
+		for _, name := range tenantNames {
+			offboardAt, offboarded := opts.offboard.At(name)
+			if onboardAt, onboarded := opts.onboard.At(name); offboarded && onboarded && offboardAt <= onboardAt {
+				log.Fatalf("[ERROR] DB %s is offboarded at %v, before its onboarding at %v", name, offboardAt, onboardAt)
+			}
+		}
+	}
+
+	switch *mode {
+	case "run":
+	case "prepare":
+		log.Printf("[INFO] Preparing %d DB(s) with %d table(s) each ...\n", len(initialTenants), len(tables))
+		if err := runPrepare(context.Background(), dsns, opts, initialTenants, *prepareThreads, *prepareBatchRows); err != nil {
+			log.Fatalf("[ERROR] Prepare failed: %v", err)
+		}
+		if dsnsB != nil {
+			log.Printf("[INFO] Preparing cluster B ...")
+			if err := runPrepare(context.Background(), dsnsB, opts, initialTenants, *prepareThreads, *prepareBatchRows); err != nil {
+				log.Fatalf("[ERROR] Prepare of cluster B failed: %v", err)
+			}
+		}
+		return
+	case "cleanup":
+		if err := runCleanup(context.Background(), dsns, opts.tenancy, opts.users, tenantNames); err != nil {
+			log.Fatalf("[ERROR] Cleanup failed: %v", err)
+		}
+		if dsnsB != nil {
+			if err := runCleanup(context.Background(), dsnsB, opts.tenancy, opts.users, tenantNames); err != nil {
+				log.Fatalf("[ERROR] Cleanup of cluster B failed: %v", err)
+			}
+		}
+		return
+	default:
+		log.Fatalf("[ERROR] Unknown -mode %q (want run, prepare or cleanup)", *mode)
+	}
+
+	if *discoverTablesFlag && sqlDriverName != "mock" {
+		if ab != nil {
+			log.Fatalf("[ERROR] -discover-tables can't be combined with -dsn-b")
+		}
+		if opts.discovered, err = discoverTables(context.Background(), dsns, opts, initialTenants); err != nil {
+			log.Fatalf("[ERROR] Table discovery failed: %v", err)
+		}
+	}
+
+	switch *verifySchemaLevel {
+	case verifyOff:
+	case verifyTables, verifyRows:
+		// Discovered tables exist by definition.
+		if sqlDriverName == "mock" || opts.discovered != nil {
+			break
+		}
+		for _, side := range []*dsnResolver{dsns, dsnsB} {
+			if side == nil {
+				continue
+			}
+			if err := verifySchema(context.Background(), side, opts, initialTenants, *verifySchemaLevel); err != nil {
+				log.Fatalf("[ERROR] Schema check failed: %v", err)
+			}
+		}
+	default:
+		log.Fatalf("[ERROR] Unknown -verify-schema %q (want %s, %s or %s)", *verifySchemaLevel, verifyOff, verifyTables, verifyRows)
+	}
+
+	if *captureFile != "" {
+		capture, err := newCaptureWriter(*captureFile)
+		if err != nil {
+			log.Fatalf("[ERROR] Failed to create capture file %s: %v", *captureFile, err)
+		}
+		defer capture.Close()
+		opts.capture = capture
+		log.Printf("[INFO] Capturing queries to %s", *captureFile)
+	}
+
+	if opts.audit, err = newAuditLog(*auditFile, int64(*auditMaxSize)<<20, *auditMaxFiles); err != nil {
+		log.Fatalf("[ERROR] Failed to create audit file %s: %v", *auditFile, err)
+	}
+	defer opts.audit.Close()
+
+	if opts.explain, err = newExplainSampler(*explainFile, *explainSampleRate, *explainAnalyze); err != nil {
+		log.Fatalf("[ERROR] Failed to create EXPLAIN file %s: %v", *explainFile, err)
+	}
+	defer opts.explain.Close()
+
+	var tiers []*tier
+	if *tierFile != "" {
+		if tiers, err = loadTierFile(*tierFile); err != nil {
+			log.Fatalf("[ERROR] Failed to load tier file: %v", err)
+		}
+	}
+	if *lifetimeFile != "" {
+		if opts.lifetimes, err = loadLifetimeFile(*lifetimeFile); err != nil {
+			log.Fatalf("[ERROR] Failed to load lifetime file: %v", err)
+		}
+	}
+	if *hintFile != "" {
+		if opts.hints, err = loadHintFile(*hintFile); err != nil {
+			log.Fatalf("[ERROR] Failed to load hint file: %v", err)
+		}
+	}
+	if *annotationFile != "" {
+		if opts.annotate, err = loadAnnotationFile(*annotationFile); err != nil {
+			log.Fatalf("[ERROR] Failed to load annotation file: %v", err)
+		}
+	}
+	if *historicalReadFile != "" {
+		if opts.historical, err = loadHistoricalFile(*historicalReadFile); err != nil {
+			log.Fatalf("[ERROR] Failed to load historical read file: %v", err)
+		}
+	}
+
+	latencyTenants, err := parseTenantSet(*injectLatencyTenants)
+	if err != nil {
+		log.Fatalf("[ERROR] Invalid -inject-latency-tenants: %v", err)
+	}
+	opts.latency = newLatencyInjector(time.Duration(*injectLatencyMs)*time.Millisecond,
+		time.Duration(*injectLatencyJitterMs)*time.Millisecond, latencyTenants)
+
+	ddlTenantSet, err := parseTenantSet(*ddlTenants)
+	if err != nil {
+		log.Fatalf("[ERROR] Invalid -ddl-tenants: %v", err)
+	}
+	if opts.ddl, err = newDDLChurn(ddlTenantSet, time.Duration(*ddlIntervalSeconds)*time.Second, *ddlOps); err != nil {
+		log.Fatalf("[ERROR] Invalid DDL churn settings: %v", err)
+	}
+
+	gcTenantSet, err := parseTenantSet(*gcPressureTenants)
+	if err != nil {
+		log.Fatalf("[ERROR] Invalid -gc-pressure-tenants: %v", err)
+	}
+	if opts.gcPressure, err = newGCPressure(gcTenantSet, *gcPressureTables, *gcPressureRows, *gcPressureBatchRows, *gcPressureRate); err != nil {
+		log.Fatalf("[ERROR] Invalid GC pressure settings: %v", err)
+	}
+
+	bankTenantSet, err := parseTenantSet(*bankTenants)
+	if err != nil {
+		log.Fatalf("[ERROR] Invalid -bank-tenants: %v", err)
+	}
+	if opts.bank, err = newBankTransfer(bankTenantSet, *bankTables, *bankMaxAmount, time.Duration(*bankCheckIntervalSeconds)*time.Second); err != nil {
+		log.Fatalf("[ERROR] Invalid bank transfer settings: %v", err)
+	}
+
+	scanHogTenantSet, err := parseTenantSet(*scanHogTenants)
+	if err != nil {
+		log.Fatalf("[ERROR] Invalid -scan-hog-tenants: %v", err)
+	}
+	// The big tables come first, in the tables of the flags as in the discovered ones.
+	if opts.scanHog, err = newScanHog(scanHogTenantSet, time.Duration(*scanHogIntervalSeconds)*time.Second,
+		*scanHogConcurrency, *bigTableNum); err != nil {
+		log.Fatalf("[ERROR] Invalid scan hog settings: %v", err)
+	}
+
+	pageTenantSet, err := parseTenantSet(*paginationTenants)
+	if err != nil {
+		log.Fatalf("[ERROR] Invalid -pagination-tenants: %v", err)
+	}
+	if opts.pagination, err = newPagination(pageTenantSet, *paginationMode, *paginationPageSize); err != nil {
+		log.Fatalf("[ERROR] Invalid pagination settings: %v", err)
+	}
+	// The workers of a tenant run the mix of one class, or the -query-mix.
+	if err := opts.checkTenantClasses(tenantNames); err != nil {
+		log.Fatalf("[ERROR] %v", err)
+	}
+
+	rangeDeleteTenantSet, err := parseTenantSet(*rangeDeleteTenants)
+	if err != nil {
+		log.Fatalf("[ERROR] Invalid -range-delete-tenants: %v", err)
+	}
+	if opts.retention, err = newRangeDelete(rangeDeleteTenantSet, *rangeDeleteChunkRows, *rangeDeleteSpanRows,
+		time.Duration(*rangeDeletePaceMs)*time.Millisecond); err != nil {
+		log.Fatalf("[ERROR] Invalid range delete settings: %v", err)
+	}
+
+	growthTenantSet, err := parseTenantSet(*growthTenants)
+	if err != nil {
+		log.Fatalf("[ERROR] Invalid -growth-tenants: %v", err)
+	}
+	if opts.growth, err = newDataGrowth(growthTenantSet, *growthRowsPerSecond, *growthBatchRows,
+		time.Duration(*growthAnalyzeSeconds)*time.Second); err != nil {
+		log.Fatalf("[ERROR] Invalid data growth settings: %v", err)
+	}
+
+	partitionRollTenantSet, err := parseTenantSet(*partitionRollTenants)
+	if err != nil {
+		log.Fatalf("[ERROR] Invalid -partition-roll-tenants: %v", err)
+	}
+	if opts.partRoll, err = newPartitionRoller(partitionRollTenantSet, time.Duration(*partitionRollIntervalSeconds)*time.Second,
+		*partitionsPerTable, *partitionScheme); err != nil {
+		log.Fatalf("[ERROR] Invalid partition roll settings: %v", err)
+	}
 
-	log.Printf("[INFO] Starting workload with %d DB(s), each DB has %d threads ...\n", *dbNum, *threadsPerDB)
+	longTxnTenantSet, err := parseTenantSet(*longTxnTenants)
+	if err != nil {
+		log.Fatalf("[ERROR] Invalid -long-txn-tenants: %v", err)
+	}
+	if opts.longTxn, err = newLongTxn(longTxnTenantSet, time.Duration(*longTxnHoldSeconds)*time.Second,
+		*longTxnRows, *longTxnForUpdate, *longTxnEnd); err != nil {
+		log.Fatalf("[ERROR] Invalid long transaction settings: %v", err)
+	}
+
+	if opts.fair, err = newFairScheduler(*sharedWorkers, *fairWeightFile); err != nil {
+		log.Fatalf("[ERROR] Invalid shared worker settings: %v", err)
+	}
+
+	if *httpListen != "" {
+		if err := startHTTPServer(*httpListen, opts); err != nil {
+			log.Fatalf("[ERROR] Failed to start HTTP server on %s: %v", *httpListen, err)
+		}
+	}
+
+	// In a multi-node run the tenants are split among the nodes, which all start and stop together.
+	var (
+		leader      *clusterLeader
+		assignment  *clusterAssignment
+		startAt     = time.Now()
+		testingTime = *testingTimeSeconds
+	)
+	switch *clusterRole {
+	case "":
+	case "leader":
+		var err error
+		if leader, err = startClusterLeader(*clusterListen, *clusterFollowers); err != nil {
+			log.Fatalf("[ERROR] Failed to start cluster leader on %s: %v", *clusterListen, err)
+		}
+		tenantNames, startAt = leader.Assign(tenantNames, *testingTimeSeconds)
+	case "follower":
+		var err error
+		if assignment, err = joinCluster(*clusterLeaderURL); err != nil {
+			log.Fatalf("[ERROR] Failed to join cluster leader %s: %v", *clusterLeaderURL, err)
+		}
+		tenantNames = assignment.Tenants
+		testingTime = assignment.TestingTimeSeconds
+		startAt = time.Now().Add(time.Duration(assignment.StartInMs) * time.Millisecond)
+	default:
+		log.Fatalf("[ERROR] Unknown -cluster-role %q (want leader or follower)", *clusterRole)
+	}
+	time.Sleep(time.Until(startAt))
+
+	// The run (and its statistics) starts now.
+	opts.startTime = time.Now()
+	opts.exitTime = opts.startTime.Add(time.Second * time.Duration(testingTime))
+	opts.stats = newStatsCollector(time.Duration(*interferenceWindowSeconds) * time.Second)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go runDumpOnSignal(ctx, *dumpFile, opts)
+	// Server status and processlist: dedicated connections to the server of one tenant sample its values for the time
+	// series and its sessions.
+	if *serverStatus != "" && *timeSeriesFile == "" {
+		log.Fatalf("[ERROR] -server-status needs -timeseries-file, which its values are written to")
+	}
+	statusTenant := *serverStatusTenant
+	if statusTenant == "" {
+		statusTenant = tenantNames[0]
+		// The database of an onboarded tenant doesn't exist yet.
+		if initial := opts.onboard.Initial(tenantNames); len(initial) > 0 {
+			statusTenant = initial[0]
+		}
+	}
+	server, err := newServerSampler(dsns.Driver(statusTenant), dsns.DSN(statusTenant), *serverStatus, time.Duration(*timeSeriesIntervalSeconds)*time.Second)
+	if err != nil {
+		log.Fatalf("[ERROR] Invalid -server-status: %v", err)
+	}
+	if server != nil {
+		go server.Run(ctx)
+	}
+	processlist, err := newProcesslistWriter(dsns.Driver(statusTenant), dsns.DSN(statusTenant), *processlistFile,
+		time.Duration(*processlistIntervalSeconds)*time.Second, *processlistCluster)
+	if err != nil {
+		log.Fatalf("[ERROR] Invalid -processlist-file settings: %v", err)
+	}
+	if processlist != nil {
+		go processlist.Run(ctx)
+	}
+	tenantDatabases := make([]string, len(tenantNames))
+	for i, name := range tenantNames {
+		tenantDatabases[i] = opts.tenancy.Database(name)
+	}
+	planDrift, err := newPlanDrift(dsns.Driver(statusTenant), dsns.DSN(statusTenant), time.Duration(*planDriftIntervalSeconds)*time.Second,
+		*runID, tenantDatabases, opts.startTime)
+	if err != nil {
+		log.Fatalf("[ERROR] Invalid -plan-drift-interval-seconds settings: %v", err)
+	}
+	if planDrift != nil {
+		go planDrift.Run(ctx)
+	}
+	timeSeries, err := newTimeSeriesWriter(*timeSeriesFile, time.Duration(*timeSeriesIntervalSeconds)*time.Second, server)
+	if err != nil {
+		log.Fatalf("[ERROR] Failed to create time series file %s: %v", *timeSeriesFile, err)
+	}
+	if timeSeries != nil {
+		go timeSeries.Run(ctx, opts.stats)
+	}
+	if opts.quiet, err = newQuietSchedule(time.Duration(*quietPeriodSeconds)*time.Second,
+		time.Duration(*quietSeconds)*time.Second, *quietWorkers); err != nil {
+		log.Fatalf("[ERROR] Invalid quiet window settings: %v", err)
+	}
+	if opts.quiet != nil {
+		go opts.quiet.Run(ctx, opts.stats, opts.startTime)
+	}
+	if opts.coCorrect, err = newCOCorrection(*coCorrect, time.Duration(*coIntervalMs)*time.Millisecond,
+		time.Duration(*sleepAfterQueryMs)*time.Millisecond); err != nil {
+		log.Fatalf("[ERROR] Invalid -co-correct settings: %v", err)
+	}
+	if opts.cache, err = newClientCache(*cacheHitRatio, time.Duration(*cacheWarmupSeconds)*time.Second, *cacheFile); err != nil {
+		log.Fatalf("[ERROR] Invalid client-side cache settings: %v", err)
+	}
+	opts.guard = newErrorGuard(*maxErrorRate, *maxConsecutiveErrors, cancel)
+	connErrorGuard = opts.guard
+	go opts.guard.WatchErrorRate(ctx, opts.stats, time.Second)
+	breakerTenantSet, err := parseTenantSet(*breakerTenants)
+	if err != nil {
+		log.Fatalf("[ERROR] Invalid -breaker-tenants: %v", err)
+	}
+	if opts.retries, err = newTxnRetry(*txnRetries, time.Duration(*txnRetryBackoffMs)*time.Millisecond); err != nil {
+		log.Fatalf("[ERROR] Invalid transaction retry settings: %v", err)
+	}
+	if opts.inflight, err = newInflightLimiter(*maxInflight); err != nil {
+		log.Fatalf("[ERROR] Invalid -max-inflight: %v", err)
+	}
+	cancelTenantSet, err := parseTenantSet(*cancelTenants)
+	if err != nil {
+		log.Fatalf("[ERROR] Invalid -cancel-tenants: %v", err)
+	}
+	if opts.cancels, err = newQueryCanceller(cancelTenantSet, *cancelFraction, time.Duration(*cancelAfterMs)*time.Millisecond,
+		*cancelKillQuery); err != nil {
+		log.Fatalf("[ERROR] Invalid query cancellation settings: %v", err)
+	}
+	if opts.breaker, err = newCircuitBreaker(breakerTenantSet, *breakerErrorRate, *breakerWindow,
+		time.Duration(*breakerOpenSeconds)*time.Second, *breakerProbes); err != nil {
+		log.Fatalf("[ERROR] Invalid circuit breaker settings: %v", err)
+	}
+	opts.tiers = newTierScheduler(ctx, tiers, *admissionQPS)
+	if opts.globalQPS, err = newGlobalRateCap(*globalQPS); err != nil {
+		log.Fatalf("[ERROR] Invalid -global-qps: %v", err)
+	}
+	if opts.targets, err = newTargetTracker(*targetTolerance, opts.tiers, opts.sweep, opts.scenario); err != nil {
+		log.Fatalf("[ERROR] Invalid -target-tolerance: %v", err)
+	}
+	if opts.targets != nil {
+		go opts.targets.Run(ctx, opts.stats)
+	}
+	if *reportIntervalSeconds > 0 {
+		go runIntervalReports(ctx, opts.stats, time.Duration(*reportIntervalSeconds)*time.Second)
+	}
+	metrics, err := newMetricsPusher(*metricsSink, *metricsAddr, *metricsPrefix, *runID, time.Duration(*metricsIntervalSeconds)*time.Second)
+	if err != nil {
+		log.Fatalf("[ERROR] Failed to set up the metrics sink: %v", err)
+	}
+	if metrics != nil {
+		go metrics.Run(ctx, opts.stats)
+	}
+	opts.aimd = newAIMDController(time.Duration(*aimdTargetP99Ms)*time.Millisecond,
+		time.Duration(*aimdIntervalSeconds)*time.Second, *aimdDecreaseFactor, *threadsPerDB)
+	if opts.aimd != nil {
+		go opts.aimd.Run(ctx, opts.stats)
+	}
+	if opts.governor, err = newBackoffGovernor(time.Duration(*governorP99Ms)*time.Millisecond,
+		time.Duration(*governorIntervalSeconds)*time.Second, *governorScope, *governorBackoffFactor, *governorRecoveryStep,
+		*governorMinRate); err != nil {
+		log.Fatalf("[ERROR] Invalid backoff governor settings: %v", err)
+	}
+	if opts.governor != nil {
+		go opts.governor.Run(ctx, opts.stats)
+	}
+	if opts.sweep != nil {
+		go opts.sweep.Run(ctx, opts.stats, opts.startTime)
+	}
+	if opts.scenario != nil {
+		go opts.scenario.Run(ctx, opts.stats, opts.startTime)
+	}
+	if *chaosKillIntervalSeconds > 0 {
+		opts.killer = newConnKiller(*chaosKillFraction)
+		go opts.killer.Run(ctx, time.Duration(*chaosKillIntervalSeconds)*time.Second)
+	}
+	if opts.recycler, err = newConnRecycler(time.Duration(*connMaxLifetimeSeconds)*time.Second, *connLifetimeJitter); err != nil {
+		log.Fatalf("[ERROR] Invalid -conn-lifetime-jitter: %v", err)
+	}
+	// Shared workers run the -query-mix on plain connections of the tenants' pools, one query at a time.
+	if opts.fair != nil {
+		switch {
+		case *churnOnSeconds > 0:
+			log.Fatalf("[ERROR] -shared-workers can't be combined with -churn-on-seconds")
+		case connSessionInit != nil:
+			log.Fatalf("[ERROR] -shared-workers can't be combined with session init statements, they need dedicated connections")
+		case opts.gcPressure != nil || opts.bank != nil || opts.orderEntry != nil || opts.ingest != nil || opts.ttl != nil || opts.pagination != nil:
+			log.Fatalf("[ERROR] -shared-workers can't be combined with tenants running their own query mix")
+		case opts.aimd != nil || opts.sweep != nil || opts.scenario != nil || opts.quiet != nil:
+			log.Fatalf("[ERROR] -shared-workers can't be combined with adaptive concurrency, load sweeps, scenarios or quiet windows, they scale dedicated workers")
+		case opts.cache != nil:
+			log.Fatalf("[ERROR] -shared-workers can't be combined with the client-side cache")
+		case opts.killer != nil || opts.recycler != nil:
+			log.Fatalf("[ERROR] -shared-workers can't be combined with connection kills or recycling, they act on dedicated connections")
+		case opts.cancels != nil || opts.latency != nil || opts.coCorrect != nil || opts.explain != nil:
+			log.Fatalf("[ERROR] -shared-workers can't be combined with query cancellation, latency injection, coordinated omission correction or EXPLAIN sampling")
+		}
+	}
+
+	stopCPUProfile, err := startCPUProfile(*cpuProfile)
+	if err != nil {
+		log.Fatalf("[ERROR] Failed to start CPU profile %s: %v", *cpuProfile, err)
+	}
+
+	log.Printf("[INFO] Starting workload with %d DB(s), each DB has %d threads ...\n", len(tenantNames), *threadsPerDB)
+
+	churnOn := time.Duration(*churnOnSeconds) * time.Second
+	churnOff := time.Duration(*churnOffSeconds) * time.Second
+	if ab != nil {
+		// Cluster B has its own tier limits, QPS cap, shared workers, adaptive concurrency, backoff governor and circuits, started with its side.
+		b := ab.Options(opts)
+		b.tiers = newTierScheduler(ctx, tiers, *admissionQPS)
+		b.globalQPS, _ = newGlobalRateCap(*globalQPS)
+		b.fair, _ = newFairScheduler(*sharedWorkers, *fairWeightFile)
+		b.aimd = newAIMDController(time.Duration(*aimdTargetP99Ms)*time.Millisecond,
+			time.Duration(*aimdIntervalSeconds)*time.Second, *aimdDecreaseFactor, *threadsPerDB)
+		b.breaker, _ = newCircuitBreaker(breakerTenantSet, *breakerErrorRate, *breakerWindow,
+			time.Duration(*breakerOpenSeconds)*time.Second, *breakerProbes)
+		b.governor, _ = newBackoffGovernor(time.Duration(*governorP99Ms)*time.Millisecond,
+			time.Duration(*governorIntervalSeconds)*time.Second, *governorScope, *governorBackoffFactor, *governorRecoveryStep,
+			*governorMinRate)
+		ab.Run(ctx, tenantNames, dsns, *threadsPerDB, churnOn, churnOff, opts)
+	} else {
+		runTenants(ctx, tenantNames, dsns, *threadsPerDB, churnOn, churnOff, opts)
+	}
+	log.Printf("[INFO] Stop workload with %d DB(s) x %d threads\n", len(tenantNames), *threadsPerDB)
+	if err := stopCPUProfile(); err != nil {
+		log.Printf("[ERROR] Failed to write CPU profile %s: %v", *cpuProfile, err)
+	}
+	if *memProfile != "" {
+		if err := writeHeapProfile(*memProfile); err != nil {
+			log.Printf("[ERROR] Failed to write heap profile %s: %v", *memProfile, err)
+		}
+	}
+
+	snapshot := opts.stats.Snapshot()
+	switch {
+	case leader != nil:
+		snapshot = leader.CollectReports(snapshot, time.Minute)
+	case assignment != nil:
+		if err := sendClusterReport(*clusterLeaderURL, assignment.NodeID, snapshot); err != nil {
+			log.Printf("[ERROR] Failed to send statistics to cluster leader: %v", err)
+		}
+	}
+	workerLog.Flush()
+	if n := workerLog.Suppressed(); n > 0 {
+		log.Printf("[INFO] log sampling: %d worker message(s) suppressed during the run", n)
+	}
+	opts.connect.logConnectSummary()
+	logSummary(snapshot)
+	if processlist != nil {
+		processlist.logProcesslistSummary(*processlistFile)
+	}
+	if planDrift != nil {
+		planDrift.logPlanDriftSummary()
+	}
+	if commonDB != "" {
+		logCommonSummary(snapshot)
+	}
+	summary := summarize(snapshot)
+	summary.Run = run
+	summary.Targets = opts.targets.Summaries()
+	for i := range summary.Tenants {
+		summary.Tenants[i].PrimaryKey = opts.pkKinds.Kind(summary.Tenants[i].Tenant)
+		summary.Tenants[i].Region = regions.Region(summary.Tenants[i].Tenant)
+	}
+	summary.Regions = regions.Summaries(snapshot)
+	printTenantTable(os.Stdout, summary)
+	if ab != nil {
+		summaryB := summarize(ab.Snapshot())
+		fmt.Println("\nCluster B (-dsn-b):")
+		printTenantTable(os.Stdout, summaryB)
+		fmt.Printf("\nA/B comparison (%s), latencies in ms, Δ from A to B:\n", ab.mode)
+		printABComparison(os.Stdout, summary, summaryB)
+	}
+	if *summaryJSONFile != "" {
+		if err := writeSummaryJSON(*summaryJSONFile, summary); err != nil {
+			log.Printf("[ERROR] Failed to write summary to %s: %v", *summaryJSONFile, err)
+		} else {
+			log.Printf("[INFO] Summary written to %s", *summaryJSONFile)
+		}
+	}
+	if *interferenceWindowSeconds > 0 {
+		logInterference(snapshot)
+	}
+	if opts.killer != nil {
+		logChaosSummary(snapshot)
+	}
+	if opts.recycler != nil {
+		logRecycleSummary(snapshot)
+	}
+	if opts.aimd != nil {
+		opts.aimd.logAIMDSummary()
+	}
+	if opts.governor != nil {
+		opts.governor.logGovernorSummary()
+	}
+	if opts.breaker != nil {
+		opts.breaker.logBreakerSummary()
+	}
+	if opts.cancels != nil {
+		opts.cancels.logCancelSummary()
+	}
+	if opts.inflight != nil {
+		opts.inflight.logInflightSummary()
+	}
+	if opts.fair != nil {
+		opts.fair.logFairSummary()
+	}
+	if opts.bank != nil {
+		opts.bank.logBankSummary()
+	}
+	if opts.longTxn != nil {
+		opts.longTxn.logLongTxnSummary()
+	}
+	if opts.retention != nil {
+		opts.retention.logRangeDeleteSummary()
+	}
+	if opts.growth != nil {
+		opts.growth.logGrowthSummary()
+	}
+	if opts.historical != nil {
+		opts.historical.logHistoricalSummary()
+	}
+	if opts.partRoll != nil {
+		opts.partRoll.logPartitionRollSummary()
+	}
+	if opts.pagination != nil {
+		opts.pagination.logPaginationSummary()
+	}
+	if opts.tiers != nil {
+		opts.tiers.logTierSummary(snapshot)
+	}
+	if opts.globalQPS != nil {
+		opts.globalQPS.logGlobalCapSummary(snapshot)
+	}
+	if regions != nil {
+		logRegionSummary(summary.Regions)
+	}
+	if opts.onboard != nil {
+		opts.onboard.logOnboardSummary(snapshot)
+	}
+	if opts.offboard != nil {
+		opts.offboard.logOffboardSummary()
+	}
+	if opts.targets != nil {
+		opts.targets.logTargetSummary()
+	}
+	if opts.cache != nil {
+		logCacheSummary(snapshot)
+	}
+	if opts.collations != nil {
+		opts.collations.logCollationSummary(snapshot)
+	}
+	if opts.pkKinds != nil {
+		opts.pkKinds.logPrimaryKeySummary(snapshot)
+	}
+	if opts.quiet != nil {
+		opts.quiet.Finish(snapshot)
+		opts.quiet.logQuietReport()
+	}
+	if opts.sweep != nil {
+		opts.sweep.Finish(snapshot)
+		opts.sweep.printSweepCurve(os.Stdout)
+		if *sweepFile != "" {
+			if err := opts.sweep.writeSweepCSV(*sweepFile); err != nil {
+				log.Printf("[ERROR] Failed to write sweep curve to %s: %v", *sweepFile, err)
+			} else {
+				log.Printf("[INFO] Sweep curve written to %s", *sweepFile)
+			}
+		}
+	}
+	if opts.scenario != nil {
+		opts.scenario.Finish(snapshot)
+		fmt.Println("\nScenario phases:")
+		opts.scenario.printScenarioReport(os.Stdout)
+	}
 
+	if reason := opts.guard.AbortReason(); reason != "" {
+		log.Printf("[ERROR] Run aborted: %s", reason)
+		opts.capture.Close()
+		opts.audit.Close()
+		os.Exit(2)
+	}
+	if n := opts.bank.Violations(); n > 0 {
+		log.Printf("[ERROR] Bank invariant violated %d time(s)", n)
+		opts.capture.Close()
+		opts.audit.Close()
+		os.Exit(3)
+	}
+}
+
+// runTenants launches the workers of every tenant and waits for all of them to finish.
+func runTenants(ctx context.Context, tenantNames []string, dsns *dsnResolver, threadsPerDB int, churnOn, churnOff time.Duration, opts *workloadOptions) {
 	var wg sync.WaitGroup
+	opts.readiness.Expect(len(tenantNames))
+	if opts.fair != nil {
+		opts.fair.StartWorkers(ctx, &wg, opts)
+	}
 
 	// For each database, create a separate *sql.DB instance and launch goroutines.
-	for dbIndex := 1; dbIndex <= *dbNum; dbIndex++ {
-		dbName := fmt.Sprintf("test%04d", dbIndex) // e.g. test0001, test0002, etc.
-		dbDSN := *dsn + dbName
+	for _, dbName := range tenantNames {
+		// In churn mode the tenant manages its own DB handle, opening and closing it per cycle.
+		if churnOn > 0 {
+			wg.Add(1)
+			go func(dbName string) {
+				defer wg.Done()
+				runChurningTenant(ctx, dsns, dbName, threadsPerDB, churnOn, churnOff, opts)
+			}(dbName)
+			continue
+		}
 
-		// Open a database handle.
+		// An offboarded tenant runs from the start, or from its onboarding, until it is drained and dropped at its offset.
+		if at, ok := opts.offboard.At(dbName); ok {
+			onboardAt, onboarded := opts.onboard.At(dbName)
+			if onboarded {
+				opts.readiness.Skip()
+			}
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				opts.offboard.Run(ctx, dsns, dbName, at, opts, func(opts *workloadOptions) {
+					if onboarded {
+						opts.onboard.Run(ctx, dsns, dbName, threadsPerDB, onboardAt, opts)
+					} else {
+						runConnectedTenant(ctx, dsns, dbName, threadsPerDB, opts)
+					}
+				})
+			}()
+			continue
+		}
+
+		// An onboarded tenant is created and loaded at its offset, then runs until the end.
+		if at, ok := opts.onboard.At(dbName); ok {
+			opts.readiness.Skip()
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				opts.onboard.Run(ctx, dsns, dbName, threadsPerDB, at, opts)
+			}()
+			continue
+		}
+
+		// A tenant with a lifetime window joins and leaves the run at its own offsets.
+		if window, ok := opts.lifetimes.Window(dbName); ok {
+			if window.start > 0 {
+				opts.readiness.Skip()
+			}
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				runTenantWindow(ctx, dsns, dbName, threadsPerDB, window, opts)
+			}()
+			continue
+		}
+
+		// Launch 'threadsPerDB' goroutines (long connections) once the tenant DB is reachable.
+		start := func(pool *tenantPool) {
+			startTenantWorkers(ctx, &wg, pool, dbName, threadsPerDB, opts)
+		}
+
+		// Open a database handle (two when reads go to their own DSN) and ping it.
 		// Note: By default, sql.DB is a connection pool manager.
 		//       We'll get a dedicated *sql.Conn from it in each goroutine.
-		dbConn, err := sql.Open("mysql", dbDSN)
-		if err != nil {
-			log.Fatalf("[ERROR] Failed to open DB %s: %v", dbName, err)
+		// An unreachable tenant aborts the run, is skipped or is retried in the background, depending on the policy.
+		pool := opts.connect.Connect(ctx, &wg, dsns, dbName, opts.stats.Tenant(dbName), opts.exitTime, start)
+		if pool == nil {
+			if opts.connect.action == connectSkip {
+				opts.readiness.Skip()
+			}
+			continue
 		}
 
 		// Optional: Set connection pool parameters if needed.
 		// Example: Use the same number for max open/idle as threadsPerDB,
 		//          so that each thread can hold one dedicated connection.
-		// dbConn.SetMaxOpenConns(*threadsPerDB)
-		// dbConn.SetMaxIdleConns(*threadsPerDB)
-
-		// Ping test to ensure the DB is reachable.
-		if err := dbConn.Ping(); err != nil {
-			log.Fatalf("[ERROR] Failed to ping DB %s: %v", dbName, err)
-		}
-		log.Printf("[INFO] DB %s connected", dbName)
+		// pool.read.SetMaxOpenConns(threadsPerDB)
+		// pool.read.SetMaxIdleConns(threadsPerDB)
 
-		// Launch 'threadsPerDB' goroutines (long connections).
-		for i := 0; i < *threadsPerDB; i++ {
-			wg.Add(1)
-			time.Sleep(50 * time.Millisecond)
-			go func(conn *sql.DB, dbName string) {
-				defer wg.Done()
-				runWorker(conn, dbName, tables, *sleepAfterQueryMs, exitTime)
-			}(dbConn, dbName)
-		}
+		start(pool)
 		time.Sleep(50 * time.Millisecond)
 	}
 
 	// Wait for all goroutines to finish (though in this case they run indefinitely).
 	wg.Wait()
-	log.Printf("[INFO] Stop workload with %d DB(s) x %d threads\n", *dbNum, *threadsPerDB)
 }
 
-// prepareTables creates the TableInfo list based on the given parameters.
-func prepareTables(bigTableNum, rowsPerBigTable int,
+// startTenantWorkers launches the workers of a connected tenant, or hands it to the shared workers, and its DDL churn, scan hog and long transaction
+// if it has them, counted in wg.
+func startTenantWorkers(ctx context.Context, wg *sync.WaitGroup, pool *tenantPool, dbName string, threadsPerDB int, opts *workloadOptions) {
+	log.Printf("[INFO] DB %s connected", dbName)
+	opts.readiness.MarkPinged(dbName)
+	opts.pools.Add(dbName, pool)
+	// With shared workers the tenant has no workers of its own, the scheduler serves it until it leaves or the run ends.
+	if opts.fair != nil {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			opts.fair.Serve(ctx, pool, dbName, opts)
+		}()
+		threadsPerDB = 0
+	}
+	for i := 0; i < threadsPerDB; i++ {
+		wg.Add(1)
+		time.Sleep(50 * time.Millisecond)
+		go func(worker int) {
+			defer wg.Done()
+			runWorker(ctx, pool.reads(), pool.writes(), dbName, worker, opts)
+		}(i)
+	}
+
+	// A DDL churn tenant additionally alters its own tables in the background.
+	if opts.ddl.Applies(dbName) && !opts.clickhouse.Applies(dbName) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			opts.ddl.Run(ctx, pool, dbName, opts)
+		}()
+	}
+
+	// A scan hog tenant additionally runs full table scans in the background.
+	if opts.scanHog.Applies(dbName) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			opts.scanHog.Run(ctx, pool, dbName, opts)
+		}()
+	}
+
+	// A range delete tenant additionally runs a retention job in the background.
+	if opts.retention.Applies(dbName) && !opts.clickhouse.Applies(dbName) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			opts.retention.Run(ctx, pool, dbName, opts)
+		}()
+	}
+
+	// A growth tenant additionally appends rows to its tables in the background.
+	if opts.growth.Applies(dbName) && !opts.clickhouse.Applies(dbName) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			opts.growth.Run(ctx, pool, dbName, opts)
+		}()
+	}
+
+	// A partition roll tenant additionally rolls the partitions of its small partition tables in the background.
+	if opts.partRoll.Applies(dbName) && !opts.clickhouse.Applies(dbName) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			opts.partRoll.Run(ctx, pool, dbName, opts)
+		}()
+	}
+
+	// A bank tenant checks the invariant of its accounts in the background.
+	if opts.bank.Applies(dbName) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			opts.bank.Run(ctx, pool, dbName, opts)
+		}()
+	}
+
+	// A long transaction tenant additionally keeps a transaction open in the background.
+	if opts.longTxn.Applies(dbName) && !opts.clickhouse.Applies(dbName) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			opts.longTxn.Run(ctx, pool, dbName, opts)
+		}()
+	}
+}
+
+// prepareTables creates the TableInfo list based on the given parameters, naming the tables by the template.
+func prepareTables(names tableNameTemplate, bigTableNum, rowsPerBigTable int,
 	smallTableNum, rowsPerSmallTable int,
 	smallPartitionTableNum, rowsPerSmallPartitionTable int) []TableInfo {
 
 	tables := make([]TableInfo, 0, bigTableNum+smallTableNum+smallPartitionTableNum)
 
-	// 1. Big tables: sbtest001 ~ sbtest067
+	// 1. Big tables: sbtest1 ~ sbtest67
 	for i := 1; i <= bigTableNum; i++ {
-		// tableName := fmt.Sprintf("sbtest%03d", i)
-		tableName := fmt.Sprintf("sbtest%d", i)
+		tableName := names.Name(i)
 		tables = append(tables, TableInfo{
 			Name: tableName,
 			MinK: 1,
@@ -135,12 +1692,11 @@ func prepareTables(bigTableNum, rowsPerBigTable int,
 		})
 	}
 
-	// 2. Small tables: sbtest068 ~ sbtest(067 + 334) = sbtest401
+	// 2. Small tables: sbtest68 ~ sbtest(67 + 334) = sbtest401
 	startSmallTableIndex := bigTableNum + 1
 	endSmallTableIndex := bigTableNum + smallTableNum
 	for i := startSmallTableIndex; i <= endSmallTableIndex; i++ {
-		//tableName := fmt.Sprintf("sbtest%03d", i)
-		tableName := fmt.Sprintf("sbtest%d", i)
+		tableName := names.Name(i)
 		tables = append(tables, TableInfo{
 			Name: tableName,
 			MinK: 1,
@@ -152,37 +1708,48 @@ func prepareTables(bigTableNum, rowsPerBigTable int,
 	startSmallPartitionIndex := endSmallTableIndex + 1
 	endSmallPartitionIndex := endSmallTableIndex + smallPartitionTableNum
 	for i := startSmallPartitionIndex; i <= endSmallPartitionIndex; i++ {
-		// tableName := fmt.Sprintf("sbtest%03d", i)
-		tableName := fmt.Sprintf("sbtest%d", i)
+		tableName := names.Name(i)
 		tables = append(tables, TableInfo{
-			Name: tableName,
-			MinK: 1,
-			MaxK: rowsPerSmallPartitionTable,
+			Name:        tableName,
+			MinK:        1,
+			MaxK:        rowsPerSmallPartitionTable,
+			Partitioned: true,
 		})
 	}
 	return tables
 }
 
-func makeActiveConn(db *sql.DB, dbName string, ctx context.Context) (*sql.Conn, error) {
-	log.Printf("[INFO] get conn for DB %s", dbName)
-	conn, err := db.Conn(ctx)
+func makeActiveConn(src connSource, dbName string, ctx context.Context) (workerConn, error) {
+	workerLog.Printf("get conn", "[INFO] get conn for DB %s", dbName)
+	conn, err := src.Conn(ctx)
 	if err != nil {
-		log.Printf("[ERROR] Failed to get conn for DB %s: %v", dbName, err)
+		workerLog.Printf(logClass("get conn failed", err), "[ERROR] Failed to get conn for DB %s: %v", dbName, err)
 		return nil, err
 	}
 	err = conn.PingContext(ctx)
 	if err != nil {
-		log.Printf("[ERROR] Failed to ping conn for DB %s: %v", dbName, err)
+		workerLog.Printf(logClass("ping conn failed", err), "[ERROR] Failed to ping conn for DB %s: %v", dbName, err)
+		conn.Close()
+		return nil, err
+	}
+	if err := connSessionInit.Run(ctx, conn, dbName); err != nil {
+		workerLog.Printf(logClass("session init failed", err), "[ERROR] Failed to initialize conn for DB %s: %v", dbName, err)
+		conn.Close()
 		return nil, err
 	}
 	return conn, nil
 }
 
-func retryMakeActiveConn(db *sql.DB, dbName string, ctx context.Context) (*sql.Conn, error) {
+func retryMakeActiveConn(src connSource, dbName string, ctx context.Context) (workerConn, error) {
 	for {
-		conn, err := makeActiveConn(db, dbName, ctx)
+		conn, err := makeActiveConn(src, dbName, ctx)
 		if err != nil {
-			log.Printf("[WARNING] retry conn for DB %s: %v", dbName, err)
+			// Stop retrying once the worker has been asked to stop.
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			connErrorGuard.ObserveConnect(err)
+			workerLog.Printf(logClass("retry conn", err), "[WARNING] retry conn for DB %s: %v", dbName, err)
 			time.Sleep(50 * time.Millisecond)
 		} else {
 			return conn, nil
@@ -190,67 +1757,274 @@ func retryMakeActiveConn(db *sql.DB, dbName string, ctx context.Context) (*sql.C
 	}
 }
 
-// runWorker gets one sql.Conn from the pool and continuously performs queries on that single connection.
-func runWorker(dbConn *sql.DB, dbName string, tables []TableInfo, sleepMs int, exitTime time.Time) {
+// runWorker gets one connection from reads and continuously performs queries on that single connection.
+// Writes run on the same connection unless reads are split to their own DSN (writes is another source than reads),
+// in which case the worker holds a second connection taken from writes.
+// It returns at exitTime or as soon as ctx is cancelled.
+func runWorker(ctx context.Context, reads, writes connSource, dbName string, worker int, opts *workloadOptions) {
+	class := opts.tenantClass(dbName)
+	mix, tables, pages := class.mix, class.tables, class.pages
+	stats := opts.stats.Tenant(dbName)
 	// Get a dedicated connection from the pool.
-	ctx := context.Background()
-	conn, err := retryMakeActiveConn(dbConn, dbName, ctx)
+	conn, err := retryMakeActiveConn(reads, dbName, ctx)
 	if err != nil {
 		log.Printf("[ERROR] Failed to get conn for DB %s: %v", dbName, err)
 		return
 	}
-	defer conn.Close()
+	// conn may be replaced after a reconnect, so close whichever one is current on exit.
+	defer func() { conn.Close() }()
+	writeConn := &lazyConn{src: writes}
+	defer writeConn.Close()
+
+	killSwitch := opts.killer.Register()
+	defer opts.killer.Unregister(killSwitch)
+	injectLatency := opts.latency.Applies(dbName)
+	aimd := opts.aimd.Tenant(dbName)
+	governor := opts.governor.Tenant(dbName)
+	circuit := opts.breaker.Tenant(dbName)
+	limiter := opts.tiers.Limiter(dbName)
+	sweep := opts.sweep.Tenant(dbName)
+	phase := opts.scenario.Tenant(dbName)
+	cache := opts.cache.Tenant(dbName, opts.startTime)
+	asOf := opts.historicalStatement(dbName)
+	// The hot path draws from the worker's own random source, reuses its argument slice,
+	// and builds every statement only once per query type and table.
+	rng := newWorkerRand(dbName, worker)
+	recycleAt := opts.recycler.Deadline(rng)
+	queries := make(queryCache)
+	var args []interface{}
 
 	// do a join select sql
-	_ = doJoinSelectRawDB(conn, ctx, 900)
+	_ = doJoinSelectRawDB(conn, ctx, 900, dbName, worker, opts, rng)
 
 	// Infinite loop to continuously send queries.
 	for {
-		// Randomly pick a table
-		tableInfo := tables[rand.Intn(len(tables))]
+		// Adaptive concurrency: workers above the tenant's current limit idle, keeping their connection.
+		if !aimd.Admit(worker) {
+			if !sleepUntilExit(ctx, aimdIdleInterval, opts.exitTime) {
+				break
+			}
+			continue
+		}
+
+		// Sweep: workers above the current step's concurrency idle, keeping their connection.
+		if !sweep.Admit(worker) {
+			if !sleepUntilExit(ctx, aimdIdleInterval, opts.exitTime) {
+				break
+			}
+			continue
+		}
+
+		// Scenario: workers above the current phase's concurrency of the tenant idle, keeping their connection.
+		if !phase.Admit(worker) {
+			if !sleepUntilExit(ctx, aimdIdleInterval, opts.exitTime) {
+				break
+			}
+			continue
+		}
+
+		// Quiet window: only the first workers of every tenant keep running, as a baseline.
+		if !opts.quiet.Admit(worker) {
+			if !sleepUntilExit(ctx, aimdIdleInterval, opts.exitTime) {
+				break
+			}
+			continue
+		}
 
-		// Generate a random 'k' value within [MinK, MaxK]
-		kVal := rand.Intn(tableInfo.MaxK-tableInfo.MinK+1) + tableInfo.MinK
+		// Paused through the control API: idle on the connection until resumed.
+		if opts.pauses.Paused(dbName) {
+			if !sleepUntilExit(ctx, pauseCheckInterval, opts.exitTime) {
+				break
+			}
+			continue
+		}
 
-		// Build the query: SELECT c FROM sbtestXYZ WHERE k=? LIMIT 1
-		query := fmt.Sprintf("SELECT c FROM %s WHERE k=? LIMIT 1", tableInfo.Name)
+		// Chaos mode: drop the connection underneath us, the next query then takes the reconnect path.
+		if killSwitch.Take() {
+			killConn(conn)
+			stats.RecordKill()
+		}
+
+		// Connection recycling: past its lifetime the connection is dropped and a new one is established.
+		if opts.recycler.Due(recycleAt) {
+			discardConn(conn)
+			recycleStart := time.Now()
+			newConn, err := retryMakeActiveConn(reads, dbName, ctx)
+			if err != nil {
+				return
+			}
+			conn = newConn
+			stats.RecordRecycle(time.Since(recycleStart))
+			recycleAt = opts.recycler.Deadline(rng)
+		}
+
+		// Randomly pick a query type and a table, e.g. SELECT c FROM sbtestXYZ WHERE k=? LIMIT 1
+		qt := mix.Pick(rng)
+		tableIndex := rng.IntN(len(tables))
+		if pages != nil {
+			tableIndex = pages.Table(rng, len(tables))
+		}
+		tableInfo := tables[tableIndex]
+
+		// Client-side cache: a read served by the tenant's cache is counted as a hit and never sent, keeping the pace.
+		if !qt.write && cache.Hit(rng) {
+			stats.RecordCacheHit()
+			if !sleepUntilExit(ctx, time.Duration(opts.sleepMs)*time.Millisecond, opts.exitTime) {
+				break
+			}
+			continue
+		}
+		query := queries.Get(qt, tableIndex, func() string {
+			query := qt.sql(opts.views.Table(qt, tableInfo))
+			query = opts.tiflash.Hint(dbName, qt, tableInfo, query)
+			query = opts.hints.Apply(dbName, qt, tableInfo, query)
+			query = opts.annotate.Apply(dbName, qt.name, qt.write, tableInfo, query)
+			return opts.tags.Tag(query, dbName, worker, qt.name)
+		})
+		if pages != nil {
+			args = pages.Args(tableInfo, args[:0])
+		} else {
+			args = qt.args(tableInfo, opts, rng, args[:0])
+		}
+
+		// Circuit breaker: while the tenant's circuit is open, its queries are not sent nor counted.
+		if !circuit.Allow() {
+			if !sleepUntilExit(ctx, breakerIdleInterval, opts.exitTime) {
+				break
+			}
+			continue
+		}
+
+		// Simulate a slow or remote client: the connection sits idle before the query is sent.
+		// The delay is not part of the measured query latency.
+		if injectLatency && !sleepCtx(ctx, opts.latency.Delay(rng)) {
+			break
+		}
+
+		// Tier rate limiting: wait for the tenant's QPS ceiling and the platform-wide admission.
+		if !limiter.Wait(ctx) {
+			break
+		}
+		// Sweep by QPS: wait for the tenant's rate of the current step.
+		if !sweep.Wait(ctx) {
+			break
+		}
+		// Scenario: wait for the tenant's QPS cap of the current phase.
+		if !phase.Wait(ctx) {
+			break
+		}
+		// GC pressure: wait for the tenant's rewrite rate.
+		if class.limiter != nil && !class.limiter.Wait(ctx) {
+			break
+		}
+		// Global QPS cap: wait for the aggregate rate of all tenants, after the tenant's own limits.
+		if !opts.globalQPS.Wait(ctx) {
+			break
+		}
 
 		// Measure query time
 		start := time.Now()
 
-		if start.After(exitTime) {
+		if start.After(opts.exitTime) || ctx.Err() != nil {
 			break
 		}
 
-		// Use QueryRowContext on the single *sql.Conn
-		row := conn.QueryRowContext(ctx, query, kVal)
+		// Writes go to the write pool when reads are split from it.
+		queryConn := conn
+		if qt.write && writes != reads {
+			if queryConn, err = writeConn.Get(ctx, dbName); err != nil {
+				break
+			}
+		}
 
-		var cVal string
-		err := row.Scan(&cVal)
+		// Global in-flight limit: wait for one of the -max-inflight slots. The wait is not part of the measured latency.
+		if opts.inflight != nil {
+			if !opts.inflight.Acquire(ctx) {
+				break
+			}
+			start = time.Now()
+		}
+
+		// Cancellation exercise: a picked query is cancelled after -cancel-after-ms and counted on its own.
+		// The connection id lookup of KILL QUERY is not part of the measured latency.
+		queryCtx, armed := opts.cancels.Arm(ctx, dbName, queryConn, writes, rng)
+		if armed != nil {
+			start = time.Now()
+		}
+		// Historical read: the tenant's snapshot is set on the connection right before the read, outside the measured latency.
+		var err error
+		if asOf != "" && opts.historical.Applies(qt) {
+			err = opts.historical.Set(queryCtx, queryConn, asOf)
+			start = time.Now()
+		}
+		// Retryable transaction errors run the query again; its latency includes the retries.
+		volume := resultVolume{BytesOut: statementBytes(query, args)}
+		if err == nil {
+			err = opts.retries.Run(queryCtx, stats, func() error {
+				return runQuery(queryCtx, queryConn, qt, tableInfo, query, args, &volume)
+			})
+		}
 		duration := time.Since(start)
+		opts.inflight.Release()
+		cancelled := armed.Disarm(err)
+		if cancelled {
+			opts.cancels.Record(duration)
+		} else {
+			opts.observeQuery(stats, dbName, qt.name, start, query, args, duration, err)
+			opts.coCorrect.Record(stats, duration, err)
+			circuit.Record(err)
+			stats.RecordVolume(qt.name, volume)
+		}
+
+		// Plan sampling runs after the measured query, on the same connection, and is not part of the statistics.
+		if (err == nil || err == sql.ErrNoRows) && qt.run == nil && opts.explain.Sample(rng) {
+			if explainErr := opts.explain.Explain(ctx, queryConn, dbName, qt, query, args); explainErr != nil {
+				workerLog.Printf(logClass("explain failed", explainErr), "[WARNING] DB=%s EXPLAIN of %s failed: %v", dbName, qt.name, explainErr)
+			}
+		}
 
 		// If there's an error and it's not a "no rows" case, log it.
 		if err != nil && err != sql.ErrNoRows {
-			log.Printf("[ERROR] DB=%s table=%s k=%d query failed: %v", dbName, tableInfo.Name, kVal, err)
-			conn, _ = retryMakeActiveConn(dbConn, dbName, ctx)
-		} else {
-			// Optionally log or collect the duration metrics here.
-			// log.Printf("[INFO] DB=%s table=%s k=%d took=%v", dbName, tableInfo.Name, kVal, duration)
-			_ = duration
+			if !cancelled {
+				workerLog.Printf(logClass("query failed", err), "[ERROR] DB=%s table=%s type=%s query failed: %v", dbName, tableInfo.Name, qt.name, err)
+			}
+			switch {
+			case opts.retries.KeepsConn(err):
+				// The server rejected the transaction after all retries, the connection is still good.
+			case queryConn != conn:
+				// The write connection is re-established on its next use.
+				writeConn.Close()
+			default:
+				conn.Close()
+				reconnectStart := time.Now()
+				newConn, err := retryMakeActiveConn(reads, dbName, ctx)
+				if err != nil {
+					return
+				}
+				conn = newConn
+				stats.RecordReconnect(time.Since(reconnectStart))
+				recycleAt = opts.recycler.Deadline(rng)
+			}
 		}
 
-		// Sleep to control QPS
-		time.Sleep(time.Duration(sleepMs) * time.Millisecond)
+		// Sleep to control QPS; GC-pressure tenants are paced by their rewrite rate instead.
+		if class.limiter == nil {
+			sleepCtx(ctx, time.Duration(opts.sleepMs)*time.Millisecond)
+		}
+		// Backoff governor: stretch the time of this query to the governed issue rate.
+		if !governor.Pace(ctx, time.Since(start)) {
+			break
+		}
 	}
 }
 
-func doJoinSelectRawDB(conn *sql.Conn, ctx context.Context, maxId uint64) error {
+func doJoinSelectRawDB(conn queryExecutor, ctx context.Context, maxId uint64, dbName string, worker int, opts *workloadOptions, rng *rand.Rand) error {
 	// do Join select query
 	// table : sysbench.sbtest1
 	// id: 1~maxID
 	// limit 100
 	// left join : sbtest1 , sbtest2 , sbtest3 , sbtest4 on `id` colunm (as same value)
-	randID := uint64(rand.Int63n(int64(maxId)-100)) + 1
+	randID := uint64(rng.Int64N(int64(maxId)-100)) + 1
 	var result SysbenchRow
 
 	/*
@@ -270,17 +2044,57 @@ func doJoinSelectRawDB(conn *sql.Conn, ctx context.Context, maxId uint64) error
 	           randID,
 	       )
 	*/
-	rows, err := conn.QueryContext(
-		ctx,
-		`select (sbtest1.id) as id, sbtest2.k as k, sbtest3.c as c, sbtest4.pad as pad
-from sbtest1
-LEFT JOIN sbtest2 ON sbtest1.id = sbtest2.id
-LEFT JOIN sbtest3 ON sbtest1.id = sbtest3.id
-LEFT JOIN sbtest4 ON sbtest1.id = sbtest4.id
-Where sbtest1.id >= ?
-limit 100`,
-		randID,
-	)
+	query, args := joinSelectQuery(opts.tenancy, opts.tableNames, dbName, randID)
+	if opts.views != nil {
+		query, args = opts.views.JoinQuery(opts.tenancy, dbName, randID)
+	}
+	query = opts.annotate.Apply(dbName, "join", false, TableInfo{Name: opts.tenancy.TableName(dbName, opts.tableNames.Name(1))}, query)
+	query = opts.tags.Tag(query, dbName, worker, "join")
+	volume := resultVolume{BytesOut: statementBytes(query, args)}
+	start := time.Now()
+	err := scanJoinRows(conn, ctx, query, args, &result, &volume)
+	duration := time.Since(start)
+	stats := opts.stats.Tenant(dbName)
+	opts.observeQuery(stats, dbName, "join", start, query, args, duration, err)
+	stats.RecordVolume("join", volume)
+	return err
+}
+
+// joinSelectQuery returns the join query over the tenant's tables 1 ~ 4.
+// The tables are aliased sbtest1 ~ sbtest4, and in the row tenancy model the join is restricted to the tenant's rows.
+func joinSelectQuery(tenancy *tenancyModel, names tableNameTemplate, tenant string, randID uint64) (string, []interface{}) {
+	table := func(i int) string {
+		alias := fmt.Sprintf("sbtest%d", i)
+		if own := tenancy.TableName(tenant, names.Name(i)); own != alias {
+			return own + " AS " + alias
+		}
+		return alias
+	}
+	joinTenant, whereTenant, args := "", "", []interface{}{randID}
+	if tenancy.kind == "row" {
+		joinTenant = " AND sbtest1.tenant_id = %s.tenant_id"
+		whereTenant = "sbtest1.tenant_id = ? AND "
+		args = []interface{}{tenantID(tenant), randID}
+	}
+	join := func(i int) string {
+		alias := fmt.Sprintf("sbtest%d", i)
+		cond := "sbtest1.id = " + alias + ".id"
+		if joinTenant != "" {
+			cond += fmt.Sprintf(joinTenant, alias)
+		}
+		return fmt.Sprintf("LEFT JOIN %s ON %s\n", table(i), cond)
+	}
+	query := `select (sbtest1.id) as id, sbtest2.k as k, sbtest3.c as c, sbtest4.pad as pad
+from ` + table(1) + "\n" +
+		join(2) + join(3) + join(4) +
+		"Where " + whereTenant + `sbtest1.id >= ?
+limit 100`
+	return query, args
+}
+
+// scanJoinRows runs the join query and scans every returned row into result, adding the rows and their values to volume.
+func scanJoinRows(conn queryExecutor, ctx context.Context, query string, args []interface{}, result *SysbenchRow, volume *resultVolume) error {
+	rows, err := conn.QueryContext(ctx, query, args...)
 	if err != nil {
 		return err
 	}
@@ -291,6 +2105,8 @@ limit 100`,
 		if err := rows.Scan(&result.ID, &result.K, &result.C, &result.Pad); err != nil {
 			return err
 		}
+		volume.Rows++
+		volume.BytesIn += 16 + uint64(len(result.C)+len(result.Pad)) // id and k count 8 bytes each
 	}
-	return nil
+	return rows.Err()
 }
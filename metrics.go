@@ -0,0 +1,174 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Formats of the push-based metric sinks.
+const (
+	metricsStatsD = "statsd"
+	metricsInflux = "influxdb"
+)
+
+// metricsMaxPacket keeps every UDP datagram below a common MTU, so no metric is lost to fragmentation.
+const metricsMaxPacket = 1400
+
+// metricsPusher pushes the throughput, errors and latency of every tenant and query type of the last interval
+// to a StatsD server or an InfluxDB line protocol endpoint (e.g. the socket_listener or http_listener_v2 of Telegraf),
+// for labs where metrics can only be collected by push. InfluxDB points are tagged with the run ID; StatsD has no tags,
+// so its buckets are the same for every run.
+type metricsPusher struct {
+	format   string // metricsStatsD or metricsInflux
+	prefix   string // StatsD bucket prefix or InfluxDB measurement name
+	runID    string // InfluxDB tag of every point
+	interval time.Duration
+	conn     net.Conn // UDP or TCP endpoint, nil for HTTP
+	url      string   // InfluxDB HTTP write URL, e.g. http://127.0.0.1:8086/write?db=workload
+}
+
+// newMetricsPusher connects to the sink at addr: host:port (UDP), tcp://host:port, udp://host:port, or an http(s) URL
+// for InfluxDB. It returns nil when format is "".
+func newMetricsPusher(format, addr, prefix, runID string, interval time.Duration) (*metricsPusher, error) {
+	if format == "" {
+		return nil, nil
+	}
+	if format != metricsStatsD && format != metricsInflux {
+		return nil, fmt.Errorf("unknown metrics sink %q (want %s or %s)", format, metricsStatsD, metricsInflux)
+	}
+	if interval <= 0 {
+		return nil, fmt.Errorf("metrics interval must be positive")
+	}
+	p := &metricsPusher{format: format, prefix: prefix, runID: runID, interval: interval}
+	if strings.HasPrefix(addr, "http://") || strings.HasPrefix(addr, "https://") {
+		if format != metricsInflux {
+			return nil, fmt.Errorf("HTTP address %q is only supported by the %s sink", addr, metricsInflux)
+		}
+		p.url = addr
+		return p, nil
+	}
+	network := "udp"
+	if rest, ok := strings.CutPrefix(addr, "tcp://"); ok {
+		network, addr = "tcp", rest
+	} else if rest, ok := strings.CutPrefix(addr, "udp://"); ok {
+		addr = rest
+	}
+	conn, err := net.DialTimeout(network, addr, 5*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	p.conn = conn
+	return p, nil
+}
+
+// Run pushes the metrics of every interval until ctx is done, then closes the connection.
+func (p *metricsPusher) Run(ctx context.Context, stats *statsCollector) {
+	defer func() {
+		if p.conn != nil {
+			p.conn.Close()
+		}
+	}()
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+	previous := make(map[string]map[string]*queryTypeStats)
+	previousConnect := make(map[string]*queryTypeStats)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		now := time.Now()
+		snapshot := stats.Snapshot()
+		var lines []string
+		for _, tenant := range snapshot.TenantNames() {
+			current := snapshot.Tenants[tenant].Types
+			prevTypes := previous[tenant]
+			for _, name := range sortedTypeNames(current) {
+				prev, ok := prevTypes[name]
+				if !ok {
+					prev = newQueryTypeStats()
+				}
+				lines = append(lines, p.points(now, tenant, name, current[name].Since(prev))...)
+			}
+			previous[tenant] = current
+
+			// Connection establishment is pushed like a query type named "connect".
+			t := snapshot.Tenants[tenant]
+			connect := &queryTypeStats{Queries: t.Connects, Errors: t.ConnectErrors, Latency: t.ConnectTime}
+			prev, ok := previousConnect[tenant]
+			if !ok {
+				prev = newQueryTypeStats()
+			}
+			lines = append(lines, p.points(now, tenant, "connect", connect.Since(prev))...)
+			previousConnect[tenant] = connect
+		}
+		if err := p.send(lines); err != nil {
+			log.Printf("[WARNING] Failed to push metrics to the %s sink: %v", p.format, err)
+		}
+	}
+}
+
+// points returns the lines of one tenant and query type in the sink's format.
+func (p *metricsPusher) points(now time.Time, tenant, queryType string, q *queryTypeStats) []string {
+	qps := float64(q.Queries) / p.interval.Seconds()
+	ms := func(quantile float64) float64 {
+		return float64(q.Latency.Quantile(quantile).Microseconds()) / 1000
+	}
+	if p.format == metricsStatsD {
+		bucket := fmt.Sprintf("%s.%s.%s.", p.prefix, tenant, queryType)
+		return []string{
+			fmt.Sprintf("%squeries:%d|c", bucket, q.Queries),
+			fmt.Sprintf("%serrors:%d|c", bucket, q.Errors),
+			fmt.Sprintf("%sqps:%.2f|g", bucket, qps),
+			fmt.Sprintf("%sp50_ms:%.3f|g", bucket, ms(0.50)),
+			fmt.Sprintf("%sp95_ms:%.3f|g", bucket, ms(0.95)),
+			fmt.Sprintf("%sp99_ms:%.3f|g", bucket, ms(0.99)),
+		}
+	}
+	return []string{fmt.Sprintf("%s,run=%s,tenant=%s,type=%s queries=%di,errors=%di,qps=%.2f,p50_ms=%.3f,p95_ms=%.3f,p99_ms=%.3f %d",
+		p.prefix, influxTag(p.runID), tenant, queryType, q.Queries, q.Errors, qps, ms(0.50), ms(0.95), ms(0.99), now.UnixNano())}
+}
+
+// send writes the lines to the sink: in datagrams of up to metricsMaxPacket bytes over UDP,
+// as a stream over TCP, or in one write request over HTTP.
+func (p *metricsPusher) send(lines []string) error {
+	if len(lines) == 0 {
+		return nil
+	}
+	if p.url != "" {
+		resp, err := http.Post(p.url, "text/plain; charset=utf-8", strings.NewReader(strings.Join(lines, "\n")+"\n"))
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		if resp.StatusCode/100 != 2 {
+			return fmt.Errorf("%s: %s", p.url, resp.Status)
+		}
+		return nil
+	}
+	var packet bytes.Buffer
+	for _, line := range lines {
+		if packet.Len() > 0 && packet.Len()+len(line)+1 > metricsMaxPacket {
+			if _, err := p.conn.Write(packet.Bytes()); err != nil {
+				return err
+			}
+			packet.Reset()
+		}
+		packet.WriteString(line)
+		packet.WriteByte('\n')
+	}
+	_, err := p.conn.Write(packet.Bytes())
+	return err
+}
+
+// influxTag escapes the characters with a meaning in InfluxDB line protocol tag values.
+func influxTag(s string) string {
+	return strings.NewReplacer(",", `\,`, "=", `\=`, " ", `\ `).Replace(s)
+}
@@ -0,0 +1,305 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"math/rand/v2"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// sqlDriverName is the database/sql driver every tenant pool is opened with: "mysql", or "mock" for offline runs.
+var sqlDriverName = "mysql"
+
+// mockSettings shape the behavior of the mock driver.
+var mockSettings struct {
+	latency   time.Duration // fixed latency of every statement
+	jitter    time.Duration // uniform random extra latency
+	errorRate float64       // fraction of statements failing
+}
+
+// errMockInjected is returned by statements the mock driver fails on purpose.
+var errMockInjected = errors.New("mock: injected error")
+
+func init() {
+	sql.Register("mock", mockDriver{})
+}
+
+// mockDriver is a database/sql driver without a database: statements only wait for a simulated latency
+// and reads return synthetic rows, so workload configs and metrics can be developed without MySQL/TiDB.
+// It accepts any DSN; the connections of a DSN registered with registerMockScript follow its script.
+type mockDriver struct{}
+
+func (mockDriver) Open(name string) (driver.Conn, error) {
+	c := &mockConn{rng: newWorkerRand("mock", nextMockConnIndex())}
+	if s, ok := mockScripts.Load(name); ok {
+		c.script = s.(*mockScript)
+	}
+	return c, nil
+}
+
+// mockConn is one simulated connection. Once closed (e.g. by the chaos mode) every statement fails
+// with driver.ErrBadConn, like a connection dropped by the server.
+type mockConn struct {
+	closed int32
+	rng    *rand.Rand  // database/sql uses a connection from one goroutine at a time
+	script *mockScript // nil when unscripted
+}
+
+// mockScripts are the scripts of the mock DSNs, by DSN.
+var mockScripts sync.Map
+
+// mockScript scripts the connections of a mock DSN, for the tests: it records every statement and answers it with the
+// first response whose match is part of the statement. Statements without a response are simulated as usual.
+type mockScript struct {
+	mu         sync.Mutex
+	statements []string // "exec UPDATE ...", "query SELECT ...", "begin", "commit", "rollback"
+	responses  []*mockResponse
+}
+
+type mockResponse struct {
+	match   string
+	columns []string
+	rows    [][]driver.Value
+	err     error
+	times   int // answers left, 0 = unlimited
+}
+
+// registerMockScript makes the connections of the mock DSN follow a new script, until unregister is called.
+func registerMockScript(dsn string) (s *mockScript, unregister func()) {
+	s = &mockScript{}
+	mockScripts.Store(dsn, s)
+	return s, func() { mockScripts.Delete(dsn) }
+}
+
+// Rows makes the statements containing match return rows of the columns.
+func (s *mockScript) Rows(match string, columns []string, rows ...[]driver.Value) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.responses = append(s.responses, &mockResponse{match: match, columns: columns, rows: rows})
+}
+
+// Fail makes the next times statements containing match fail with err, every one if times is 0.
+func (s *mockScript) Fail(match string, err error, times int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.responses = append(s.responses, &mockResponse{match: match, err: err, times: times})
+}
+
+// Statements returns the statements run so far, in order.
+func (s *mockScript) Statements() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.statements...)
+}
+
+// answer records a statement and returns its response, nil if it has none. A nil *mockScript records nothing.
+func (s *mockScript) answer(kind, query string) *mockResponse {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.statements = append(s.statements, strings.TrimSpace(kind+" "+query))
+	for i, r := range s.responses {
+		if !strings.Contains(query, r.match) {
+			continue
+		}
+		if r.times > 0 {
+			if r.times--; r.times == 0 {
+				s.responses = append(s.responses[:i:i], s.responses[i+1:]...)
+			}
+		}
+		return r
+	}
+	return nil
+}
+
+var (
+	_ driver.QueryerContext = (*mockConn)(nil)
+	_ driver.ExecerContext  = (*mockConn)(nil)
+	_ driver.Pinger         = (*mockConn)(nil)
+)
+
+func (c *mockConn) Prepare(query string) (driver.Stmt, error) {
+	return nil, errors.New("mock: prepared statements are not supported")
+}
+
+func (c *mockConn) Close() error {
+	atomic.StoreInt32(&c.closed, 1)
+	return nil
+}
+
+func (c *mockConn) Begin() (driver.Tx, error) {
+	c.script.answer("begin", "")
+	return mockTx{c.script}, nil
+}
+
+func (c *mockConn) Ping(ctx context.Context) error {
+	if atomic.LoadInt32(&c.closed) != 0 {
+		return driver.ErrBadConn
+	}
+	return nil
+}
+
+// simulate waits for the statement's latency and decides whether it fails.
+func (c *mockConn) simulate(ctx context.Context) error {
+	if atomic.LoadInt32(&c.closed) != 0 {
+		return driver.ErrBadConn
+	}
+	d := mockSettings.latency
+	if mockSettings.jitter > 0 {
+		d += time.Duration(c.rng.Int64N(int64(mockSettings.jitter) + 1))
+	}
+	if !sleepCtx(ctx, d) {
+		return ctx.Err()
+	}
+	if mockSettings.errorRate > 0 && c.rng.Float64() < mockSettings.errorRate {
+		return errMockInjected
+	}
+	return nil
+}
+
+func (c *mockConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	if r := c.script.answer("exec", query); r != nil && r.err != nil {
+		return nil, r.err
+	}
+	if err := c.simulate(ctx); err != nil {
+		return nil, err
+	}
+	return driver.RowsAffected(1), nil
+}
+
+func (c *mockConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	r := c.script.answer("query", query)
+	if r != nil && r.err != nil {
+		return nil, r.err
+	}
+	if err := c.simulate(ctx); err != nil {
+		return nil, err
+	}
+	if r != nil {
+		return &mockScriptRows{columns: r.columns, rows: r.rows}, nil
+	}
+	return newMockRows(query, c.rng), nil
+}
+
+type mockTx struct {
+	script *mockScript
+}
+
+func (t mockTx) Commit() error {
+	t.script.answer("commit", "")
+	return nil
+}
+
+func (t mockTx) Rollback() error {
+	t.script.answer("rollback", "")
+	return nil
+}
+
+// mockScriptRows returns the rows of a scripted response.
+type mockScriptRows struct {
+	columns []string
+	rows    [][]driver.Value
+}
+
+func (r *mockScriptRows) Columns() []string { return r.columns }
+
+func (r *mockScriptRows) Close() error { return nil }
+
+func (r *mockScriptRows) Next(dest []driver.Value) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.rows[0])
+	r.rows = r.rows[1:]
+	return nil
+}
+
+var (
+	mockSelectList = regexp.MustCompile(`(?is)^\s*(?:/\*.*?\*/\s*)*(?:EXPLAIN\s+(?:ANALYZE\s+)?)?(?:/\*.*?\*/\s*)*SELECT\s+(.*?)\s+FROM\s`)
+	mockLimit      = regexp.MustCompile(`(?i)\bLIMIT\s+(\d+)\s*$`)
+)
+
+// mockRows returns synthetic rows shaped after the select list: integers for id/k/count-like
+// columns and sysbench-like strings otherwise. Statements without a recognizable select list return one column.
+type mockRows struct {
+	rng     *rand.Rand
+	columns []string
+	numeric []bool
+	left    int
+}
+
+func newMockRows(query string, rng *rand.Rand) *mockRows {
+	r := &mockRows{rng: rng, columns: []string{"value"}, numeric: []bool{false}, left: 1}
+	if m := mockSelectList.FindStringSubmatch(query); m != nil {
+		r.columns, r.numeric = nil, nil
+		for _, expr := range splitSelectList(m[1]) {
+			name := strings.ToLower(expr)
+			if i := strings.LastIndexAny(name, " ."); i >= 0 {
+				name = name[i+1:]
+			}
+			name = strings.Trim(name, "`()")
+			r.columns = append(r.columns, name)
+			r.numeric = append(r.numeric, name == "id" || name == "k" || strings.Contains(name, "count") || strings.HasSuffix(name, "_id"))
+		}
+	}
+	if m := mockLimit.FindStringSubmatch(strings.TrimSpace(query)); m != nil {
+		if n, err := strconv.Atoi(m[1]); err == nil {
+			r.left = n
+		}
+	}
+	if r.left > 100 {
+		r.left = 100
+	}
+	return r
+}
+
+// splitSelectList splits a select list at the commas outside parentheses.
+func splitSelectList(list string) []string {
+	var items []string
+	depth, start := 0, 0
+	for i, ch := range list {
+		switch ch {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				items = append(items, strings.TrimSpace(list[start:i]))
+				start = i + 1
+			}
+		}
+	}
+	return append(items, strings.TrimSpace(list[start:]))
+}
+
+func (r *mockRows) Columns() []string { return r.columns }
+
+func (r *mockRows) Close() error { return nil }
+
+func (r *mockRows) Next(dest []driver.Value) error {
+	if r.left <= 0 {
+		return io.EOF
+	}
+	r.left--
+	for i := range dest {
+		if strings.Contains(r.columns[i], "count") || strings.HasPrefix(r.columns[i], "sum(") {
+			dest[i] = int64(0) // tables look empty, e.g. to prepare mode and the bank checks
+		} else if r.numeric[i] {
+			dest[i] = int64(r.rng.IntN(1000000) + 1)
+		} else {
+			dest[i] = []byte(sysbenchString(r.rng, 20))
+		}
+	}
+	return nil
+}
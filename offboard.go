@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// offboardEvent is the offboarding of one tenant: its workers drained and its database dropped.
+type offboardEvent struct {
+	tenant string
+	at     time.Duration // offset from the start of the run
+	drain  time.Duration // from the offset until the workers stopped and the pools were closed
+	drop   time.Duration
+	err    error
+	// before and after are the queries of the other tenants in the observation windows before the offboarding and
+	// after the drop, of the given seconds.
+	before, after               *queryTypeStats
+	beforeSeconds, afterSeconds float64
+}
+
+// tenantOffboarding removes tenants while the run goes on, for measuring the impact of the mass delete and its garbage
+// collection on the tenants still running: at its offset an offboarded tenant's workers are drained and its pools
+// closed, then its database and its provisioned users are dropped. The other tenants' latency in an observation window
+// after the drop is compared with the same window before the offboarding. A nil *tenantOffboarding keeps every tenant
+// until the end of the run.
+type tenantOffboarding struct {
+	rules   []tenantRule[time.Duration] // offsets from the start of the run
+	observe time.Duration
+
+	mu     sync.Mutex
+	events []offboardEvent
+}
+
+// loadOffboardFile reads an offboarding file with one "tenants offset" entry per line, e.g. "3-4 10m"; see
+// parseTenantRules and parseOffset. The neighbors are observed for observe before the offboarding and after the drop.
+func loadOffboardFile(path string, observe time.Duration) (*tenantOffboarding, error) {
+	if observe <= 0 {
+		return nil, fmt.Errorf("the observation window must be positive")
+	}
+	rules, err := parseTenantRules(path, "offset", parseOffset)
+	if err != nil {
+		return nil, err
+	}
+	return &tenantOffboarding{rules: rules, observe: observe}, nil
+}
+
+// At returns the offset a tenant is offboarded at, if it is offboarded during the run.
+func (o *tenantOffboarding) At(tenant string) (time.Duration, bool) {
+	if o == nil {
+		return 0, false
+	}
+	return matchTenantRule(o.rules, tenant)
+}
+
+// Run runs a tenant with run until its offset, then offboards it: run gets options ending at the offset, so the
+// tenant's workers finish their queries in flight and stop like at the end of the run, and returns once they are
+// drained. Then the tenant's database and users are dropped. A tenant that stops before the offset, e.g. one that
+// failed to connect or to onboard, is dropped at the offset all the same. The neighbors are observed until the end of
+// the observation window, or of the run. A run that ends before the offset leaves the tenant in place.
+func (o *tenantOffboarding) Run(ctx context.Context, dsns *dsnResolver, dbName string, at time.Duration, opts *workloadOptions,
+	run func(opts *workloadOptions)) {
+	offset := opts.startTime.Add(at)
+	// The neighbors' baseline starts an observation window before the offset, or at the start of the run.
+	baseline := make(chan *statsSnapshot, 1)
+	timer := time.AfterFunc(time.Until(offset.Add(-o.observe)), func() { baseline <- opts.stats.Snapshot() })
+	defer timer.Stop()
+
+	tenantOpts := *opts
+	if offset.Before(opts.exitTime) {
+		tenantOpts.exitTime = offset
+	}
+	run(&tenantOpts)
+	if !sleepUntilExit(ctx, time.Until(offset), opts.exitTime) || !time.Now().Before(opts.exitTime) {
+		return
+	}
+	event := offboardEvent{tenant: dbName, at: at, drain: time.Since(offset)}
+	before, drained := <-baseline, opts.stats.Snapshot()
+	event.before = neighborStats(drained, dbName).Since(neighborStats(before, dbName))
+	event.beforeSeconds = drained.ElapsedSeconds - before.ElapsedSeconds
+	log.Printf("[INFO] offboarding: DB %s drained in %v, dropping it", dbName, event.drain.Round(time.Millisecond))
+
+	start := time.Now()
+	if provisionedUser(dsns, opts.users, dbName) {
+		if err := dropTenantUser(ctx, dsns.Driver(dbName), dsns.DSN(dbName), opts.users, dbName); err != nil {
+			event.err = fmt.Errorf("drop user: %v", err)
+		}
+	}
+	if event.err == nil {
+		if err := dropDatabase(ctx, dsns.Driver(dbName), dsns.DSN(dbName), opts.tenancy.Database(dbName)); err != nil {
+			event.err = fmt.Errorf("drop database: %v", err)
+		}
+	}
+	event.drop = time.Since(start)
+	if event.err != nil {
+		log.Printf("[ERROR] offboarding: DB %s: %v", dbName, event.err)
+	} else {
+		log.Printf("[INFO] offboarding: DB %s dropped in %v", dbName, event.drop.Round(time.Millisecond))
+	}
+
+	dropped := opts.stats.Snapshot()
+	sleepUntilExit(ctx, o.observe, opts.exitTime)
+	observed := opts.stats.Snapshot()
+	event.after = neighborStats(observed, dbName).Since(neighborStats(dropped, dbName))
+	event.afterSeconds = observed.ElapsedSeconds - dropped.ElapsedSeconds
+	o.mu.Lock()
+	o.events = append(o.events, event)
+	o.mu.Unlock()
+}
+
+// logOffboardSummary logs every offboarding with the queries of the other tenants before it and after the drop.
+func (o *tenantOffboarding) logOffboardSummary() {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	rate := func(q *queryTypeStats, seconds float64) float64 {
+		if seconds <= 0 {
+			return 0
+		}
+		return float64(q.Queries) / seconds
+	}
+	for _, e := range o.events {
+		status := "dropped in " + e.drop.Round(time.Millisecond).String()
+		if e.err != nil {
+			status = "drop failed: " + e.err.Error()
+		}
+		log.Printf("[INFO] Offboarding: DB=%s at %v drained in %v, %s", e.tenant, e.at, e.drain.Round(time.Millisecond), status)
+		log.Printf("[INFO] Offboarding: DB=%s neighbors qps=%.1f errors=%d p99=%v in %.0fs before, qps=%.1f errors=%d p99=%v in %.0fs after the drop",
+			e.tenant, rate(e.before, e.beforeSeconds), e.before.Errors, e.before.Latency.Quantile(0.99), e.beforeSeconds,
+			rate(e.after, e.afterSeconds), e.after.Errors, e.after.Latency.Quantile(0.99), e.afterSeconds)
+	}
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+// onboardEvent is the onboarding of one tenant: its database created and its tables loaded.
+type onboardEvent struct {
+	tenant string
+	at     time.Duration // offset from the start of the run
+	took   time.Duration
+	err    error
+	// neighbors are the queries of the other tenants while the tenant was created and loaded.
+	neighbors *queryTypeStats
+}
+
+// tenantOnboarding creates brand-new tenants while the run goes on, for measuring the operational impact of onboarding
+// on the tenants already running: at its offset an onboarded tenant's database is created and its tables are loaded
+// like in prepare mode, then its workers start. Onboarded tenants are left out of prepare mode, so they don't exist
+// before the run, and are dropped by cleanup mode like the others. A nil *tenantOnboarding starts every tenant with
+// the run.
+type tenantOnboarding struct {
+	rules     []tenantRule[time.Duration] // offsets from the start of the run
+	threads   int                         // concurrent table loaders of one tenant
+	batchRows int
+
+	mu     sync.Mutex
+	events []onboardEvent
+}
+
+// loadOnboardFile reads an onboarding file with one "tenants offset" entry per line, e.g.
+//
+//	9     60s
+//	10-12 5m
+//
+// tenants are names or 1-based ranges, "*" for every tenant; the offset from the start of the run is a duration
+// (90s, 5m) or seconds. The first matching line applies; tenants without one exist from the start. Empty lines and
+// lines starting with "#" are ignored. Tables are loaded with threads loaders in INSERTs of batchRows rows.
+func loadOnboardFile(path string, threads, batchRows int) (*tenantOnboarding, error) {
+	rules, err := parseTenantRules(path, "offset", parseOffset)
+	if err != nil {
+		return nil, err
+	}
+	return &tenantOnboarding{rules: rules, threads: threads, batchRows: batchRows}, nil
+}
+
+// At returns the offset a tenant is onboarded at, if it is onboarded during the run.
+func (o *tenantOnboarding) At(tenant string) (time.Duration, bool) {
+	if o == nil {
+		return 0, false
+	}
+	return matchTenantRule(o.rules, tenant)
+}
+
+// Initial returns the tenants that exist from the start of the run, in order.
+func (o *tenantOnboarding) Initial(tenantNames []string) []string {
+	if o == nil {
+		return tenantNames
+	}
+	initial := make([]string, 0, len(tenantNames))
+	for _, name := range tenantNames {
+		if _, ok := o.At(name); !ok {
+			initial = append(initial, name)
+		}
+	}
+	return initial
+}
+
+// Run onboards a tenant at its offset and runs it until the end of the run, or until ctx is done: it creates the
+// database, loads the tables, then connects and starts the workers. A tenant that fails to onboard doesn't run.
+func (o *tenantOnboarding) Run(ctx context.Context, dsns *dsnResolver, dbName string, threadsPerDB int, at time.Duration, opts *workloadOptions) {
+	if !sleepUntilExit(ctx, time.Until(opts.startTime.Add(at)), opts.exitTime) {
+		return
+	}
+	log.Printf("[INFO] onboarding: creating DB %s at %v", dbName, at)
+	before := opts.stats.Snapshot()
+	start := time.Now()
+	err := runPrepare(ctx, dsns, opts, []string{dbName}, o.threads, o.batchRows)
+	event := onboardEvent{tenant: dbName, at: at, took: time.Since(start), err: err,
+		neighbors: neighborStats(opts.stats.Snapshot(), dbName).Since(neighborStats(before, dbName))}
+	o.mu.Lock()
+	o.events = append(o.events, event)
+	o.mu.Unlock()
+	if err != nil {
+		log.Printf("[ERROR] onboarding: DB %s failed after %v: %v", dbName, event.took.Round(time.Millisecond), err)
+		return
+	}
+	log.Printf("[INFO] onboarding: DB %s created and loaded in %v", dbName, event.took.Round(time.Millisecond))
+	runConnectedTenant(ctx, dsns, dbName, threadsPerDB, opts)
+}
+
+// neighborStats returns the queries of all tenants but one.
+func neighborStats(snapshot *statsSnapshot, tenant string) *queryTypeStats {
+	neighbors := newQueryTypeStats()
+	for name, t := range snapshot.Tenants {
+		if name != tenant {
+			neighbors.merge(&queryTypeStats{Queries: t.Queries, Errors: t.Errors, Latency: t.Latency})
+		}
+	}
+	return neighbors
+}
+
+// logOnboardSummary logs every onboarding with the queries of the other tenants while it ran, against the rest of
+// the run.
+func (o *tenantOnboarding) logOnboardSummary(snapshot *statsSnapshot) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	for _, e := range o.events {
+		if e.err != nil {
+			log.Printf("[INFO] Onboarding: DB=%s at %v failed after %v: %v", e.tenant, e.at, e.took.Round(time.Millisecond), e.err)
+			continue
+		}
+		rest := neighborStats(snapshot, e.tenant).Since(e.neighbors)
+		log.Printf("[INFO] Onboarding: DB=%s at %v took %v, neighbors qps=%.1f errors=%d p99=%v while onboarding, p99=%v otherwise",
+			e.tenant, e.at, e.took.Round(time.Millisecond), float64(e.neighbors.Queries)/e.took.Seconds(), e.neighbors.Errors,
+			e.neighbors.Latency.Quantile(0.99), rest.Latency.Quantile(0.99))
+	}
+}
@@ -0,0 +1,303 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"math/rand/v2"
+	"slices"
+	"strings"
+)
+
+// Sizes of the order-entry schema when the oe_* query types run without order-entry settings.
+const (
+	defaultOrderEntryDistricts = 10
+	defaultOrderEntryCustomers = 300
+	defaultOrderEntryItems     = 10000
+)
+
+// orderEntryTableName is the base name of the order-entry tables of a tenant: oe_district, oe_customer, ... in the db
+// and row tenancy models, test0001_oe_district, ... in the schema model.
+const orderEntryTableName = "oe"
+
+// orderEntryTable is one table of the order-entry schema.
+type orderEntryTable struct {
+	suffix  string
+	columns []string
+	key     string // primary key columns, after tenant_id in the row tenancy model
+	index   string // secondary index columns, "" for none
+}
+
+// orderEntryTables are the tables of the order-entry schema, a much simplified TPC-C schema of one warehouse:
+// the item table is folded into the stock table and there is no history or new-order queue.
+var orderEntryTables = []orderEntryTable{
+	{suffix: "_district", columns: []string{"d_id INT NOT NULL", "d_next_o_id INT NOT NULL", "d_ytd DECIMAL(12,2) NOT NULL"}, key: "d_id"},
+	{suffix: "_customer", columns: []string{"c_d_id INT NOT NULL", "c_id INT NOT NULL", "c_last VARCHAR(16) NOT NULL",
+		"c_balance DECIMAL(12,2) NOT NULL", "c_ytd_payment DECIMAL(12,2) NOT NULL", "c_payment_cnt INT NOT NULL"}, key: "c_d_id, c_id"},
+	{suffix: "_stock", columns: []string{"s_i_id INT NOT NULL", "i_price DECIMAL(5,2) NOT NULL", "s_quantity INT NOT NULL",
+		"s_ytd INT NOT NULL", "s_order_cnt INT NOT NULL"}, key: "s_i_id"},
+	{suffix: "_orders", columns: []string{"o_d_id INT NOT NULL", "o_id INT NOT NULL", "o_c_id INT NOT NULL", "o_entry_d DATETIME NOT NULL",
+		"o_ol_cnt INT NOT NULL"}, key: "o_d_id, o_id", index: "o_d_id, o_c_id, o_id"},
+	{suffix: "_order_line", columns: []string{"ol_d_id INT NOT NULL", "ol_o_id INT NOT NULL", "ol_number INT NOT NULL", "ol_i_id INT NOT NULL",
+		"ol_quantity INT NOT NULL", "ol_amount DECIMAL(8,2) NOT NULL"}, key: "ol_d_id, ol_o_id, ol_number"},
+}
+
+// orderEntry makes selected tenants run a simplified order-entry workload (new order, payment and order status
+// transactions across the tables of a small TPC-C-like schema created by prepare mode) instead of the sbtest query mix,
+// so the mix of tenants includes multi-table transactional tenants with hot rows (the districts) next to the
+// point reads. A nil *orderEntry is disabled.
+type orderEntry struct {
+	tenants   tenantSet
+	districts int
+	customers int // per district
+	items     int
+	mix       *queryMix
+}
+
+// newOrderEntry returns nil when no tenant is selected. mix is the weighted mix of the oe_* query types.
+func newOrderEntry(tenants tenantSet, districts, customers, items int, mix string) (*orderEntry, error) {
+	if tenants == nil {
+		return nil, nil
+	}
+	if districts < 1 || customers < 1 || items < orderEntryMaxLines {
+		return nil, fmt.Errorf("districts and customers must be at least 1 and items at least %d", orderEntryMaxLines)
+	}
+	m, err := parseQueryMix(mix)
+	if err != nil {
+		return nil, err
+	}
+	for _, qt := range m.types {
+		if !qt.orderEntry {
+			return nil, fmt.Errorf("query type %s is not an order-entry transaction (want oe_new_order, oe_payment or oe_order_status)", qt.name)
+		}
+	}
+	return &orderEntry{tenants: tenants, districts: districts, customers: customers, items: items, mix: m}, nil
+}
+
+// Applies reports whether the tenant is an order-entry tenant.
+func (e *orderEntry) Applies(tenant string) bool {
+	return e != nil && e.tenants.Contains(tenant)
+}
+
+// Tables returns the order-entry schema of a tenant as the one table the workers of the tenant pick: the names of
+// its tables are the table's name with the suffixes of orderEntryTables.
+func (e *orderEntry) Tables(tenancy *tenancyModel, tenant string) []TableInfo {
+	return tenancy.Tables(tenant, []TableInfo{{Name: orderEntryTableName}})
+}
+
+// sizes returns the districts, customers per district and items, the defaults for a nil *orderEntry.
+func (e *orderEntry) sizes() (districts, customers, items int) {
+	if e == nil {
+		return defaultOrderEntryDistricts, defaultOrderEntryCustomers, defaultOrderEntryItems
+	}
+	return e.districts, e.customers, e.items
+}
+
+// Lines of a new order, as in TPC-C.
+const (
+	orderEntryMinLines = 5
+	orderEntryMaxLines = 15
+)
+
+// newOrderArgs appends the arguments of a new order of a random customer: the tenant_id in the row tenancy model,
+// the district, the customer and the item and quantity of every order line, the items distinct and ascending so
+// concurrent orders lock their stock rows in the same order.
+func (e *orderEntry) newOrderArgs(t TableInfo, rng *rand.Rand, args []interface{}) []interface{} {
+	districts, customers, items := e.sizes()
+	args = append(t.appendWhereArgs(args), rng.IntN(districts)+1, rng.IntN(customers)+1)
+	lines := make([]int, 0, orderEntryMaxLines)
+	for n := orderEntryMinLines + rng.IntN(orderEntryMaxLines-orderEntryMinLines+1); len(lines) < n; {
+		if item := rng.IntN(items) + 1; !slices.Contains(lines, item) {
+			lines = append(lines, item)
+		}
+	}
+	slices.Sort(lines)
+	for _, item := range lines {
+		args = append(args, item, rng.IntN(10)+1)
+	}
+	return args
+}
+
+// paymentArgs appends the arguments of a payment of a random customer: the amount, the tenant_id in the row tenancy
+// model, the district and the customer.
+func (e *orderEntry) paymentArgs(t TableInfo, rng *rand.Rand, args []interface{}) []interface{} {
+	districts, customers, _ := e.sizes()
+	amount := float64(rng.IntN(499901)+100) / 100 // 1.00 .. 5000.00
+	return append(t.appendWhereArgs(append(args, amount)), rng.IntN(districts)+1, rng.IntN(customers)+1)
+}
+
+// customerArgs appends the arguments of an order status of a random customer: the tenant_id in the row tenancy model,
+// the district and the customer.
+func (e *orderEntry) customerArgs(t TableInfo, rng *rand.Rand, args []interface{}) []interface{} {
+	districts, customers, _ := e.sizes()
+	return append(t.appendWhereArgs(args), rng.IntN(districts)+1, rng.IntN(customers)+1)
+}
+
+// runNewOrder runs the script of the oe_new_order query type: in one transaction it takes the next order id of the
+// district (the statement, locking the district row), inserts the order, takes the ordered quantities from the stock
+// and inserts the order lines priced from the stock table.
+func runNewOrder(ctx context.Context, conn queryExecutor, t TableInfo, query string, args []interface{}) error {
+	n := len(t.appendWhereArgs(nil))
+	tenantArgs, district, customer, lines := args[:n], args[n], args[n+1], args[n+2:]
+	key := func(values ...interface{}) []interface{} {
+		return append(tenantArgs[:n:n], values...)
+	}
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	var orderID int
+	if err := tx.QueryRowContext(ctx, query, key(district)...).Scan(&orderID); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, "UPDATE "+t.Name+"_district SET d_next_o_id=d_next_o_id+1 WHERE "+t.whereSQL("d_id=?"), key(district)...); err != nil {
+		return err
+	}
+	columns, values := "o_d_id, o_id, o_c_id, o_entry_d, o_ol_cnt", "?, ?, ?, NOW(), ?"
+	if n > 0 {
+		columns, values = "tenant_id, "+columns, "?, "+values
+	}
+	if _, err := tx.ExecContext(ctx, "INSERT INTO "+t.Name+"_orders ("+columns+") VALUES ("+values+")",
+		key(district, orderID, customer, len(lines)/2)...); err != nil {
+		return err
+	}
+	columns, values = "ol_d_id, ol_o_id, ol_number, ol_i_id, ol_quantity, ol_amount", "?, ?, ?, ?, ?, ? * i_price"
+	if n > 0 {
+		columns, values = "tenant_id, "+columns, "?, "+values
+	}
+	insertLine := "INSERT INTO " + t.Name + "_order_line (" + columns + ") SELECT " + values + " FROM " + t.Name + "_stock WHERE " + t.whereSQL("s_i_id=?")
+	updateStock := "UPDATE " + t.Name + "_stock SET s_quantity=IF(s_quantity >= ? + 10, s_quantity - ?, s_quantity - ? + 91), " +
+		"s_ytd=s_ytd + ?, s_order_cnt=s_order_cnt + 1 WHERE " + t.whereSQL("s_i_id=?")
+	for i := 0; i+1 < len(lines); i += 2 {
+		item, quantity := lines[i], lines[i+1]
+		if _, err := tx.ExecContext(ctx, updateStock, append([]interface{}{quantity, quantity, quantity, quantity}, key(item)...)...); err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, insertLine, append(key(district, orderID, i/2+1, item, quantity, quantity), key(item)...)...); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// runPayment runs the script of the oe_payment query type: in one transaction it adds the amount to the year-to-date
+// payments of the district (the statement) and takes it from the balance of the customer.
+func runPayment(ctx context.Context, conn queryExecutor, t TableInfo, query string, args []interface{}) error {
+	amount, tenantArgs := args[0], args[1:len(args)-2]
+	district, customer := args[len(args)-2], args[len(args)-1]
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.ExecContext(ctx, query, args[:len(args)-1]...); err != nil {
+		return err
+	}
+	customerArgs := append(append([]interface{}{amount, amount}, tenantArgs...), district, customer)
+	if _, err := tx.ExecContext(ctx, "UPDATE "+t.Name+"_customer SET c_balance=c_balance - ?, c_ytd_payment=c_ytd_payment + ?, "+
+		"c_payment_cnt=c_payment_cnt + 1 WHERE "+t.whereSQL("c_d_id=? AND c_id=?"), customerArgs...); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// runOrderStatus runs the script of the oe_order_status query type: it reads the customer (the statement), its last
+// order and the lines of that order.
+func runOrderStatus(ctx context.Context, conn queryExecutor, t TableInfo, query string, args []interface{}) error {
+	if err := execAndDrain(ctx, conn, query, args...); err != nil {
+		return err
+	}
+	if err := execAndDrain(ctx, conn, "SELECT o_id, o_entry_d, o_ol_cnt FROM "+t.Name+"_orders WHERE "+
+		t.whereSQL("o_d_id=? AND o_c_id=?")+" ORDER BY o_id DESC LIMIT 1", args...); err != nil {
+		return err
+	}
+	// Unqualified columns of the subquery (tenant_id in the row tenancy model) refer to the orders table.
+	n := len(t.appendWhereArgs(nil))
+	lineArgs := append(append(t.appendWhereArgs(nil), args[n]), args...)
+	return execAndDrain(ctx, conn, "SELECT ol_i_id, ol_quantity, ol_amount FROM "+t.Name+"_order_line WHERE "+
+		t.whereSQL("ol_d_id=? AND ol_o_id=(SELECT MAX(o_id) FROM "+t.Name+"_orders WHERE "+t.whereSQL("o_d_id=? AND o_c_id=?")+")"), lineArgs...)
+}
+
+// createOrderEntryTableSQL returns the CREATE TABLE statement of a table of the order-entry schema of t.
+func createOrderEntryTableSQL(t TableInfo, table orderEntryTable, tableOptions string) string {
+	columns, key, index := table.columns, table.key, table.index
+	if t.TenantID != 0 {
+		columns = append([]string{"tenant_id INT NOT NULL"}, columns...)
+		key = "tenant_id, " + key
+		if index != "" {
+			index = "tenant_id, " + index
+		}
+	}
+	definitions := append(columns, "PRIMARY KEY ("+key+")")
+	if index != "" {
+		definitions = append(definitions, "KEY `"+strings.TrimPrefix(table.suffix, "_")+"_1` ("+index+")")
+	}
+	return fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s%s (\n  %s\n)%s", t.Name, table.suffix, strings.Join(definitions, ",\n  "), tableOptions)
+}
+
+// prepareOrderEntry runs a prepare job of the order-entry schema, see orderEntry.prepare.
+func prepareOrderEntry(ctx context.Context, db *sql.DB, job prepareJob, schema *schemaOptions, batchRows int, rng *rand.Rand) error {
+	return job.orderEntry.prepare(ctx, db, job.tenant, job.table, job.options, batchRows, rng)
+}
+
+// prepare creates the order-entry tables of t and loads the districts, customers and stock, unless the districts
+// (of the tenant, in the row tenancy model) are already loaded. Orders are only created by the workload.
+func (e *orderEntry) prepare(ctx context.Context, db *sql.DB, tenant string, t TableInfo, tableOptions string, batchRows int, rng *rand.Rand) error {
+	for _, table := range orderEntryTables {
+		if _, err := db.ExecContext(ctx, createOrderEntryTableSQL(t, table, tableOptions)); err != nil {
+			return err
+		}
+	}
+	var existing int
+	where, whereArgs := t.where("d_id > 0")
+	if err := db.QueryRowContext(ctx, "SELECT COUNT(*) FROM "+t.Name+"_district WHERE "+where, whereArgs...).Scan(&existing); err != nil {
+		return err
+	}
+	if existing > 0 {
+		log.Printf("[INFO] prepare: %s order-entry tables already loaded, skipping load", tenant)
+		return nil
+	}
+	// Districts last: they mark the schema as loaded.
+	if err := loadOrderEntryRows(ctx, db, t, "_stock", "s_i_id, i_price, s_quantity, s_ytd, s_order_cnt", e.items, batchRows, func(i int) []interface{} {
+		return []interface{}{i + 1, float64(rng.IntN(9901)+100) / 100, rng.IntN(91) + 10, 0, 0}
+	}); err != nil {
+		return err
+	}
+	if err := loadOrderEntryRows(ctx, db, t, "_customer", "c_d_id, c_id, c_last, c_balance, c_ytd_payment, c_payment_cnt", e.districts*e.customers, batchRows, func(i int) []interface{} {
+		return []interface{}{i/e.customers + 1, i%e.customers + 1, sysbenchString(rng, 16), -10.0, 10.0, 1}
+	}); err != nil {
+		return err
+	}
+	if err := loadOrderEntryRows(ctx, db, t, "_district", "d_id, d_next_o_id, d_ytd", e.districts, batchRows, func(i int) []interface{} {
+		return []interface{}{i + 1, 1, 30000.0}
+	}); err != nil {
+		return err
+	}
+	log.Printf("[INFO] prepare: %s order-entry tables loaded: %d district(s), %d customer(s), %d item(s)",
+		tenant, e.districts, e.districts*e.customers, e.items)
+	return nil
+}
+
+// loadOrderEntryRows inserts n rows into a table of the order-entry schema in multi-row INSERTs, row(i) returning the
+// values of the i-th row (0-based) for the columns.
+func loadOrderEntryRows(ctx context.Context, db *sql.DB, t TableInfo, suffix, columns string, n, batchRows int, row func(i int) []interface{}) error {
+	width := strings.Count(columns, ",") + 1
+	if t.TenantID != 0 {
+		columns, width = "tenant_id, "+columns, width+1
+	}
+	placeholders := "(" + strings.TrimSuffix(strings.Repeat("?,", width), ",") + ")"
+	for first := 0; first < n; first += batchRows {
+		count := min(batchRows, n-first)
+		args := make([]interface{}, 0, count*width)
+		for i := first; i < first+count; i++ {
+			args = append(t.appendWhereArgs(args), row(i)...)
+		}
+		query := fmt.Sprintf("INSERT INTO %s%s (%s) VALUES %s", t.Name, suffix, columns, strings.TrimSuffix(strings.Repeat(placeholders+",", count), ","))
+		if _, err := db.ExecContext(ctx, query, args...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math/rand/v2"
+	"sync/atomic"
+)
+
+// defaultPageSize is the page size of page_offset and page_keyset when they are used in -query-mix
+// rather than by a pagination tenant.
+const defaultPageSize = 100
+
+// pagination makes selected tenants page through their tables from the first page to the last, like a listing or
+// an export job, with OFFSET or keyset pagination, to show the cost of deep offsets under multi-tenant load.
+// Every worker walks one random table at a time; pages are assumed to follow the dense ids loaded by prepare mode.
+// A nil *pagination is disabled.
+type pagination struct {
+	tenants  tenantSet
+	keyset   bool
+	pageSize int
+	mix      *queryMix
+
+	walks int64 // tables walked to the end
+}
+
+// newPagination returns nil when no tenant is selected.
+func newPagination(tenants tenantSet, mode string, pageSize int) (*pagination, error) {
+	if tenants == nil {
+		return nil, nil
+	}
+	if pageSize < 1 {
+		return nil, fmt.Errorf("the page size must be at least 1")
+	}
+	var queryType string
+	switch mode {
+	case "offset":
+		queryType = "page_offset"
+	case "keyset":
+		queryType = "page_keyset"
+	default:
+		return nil, fmt.Errorf("unknown pagination mode %q (want offset or keyset)", mode)
+	}
+	mix, err := parseQueryMix(queryType)
+	if err != nil {
+		return nil, err
+	}
+	return &pagination{tenants: tenants, keyset: mode == "keyset", pageSize: pageSize, mix: mix}, nil
+}
+
+// Applies reports whether the tenant pages through its tables.
+func (p *pagination) Applies(tenant string) bool {
+	return p != nil && p.tenants.Contains(tenant)
+}
+
+// PageSize returns the rows per page.
+func (p *pagination) PageSize() int {
+	if p == nil {
+		return defaultPageSize
+	}
+	return p.pageSize
+}
+
+// Cursor returns the walk state of one worker.
+func (p *pagination) Cursor() *pageCursor {
+	return &pageCursor{p: p, table: -1}
+}
+
+// logPaginationSummary logs how many tables were walked to the end.
+func (p *pagination) logPaginationSummary() {
+	log.Printf("[INFO] Summary: pagination walked %d table(s) to the last page", atomic.LoadInt64(&p.walks))
+}
+
+// pageCursor is the position of a worker in the table it is walking.
+type pageCursor struct {
+	p     *pagination
+	table int // index of the table being walked, -1 when the next walk starts
+	next  int // offset, or last id, of the next page
+}
+
+// Table returns the index of the table to read the next page of, starting a walk of a random table if needed.
+func (c *pageCursor) Table(rng *rand.Rand, tables int) int {
+	if c.table < 0 {
+		c.table, c.next = rng.IntN(tables), 0
+	}
+	return c.table
+}
+
+// Args appends the arguments of the next page of the table to args and moves on to the page after it.
+func (c *pageCursor) Args(t TableInfo, args []interface{}) []interface{} {
+	args = t.appendWhereArgs(args)
+	if c.p.keyset {
+		args = append(args, c.next, c.p.pageSize)
+	} else {
+		args = append(args, c.p.pageSize, c.next)
+	}
+	c.next += c.p.pageSize
+	if c.next >= highestID(t) {
+		c.table = -1
+		atomic.AddInt64(&c.p.walks, 1)
+	}
+	return args
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// rangePartitionWidth returns the ids of one range partition of a small partition table: its rows are spread over
+// the partitions p0..pN-1 of equal width, p0 holding the ids from 1.
+func rangePartitionWidth(t TableInfo, partitions int) int {
+	return (highestID(t) + partitions - 1) / partitions
+}
+
+// rangePartitionSQL returns the definition of the range partition p<i>, holding the ids below (i+1)*width+1.
+func rangePartitionSQL(i, width int) string {
+	return fmt.Sprintf("PARTITION p%d VALUES LESS THAN (%d)", i, (i+1)*width+1)
+}
+
+// rangePartitionsSQL returns the PARTITION BY clause of a small partition table of the range partition scheme.
+func rangePartitionsSQL(t TableInfo, partitions int) string {
+	width := rangePartitionWidth(t, partitions)
+	defs := make([]string, partitions)
+	for i := range defs {
+		defs[i] = "  " + rangePartitionSQL(i, width)
+	}
+	return "PARTITION BY RANGE (id) (\n" + strings.Join(defs, ",\n") + "\n)"
+}
+
+// partitionRoller makes selected tenants run rolling-partition maintenance on their small partition tables, which
+// must be range partitioned (-partition-scheme range): every interval it adds the next id range partition above the
+// highest one and drops the oldest, like a retention scheme dropping the data of the oldest day. The rows of the
+// dropped partitions are gone. A nil *partitionRoller is disabled.
+type partitionRoller struct {
+	tenants    tenantSet
+	interval   time.Duration
+	partitions int // partitions created by prepare, -partitions-per-table
+
+	added   int64
+	dropped int64
+}
+
+// newPartitionRoller returns nil when interval is 0.
+func newPartitionRoller(tenants tenantSet, interval time.Duration, partitions int, scheme string) (*partitionRoller, error) {
+	if interval <= 0 {
+		return nil, nil
+	}
+	if scheme != "range" {
+		return nil, fmt.Errorf("rolling partitions need range partitioned tables (-partition-scheme range)")
+	}
+	if partitions < 1 {
+		return nil, fmt.Errorf("the partitions per table must be at least 1")
+	}
+	return &partitionRoller{tenants: tenants, interval: interval, partitions: partitions}, nil
+}
+
+// Applies reports whether the tenant rolls its partitions.
+func (r *partitionRoller) Applies(tenant string) bool {
+	return r != nil && r.tenants.Contains(tenant)
+}
+
+// rollState is the range of partitions p<first>..p<last> of one table.
+type rollState struct {
+	first, last int
+}
+
+// Run rolls the partitions of every small partition table of the tenant each interval until ctx is done or
+// exitTime is reached. The partitions present are read from information_schema at the start and after failures,
+// as an earlier run or, in the row tenancy model, another tenant may have rolled them already.
+func (r *partitionRoller) Run(ctx context.Context, pool *tenantPool, dbName string, opts *workloadOptions) {
+	var tables []TableInfo
+	for _, t := range opts.tenantTables(dbName) {
+		if t.Partitioned && t.Dialect != dialectClickHouse {
+			tables = append(tables, t)
+		}
+	}
+	if len(tables) == 0 {
+		return
+	}
+	conn, err := retryMakeActiveConn(pool.writes(), dbName, ctx)
+	if err != nil {
+		return
+	}
+	defer func() { conn.Close() }()
+
+	stats := opts.stats.Tenant(dbName)
+	states := make(map[string]*rollState)
+	for sleepUntilExit(ctx, r.interval, opts.exitTime) {
+		for _, t := range tables {
+			s, ok := states[t.Name]
+			if !ok {
+				s = r.readState(ctx, conn, t)
+				states[t.Name] = s
+			}
+			stmt, err := r.roll(ctx, conn, stats, t, s)
+			if err != nil {
+				if ctx.Err() != nil {
+					return
+				}
+				log.Printf("[ERROR] partition roll: DB=%s %s failed: %v", dbName, stmt, err)
+				delete(states, t.Name)
+				if conn.PingContext(ctx) != nil {
+					conn.Close()
+					newConn, err := retryMakeActiveConn(pool.writes(), dbName, ctx)
+					if err != nil {
+						return
+					}
+					conn = newConn
+				}
+				continue
+			}
+			log.Printf("[INFO] partition roll: DB=%s %s now holds partitions p%d..p%d", dbName, t.Name, s.first, s.last)
+		}
+	}
+}
+
+// roll adds the partition after the last one of the table and then drops the first one, keeping at least one.
+// Both DDL statements are recorded in the DDL statistics of the tenant. It returns the statement executed last.
+func (r *partitionRoller) roll(ctx context.Context, conn workerConn, stats *tenantStats, t TableInfo, s *rollState) (string, error) {
+	width := rangePartitionWidth(t, r.partitions)
+	stmt := fmt.Sprintf("ALTER TABLE %s ADD PARTITION (%s)", t.Name, rangePartitionSQL(s.last+1, width))
+	start := time.Now()
+	_, err := conn.ExecContext(ctx, stmt)
+	stats.RecordDDL(time.Since(start), err)
+	if err != nil {
+		return stmt, err
+	}
+	s.last++
+	atomic.AddInt64(&r.added, 1)
+	if s.first >= s.last {
+		return stmt, nil
+	}
+
+	stmt = fmt.Sprintf("ALTER TABLE %s DROP PARTITION p%d", t.Name, s.first)
+	start = time.Now()
+	_, err = conn.ExecContext(ctx, stmt)
+	stats.RecordDDL(time.Since(start), err)
+	if err != nil {
+		return stmt, err
+	}
+	s.first++
+	atomic.AddInt64(&r.dropped, 1)
+	return stmt, nil
+}
+
+// readState returns the partitions of the table named p<n>, as found in information_schema, or else those
+// created by prepare mode.
+func (r *partitionRoller) readState(ctx context.Context, conn workerConn, t TableInfo) *rollState {
+	prepared := &rollState{first: 0, last: r.partitions - 1}
+	rows, err := conn.QueryContext(ctx, "SELECT PARTITION_NAME FROM information_schema.PARTITIONS WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ?", t.Name)
+	if err != nil {
+		return prepared
+	}
+	defer rows.Close()
+	s, found := &rollState{}, false
+	for rows.Next() {
+		var name sql.NullString
+		if rows.Scan(&name) != nil || !strings.HasPrefix(name.String, "p") {
+			continue
+		}
+		n, err := strconv.Atoi(name.String[1:])
+		if err != nil {
+			continue
+		}
+		if !found || n < s.first {
+			s.first = n
+		}
+		if !found || n > s.last {
+			s.last = n
+		}
+		found = true
+	}
+	if !found {
+		return prepared
+	}
+	return s
+}
+
+// logPartitionRollSummary logs the partitions added and dropped by the rolling-partition maintenance.
+func (r *partitionRoller) logPartitionRollSummary() {
+	log.Printf("[INFO] Summary: partition roll added=%d dropped=%d partition(s)", atomic.LoadInt64(&r.added), atomic.LoadInt64(&r.dropped))
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+)
+
+// pauseCheckInterval is how often the workers of a paused tenant check whether it was resumed.
+const pauseCheckInterval = 100 * time.Millisecond
+
+// tenantPauses holds the tenants paused at runtime through the control API. The workers of a paused tenant
+// keep their connections but send no queries, so the effect of a tenant going quiet on the others can be observed.
+// A nil *tenantPauses pauses nothing.
+type tenantPauses struct {
+	tenants []string
+
+	mu     sync.RWMutex
+	paused map[string]time.Time // tenant -> paused since
+}
+
+func newTenantPauses(tenants []string) *tenantPauses {
+	return &tenantPauses{tenants: tenants, paused: make(map[string]time.Time)}
+}
+
+// Paused reports whether the tenant is paused.
+func (p *tenantPauses) Paused(tenant string) bool {
+	if p == nil {
+		return false
+	}
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	_, ok := p.paused[tenant]
+	return ok
+}
+
+// selected returns the tenants of the run in the selector, all of them for an empty selector.
+func (p *tenantPauses) selected(spec string) ([]string, error) {
+	set, err := parseTenantSet(spec)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, name := range p.tenants {
+		if set.Contains(name) {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return nil, fmt.Errorf("no tenant of this run matches %q", spec)
+	}
+	return names, nil
+}
+
+// Pause pauses the selected tenants and returns them.
+func (p *tenantPauses) Pause(spec string) ([]string, error) {
+	names, err := p.selected(spec)
+	if err != nil {
+		return nil, err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	now := time.Now()
+	for _, name := range names {
+		if _, ok := p.paused[name]; !ok {
+			p.paused[name] = now
+			log.Printf("[INFO] DB=%s paused", name)
+		}
+	}
+	return names, nil
+}
+
+// Resume resumes the selected tenants and returns them.
+func (p *tenantPauses) Resume(spec string) ([]string, error) {
+	names, err := p.selected(spec)
+	if err != nil {
+		return nil, err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, name := range names {
+		if since, ok := p.paused[name]; ok {
+			delete(p.paused, name)
+			log.Printf("[INFO] DB=%s resumed after %v", name, time.Since(since).Round(time.Millisecond))
+		}
+	}
+	return names, nil
+}
+
+// List returns the paused tenants in sorted order.
+func (p *tenantPauses) List() []string {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	names := make([]string, 0, len(p.paused))
+	for name := range p.paused {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
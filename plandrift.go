@@ -0,0 +1,168 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// planDriftTagPattern matches the fields of the statement comment of -sql-comment.
+var planDriftTagPattern = regexp.MustCompile(`\b(run|tenant|qtype)=(\S+)`)
+
+// planDrift samples the TiDB statement summary through a dedicated connection and flags the statement digests whose
+// plan digest changed during the run, e.g. when plan cache or statistics instability under multi-tenant pressure
+// switches a query to another plan. Statements are attributed to a tenant and query type by the comment of
+// -sql-comment in their sample text; without it the tenant is the database of the statement and the type unknown.
+// A nil *planDrift samples nothing.
+type planDrift struct {
+	db        *sql.DB
+	interval  time.Duration
+	runID     string
+	databases map[string]bool // databases of the tenants, for statements without the comment
+	start     int64           // unix time of the start of the run; plans first seen before it are no drift
+
+	mu     sync.Mutex
+	plans  map[string]map[string]bool // tenant database and digest -> plan digests seen
+	drifts map[planDriftKey]*planDriftCount
+}
+
+// planDriftKey is the tenant and query type of a drifted statement.
+type planDriftKey struct {
+	tenant, queryType string
+}
+
+// planDriftCount counts the drifted digests and the plan changes of one tenant and query type.
+type planDriftCount struct {
+	digests map[string]bool
+	changes int
+}
+
+// newPlanDrift opens the dedicated connection. It returns nil when interval is 0.
+func newPlanDrift(driverName, dsn string, interval time.Duration, runID string, databases []string, start time.Time) (*planDrift, error) {
+	if interval <= 0 {
+		return nil, nil
+	}
+	if driverName == "clickhouse" {
+		return nil, fmt.Errorf("plan digests are sampled from TiDB only")
+	}
+	db, err := sql.Open(driverName, dsn)
+	if err != nil {
+		return nil, err
+	}
+	db.SetMaxOpenConns(1)
+	d := &planDrift{db: db, interval: interval, runID: runID, databases: make(map[string]bool), start: start.Unix(),
+		plans: make(map[string]map[string]bool), drifts: make(map[planDriftKey]*planDriftCount)}
+	for _, name := range databases {
+		d.databases[name] = true
+	}
+	return d, nil
+}
+
+// Run samples the statement summary every interval until ctx is done, then closes the connection.
+func (d *planDrift) Run(ctx context.Context) {
+	defer d.db.Close()
+	logged := false
+	for sleepCtx(ctx, d.interval) {
+		if err := d.sample(ctx); err != nil && ctx.Err() == nil && !logged {
+			log.Printf("[WARNING] plan drift: sampling the statement summary failed (logged once): %v", err)
+			logged = true
+		}
+	}
+}
+
+// sample reads the plans of the statement summary in the order they were first seen and records the new ones.
+func (d *planDrift) sample(ctx context.Context) error {
+	rows, err := d.db.QueryContext(ctx, "SELECT SCHEMA_NAME, DIGEST, PLAN_DIGEST, UNIX_TIMESTAMP(FIRST_SEEN), QUERY_SAMPLE_TEXT "+
+		"FROM information_schema.statements_summary WHERE PLAN_DIGEST != '' ORDER BY FIRST_SEEN")
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var schema, digest, plan, sample sql.NullString
+		var firstSeen sql.NullFloat64
+		if err := rows.Scan(&schema, &digest, &plan, &firstSeen, &sample); err != nil {
+			return err
+		}
+		tenant, queryType, ok := d.attribute(schema.String, sample.String)
+		if !ok {
+			continue
+		}
+		d.record(tenant, queryType, schema.String, digest.String, plan.String, int64(firstSeen.Float64) >= d.start)
+	}
+	return rows.Err()
+}
+
+// attribute returns the tenant and query type of a statement, and whether it is one of the run.
+func (d *planDrift) attribute(schema, sample string) (tenant, queryType string, ok bool) {
+	tags := make(map[string]string)
+	for _, m := range planDriftTagPattern.FindAllStringSubmatch(sample, -1) {
+		if _, seen := tags[m[1]]; !seen {
+			tags[m[1]] = strings.TrimSuffix(m[2], "*/")
+		}
+	}
+	if run, tagged := tags["run"]; tagged {
+		return tags["tenant"], tags["qtype"], run == d.runID
+	}
+	return schema, "?", d.databases[schema]
+}
+
+// record adds a plan of a digest. A plan new to a digest that already has one is a drift when it was first seen
+// during the run.
+func (d *planDrift) record(tenant, queryType, schema, digest, plan string, duringRun bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	plans := d.plans[schema+"/"+digest]
+	if plans == nil {
+		plans = make(map[string]bool)
+		d.plans[schema+"/"+digest] = plans
+	}
+	if plans[plan] {
+		return
+	}
+	plans[plan] = true
+	if len(plans) == 1 || !duringRun {
+		return
+	}
+	key := planDriftKey{tenant, queryType}
+	count := d.drifts[key]
+	if count == nil {
+		count = &planDriftCount{digests: make(map[string]bool)}
+		d.drifts[key] = count
+	}
+	count.digests[schema+"/"+digest] = true
+	count.changes++
+	log.Printf("[WARNING] plan drift: tenant=%s type=%s digest %s changed to plan %s (%d plans seen)",
+		tenant, queryType, digest, plan, len(plans))
+}
+
+// logPlanDriftSummary logs the digests whose plan changed during the run by tenant and query type.
+func (d *planDrift) logPlanDriftSummary() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if len(d.drifts) == 0 {
+		log.Printf("[INFO] Summary: plan drift none, %d digest(s) kept their plan", len(d.plans))
+		return
+	}
+	keys := make([]planDriftKey, 0, len(d.drifts))
+	for key := range d.drifts {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].tenant != keys[j].tenant {
+			return keys[i].tenant < keys[j].tenant
+		}
+		return keys[i].queryType < keys[j].queryType
+	})
+	for _, key := range keys {
+		count := d.drifts[key]
+		log.Printf("[INFO] Summary: plan drift tenant=%s type=%s digests=%d plan changes=%d",
+			key.tenant, key.queryType, len(count.digests), count.changes)
+	}
+}
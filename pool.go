@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"sync/atomic"
+	"time"
+)
+
+// tenantPool holds the connection pools of one tenant database.
+// read and write are the same handle unless reads are split to a separate DSN.
+type tenantPool struct {
+	read  *sql.DB
+	write *sql.DB
+}
+
+// openTenantPool opens the connection pools of a tenant. The DSNs are not contacted until Ping.
+// Every connection the pools establish is recorded in stats unless it is nil. With several accounts the connections
+// of each pool rotate among them.
+func openTenantPool(dsns *dsnResolver, tenant string, stats *tenantStats) (*tenantPool, error) {
+	writeDSNs, readDSNs := dsns.AccountDSNs(tenant, false), dsns.AccountDSNs(tenant, true)
+	write, err := openTimedDB(dsns.Driver(tenant), writeDSNs, stats)
+	if err != nil {
+		return nil, err
+	}
+	pool := &tenantPool{read: write, write: write}
+	if readDSNs[0] != writeDSNs[0] {
+		if pool.read, err = openTimedDB(dsns.Driver(tenant), readDSNs, stats); err != nil {
+			write.Close()
+			return nil, err
+		}
+	}
+	return pool, nil
+}
+
+// split reports whether reads use their own DSN.
+func (p *tenantPool) split() bool {
+	return p.read != p.write
+}
+
+// reads returns the source of the connections reads run on.
+func (p *tenantPool) reads() connSource {
+	return dbConns{p.read}
+}
+
+// writes returns the source of the connections writes run on, the same as reads unless reads are split.
+func (p *tenantPool) writes() connSource {
+	return dbConns{p.write}
+}
+
+// Ping checks that both pools can reach their server.
+func (p *tenantPool) Ping() error {
+	if err := p.write.Ping(); err != nil {
+		return err
+	}
+	if p.split() {
+		return p.read.Ping()
+	}
+	return nil
+}
+
+// Close closes the pools and all of their connections.
+func (p *tenantPool) Close() error {
+	if p.split() {
+		p.read.Close()
+	}
+	return p.write.Close()
+}
+
+// openTimedDB opens a database handle like sql.Open whose connections record how long establishing them (dial,
+// handshake and authentication) took in stats, apart from the query latency. With several DSNs, e.g. of the accounts
+// of a tenant, new connections use them in turn. With one DSN and nil stats it is sql.Open.
+func openTimedDB(driverName string, dsns []string, stats *tenantStats) (*sql.DB, error) {
+	db, err := sql.Open(driverName, dsns[0])
+	if err != nil || (stats == nil && len(dsns) == 1) {
+		return db, err
+	}
+	d := db.Driver()
+	db.Close()
+	connectors := make([]driver.Connector, len(dsns))
+	for i, dsn := range dsns {
+		connectors[i] = dsnConnector{dsn: dsn, driver: d}
+		if dc, ok := d.(driver.DriverContext); ok {
+			if connectors[i], err = dc.OpenConnector(dsn); err != nil {
+				return nil, err
+			}
+		}
+	}
+	connector := connectors[0]
+	if len(connectors) > 1 {
+		connector = &rotatingConnector{connectors: connectors}
+	}
+	if stats != nil {
+		connector = &timedConnector{Connector: connector, stats: stats}
+	}
+	return sql.OpenDB(connector), nil
+}
+
+// rotatingConnector establishes every new connection with the next of its connectors, round robin.
+type rotatingConnector struct {
+	connectors []driver.Connector
+	next       uint64
+}
+
+func (c *rotatingConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	i := atomic.AddUint64(&c.next, 1) - 1
+	return c.connectors[i%uint64(len(c.connectors))].Connect(ctx)
+}
+
+func (c *rotatingConnector) Driver() driver.Driver {
+	return c.connectors[0].Driver()
+}
+
+// timedConnector records the time of every connection established by its connector.
+type timedConnector struct {
+	driver.Connector
+	stats *tenantStats
+}
+
+func (c *timedConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	start := time.Now()
+	conn, err := c.Connector.Connect(ctx)
+	c.stats.RecordConnect(time.Since(start), err)
+	return conn, err
+}
+
+// dsnConnector is the connector of drivers without one of their own.
+type dsnConnector struct {
+	dsn    string
+	driver driver.Driver
+}
+
+func (c dsnConnector) Connect(context.Context) (driver.Conn, error) {
+	return c.driver.Open(c.dsn)
+}
+
+func (c dsnConnector) Driver() driver.Driver {
+	return c.driver
+}
@@ -0,0 +1,520 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"math/rand/v2"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// prepareMaxBatchBytes bounds the size of one multi-row INSERT, well below the default max_allowed_packet.
+const prepareMaxBatchBytes = 4 << 20
+
+// schemaOptions describes the tables created by prepare mode.
+type schemaOptions struct {
+	cSize       int    // length of column c (sysbench: 120)
+	padSize     int    // length of column pad (sysbench: 60)
+	payloadType string // "text" or "blob": column type family used for c and pad
+	partitions  int    // partitions of each small partition table
+	rangeParts  bool   // partition the small partition tables by id range instead of hash of k
+	jsonColumn  bool   // add the JSON document column doc and its indexed generated column
+	generated   bool   // add the indexed generated column c_prefix
+	procedures  bool   // create the stored procedure of every table
+	foreignKeys bool   // create the child table of every table, referencing it with a foreign key
+	views       bool   // create the view of every table and the join view of every tenant
+}
+
+// cLen returns the length of column c of the table: that of the tenant's schema profile, or else cSize.
+func (s *schemaOptions) cLen(t TableInfo) int {
+	if t.CSize > 0 {
+		return t.CSize
+	}
+	return s.cSize
+}
+
+// padLen returns the length of column pad of the table: that of the tenant's schema profile, or else padSize.
+func (s *schemaOptions) padLen(t TableInfo) int {
+	if t.PadSize > 0 {
+		return t.PadSize
+	}
+	return s.padSize
+}
+
+// payloadColumnType returns the column type holding size characters (or bytes).
+// Small sizes stay VARCHAR/VARBINARY like sysbench; larger ones use TEXT/BLOB types.
+func (s *schemaOptions) payloadColumnType(size int) string {
+	blob := s.payloadType == "blob"
+	switch {
+	case size <= 2048 && blob:
+		return fmt.Sprintf("VARBINARY(%d)", size)
+	case size <= 2048:
+		return fmt.Sprintf("VARCHAR(%d)", size)
+	case size <= 65535 && blob:
+		return "BLOB"
+	case size <= 65535:
+		return "TEXT"
+	case blob:
+		return "MEDIUMBLOB"
+	default:
+		return "MEDIUMTEXT"
+	}
+}
+
+// payloadDefault returns the DEFAULT clause for a payload column; TEXT/BLOB columns can't have one in MySQL.
+func payloadDefault(columnType string) string {
+	if strings.HasPrefix(columnType, "VAR") {
+		return " DEFAULT ''"
+	}
+	return ""
+}
+
+// createTableSQL returns the CREATE TABLE statement of a workload table with the table options, e.g. its collation,
+// and the attribute of its primary key, e.g. its clustering.
+func (s *schemaOptions) createTableSQL(t TableInfo, tableOptions, primaryKey string) string {
+	if t.Dialect == dialectClickHouse {
+		return s.createClickHouseTableSQL(t)
+	}
+	cType, padType := s.payloadColumnType(s.cLen(t)), s.payloadColumnType(s.padLen(t))
+	// In the row tenancy model the tables are shared and every key starts with tenant_id.
+	shared := t.TenantID != 0
+	tenantKey, tenantColumn := "", ""
+	if shared {
+		tenantKey, tenantColumn = "`tenant_id`,", "  tenant_id INT NOT NULL,\n"
+	}
+	autoIncrement := " AUTO_INCREMENT"
+	if t.Partitioned || shared {
+		autoIncrement = ""
+	}
+	columns := tenantColumn + fmt.Sprintf(`  id  INT NOT NULL%s,
+  k   INT NOT NULL DEFAULT 0,
+  c   %s NOT NULL%s,
+  pad %s NOT NULL%s,
+`, autoIncrement, cType, payloadDefault(cType), padType, payloadDefault(padType))
+	if s.jsonColumn {
+		// doc_category is extracted from the document and indexed, like a typical document store secondary index.
+		columns += "  doc JSON,\n  doc_category INT AS (JSON_EXTRACT(doc, '$.category')) VIRTUAL,\n  KEY `doc_category_1` (" + tenantKey + "`doc_category`),\n"
+	}
+	if t.Indexes > 0 {
+		columns += extraIndexesSQL(t, tenantKey)
+	}
+	if s.generated {
+		// c_prefix is derived from c, so every write of c maintains its index too.
+		columns += fmt.Sprintf("  c_prefix CHAR(%d) AS (LEFT(c, %d)) VIRTUAL,\n  KEY `c_prefix_1` (%s`c_prefix`),\n",
+			generatedPrefixSize, generatedPrefixSize, tenantKey)
+	}
+
+	if t.Partitioned && s.rangeParts {
+		return fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (\n%s  PRIMARY KEY (%s`id`,`k`)%s,\n  KEY `k_1` (%s`k`)\n)%s\n%s",
+			t.Name, columns, tenantKey, primaryKey, tenantKey, tableOptions, rangePartitionsSQL(t, s.partitions))
+	}
+	if t.Partitioned {
+		return fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (\n%s  PRIMARY KEY (%s`id`,`k`)%s,\n  KEY `k_1` (%s`k`)\n)%s\nPARTITION BY HASH (k)\nPARTITIONS %d",
+			t.Name, columns, tenantKey, primaryKey, tenantKey, tableOptions, s.partitions)
+	}
+	return fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (\n%s  PRIMARY KEY (%s`id`)%s,\n  KEY `k_1` (%s`k`)\n)%s", t.Name, columns, tenantKey, primaryKey, tenantKey, tableOptions)
+}
+
+// procedureName returns the name of the stored procedure of a table, e.g. sbtest1_call.
+func procedureName(t TableInfo) string {
+	return t.Name + "_call"
+}
+
+// createProcedureSQL returns the CREATE PROCEDURE statement of the stored procedure of a table: it reads the payload
+// of one row by primary key and rewrites its c column, like a small business operation kept in the database.
+// In the row tenancy model the procedure takes the tenant_id first.
+func (s *schemaOptions) createProcedureSQL(t TableInfo) string {
+	params, where := "", "id = p_id"
+	if t.TenantID != 0 {
+		params, where = "IN p_tenant INT, ", "tenant_id = p_tenant AND id = p_id"
+	}
+	return fmt.Sprintf(`CREATE PROCEDURE %s(%sIN p_id INT, IN p_c %s)
+BEGIN
+  SELECT c, pad FROM %s WHERE %s;
+  UPDATE %s SET c = p_c WHERE %s;
+END`, procedureName(t), params, s.payloadColumnType(s.cLen(t)), t.Name, where, t.Name, where)
+}
+
+// createClickHouseTableSQL returns the CREATE TABLE statement of a workload table of a ClickHouse tenant:
+// a MergeTree table sorted by k, like the k_1 index of MySQL/TiDB. Payload sizes are not enforced by String columns,
+// and there is no JSON column.
+func (s *schemaOptions) createClickHouseTableSQL(t TableInfo) string {
+	tenantColumn, tenantKey := "", ""
+	if t.TenantID != 0 {
+		tenantColumn, tenantKey = "  tenant_id UInt32,\n", "tenant_id, "
+	}
+	return fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (\n%s  id  UInt32,\n  k   Int32,\n  c   String,\n  pad String\n)\nENGINE = MergeTree\nORDER BY (%sk, id)",
+		t.Name, tenantColumn, tenantKey)
+}
+
+// sysbenchString returns a random string of the given length made of dash separated 11-digit groups,
+// like the c and pad values of sysbench.
+func sysbenchString(rng *rand.Rand, size int) string {
+	var b strings.Builder
+	b.Grow(size)
+	for i := 0; b.Len() < size; i++ {
+		if i%12 == 11 {
+			b.WriteByte('-')
+		} else {
+			b.WriteByte(byte('0' + rng.IntN(10)))
+		}
+	}
+	return b.String()
+}
+
+// generatedPrefixSize is the length of the generated column c_prefix, the first characters of c. As c starts with
+// digits, it has generatedPrefixes distinct values; gen_range covers generatedPrefixRange of them.
+const (
+	generatedPrefixSize  = 4
+	generatedPrefixes    = 10000
+	generatedPrefixRange = 10
+)
+
+// randomPrefix returns a random c_prefix value.
+func randomPrefix(rng *rand.Rand) string {
+	return fmt.Sprintf("%0*d", generatedPrefixSize, rng.IntN(generatedPrefixes))
+}
+
+// jsonCategories is the number of distinct $.category values of the JSON documents.
+const jsonCategories = 100
+
+// jsonDocument returns a random JSON document for the doc column.
+func jsonDocument(rng *rand.Rand, id int) string {
+	return fmt.Sprintf(`{"id": %d, "category": %d, "score": %d, "tags": ["t%d", "t%d"], "attrs": {"active": %t, "name": "%s"}}`,
+		id, rng.IntN(jsonCategories), rng.IntN(1000), rng.IntN(20), rng.IntN(20), rng.IntN(2) == 0, sysbenchString(rng, 11))
+}
+
+// serverDSN returns dsn without its database name, for statements like CREATE DATABASE.
+func serverDSN(dsn string) (string, error) {
+	if strings.HasPrefix(dsn, "http://") || strings.HasPrefix(dsn, "https://") {
+		// ClickHouse HTTP URL: the database is the path.
+		u, err := url.Parse(dsn)
+		if err != nil {
+			return "", err
+		}
+		u.Path = "/"
+		return u.String(), nil
+	}
+	cfg, err := mysql.ParseDSN(dsn)
+	if err != nil {
+		return "", err
+	}
+	cfg.DBName = ""
+	return cfg.FormatDSN(), nil
+}
+
+// prepareJob is one table of one tenant to create and load.
+type prepareJob struct {
+	tenant  string
+	table   TableInfo
+	tiflash bool   // add a TiFlash replica for an AP tenant
+	options string // table options of the tenant's collation
+	// orderEntry is set for the job creating the order-entry schema of an order-entry tenant, table being the schema.
+	orderEntry *orderEntry
+	// ingest is set for the job creating the events table of an ingestion tenant.
+	ingest *ingestion
+	// ttl is set for the jobs creating the TTL tables of a TTL tenant.
+	ttl *ttlTables
+	// primaryKey is the attribute of the primary key of the table, e.g. its clustering.
+	primaryKey string
+}
+
+// runPrepare creates the tenant databases and their tables and loads rows with ids 1..MaxK
+// and random k values, using threads concurrent loaders. Tables that already contain rows
+// (of the tenant, in the row tenancy model) are left alone.
+func runPrepare(ctx context.Context, dsns *dsnResolver, opts *workloadOptions, tenantNames []string, threads, batchRows int) error {
+	tenancy, schema := opts.tenancy, opts.schema
+
+	if commonDB != "" {
+		if err := prepareCommonDB(ctx, dsns, tenantNames); err != nil {
+			return err
+		}
+	}
+
+	pools := make(map[string]*sql.DB, len(tenantNames))
+	defer func() {
+		for _, db := range pools {
+			db.Close()
+		}
+	}()
+	for _, tenant := range tenantNames {
+		databaseOptions := ""
+		if tenancy.Database(tenant) == tenant && dsns.Driver(tenant) != "clickhouse" {
+			databaseOptions = opts.collations.DatabaseOptions(tenant)
+		}
+		if err := createDatabase(ctx, dsns.Driver(tenant), dsns.DSN(tenant), tenancy.Database(tenant), databaseOptions); err != nil {
+			return fmt.Errorf("create database %s: %v", tenancy.Database(tenant), err)
+		}
+		if provisionedUser(dsns, opts.users, tenant) {
+			if err := createTenantUser(ctx, dsns.Driver(tenant), dsns.DSN(tenant), opts.users, tenant, tenancy.Database(tenant)); err != nil {
+				return fmt.Errorf("create user of %s: %v", tenant, err)
+			}
+		}
+		db, err := sql.Open(dsns.Driver(tenant), dsns.DSN(tenant))
+		if err != nil {
+			return err
+		}
+		pools[tenant] = db
+	}
+
+	jobs := make(chan prepareJob)
+	errs := make(chan error, threads)
+	var wg sync.WaitGroup
+	for i := 0; i < threads; i++ {
+		wg.Add(1)
+		go func(loader int) {
+			defer wg.Done()
+			rng := newWorkerRand("prepare", loader)
+			for job := range jobs {
+				prepare := prepareTable
+				if job.orderEntry != nil {
+					prepare = prepareOrderEntry
+				}
+				if job.ingest != nil {
+					prepare = prepareIngest
+				}
+				if job.ttl != nil {
+					prepare = prepareTTL
+				}
+				if err := prepare(ctx, pools[job.tenant], job, schema, batchRows, rng); err != nil {
+					errs <- fmt.Errorf("%s.%s: %v", job.tenant, job.table.Name, err)
+					return
+				}
+			}
+		}(i)
+	}
+
+	start := time.Now()
+	var err error
+feed:
+	for _, tenant := range tenantNames {
+		for _, t := range opts.tenantTables(tenant) {
+			job := prepareJob{tenant: tenant, table: t, tiflash: opts.tiflash.Applies(tenant) && t.Dialect != dialectClickHouse,
+				options: opts.collations.TableOptions(tenant, t), primaryKey: opts.pkKinds.Clause(tenant, t)}
+			select {
+			case jobs <- job:
+			case err = <-errs:
+				break feed
+			}
+		}
+		if opts.orderEntry.Applies(tenant) && !opts.clickhouse.Applies(tenant) {
+			t := opts.orderEntry.Tables(tenancy, tenant)[0]
+			job := prepareJob{tenant: tenant, table: t, options: opts.collations.TableOptions(tenant, t), orderEntry: opts.orderEntry}
+			select {
+			case jobs <- job:
+			case err = <-errs:
+				break feed
+			}
+		}
+		if opts.ingest.Applies(tenant) && !opts.clickhouse.Applies(tenant) {
+			t := opts.ingest.Tables(tenancy, tenant)[0]
+			job := prepareJob{tenant: tenant, table: t, options: opts.collations.TableOptions(tenant, t), ingest: opts.ingest}
+			select {
+			case jobs <- job:
+			case err = <-errs:
+				break feed
+			}
+		}
+		if opts.ttl.Applies(tenant) && !opts.clickhouse.Applies(tenant) {
+			for _, t := range opts.ttl.Tables(tenancy, tenant) {
+				job := prepareJob{tenant: tenant, table: t, options: opts.collations.TableOptions(tenant, t), ttl: opts.ttl}
+				select {
+				case jobs <- job:
+				case err = <-errs:
+					break feed
+				}
+			}
+		}
+	}
+	close(jobs)
+	wg.Wait()
+	if err == nil {
+		select {
+		case err = <-errs:
+		default:
+		}
+	}
+	if err != nil {
+		return err
+	}
+	// The join view of a tenant needs all of its tables.
+	if schema.views {
+		for _, tenant := range tenantNames {
+			if err := prepareJoinView(ctx, pools[tenant], opts, tenant); err != nil {
+				return err
+			}
+		}
+	}
+	log.Printf("[INFO] prepare: %d DB(s) x %d table(s) ready in %v", len(tenantNames), len(opts.tables), time.Since(start).Round(time.Second))
+	return nil
+}
+
+// createDatabase creates the database with the options (character set and collation) if it doesn't exist.
+func createDatabase(ctx context.Context, driverName, dsn, database, options string) error {
+	dsn, err := serverDSN(dsn)
+	if err != nil {
+		return err
+	}
+	db, err := sql.Open(driverName, dsn)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	_, err = db.ExecContext(ctx, fmt.Sprintf("CREATE DATABASE IF NOT EXISTS `%s`%s", database, options))
+	return err
+}
+
+// insertRows returns a multi-row INSERT of n random rows with the ids from first on, and its arguments.
+func (s *schemaOptions) insertRows(t TableInfo, first, n int, rng *rand.Rand) (string, []interface{}) {
+	columns, row := "id, k, c, pad", "?,?,?,?"
+	withDoc := s.jsonColumn && t.Dialect != dialectClickHouse
+	if withDoc {
+		columns, row = columns+", doc", row+",?"
+	}
+	if t.TenantID != 0 {
+		columns, row = "tenant_id, "+columns, "?,"+row
+	}
+	query := fmt.Sprintf("INSERT INTO %s (%s) VALUES %s", t.Name, columns,
+		strings.TrimSuffix(strings.Repeat("("+row+"),", n), ","))
+	args := make([]interface{}, 0, n*6)
+	for id := first; id < first+n; id++ {
+		if t.TenantID != 0 {
+			args = append(args, t.TenantID)
+		}
+		args = append(args, id, randomK(t, rng), sysbenchString(rng, s.cLen(t)), sysbenchString(rng, s.padLen(t)))
+		if withDoc {
+			args = append(args, jsonDocument(rng, id))
+		}
+	}
+	return query, args
+}
+
+// dropDatabase drops the database if it exists.
+func dropDatabase(ctx context.Context, driverName, dsn, database string) error {
+	dsn, err := serverDSN(dsn)
+	if err != nil {
+		return err
+	}
+	db, err := sql.Open(driverName, dsn)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	_, err = db.ExecContext(ctx, fmt.Sprintf("DROP DATABASE IF EXISTS `%s`", database))
+	return err
+}
+
+// prepareTable creates one table and loads its rows in multi-row INSERTs.
+func prepareTable(ctx context.Context, db *sql.DB, job prepareJob, schema *schemaOptions, batchRows int, rng *rand.Rand) error {
+	t := job.table
+	if _, err := db.ExecContext(ctx, schema.createTableSQL(t, job.options, job.primaryKey)); err != nil {
+		return err
+	}
+	if job.tiflash {
+		// The replica is built asynchronously by TiFlash while the rows are loaded.
+		if _, err := db.ExecContext(ctx, fmt.Sprintf("ALTER TABLE %s SET TIFLASH REPLICA 1", t.Name)); err != nil {
+			return err
+		}
+	}
+	if schema.procedures && t.Dialect != dialectClickHouse {
+		// Recreated every time, as MySQL before 8.0.29 has no CREATE PROCEDURE IF NOT EXISTS.
+		if _, err := db.ExecContext(ctx, "DROP PROCEDURE IF EXISTS "+procedureName(t)); err != nil {
+			return err
+		}
+		if _, err := db.ExecContext(ctx, schema.createProcedureSQL(t)); err != nil {
+			return err
+		}
+	}
+	if schema.foreignKeys && t.Dialect != dialectClickHouse {
+		if _, err := db.ExecContext(ctx, schema.createChildTableSQL(t, job.options)); err != nil {
+			return err
+		}
+	}
+	if schema.views && t.Dialect != dialectClickHouse {
+		if _, err := db.ExecContext(ctx, createViewSQL(t)); err != nil {
+			return err
+		}
+	}
+	var existing int
+	where, whereArgs := t.where("1=1")
+	if err := db.QueryRowContext(ctx, fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE %s", t.Name, where), whereArgs...).Scan(&existing); err != nil {
+		return err
+	}
+	if existing > 0 {
+		log.Printf("[INFO] prepare: %s.%s already has %d row(s), skipping load", job.tenant, t.Name, existing)
+		return nil
+	}
+
+	// Keep each INSERT below prepareMaxBatchBytes even with wide rows.
+	if perRow := schema.cLen(t) + schema.padLen(t) + 160; batchRows*perRow > prepareMaxBatchBytes {
+		batchRows = prepareMaxBatchBytes / perRow
+		if batchRows < 1 {
+			batchRows = 1
+		}
+	}
+
+	rows := t.MaxK
+	for first := 1; first <= rows; first += batchRows {
+		n := batchRows
+		if first+n-1 > rows {
+			n = rows - first + 1
+		}
+		query, args := schema.insertRows(t, first, n, rng)
+		if _, err := db.ExecContext(ctx, query, args...); err != nil {
+			return err
+		}
+	}
+	log.Printf("[INFO] prepare: %s.%s loaded %d row(s)", job.tenant, t.Name, rows)
+	return nil
+}
+
+// runCleanup drops the tenant databases, or the shared database of the schema and row tenancy models,
+// the common database and the tenant users created by prepare mode.
+func runCleanup(ctx context.Context, dsns *dsnResolver, tenancy *tenancyModel, users *tenantUsers, tenantNames []string) error {
+	dropped := make(map[string]bool)
+	for _, tenant := range tenantNames {
+		if provisionedUser(dsns, users, tenant) {
+			if err := dropTenantUser(ctx, dsns.Driver(tenant), dsns.DSN(tenant), users, tenant); err != nil {
+				return fmt.Errorf("drop user of %s: %v", tenant, err)
+			}
+		}
+		dsn, err := serverDSN(dsns.DSN(tenant))
+		if err != nil {
+			return err
+		}
+		database := tenancy.Database(tenant)
+		if dropped[dsn+database] {
+			continue
+		}
+		dropped[dsn+database] = true
+		if err := dropDatabase(ctx, dsns.Driver(tenant), dsn, database); err != nil {
+			return fmt.Errorf("drop database %s: %v", database, err)
+		}
+		log.Printf("[INFO] cleanup: dropped database %s", database)
+	}
+	if commonDB == "" {
+		return nil
+	}
+	droppedCommon := make(map[string]bool)
+	for _, tenant := range tenantNames {
+		dsn, err := serverDSN(dsns.DSN(tenant))
+		if err != nil {
+			return err
+		}
+		if dsns.Driver(tenant) == "clickhouse" || droppedCommon[dsn] {
+			continue
+		}
+		droppedCommon[dsn] = true
+		if err := dropDatabase(ctx, dsns.Driver(tenant), dsn, commonDB); err != nil {
+			return fmt.Errorf("drop database %s: %v", commonDB, err)
+		}
+		log.Printf("[INFO] cleanup: dropped common database %s", commonDB)
+	}
+	return nil
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"fmt"
+	"log"
+)
+
+// Primary key kinds of the tables of a tenant.
+const (
+	pkClustered    = "clustered"
+	pkNonclustered = "nonclustered"
+)
+
+// primaryKeys sets whether the primary keys of the tables of selected tenants are clustered (the rows are stored
+// by primary key) or not (the rows get a hidden _tidb_rowid and the primary key is a separate index), so one run
+// compares the point-read latency of both kinds side by side. The other tenants get the server default
+// (tidb_enable_clustered_index). A nil *primaryKeys leaves every tenant with the default.
+type primaryKeys struct {
+	clustered    tenantSet // nil = none
+	nonclustered tenantSet // nil = none
+}
+
+// newPrimaryKeys returns nil when no tenant is selected.
+func newPrimaryKeys(clustered, nonclustered string) (*primaryKeys, error) {
+	c, err := parseTenantSet(clustered)
+	if err != nil {
+		return nil, fmt.Errorf("clustered tenants: %v", err)
+	}
+	n, err := parseTenantSet(nonclustered)
+	if err != nil {
+		return nil, fmt.Errorf("nonclustered tenants: %v", err)
+	}
+	if c == nil && n == nil {
+		return nil, nil
+	}
+	return &primaryKeys{clustered: c, nonclustered: n}, nil
+}
+
+// Kind returns the primary key kind of the tenant's tables, "" for the server default.
+// A tenant selected as both clustered and nonclustered is clustered.
+func (p *primaryKeys) Kind(tenant string) string {
+	switch {
+	case p == nil:
+		return ""
+	case p.clustered != nil && p.clustered.Contains(tenant):
+		return pkClustered
+	case p.nonclustered != nil && p.nonclustered.Contains(tenant):
+		return pkNonclustered
+	}
+	return ""
+}
+
+// Clause returns the attribute of the PRIMARY KEY of the tenant's CREATE TABLE statements, "" for the default.
+// It is a TiDB-only comment, ignored by MySQL. ClickHouse tables have no primary key of this kind.
+func (p *primaryKeys) Clause(tenant string, t TableInfo) string {
+	kind := p.Kind(tenant)
+	if kind == "" || t.Dialect == dialectClickHouse {
+		return ""
+	}
+	if kind == pkClustered {
+		return " /*T![clustered_index] CLUSTERED */"
+	}
+	return " /*T![clustered_index] NONCLUSTERED */"
+}
+
+// logPrimaryKeySummary logs the queries, QPS and latency of the tenants of every primary key kind, and the latency
+// of their point reads on their own.
+func (p *primaryKeys) logPrimaryKeySummary(snapshot *statsSnapshot) {
+	for _, kind := range []string{pkClustered, pkNonclustered, ""} {
+		tenants, all, points := 0, newQueryTypeStats(), newQueryTypeStats()
+		for _, name := range snapshot.TenantNames() {
+			if p.Kind(name) != kind {
+				continue
+			}
+			tenants++
+			for typeName, q := range snapshot.Tenants[name].Types {
+				all.merge(q)
+				if typeName == "point_select" {
+					points.merge(q)
+				}
+			}
+		}
+		if tenants == 0 {
+			continue
+		}
+		if kind == "" {
+			kind = "(default)"
+		}
+		qps := 0.0
+		if snapshot.ElapsedSeconds > 0 {
+			qps = float64(all.Queries) / snapshot.ElapsedSeconds
+		}
+		log.Printf("[INFO] primary key=%s tenants=%d queries=%d errors=%d qps=%.1f avg=%v p50=%v p99=%v point_select p50=%v p99=%v",
+			kind, tenants, all.Queries, all.Errors, qps, all.Latency.Mean(), all.Latency.Quantile(0.50), all.Latency.Quantile(0.99),
+			points.Latency.Quantile(0.50), points.Latency.Quantile(0.99))
+	}
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"fmt"
+	"log"
+	"os"
+	"sync/atomic"
+	"time"
+)
+
+// processlistMarker is in the snapshot query, so the snapshot connection leaves its own session out.
+const processlistMarker = "processlist snapshot"
+
+// processlistWriter captures the sessions running on the server every interval into a CSV file through a dedicated
+// connection, for the post-mortem of what was actually running server-side when a latency spike occurred. Every row
+// is one non-idle session of one snapshot: the snapshot time followed by the columns of information_schema.processlist,
+// or on TiDB of information_schema.cluster_processlist, which has the sessions of every TiDB instance.
+type processlistWriter struct {
+	db       *sql.DB
+	file     *os.File
+	csv      *csv.Writer
+	query    string
+	interval time.Duration
+	header   bool // whether the header was written, with the columns of the first snapshot
+
+	snapshots int64
+	sessions  int64
+}
+
+// newProcesslistWriter creates (or truncates) the CSV file and opens the dedicated connection. It returns nil when
+// path is "".
+func newProcesslistWriter(driverName, dsn, path string, interval time.Duration, cluster bool) (*processlistWriter, error) {
+	if path == "" {
+		return nil, nil
+	}
+	if interval <= 0 {
+		return nil, fmt.Errorf("processlist interval must be positive")
+	}
+	if driverName == "clickhouse" {
+		return nil, fmt.Errorf("processlist snapshots are taken from MySQL or TiDB only")
+	}
+	table := "information_schema.processlist"
+	if cluster {
+		table = "information_schema.cluster_processlist"
+	}
+	db, err := sql.Open(driverName, dsn)
+	if err != nil {
+		return nil, err
+	}
+	db.SetMaxOpenConns(1)
+	f, err := os.Create(path)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &processlistWriter{
+		db:   db,
+		file: f,
+		csv:  csv.NewWriter(f),
+		query: fmt.Sprintf("SELECT /* %s */ * FROM %s WHERE COMMAND != 'Sleep' AND (INFO IS NULL OR INFO NOT LIKE '%%%s%%')",
+			processlistMarker, table, processlistMarker),
+		interval: interval,
+	}, nil
+}
+
+// Run takes a snapshot every interval until ctx is done, then closes the file and the connection.
+// Rows are flushed every snapshot, so the file can be followed while the run goes on. Failed snapshots are logged.
+func (w *processlistWriter) Run(ctx context.Context) {
+	defer func() {
+		w.csv.Flush()
+		if err := w.file.Close(); err != nil {
+			log.Printf("[ERROR] Failed to close processlist file: %v", err)
+		}
+		w.db.Close()
+	}()
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if err := w.snapshot(ctx); err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			log.Printf("[ERROR] processlist snapshot failed: %v", err)
+		}
+		w.csv.Flush()
+		if err := w.csv.Error(); err != nil {
+			log.Printf("[ERROR] Failed to write processlist: %v", err)
+		}
+	}
+}
+
+// snapshot appends the sessions running now.
+func (w *processlistWriter) snapshot(ctx context.Context) error {
+	timestamp := time.Now().UTC().Format(time.RFC3339Nano)
+	rows, err := w.db.QueryContext(ctx, w.query)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	columns, err := rows.Columns()
+	if err != nil {
+		return err
+	}
+	if !w.header {
+		w.csv.Write(append([]string{"snapshot_time"}, columns...))
+		w.header = true
+	}
+	values := make([]sql.NullString, len(columns))
+	dest := make([]interface{}, len(columns))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+	for rows.Next() {
+		if err := rows.Scan(dest...); err != nil {
+			return err
+		}
+		record := make([]string, 0, len(columns)+1)
+		record = append(record, timestamp)
+		for _, v := range values {
+			record = append(record, v.String)
+		}
+		w.csv.Write(record)
+		atomic.AddInt64(&w.sessions, 1)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	atomic.AddInt64(&w.snapshots, 1)
+	return nil
+}
+
+// logProcesslistSummary logs the snapshots taken and the session rows written.
+func (w *processlistWriter) logProcesslistSummary(path string) {
+	log.Printf("[INFO] Summary: processlist snapshots=%d sessions=%d written to %s",
+		atomic.LoadInt64(&w.snapshots), atomic.LoadInt64(&w.sessions), path)
+}
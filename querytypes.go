@@ -0,0 +1,776 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// queryType is one kind of generated query.
+type queryType struct {
+	name string
+	// write queries run on the tenant's write connection and are executed without reading rows.
+	write bool
+	// mysqlOnly queries have no ClickHouse form and can't be used in -clickhouse-query-mix.
+	mysqlOnly bool
+	// json queries use the doc column, which only exists when tables were prepared with -json-column.
+	json bool
+	// generated queries use the c_prefix column, which only exists when tables were prepared with -generated-column.
+	generated bool
+	// procedure queries call the stored procedure of the table, which only exists when tables were prepared with -stored-procedures.
+	procedure bool
+	// foreignKey queries use the child tables, which only exist when tables were prepared with -foreign-keys.
+	foreignKey bool
+	// common queries read the tables of the common database, which only exists with -common-db.
+	common bool
+	// analytic queries scan ranges of the table and are the ones sent to TiFlash by -tiflash-tenants.
+	analytic bool
+	// orderEntry queries run on the order-entry tables of the order-entry tenants and can't be used in -query-mix.
+	orderEntry bool
+	// ingest queries run on the events table of the ingestion tenants and can't be used in -query-mix.
+	ingest bool
+	// ttl queries run on the TTL tables of the TTL tenants and can't be used in -query-mix.
+	ttl bool
+	// sql returns the statement for a table. It only depends on the table, so workers build it once per table.
+	sql func(t TableInfo) string
+	// run, if set, runs a script of several statements around the statement on the connection instead of it alone.
+	// Scripts are not EXPLAINed.
+	run func(ctx context.Context, conn queryExecutor, t TableInfo, query string, args []interface{}) error
+	// args appends the arguments of the statement for a random row of the table to args.
+	args func(t TableInfo, opts *workloadOptions, rng *rand.Rand, args []interface{}) []interface{}
+}
+
+// build returns the statement and its arguments for a random row of the table.
+func (qt *queryType) build(t TableInfo, opts *workloadOptions, rng *rand.Rand) (string, []interface{}) {
+	return qt.sql(t), qt.args(t, opts, rng, nil)
+}
+
+// batchPointGetSize is the number of ids looked up by one batch_point_get statement, set by -batch-point-get-size.
+// It is part of the statement text, so it is the same for all workers.
+var batchPointGetSize = 10
+
+// queryTypes are the query types that can be used in -query-mix.
+var queryTypes = map[string]*queryType{
+	// The original workload: SELECT c FROM sbtestXYZ WHERE k=? LIMIT 1
+	"point_select": {
+		name: "point_select",
+		sql: func(t TableInfo) string {
+			return "SELECT c FROM " + t.Name + " WHERE " + t.whereSQL("k=?") + " LIMIT 1"
+		},
+		args: func(t TableInfo, opts *workloadOptions, rng *rand.Rand, args []interface{}) []interface{} {
+			return append(t.appendWhereArgs(args), randomK(t, rng))
+		},
+	},
+	// Reads both payload columns of one row by primary key.
+	"payload_read": {
+		name: "payload_read",
+		sql: func(t TableInfo) string {
+			return "SELECT c, pad FROM " + t.Name + " WHERE " + t.whereSQL("id=?")
+		},
+		args: func(t TableInfo, opts *workloadOptions, rng *rand.Rand, args []interface{}) []interface{} {
+			return append(t.appendWhereArgs(args), randomID(t, rng))
+		},
+	},
+	// Looks up several random rows by primary key at once, planned as a batch point get on TiDB.
+	"batch_point_get": {
+		name: "batch_point_get",
+		sql: func(t TableInfo) string {
+			list := strings.TrimSuffix(strings.Repeat("?,", batchPointGetSize), ",")
+			return "SELECT c FROM " + t.Name + " WHERE " + t.whereSQL("id IN ("+list+")")
+		},
+		args: func(t TableInfo, opts *workloadOptions, rng *rand.Rand, args []interface{}) []interface{} {
+			args = t.appendWhereArgs(args)
+			for i := 0; i < batchPointGetSize; i++ {
+				args = append(args, randomID(t, rng))
+			}
+			return args
+		},
+	},
+	// Rewrites both payload columns of one row with fresh values of the configured sizes.
+	"payload_update": {
+		name:      "payload_update",
+		write:     true,
+		mysqlOnly: true,
+		sql: func(t TableInfo) string {
+			return "UPDATE " + t.Name + " SET c=?, pad=? WHERE " + t.whereSQL("id=?")
+		},
+		args: func(t TableInfo, opts *workloadOptions, rng *rand.Rand, args []interface{}) []interface{} {
+			args = append(args, sysbenchString(rng, opts.schema.cLen(t)), sysbenchString(rng, opts.schema.padLen(t)))
+			return append(t.appendWhereArgs(args), randomID(t, rng))
+		},
+	},
+	// Rewrites the payload of a batch of rows within a small hot id range, piling up MVCC versions of the same keys.
+	// The range is set by the -gc-pressure-* flags.
+	"mvcc_rewrite": {
+		name:      "mvcc_rewrite",
+		write:     true,
+		mysqlOnly: true,
+		sql: func(t TableInfo) string {
+			return "UPDATE " + t.Name + " SET c=?, pad=? WHERE " + t.whereSQL("id BETWEEN ? AND ?")
+		},
+		args: func(t TableInfo, opts *workloadOptions, rng *rand.Rand, args []interface{}) []interface{} {
+			args = append(args, sysbenchString(rng, opts.schema.cLen(t)), sysbenchString(rng, opts.schema.padLen(t)))
+			lo, hi := opts.gcPressure.hotRange(t, rng)
+			return append(t.appendWhereArgs(args), lo, hi)
+		},
+	},
+	// Moves a random amount of "balance" (k) from one random row to another in one transaction, keeping the total
+	// balance of the table. The transaction is run by runBankTransfer, the amounts are set by -bank-max-amount.
+	"bank_transfer": {
+		name:      "bank_transfer",
+		write:     true,
+		mysqlOnly: true,
+		sql: func(t TableInfo) string {
+			return "UPDATE " + t.Name + " SET k=k+? WHERE " + t.whereSQL("id=?")
+		},
+		run: runBankTransfer,
+		args: func(t TableInfo, opts *workloadOptions, rng *rand.Rand, args []interface{}) []interface{} {
+			return opts.bank.transferArgs(t, rng, args)
+		},
+	},
+	// Order-entry transactions of the order-entry tenants, run on their schema by runNewOrder, runPayment and
+	// runOrderStatus. The table is the order-entry schema, see orderEntry.Tables.
+	"oe_new_order": {
+		name:       "oe_new_order",
+		write:      true,
+		mysqlOnly:  true,
+		orderEntry: true,
+		sql: func(t TableInfo) string {
+			return "SELECT d_next_o_id FROM " + t.Name + "_district WHERE " + t.whereSQL("d_id=?") + " FOR UPDATE"
+		},
+		run: runNewOrder,
+		args: func(t TableInfo, opts *workloadOptions, rng *rand.Rand, args []interface{}) []interface{} {
+			return opts.orderEntry.newOrderArgs(t, rng, args)
+		},
+	},
+	"oe_payment": {
+		name:       "oe_payment",
+		write:      true,
+		mysqlOnly:  true,
+		orderEntry: true,
+		sql: func(t TableInfo) string {
+			return "UPDATE " + t.Name + "_district SET d_ytd=d_ytd + ? WHERE " + t.whereSQL("d_id=?")
+		},
+		run: runPayment,
+		args: func(t TableInfo, opts *workloadOptions, rng *rand.Rand, args []interface{}) []interface{} {
+			return opts.orderEntry.paymentArgs(t, rng, args)
+		},
+	},
+	"oe_order_status": {
+		name:       "oe_order_status",
+		mysqlOnly:  true,
+		orderEntry: true,
+		sql: func(t TableInfo) string {
+			return "SELECT c_balance, c_last FROM " + t.Name + "_customer WHERE " + t.whereSQL("c_d_id=? AND c_id=?")
+		},
+		run: runOrderStatus,
+		args: func(t TableInfo, opts *workloadOptions, rng *rand.Rand, args []interface{}) []interface{} {
+			return opts.orderEntry.customerArgs(t, rng, args)
+		},
+	},
+	// Time-series queries of the ingestion tenants, on their events table (see ingestion.Tables): appends a batch of
+	// -ingest-batch-rows rows stamped with the current time, and aggregates every series over the recent window.
+	"ingest_insert": {
+		name:      "ingest_insert",
+		write:     true,
+		mysqlOnly: true,
+		ingest:    true,
+		sql:       ingestInsertSQL,
+		args: func(t TableInfo, opts *workloadOptions, rng *rand.Rand, args []interface{}) []interface{} {
+			return opts.ingest.insertArgs(t, rng, args)
+		},
+	},
+	"ingest_recent": {
+		name:      "ingest_recent",
+		mysqlOnly: true,
+		ingest:    true,
+		sql: func(t TableInfo) string {
+			return "SELECT series_id, COUNT(*), AVG(value), MAX(value) FROM " + t.Name + " WHERE " +
+				t.whereSQL("ts >= NOW(3) - INTERVAL ? SECOND") + " GROUP BY series_id ORDER BY series_id LIMIT 100"
+		},
+		args: func(t TableInfo, opts *workloadOptions, rng *rand.Rand, args []interface{}) []interface{} {
+			return opts.ingest.windowArgs(t, args)
+		},
+	},
+	// Insert of a row into a TTL table of a TTL tenant (see ttlTables), expiring after a random lifetime.
+	"ttl_insert": {
+		name:      "ttl_insert",
+		write:     true,
+		mysqlOnly: true,
+		ttl:       true,
+		sql:       ttlInsertSQL,
+		args: func(t TableInfo, opts *workloadOptions, rng *rand.Rand, args []interface{}) []interface{} {
+			return opts.ttl.insertArgs(t, rng, args)
+		},
+	},
+	// Calls the stored procedure of the table, which reads one row by primary key and rewrites its c column.
+	"proc_call": {
+		name:      "proc_call",
+		write:     true,
+		mysqlOnly: true,
+		procedure: true,
+		sql: func(t TableInfo) string {
+			if t.TenantID != 0 {
+				return "CALL " + procedureName(t) + "(?, ?, ?)"
+			}
+			return "CALL " + procedureName(t) + "(?, ?)"
+		},
+		args: func(t TableInfo, opts *workloadOptions, rng *rand.Rand, args []interface{}) []interface{} {
+			args = append(t.appendWhereArgs(args), randomID(t, rng))
+			return append(args, sysbenchString(rng, opts.schema.cLen(t)))
+		},
+	},
+	// Extracts a field of the JSON document of one row by primary key.
+	"json_extract": {
+		name:      "json_extract",
+		json:      true,
+		mysqlOnly: true,
+		sql: func(t TableInfo) string {
+			return "SELECT JSON_EXTRACT(doc, '$.score'), JSON_EXTRACT(doc, '$.attrs.name') FROM " + t.Name + " WHERE " + t.whereSQL("id=?")
+		},
+		args: func(t TableInfo, opts *workloadOptions, rng *rand.Rand, args []interface{}) []interface{} {
+			return append(t.appendWhereArgs(args), randomID(t, rng))
+		},
+	},
+	// Filters documents by a field through the index on the generated column doc_category.
+	"json_filter": {
+		name:      "json_filter",
+		json:      true,
+		mysqlOnly: true,
+		sql: func(t TableInfo) string {
+			return "SELECT id, JSON_EXTRACT(doc, '$.tags') FROM " + t.Name + " WHERE " + t.whereSQL("doc_category=?") + " LIMIT 10"
+		},
+		args: func(t TableInfo, opts *workloadOptions, rng *rand.Rand, args []interface{}) []interface{} {
+			return append(t.appendWhereArgs(args), rng.IntN(jsonCategories))
+		},
+	},
+	// Updates one field of the JSON document of one row in place.
+	"json_update": {
+		name:      "json_update",
+		write:     true,
+		json:      true,
+		mysqlOnly: true,
+		sql: func(t TableInfo) string {
+			return "UPDATE " + t.Name + " SET doc=JSON_SET(doc, '$.score', ?) WHERE " + t.whereSQL("id=?")
+		},
+		args: func(t TableInfo, opts *workloadOptions, rng *rand.Rand, args []interface{}) []interface{} {
+			args = append(args, rng.IntN(1000))
+			return append(t.appendWhereArgs(args), randomID(t, rng))
+		},
+	},
+	// Looks rows up through the index on the generated column c_prefix, the first characters of c.
+	"gen_lookup": {
+		name:      "gen_lookup",
+		generated: true,
+		mysqlOnly: true,
+		sql: func(t TableInfo) string {
+			return "SELECT id, c FROM " + t.Name + " WHERE " + t.whereSQL("c_prefix=?") + " LIMIT 10"
+		},
+		args: func(t TableInfo, opts *workloadOptions, rng *rand.Rand, args []interface{}) []interface{} {
+			return append(t.appendWhereArgs(args), randomPrefix(rng))
+		},
+	},
+	// Counts the rows of a range of c_prefix values, a range scan of the generated column index.
+	"gen_range": {
+		name:      "gen_range",
+		generated: true,
+		mysqlOnly: true,
+		sql: func(t TableInfo) string {
+			return "SELECT COUNT(*) FROM " + t.Name + " WHERE " + t.whereSQL("c_prefix BETWEEN ? AND ?")
+		},
+		args: func(t TableInfo, opts *workloadOptions, rng *rand.Rand, args []interface{}) []interface{} {
+			lo := rng.IntN(generatedPrefixes - generatedPrefixRange + 1)
+			return append(t.appendWhereArgs(args), fmt.Sprintf("%0*d", generatedPrefixSize, lo),
+				fmt.Sprintf("%0*d", generatedPrefixSize, lo+generatedPrefixRange-1))
+		},
+	},
+	// Sums k over a random range of -range-size values of k, aggregated by the storage layer (coprocessor).
+	"sum_range": {
+		name: "sum_range",
+		sql: func(t TableInfo) string {
+			if t.Dialect == dialectClickHouse {
+				return "SELECT sum(k) FROM " + t.Name + " WHERE " + t.whereSQL("k BETWEEN ? AND ?")
+			}
+			return "SELECT SUM(k) FROM " + t.Name + " WHERE " + t.whereSQL("k BETWEEN ? AND ?")
+		},
+		args: func(t TableInfo, opts *workloadOptions, rng *rand.Rand, args []interface{}) []interface{} {
+			lo, hi := randomKSpan(t, opts.rangeSize, rng)
+			return append(t.appendWhereArgs(args), lo, hi)
+		},
+	},
+	// Counts the rows of a random range of -range-size values of k per 2-character prefix of c (up to 100 groups).
+	"group_by_prefix": {
+		name: "group_by_prefix",
+		sql: func(t TableInfo) string {
+			where := t.whereSQL("k BETWEEN ? AND ?")
+			if t.Dialect == dialectClickHouse {
+				return "SELECT substring(c, 1, 2) AS prefix, count() AS cnt FROM " + t.Name + " WHERE " + where + " GROUP BY prefix"
+			}
+			return "SELECT LEFT(c, 2) AS prefix, COUNT(*) AS cnt FROM " + t.Name + " WHERE " + where + " GROUP BY prefix"
+		},
+		args: func(t TableInfo, opts *workloadOptions, rng *rand.Rand, args []interface{}) []interface{} {
+			lo, hi := randomKSpan(t, opts.rangeSize, rng)
+			return append(t.appendWhereArgs(args), lo, hi)
+		},
+	},
+	// Reads a random page of the table ordered by id, skipping the rows before it with OFFSET.
+	"page_offset": {
+		name: "page_offset",
+		sql: func(t TableInfo) string {
+			if t.TenantID != 0 {
+				return "SELECT id, c FROM " + t.Name + " WHERE tenant_id=? ORDER BY id LIMIT ? OFFSET ?"
+			}
+			return "SELECT id, c FROM " + t.Name + " ORDER BY id LIMIT ? OFFSET ?"
+		},
+		args: func(t TableInfo, opts *workloadOptions, rng *rand.Rand, args []interface{}) []interface{} {
+			size := opts.pagination.PageSize()
+			return append(t.appendWhereArgs(args), size, rng.IntN(highestID(t)/size+1)*size)
+		},
+	},
+	// Reads a random page of the table ordered by id, starting after the last id of the previous page (keyset pagination).
+	"page_keyset": {
+		name: "page_keyset",
+		sql: func(t TableInfo) string {
+			return "SELECT id, c FROM " + t.Name + " WHERE " + t.whereSQL("id > ?") + " ORDER BY id LIMIT ?"
+		},
+		args: func(t TableInfo, opts *workloadOptions, rng *rand.Rand, args []interface{}) []interface{} {
+			size := opts.pagination.PageSize()
+			return append(t.appendWhereArgs(args), rng.IntN(highestID(t)/size+1)*size, size)
+		},
+	},
+	// Counts the rows whose c contains a random 3-digit string: no index helps, so the whole table is scanned.
+	"full_scan": {
+		name: "full_scan",
+		sql: func(t TableInfo) string {
+			return "SELECT COUNT(*) FROM " + t.Name + " WHERE " + t.whereSQL("c LIKE ?")
+		},
+		args: func(t TableInfo, opts *workloadOptions, rng *rand.Rand, args []interface{}) []interface{} {
+			return append(t.appendWhereArgs(args), fmt.Sprintf("%%%03d%%", rng.IntN(1000)))
+		},
+	},
+	// Retention delete of an id range, a chunk of rows at a time (see rangeDelete).
+	"range_delete": {
+		name:      "range_delete",
+		write:     true,
+		mysqlOnly: true,
+		sql: func(t TableInfo) string {
+			return "DELETE FROM " + t.Name + " WHERE " + t.whereSQL("id BETWEEN ? AND ?") + " LIMIT ?"
+		},
+		args: func(t TableInfo, opts *workloadOptions, rng *rand.Rand, args []interface{}) []interface{} {
+			return opts.retention.args(t, rng, args)
+		},
+	},
+	// Cross-database join: 10 consecutive rows of the table with their entry of the lookup table of the common database.
+	"common_join": {
+		name:      "common_join",
+		mysqlOnly: true,
+		common:    true,
+		sql: func(t TableInfo) string {
+			return fmt.Sprintf("SELECT t.id, t.c, r.name FROM %s t JOIN %s r ON r.id = t.k %% %d + 1 WHERE %s",
+				t.Name, commonTable("ref_lookup"), commonLookupRows, t.whereSQL("t.id BETWEEN ? AND ?"))
+		},
+		args: func(t TableInfo, opts *workloadOptions, rng *rand.Rand, args []interface{}) []interface{} {
+			lo := randomID(t, rng)
+			return append(t.appendWhereArgs(args), lo, lo+9)
+		},
+	},
+	// Reads one entry of the lookup table of the common database by primary key. It doesn't depend on the table.
+	"common_lookup": {
+		name:      "common_lookup",
+		mysqlOnly: true,
+		common:    true,
+		sql: func(t TableInfo) string {
+			return "SELECT name, weight FROM " + commonTable("ref_lookup") + " WHERE id=?"
+		},
+		args: func(t TableInfo, opts *workloadOptions, rng *rand.Rand, args []interface{}) []interface{} {
+			return append(args, rng.IntN(commonLookupRows)+1)
+		},
+	},
+	// Reads one setting of the global configuration table of the common database by name.
+	"common_config": {
+		name:      "common_config",
+		mysqlOnly: true,
+		common:    true,
+		sql: func(t TableInfo) string {
+			return "SELECT value FROM " + commonTable("global_config") + " WHERE name=?"
+		},
+		args: func(t TableInfo, opts *workloadOptions, rng *rand.Rand, args []interface{}) []interface{} {
+			return append(args, commonConfigName(rng.IntN(commonConfigRows)+1))
+		},
+	},
+	// Reads the whole global configuration table, like an application refreshing its configuration cache.
+	"common_config_scan": {
+		name:      "common_config_scan",
+		mysqlOnly: true,
+		common:    true,
+		sql: func(t TableInfo) string {
+			return "SELECT name, value FROM " + commonTable("global_config")
+		},
+		args: func(t TableInfo, opts *workloadOptions, rng *rand.Rand, args []interface{}) []interface{} {
+			return args
+		},
+	},
+	// Reporting job: joins a session temporary table of 200 consecutive ids with the table and aggregates the result.
+	// The temporary table is created, filled and dropped around the report by runTempTableReport.
+	"temp_table_report": {
+		name:      "temp_table_report",
+		write:     true,
+		mysqlOnly: true,
+		sql: func(t TableInfo) string {
+			return "SELECT s.k DIV 100 AS bucket, SUM(r.weight) AS weight, COUNT(*) AS cnt FROM tmp_report r JOIN " + t.Name +
+				" s ON s.id = r.id WHERE " + t.whereSQL("r.id BETWEEN ? AND ?") + " GROUP BY bucket"
+		},
+		run: runTempTableReport,
+		args: func(t TableInfo, opts *workloadOptions, rng *rand.Rand, args []interface{}) []interface{} {
+			lo := randomID(t, rng)
+			return append(t.appendWhereArgs(args), lo, lo+tempTableRows-1)
+		},
+	},
+	// Inserts a new parent row with its child rows and deletes it again, cascading to the children through the foreign key.
+	// The parent ids lie far above the loaded ones. The children are inserted and the parent deleted by runParentChild.
+	"fk_parent_child": {
+		name:       "fk_parent_child",
+		write:      true,
+		mysqlOnly:  true,
+		foreignKey: true,
+		sql: func(t TableInfo) string {
+			if t.TenantID != 0 {
+				return "INSERT INTO " + t.Name + " (tenant_id, id, k, c, pad) VALUES (?, ?, ?, ?, ?)"
+			}
+			return "INSERT INTO " + t.Name + " (id, k, c, pad) VALUES (?, ?, ?, ?)"
+		},
+		run: runParentChild,
+		args: func(t TableInfo, opts *workloadOptions, rng *rand.Rand, args []interface{}) []interface{} {
+			id := highestID(t) + 1 + rng.IntN(1<<30)
+			return append(t.appendWhereArgs(args), id, randomK(t, rng),
+				sysbenchString(rng, opts.schema.cLen(t)), sysbenchString(rng, opts.schema.padLen(t)))
+		},
+	},
+	// Correlated scalar subquery: counts, for every row of a range of -range-size values of k, the rows sharing its k.
+	// Unqualified columns of the subquery (tenant_id in the row tenancy model) refer to its own table.
+	"correlated_subquery": {
+		name:      "correlated_subquery",
+		mysqlOnly: true,
+		sql: func(t TableInfo) string {
+			return "SELECT o.id, o.k, (SELECT COUNT(*) FROM " + t.Name + " s WHERE " + t.whereSQL("s.k = o.k") + ") AS same_k FROM " +
+				t.Name + " o WHERE " + t.whereSQL("o.k BETWEEN ? AND ?")
+		},
+		args: func(t TableInfo, opts *workloadOptions, rng *rand.Rand, args []interface{}) []interface{} {
+			lo, hi := randomKSpan(t, opts.rangeSize, rng)
+			return append(t.appendWhereArgs(t.appendWhereArgs(args)), lo, hi)
+		},
+	},
+	// Semi join: the rows of a range of -range-size values of k whose k is shared by another row.
+	"exists_subquery": {
+		name:      "exists_subquery",
+		mysqlOnly: true,
+		sql: func(t TableInfo) string {
+			return "SELECT o.id, o.c FROM " + t.Name + " o WHERE " + t.whereSQL("o.k BETWEEN ? AND ?") +
+				" AND EXISTS (SELECT 1 FROM " + t.Name + " s WHERE " + t.whereSQL("s.k = o.k AND s.id <> o.id") + ") LIMIT 100"
+		},
+		args: func(t TableInfo, opts *workloadOptions, rng *rand.Rand, args []interface{}) []interface{} {
+			lo, hi := randomKSpan(t, opts.rangeSize, rng)
+			return t.appendWhereArgs(append(t.appendWhereArgs(args), lo, hi))
+		},
+	},
+	// Anti join: counts the rows of a range of -range-size ids for which no row has the next k value.
+	"not_exists_subquery": {
+		name:      "not_exists_subquery",
+		mysqlOnly: true,
+		sql: func(t TableInfo) string {
+			return "SELECT COUNT(*) FROM " + t.Name + " o WHERE " + t.whereSQL("o.id BETWEEN ? AND ?") +
+				" AND NOT EXISTS (SELECT 1 FROM " + t.Name + " s WHERE " + t.whereSQL("s.k = o.k + 1") + ")"
+		},
+		args: func(t TableInfo, opts *workloadOptions, rng *rand.Rand, args []interface{}) []interface{} {
+			lo := randomID(t, rng)
+			return t.appendWhereArgs(append(t.appendWhereArgs(args), lo, lo+opts.rangeSize-1))
+		},
+	},
+	// Analytical: aggregates a random 1% range of k into 10 buckets.
+	"analytic_agg": {
+		name:     "analytic_agg",
+		analytic: true,
+		sql: func(t TableInfo) string {
+			bucket := (kRangeWidth(t, 0.01)-1)/10 + 1
+			where := t.whereSQL("k BETWEEN ? AND ?")
+			if t.Dialect == dialectClickHouse {
+				return fmt.Sprintf("SELECT intDiv(k, %d) AS bucket, count() AS cnt, avg(length(c)) AS avg_len, uniq(pad) AS pads FROM %s WHERE %s GROUP BY bucket ORDER BY bucket",
+					bucket, t.Name, where)
+			}
+			return fmt.Sprintf("SELECT k DIV %d AS bucket, COUNT(*) AS cnt, AVG(LENGTH(c)) AS avg_len, COUNT(DISTINCT pad) AS pads FROM %s WHERE %s GROUP BY bucket ORDER BY bucket",
+				bucket, t.Name, where)
+		},
+		args: func(t TableInfo, opts *workloadOptions, rng *rand.Rand, args []interface{}) []interface{} {
+			lo, hi := randomKRange(t, 0.01, rng)
+			return append(t.appendWhereArgs(args), lo, hi)
+		},
+	},
+	// Analytical: numbers the rows of a random 1% range of k within 10 buckets and sums k per bucket with window functions.
+	// The window covers the whole range, only the first rows are returned.
+	"analytic_window": {
+		name:     "analytic_window",
+		analytic: true,
+		sql: func(t TableInfo) string {
+			bucket := (kRangeWidth(t, 0.01)-1)/10 + 1
+			where := t.whereSQL("k BETWEEN ? AND ?")
+			if t.Dialect == dialectClickHouse {
+				return fmt.Sprintf("SELECT id, k, row_number() OVER (PARTITION BY intDiv(k, %d) ORDER BY id) AS rn, sum(k) OVER (PARTITION BY intDiv(k, %d)) AS bucket_sum FROM %s WHERE %s ORDER BY intDiv(k, %d), rn LIMIT 100",
+					bucket, bucket, t.Name, where, bucket)
+			}
+			return fmt.Sprintf("SELECT id, k, ROW_NUMBER() OVER (PARTITION BY k DIV %d ORDER BY id) AS rn, SUM(k) OVER (PARTITION BY k DIV %d) AS bucket_sum FROM %s WHERE %s ORDER BY k DIV %d, rn LIMIT 100",
+				bucket, bucket, t.Name, where, bucket)
+		},
+		args: func(t TableInfo, opts *workloadOptions, rng *rand.Rand, args []interface{}) []interface{} {
+			lo, hi := randomKRange(t, 0.01, rng)
+			return append(t.appendWhereArgs(args), lo, hi)
+		},
+	},
+	// Analytical: the most frequent k values of a random 1% range.
+	"analytic_topn": {
+		name:     "analytic_topn",
+		analytic: true,
+		sql: func(t TableInfo) string {
+			where := t.whereSQL("k BETWEEN ? AND ?")
+			if t.Dialect == dialectClickHouse {
+				return "SELECT k, count() AS cnt FROM " + t.Name + " WHERE " + where + " GROUP BY k ORDER BY cnt DESC LIMIT 10"
+			}
+			return "SELECT k, COUNT(*) AS cnt FROM " + t.Name + " WHERE " + where + " GROUP BY k ORDER BY cnt DESC LIMIT 10"
+		},
+		args: func(t TableInfo, opts *workloadOptions, rng *rand.Rand, args []interface{}) []interface{} {
+			lo, hi := randomKRange(t, 0.01, rng)
+			return append(t.appendWhereArgs(args), lo, hi)
+		},
+	},
+}
+
+// kRangeWidth returns the number of k values covering the given fraction of [MinK, MaxK], at least 1.
+func kRangeWidth(t TableInfo, fraction float64) int {
+	width := int(float64(t.MaxK-t.MinK+1) * fraction)
+	if width < 1 {
+		width = 1
+	}
+	return width
+}
+
+// randomKRange returns a random range of column k covering the given fraction of [MinK, MaxK].
+func randomKRange(t TableInfo, fraction float64, rng *rand.Rand) (int, int) {
+	lo := t.MinK + rng.IntN(t.MaxK-t.MinK+1)
+	return lo, lo + kRangeWidth(t, fraction) - 1
+}
+
+// randomKSpan returns a random range of width values of column k starting within [MinK, MaxK].
+func randomKSpan(t TableInfo, width int, rng *rand.Rand) (int, int) {
+	if width < 1 {
+		width = 1
+	}
+	lo := randomK(t, rng)
+	return lo, lo + width - 1
+}
+
+// randomK returns a random value of column k within [MinK, MaxK].
+func randomK(t TableInfo, rng *rand.Rand) int {
+	return rng.IntN(t.MaxK-t.MinK+1) + t.MinK
+}
+
+// randomID returns a random id up to the table's highest.
+func randomID(t TableInfo, rng *rand.Rand) int {
+	return rng.IntN(highestID(t)) + 1
+}
+
+// highestID returns the highest id of the table. Prepare mode loads ids 1..MaxK.
+func highestID(t TableInfo) int {
+	if t.MaxID > 0 {
+		return t.MaxID
+	}
+	return t.MaxK
+}
+
+// queryKey identifies a statement in a queryCache.
+type queryKey struct {
+	qt    *queryType
+	table int
+}
+
+// queryCache holds the statements a worker has built, with hints applied, by query type and table index,
+// so the hot path only generates the arguments. It belongs to a single worker.
+type queryCache map[queryKey]string
+
+// Get returns the statement of a query type on the i-th table, building it with build on first use.
+func (c queryCache) Get(qt *queryType, i int, build func() string) string {
+	key := queryKey{qt: qt, table: i}
+	query, ok := c[key]
+	if !ok {
+		query = build()
+		c[key] = query
+	}
+	return query
+}
+
+// runQuery executes a generated query on conn. Rows of reads are read and discarded, and added to volume unless it is
+// nil. The rows of transaction scripts are not counted.
+func runQuery(ctx context.Context, conn queryExecutor, qt *queryType, t TableInfo, query string, args []interface{}, volume *resultVolume) error {
+	if qt.run != nil {
+		return qt.run(ctx, conn, t, query, args)
+	}
+	if qt.write {
+		_, err := conn.ExecContext(ctx, query, args...)
+		return err
+	}
+	rows, err := conn.QueryContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	return drainRows(rows, volume)
+}
+
+// queryMix picks query types at random according to their weights.
+type queryMix struct {
+	types   []*queryType
+	weights []int // cumulative
+}
+
+// parseQueryMix parses "type:weight,type:weight", e.g. "point_select:90,payload_update:10".
+// A type without weight gets weight 1.
+func parseQueryMix(spec string) (*queryMix, error) {
+	mix := &queryMix{}
+	total := 0
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		name, weightText, hasWeight := strings.Cut(item, ":")
+		qt, ok := queryTypes[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown query type %q (known: %s)", name, strings.Join(queryTypeNames(), ", "))
+		}
+		weight := 1
+		if hasWeight {
+			var err error
+			if weight, err = strconv.Atoi(weightText); err != nil || weight < 0 {
+				return nil, fmt.Errorf("invalid weight in %q", item)
+			}
+		}
+		if weight == 0 {
+			continue
+		}
+		total += weight
+		mix.types = append(mix.types, qt)
+		mix.weights = append(mix.weights, total)
+	}
+	if total == 0 {
+		return nil, fmt.Errorf("query mix %q selects no query type", spec)
+	}
+	return mix, nil
+}
+
+// Pick returns a random query type.
+func (m *queryMix) Pick(rng *rand.Rand) *queryType {
+	n := rng.IntN(m.weights[len(m.weights)-1])
+	i := sort.SearchInts(m.weights, n+1)
+	return m.types[i]
+}
+
+// NeedsJSON reports whether the mix contains query types using the JSON column.
+func (m *queryMix) NeedsJSON() bool {
+	for _, qt := range m.types {
+		if qt.json {
+			return true
+		}
+	}
+	return false
+}
+
+// NeedsGenerated reports whether the mix contains query types using the generated column.
+func (m *queryMix) NeedsGenerated() bool {
+	for _, qt := range m.types {
+		if qt.generated {
+			return true
+		}
+	}
+	return false
+}
+
+// NeedsProcedures reports whether the mix contains query types calling the stored procedures.
+func (m *queryMix) NeedsProcedures() bool {
+	for _, qt := range m.types {
+		if qt.procedure {
+			return true
+		}
+	}
+	return false
+}
+
+// NeedsForeignKeys reports whether the mix contains query types using the child tables.
+func (m *queryMix) NeedsForeignKeys() bool {
+	for _, qt := range m.types {
+		if qt.foreignKey {
+			return true
+		}
+	}
+	return false
+}
+
+// NeedsCommon reports whether the mix contains query types reading the common database.
+func (m *queryMix) NeedsCommon() bool {
+	for _, qt := range m.types {
+		if qt.common {
+			return true
+		}
+	}
+	return false
+}
+
+// NeedsOrderEntry reports whether the mix contains order-entry transactions.
+func (m *queryMix) NeedsOrderEntry() bool {
+	for _, qt := range m.types {
+		if qt.orderEntry {
+			return true
+		}
+	}
+	return false
+}
+
+// NeedsIngest reports whether the mix contains time-series ingestion query types.
+func (m *queryMix) NeedsIngest() bool {
+	for _, qt := range m.types {
+		if qt.ingest {
+			return true
+		}
+	}
+	return false
+}
+
+// NeedsTTL reports whether the mix contains query types of TTL tables.
+func (m *queryMix) NeedsTTL() bool {
+	for _, qt := range m.types {
+		if qt.ttl {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckClickHouse returns an error if the mix contains query types without a ClickHouse form.
+func (m *queryMix) CheckClickHouse() error {
+	for _, qt := range m.types {
+		if qt.mysqlOnly {
+			return fmt.Errorf("query type %s is not supported on ClickHouse", qt.name)
+		}
+	}
+	return nil
+}
+
+// queryTypeNames returns the known query types in sorted order.
+func queryTypeNames() []string {
+	names := make([]string, 0, len(queryTypes))
+	for name := range queryTypes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"math/rand/v2"
+	"reflect"
+	"sync/atomic"
+	"testing"
+)
+
+// benchmarkOptions returns the workload options of the benchmarks: the default tables and sysbench payload sizes.
+func benchmarkOptions() *workloadOptions {
+	return &workloadOptions{
+		tables:    prepareTables(tableNameTemplate{format: "sbtest%d", prefix: "sbtest"}, 67, 10000, 334, 900, 3, 334800),
+		schema:    &schemaOptions{cSize: 120, padSize: 60},
+		rangeSize: 100,
+	}
+}
+
+// BenchmarkQueryBuild builds every statement from scratch, as the worker loop did before statements were cached.
+func BenchmarkQueryBuild(b *testing.B) {
+	opts := benchmarkOptions()
+	qt := queryTypes["point_select"]
+	rng := newWorkerRand("bench", 0)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		t := opts.tables[rng.IntN(len(opts.tables))]
+		qt.build(t, opts, rng)
+	}
+}
+
+// BenchmarkQueryCached builds statements like the worker loop: from the worker's cache, reusing the argument slice.
+func BenchmarkQueryCached(b *testing.B) {
+	opts := benchmarkOptions()
+	qt := queryTypes["point_select"]
+	rng := newWorkerRand("bench", 0)
+	queries := make(queryCache)
+	var args []interface{}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		tableIndex := rng.IntN(len(opts.tables))
+		t := opts.tables[tableIndex]
+		queries.Get(qt, tableIndex, func() string { return qt.sql(t) })
+		args = qt.args(t, opts, rng, args[:0])
+	}
+}
+
+// BenchmarkRandGlobalParallel draws from the global source from all goroutines, like workers used to.
+func BenchmarkRandGlobalParallel(b *testing.B) {
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			rand.IntN(10000)
+		}
+	})
+}
+
+// BenchmarkRandWorkerParallel draws from a source per goroutine, like the workers do.
+func BenchmarkRandWorkerParallel(b *testing.B) {
+	var workers int64
+	b.RunParallel(func(pb *testing.PB) {
+		rng := newWorkerRand("bench", int(atomic.AddInt64(&workers, 1)))
+		for pb.Next() {
+			rng.IntN(10000)
+		}
+	})
+}
+
+// TestQueryCacheMatchesBuild checks for every query type that the worker loop's cached statement and reused argument
+// slice give the same statement and arguments as building them from scratch with the same random source.
+func TestQueryCacheMatchesBuild(t *testing.T) {
+	opts := benchmarkOptions()
+	for name, qt := range queryTypes {
+		built, cached := newWorkerRand(name, 0), newWorkerRand(name, 0)
+		queries := make(queryCache)
+		var args []interface{}
+		for i := 0; i < 3*len(opts.tables); i++ {
+			tableIndex := i % len(opts.tables)
+			table := opts.tables[tableIndex]
+			wantQuery, wantArgs := qt.build(table, opts, built)
+			query := queries.Get(qt, tableIndex, func() string { return qt.sql(table) })
+			args = qt.args(table, opts, cached, args[:0])
+			if query != wantQuery {
+				t.Fatalf("%s on %s: cached statement %q, built %q", name, table.Name, query, wantQuery)
+			}
+			if !reflect.DeepEqual(args, wantArgs) && !(len(args) == 0 && len(wantArgs) == 0) {
+				t.Fatalf("%s on %s: reused arguments %v, built %v", name, table.Name, args, wantArgs)
+			}
+		}
+	}
+}
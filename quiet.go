@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// quietSchedule drops all tenants to minimal load for a quiet window at the end of every period, e.g. 30s out of
+// every 5 minutes, and compares the latency of every tenant in the quiet windows with the loaded ones. The quiet
+// windows are the baseline of the run: what the cluster delivers when the tenants don't interfere with each other.
+// A nil *quietSchedule never quiets the run.
+type quietSchedule struct {
+	period  time.Duration
+	length  time.Duration
+	workers int // active workers per tenant in a quiet window
+
+	quiet int32 // 1 during a quiet window, read by the workers without locking
+
+	mu       sync.Mutex
+	previous map[string]*tenantStats // statistics at the last phase change
+	elapsed  float64                 // elapsed seconds of the run at the last phase change
+	phases   [2]quietPhase           // loaded, quiet
+}
+
+// quietPhase accumulates the queries of all quiet or all loaded windows.
+type quietPhase struct {
+	seconds float64
+	tenants map[string]*queryTypeStats
+}
+
+// newQuietSchedule returns nil when length is 0.
+func newQuietSchedule(period, length time.Duration, workers int) (*quietSchedule, error) {
+	if length <= 0 {
+		return nil, nil
+	}
+	if period <= length {
+		return nil, fmt.Errorf("the quiet period (%v) must be longer than the quiet window (%v)", period, length)
+	}
+	if workers < 0 {
+		return nil, fmt.Errorf("quiet workers must not be negative")
+	}
+	q := &quietSchedule{period: period, length: length, workers: workers, previous: make(map[string]*tenantStats)}
+	for i := range q.phases {
+		q.phases[i].tenants = make(map[string]*queryTypeStats)
+	}
+	return q, nil
+}
+
+// Admit reports whether the worker-th worker (0-based) of a tenant may run a query now.
+func (q *quietSchedule) Admit(worker int) bool {
+	return q == nil || atomic.LoadInt32(&q.quiet) == 0 || worker < q.workers
+}
+
+// Run switches between loaded and quiet windows from start until ctx is done, attributing the queries
+// since the last switch to the window that just ended.
+func (q *quietSchedule) Run(ctx context.Context, stats *statsCollector, start time.Time) {
+	for period := time.Duration(0); ; period += q.period {
+		// Loaded until the quiet window of this period starts, then quiet until the period ends.
+		if !sleepCtx(ctx, time.Until(start.Add(period+q.period-q.length))) {
+			return
+		}
+		q.record(stats.Snapshot())
+		atomic.StoreInt32(&q.quiet, 1)
+		if !sleepCtx(ctx, time.Until(start.Add(period+q.period))) {
+			return
+		}
+		q.record(stats.Snapshot())
+		atomic.StoreInt32(&q.quiet, 0)
+	}
+}
+
+// Finish attributes the queries after the last switch, from the final statistics of the run.
+func (q *quietSchedule) Finish(snapshot *statsSnapshot) {
+	q.record(snapshot)
+}
+
+// record adds the queries since the last switch to the current phase.
+func (q *quietSchedule) record(snapshot *statsSnapshot) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	phase := &q.phases[atomic.LoadInt32(&q.quiet)]
+	phase.seconds += snapshot.ElapsedSeconds - q.elapsed
+	q.elapsed = snapshot.ElapsedSeconds
+	for _, name := range snapshot.TenantNames() {
+		current := snapshot.Tenants[name]
+		prev, ok := q.previous[name]
+		if !ok {
+			prev = newTenantStats()
+		}
+		acc, ok := phase.tenants[name]
+		if !ok {
+			acc = newQueryTypeStats()
+			phase.tenants[name] = acc
+		}
+		acc.merge(&queryTypeStats{
+			Queries: current.Queries - prev.Queries,
+			Errors:  current.Errors - prev.Errors,
+			Latency: current.Latency.Since(prev.Latency),
+		})
+		q.previous[name] = current
+	}
+}
+
+// logQuietReport compares the latency of every tenant, and of all tenants, in the quiet and the loaded windows.
+func (q *quietSchedule) logQuietReport() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	loaded, quiet := q.phases[0], q.phases[1]
+	if quiet.seconds == 0 || loaded.seconds == 0 {
+		log.Printf("[INFO] Quiet baseline: the run had no complete quiet and loaded windows")
+		return
+	}
+	line := func(name string, l, b *queryTypeStats) {
+		if l == nil || b == nil || l.Queries == 0 || b.Queries == 0 {
+			log.Printf("[INFO] Quiet baseline: DB=%s not enough queries", name)
+			return
+		}
+		degradation := 0.0
+		if baseline := b.Latency.Quantile(0.99); baseline > 0 {
+			degradation = float64(l.Latency.Quantile(0.99)) / float64(baseline)
+		}
+		log.Printf("[INFO] Quiet baseline: DB=%s qps loaded=%.1f quiet=%.1f p50 loaded=%v quiet=%v p99 loaded=%v quiet=%v degradation=%.2fx",
+			name, float64(l.Queries)/loaded.seconds, float64(b.Queries)/quiet.seconds,
+			l.Latency.Quantile(0.50), b.Latency.Quantile(0.50), l.Latency.Quantile(0.99), b.Latency.Quantile(0.99), degradation)
+	}
+	allLoaded, allQuiet := newQueryTypeStats(), newQueryTypeStats()
+	for _, name := range sortedTypeNames(loaded.tenants) {
+		line(name, loaded.tenants[name], quiet.tenants[name])
+		allLoaded.merge(loaded.tenants[name])
+		if b, ok := quiet.tenants[name]; ok {
+			allQuiet.merge(b)
+		}
+	}
+	line("all", allLoaded, allQuiet)
+	log.Printf("[INFO] Quiet baseline: %.0fs in quiet windows, %.0fs loaded", quiet.seconds, loaded.seconds)
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"hash/fnv"
+	"math/rand/v2"
+	"sync/atomic"
+)
+
+// runSeed is the seed of the run (-seed) every goroutine derives its random source from.
+var runSeed uint64
+
+// setRunSeed sets the seed of the run; 0 picks a random one. It returns the seed in use, to be logged
+// so the generated values of a run can be reproduced.
+func setRunSeed(seed uint64) uint64 {
+	for seed == 0 {
+		seed = rand.Uint64()
+	}
+	runSeed = seed
+	return seed
+}
+
+// newWorkerRand returns a random source of its own for one goroutine, so workers don't contend for a shared
+// source and random generation scales with the number of workers. The source depends only on the run seed
+// and the goroutine's stream (e.g. the tenant) and index, so the same run seed gives every worker the same values.
+func newWorkerRand(stream string, index int) *rand.Rand {
+	h := fnv.New64a()
+	h.Write([]byte(stream))
+	return rand.New(rand.NewPCG(runSeed, h.Sum64()^uint64(index)))
+}
+
+// mockConnSeq numbers the connections of the mock driver, each of which has its own random source.
+var mockConnSeq int64
+
+// nextMockConnIndex returns the index of a new mock connection.
+func nextMockConnIndex() int {
+	return int(atomic.AddInt64(&mockConnSeq, 1))
+}
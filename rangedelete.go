@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math/rand/v2"
+	"sync/atomic"
+	"time"
+)
+
+// Defaults of the range_delete query type when it runs without range delete settings, e.g. in -query-mix.
+const (
+	defaultRangeDeleteChunk = 1000
+	defaultRangeDeleteSpan  = 10000
+)
+
+// rangeDelete makes selected tenants run a data retention job next to their normal workload: it walks the tables of
+// the tenant from the lowest id up, deleting the rows of one id range after the other in chunks of
+// DELETE ... WHERE id BETWEEN ? AND ? LIMIT n, pausing between the chunks, so the load of background maintenance,
+// large deletes and the garbage they leave behind, is part of the tenant mix. The deletes are recorded as
+// range_delete queries of the tenant. A nil *rangeDelete is disabled.
+type rangeDelete struct {
+	tenants tenantSet
+	chunk   int           // rows deleted by one statement at most
+	span    int           // ids of the range deleted chunk by chunk
+	pace    time.Duration // between two chunks
+
+	deleted int64 // rows deleted, over all tenants
+	ranges  int64 // id ranges finished
+}
+
+// newRangeDelete returns nil when chunk is 0.
+func newRangeDelete(tenants tenantSet, chunk, span int, pace time.Duration) (*rangeDelete, error) {
+	if chunk <= 0 {
+		return nil, nil
+	}
+	if span < 1 {
+		return nil, fmt.Errorf("the id range must be at least 1")
+	}
+	if pace < 0 {
+		return nil, fmt.Errorf("the pause between chunks can't be negative")
+	}
+	return &rangeDelete{tenants: tenants, chunk: chunk, span: span, pace: pace}, nil
+}
+
+// Applies reports whether the tenant runs the retention job.
+func (d *rangeDelete) Applies(tenant string) bool {
+	return d != nil && d.tenants.Contains(tenant)
+}
+
+// args appends the arguments of a range_delete of a random id range: the tenant_id in the row tenancy model, the
+// bounds of the range and the chunk size.
+func (d *rangeDelete) args(t TableInfo, rng *rand.Rand, args []interface{}) []interface{} {
+	chunk, span := defaultRangeDeleteChunk, defaultRangeDeleteSpan
+	if d != nil {
+		chunk, span = d.chunk, d.span
+	}
+	lo := randomID(t, rng)
+	return append(t.appendWhereArgs(args), lo, lo+span-1, chunk)
+}
+
+// Run deletes chunk after chunk on a connection of the tenant's write pool until ctx is done or exitTime is reached.
+// A range is finished when a chunk deletes fewer rows than the chunk size; after the highest id of a table the job
+// goes on with the next table, and after the last table it starts over, then deleting what was written since.
+func (d *rangeDelete) Run(ctx context.Context, pool *tenantPool, dbName string, opts *workloadOptions) {
+	conn, err := retryMakeActiveConn(pool.writes(), dbName, ctx)
+	if err != nil {
+		return
+	}
+	defer func() { conn.Close() }()
+
+	stats := opts.stats.Tenant(dbName)
+	qt := queryTypes["range_delete"]
+	tables := opts.tenantTables(dbName)
+	table, lo := 0, 1
+	for ctx.Err() == nil && time.Now().Before(opts.exitTime) {
+		t := tables[table]
+		query := opts.tags.Tag(qt.sql(t), dbName, -1, qt.name)
+		args := append(t.appendWhereArgs(nil), lo, lo+d.span-1, d.chunk)
+		start := time.Now()
+		result, err := conn.ExecContext(ctx, query, args...)
+		opts.observeQuery(stats, dbName, qt.name, start, query, args, time.Since(start), err)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			workerLog.Printf(logClass("query failed", err), "[ERROR] DB=%s table=%s type=%s query failed: %v", dbName, t.Name, qt.name, err)
+			if conn.PingContext(ctx) != nil {
+				conn.Close()
+				newConn, err := retryMakeActiveConn(pool.writes(), dbName, ctx)
+				if err != nil {
+					return
+				}
+				conn = newConn
+			}
+			sleepUntilExit(ctx, time.Second, opts.exitTime)
+			continue
+		}
+		n, _ := result.RowsAffected()
+		atomic.AddInt64(&d.deleted, n)
+		if n < int64(d.chunk) {
+			atomic.AddInt64(&d.ranges, 1)
+			lo += d.span
+			if lo > highestID(t) {
+				table, lo = (table+1)%len(tables), 1
+			}
+		}
+		if !sleepUntilExit(ctx, d.pace, opts.exitTime) {
+			return
+		}
+	}
+}
+
+// logRangeDeleteSummary logs the rows deleted by the retention jobs and the id ranges they finished.
+func (d *rangeDelete) logRangeDeleteSummary() {
+	log.Printf("[INFO] Summary: range delete deleted=%d row(s) in %d finished id range(s)",
+		atomic.LoadInt64(&d.deleted), atomic.LoadInt64(&d.ranges))
+}
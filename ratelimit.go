@@ -0,0 +1,187 @@
+package main
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// tokenBucket is a token bucket rate limiter: rate tokens per second, holding up to burst tokens.
+// It is shared by all workers of a tenant.
+type tokenBucket struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// newTokenBucket returns a full bucket. burst is raised to 1 so at least one query can pass.
+func newTokenBucket(rate float64, burst int) *tokenBucket {
+	b := float64(burst)
+	if b < 1 {
+		b = 1
+	}
+	return &tokenBucket{rate: rate, burst: b, tokens: b, last: time.Now()}
+}
+
+// reserve takes one token and returns how long the caller has to wait until it is actually available.
+func (b *tokenBucket) reserve() time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	now := time.Now()
+	b.tokens += now.Sub(b.last).Seconds() * b.rate
+	if b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+	b.last = now
+	b.tokens--
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / b.rate * float64(time.Second))
+}
+
+// refund gives back a token taken by reserve that wasn't used.
+func (b *tokenBucket) refund() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.tokens = math.Min(b.tokens+1, b.burst)
+}
+
+// SetRate changes the rate and burst, keeping the tokens accumulated at the old rate.
+func (b *tokenBucket) SetRate(rate float64, burst int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	now := time.Now()
+	b.tokens += now.Sub(b.last).Seconds() * b.rate
+	b.last = now
+	b.rate, b.burst = rate, math.Max(1, float64(burst))
+	if b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+}
+
+// Rate returns the current rate in tokens per second.
+func (b *tokenBucket) Rate() float64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.rate
+}
+
+// Wait blocks until a token is available and reports whether ctx is still active.
+func (b *tokenBucket) Wait(ctx context.Context) bool {
+	return sleepCtx(ctx, b.reserve())
+}
+
+// priorityAdmission hands out a shared rate of admissions, always to the waiter of the highest priority
+// (lowest number) first, modeling the admission control of a SaaS platform in front of the database.
+type priorityAdmission struct {
+	bucket  *tokenBucket
+	mu      sync.Mutex
+	waiting map[int][]chan struct{}
+	wake    chan struct{}
+}
+
+// newPriorityAdmission starts the dispatcher admitting rate queries per second until ctx is done.
+func newPriorityAdmission(ctx context.Context, rate float64) *priorityAdmission {
+	a := &priorityAdmission{
+		bucket:  newTokenBucket(rate, int(rate/10)), // up to 100ms worth of queries at once
+		waiting: make(map[int][]chan struct{}),
+		wake:    make(chan struct{}, 1),
+	}
+	go a.dispatch(ctx)
+	return a
+}
+
+// Wait blocks until the query is admitted and reports whether ctx is still active. A query whose ctx is done leaves
+// the queue, and gives the token back if it was admitted meanwhile.
+func (a *priorityAdmission) Wait(ctx context.Context, priority int) bool {
+	ch := make(chan struct{})
+	a.mu.Lock()
+	a.waiting[priority] = append(a.waiting[priority], ch)
+	a.mu.Unlock()
+	select {
+	case a.wake <- struct{}{}:
+	default:
+	}
+	select {
+	case <-ch:
+		return true
+	case <-ctx.Done():
+		if !a.leave(priority, ch) {
+			// dispatch took it off the queue with a token, and closes ch.
+			a.bucket.refund()
+		}
+		return false
+	}
+}
+
+// leave removes a waiter from its queue and reports whether it was still waiting.
+func (a *priorityAdmission) leave(priority int, ch chan struct{}) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	q := a.waiting[priority]
+	for i, waiter := range q {
+		if waiter == ch {
+			a.waiting[priority] = append(q[:i:i], q[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// next removes and returns the first waiter of the highest priority, nil if nobody waits.
+func (a *priorityAdmission) next() chan struct{} {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	best := -1
+	for p, q := range a.waiting {
+		if len(q) > 0 && (best < 0 || p < best) {
+			best = p
+		}
+	}
+	if best < 0 {
+		return nil
+	}
+	ch := a.waiting[best][0]
+	a.waiting[best] = a.waiting[best][1:]
+	return ch
+}
+
+// dispatch admits one waiter per token. The waiter is picked once the token is available,
+// so a high priority query arriving meanwhile still goes first.
+func (a *priorityAdmission) dispatch(ctx context.Context) {
+	for {
+		if !a.pending() {
+			select {
+			case <-ctx.Done():
+				return
+			case <-a.wake:
+			}
+			continue
+		}
+		if !a.bucket.Wait(ctx) {
+			return
+		}
+		if ch := a.next(); ch != nil {
+			close(ch)
+		} else {
+			// The waiters left while the token was awaited.
+			a.bucket.refund()
+		}
+	}
+}
+
+// pending reports whether anybody waits.
+func (a *priorityAdmission) pending() bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for _, q := range a.waiting {
+		if len(q) > 0 {
+			return true
+		}
+	}
+	return false
+}
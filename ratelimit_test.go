@@ -0,0 +1,41 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestPriorityAdmissionTimedOutWaiters checks that waiters whose context is done leave the queue without spending
+// the admissions of the ones still waiting.
+func TestPriorityAdmissionTimedOutWaiters(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	a := newPriorityAdmission(ctx, 10) // one admission per 100ms
+	if !a.Wait(ctx, 1) {
+		t.Fatal("the first query wasn't admitted")
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			waitCtx, stop := context.WithTimeout(ctx, 20*time.Millisecond)
+			defer stop()
+			a.Wait(waitCtx, 1)
+		}()
+	}
+	wg.Wait()
+	if a.pending() {
+		t.Error("timed-out waiters are still queued")
+	}
+
+	waitCtx, stop := context.WithTimeout(ctx, time.Second)
+	defer stop()
+	start := time.Now()
+	if !a.Wait(waitCtx, 1) {
+		t.Fatalf("a query after the timed-out ones wasn't admitted within %v", time.Since(start))
+	}
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"log"
+	"math/rand/v2"
+	"time"
+)
+
+// connRecycler drops the dedicated connection of every worker once it is older than its lifetime and establishes
+// a new one, like the maxLifetime of an application's connection pool, so the steady connection churn of production
+// pools and the latency of the re-handshakes are part of the simulation. A nil *connRecycler never recycles.
+type connRecycler struct {
+	lifetime time.Duration
+	jitter   float64 // lifetimes are spread uniformly over lifetime * (1 ± jitter)
+}
+
+// newConnRecycler returns nil when lifetime is 0.
+func newConnRecycler(lifetime time.Duration, jitter float64) (*connRecycler, error) {
+	if lifetime <= 0 {
+		return nil, nil
+	}
+	if jitter < 0 || jitter >= 1 {
+		return nil, fmt.Errorf("the lifetime jitter must be in [0, 1)")
+	}
+	return &connRecycler{lifetime: lifetime, jitter: jitter}, nil
+}
+
+// Deadline returns when a connection established now is to be recycled, the zero time for never.
+// The jitter keeps the connections of workers started together from being recycled together.
+func (r *connRecycler) Deadline(rng *rand.Rand) time.Time {
+	if r == nil {
+		return time.Time{}
+	}
+	lifetime := time.Duration(float64(r.lifetime) * (1 + r.jitter*(2*rng.Float64()-1)))
+	return time.Now().Add(lifetime)
+}
+
+// Due reports whether a connection with the deadline is to be recycled now.
+func (r *connRecycler) Due(deadline time.Time) bool {
+	return r != nil && !deadline.IsZero() && time.Now().After(deadline)
+}
+
+// discardConn closes conn and the driver connection underneath it, so database/sql doesn't put it back into the
+// pool and the next connection taken from the pool has to be established anew.
+func discardConn(conn workerConn) {
+	// Returning driver.ErrBadConn makes database/sql close the driver connection.
+	conn.Raw(func(driverConn interface{}) error {
+		return driver.ErrBadConn
+	})
+	conn.Close()
+}
+
+// logRecycleSummary logs the recycled connections of every tenant and the time it took to establish their successors.
+func logRecycleSummary(s *statsSnapshot) {
+	for _, name := range s.TenantNames() {
+		t := s.Tenants[name]
+		log.Printf("[INFO] recycle: DB=%s recycles=%d re-handshake avg=%v p99=%v max=%v",
+			name, t.Recycles, t.RecycleTime.Mean(), t.RecycleTime.Quantile(0.99),
+			time.Duration(t.RecycleTime.MaxUs)*time.Microsecond)
+	}
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+)
+
+// regionRule puts some tenants into a region, optionally served by the region's own DSNs.
+type regionRule struct {
+	tenants tenantSet // nil = every tenant
+	region  string
+	dsn     string // "" = the tenants keep the -dsn prefix
+	readDSN string // "" = reads use dsn
+}
+
+// tenantRegions tags tenants with the region they are served from in a geo-distributed deployment, e.g. a TiDB
+// cluster whose placement rules keep each tenant's data in its region, so one coordinator can drive the tenants of
+// all regions through the regional endpoints and report the latency per region. A nil *tenantRegions puts no tenant
+// into a region.
+type tenantRegions struct {
+	rules []regionRule
+}
+
+// loadRegionFile reads a region file with one "tenants region [dsn [read_dsn]]" entry per line, e.g.
+//
+//	1-4   us-east   root:@tcp(tidb-us-east:4000)/
+//	5-8   eu-west   root:@tcp(tidb-eu-west:4000)/  root:@tcp(tidb-eu-west-ro:4000)/
+//	*     ap-north
+//
+// tenants are names or 1-based ranges, "*" for every tenant; a tenant gets the region of the first matching line.
+// A DSN ending in "/" is treated as a prefix and gets the tenant's database name appended; without a DSN, or with
+// "-", the tenants keep the -dsn prefix (and -read-dsn). Empty lines and lines starting with "#" are ignored.
+func loadRegionFile(path string) (*tenantRegions, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	r := &tenantRegions{}
+	scanner := bufio.NewScanner(f)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) < 2 || len(fields) > 4 {
+			return nil, fmt.Errorf("%s:%d: want \"tenants region [dsn [read_dsn]]\", got %q", path, lineNo, line)
+		}
+		rule := regionRule{region: fields[1]}
+		if fields[0] != "*" {
+			if rule.tenants, err = parseTenantSet(fields[0]); err != nil {
+				return nil, fmt.Errorf("%s:%d: %v", path, lineNo, err)
+			}
+		}
+		if len(fields) > 2 && fields[2] != "-" {
+			rule.dsn = fields[2]
+		}
+		if len(fields) > 3 {
+			if rule.dsn == "" {
+				return nil, fmt.Errorf("%s:%d: a read DSN needs the region's DSN", path, lineNo)
+			}
+			rule.readDSN = fields[3]
+		}
+		r.rules = append(r.rules, rule)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// rule returns the first rule matching the tenant, nil when it is in no region.
+func (r *tenantRegions) rule(tenant string) *regionRule {
+	if r == nil {
+		return nil
+	}
+	for i := range r.rules {
+		if r.rules[i].tenants.Contains(tenant) {
+			return &r.rules[i]
+		}
+	}
+	return nil
+}
+
+// Region returns the region of the tenant, "" when it is in no region.
+func (r *tenantRegions) Region(tenant string) string {
+	if rule := r.rule(tenant); rule != nil {
+		return rule.region
+	}
+	return ""
+}
+
+// DSN returns the DSN of the tenant's region for writes and for reads, "" when the tenant keeps the -dsn prefix.
+func (r *tenantRegions) DSN(tenant string) (dsn, readDSN string) {
+	rule := r.rule(tenant)
+	if rule == nil || rule.dsn == "" {
+		return "", ""
+	}
+	if rule.readDSN == "" {
+		return rule.dsn, rule.dsn
+	}
+	return rule.dsn, rule.readDSN
+}
+
+// regionSummary is the summary of the tenants of one region.
+type regionSummary struct {
+	Region      string `json:"region"`
+	TenantCount int    `json:"tenant_count"`
+	tenantSummary
+}
+
+// Summaries returns the summary of every region, in the order of the region names. Tenants in no region are
+// summarized as "(none)".
+func (r *tenantRegions) Summaries(snapshot *statsSnapshot) []regionSummary {
+	if r == nil {
+		return nil
+	}
+	totals := make(map[string]*tenantStats)
+	counts := make(map[string]int)
+	for _, name := range snapshot.TenantNames() {
+		region := r.Region(name)
+		if region == "" {
+			region = "(none)"
+		}
+		total, ok := totals[region]
+		if !ok {
+			total = newTenantStats()
+			totals[region] = total
+		}
+		total.merge(snapshot.Tenants[name])
+		counts[region]++
+	}
+	names := make([]string, 0, len(totals))
+	for name := range totals {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	summaries := make([]regionSummary, 0, len(names))
+	for _, name := range names {
+		summaries = append(summaries, regionSummary{Region: name, TenantCount: counts[name],
+			tenantSummary: summarizeTenant("", totals[name], snapshot.ElapsedSeconds)})
+	}
+	return summaries
+}
+
+// logRegionSummary logs the queries, QPS and latency of the tenants of every region.
+func logRegionSummary(summaries []regionSummary) {
+	for _, s := range summaries {
+		log.Printf("[INFO] region=%s tenants=%d queries=%d errors=%d qps=%.1f avg=%.2fms p50=%.2fms p95=%.2fms p99=%.2fms",
+			s.Region, s.TenantCount, s.Queries, s.Errors, s.QPS, s.Latency.AvgMs, s.Latency.P50Ms, s.Latency.P95Ms, s.Latency.P99Ms)
+	}
+}
@@ -0,0 +1,390 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ReplayRecord is one statement to be replayed against a tenant database.
+type ReplayRecord struct {
+	Time   time.Time
+	Tenant string
+	SQL    string
+	Args   []interface{}
+}
+
+// replayReader yields replay records in file order and returns io.EOF at the end.
+type replayReader interface {
+	Next() (ReplayRecord, error)
+}
+
+// newReplayReader returns a reader for the given replay file format.
+//
+//	tsv:         timestamp(RFC3339Nano) \t tenant \t sql [\t args(JSON array) [\t ...]]
+//	general-log: MySQL general query log; the tenant is the current database of each thread.
+func newReplayReader(r io.Reader, format string) (replayReader, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	switch format {
+	case "tsv":
+		return &tsvReplayReader{scanner: scanner}, nil
+	case "general-log":
+		return &generalLogReplayReader{scanner: scanner, threadDB: make(map[string]string)}, nil
+	default:
+		return nil, fmt.Errorf("unknown replay format %q (want tsv or general-log)", format)
+	}
+}
+
+// tsvReplayReader reads the tab separated format, which is also what capture mode writes.
+type tsvReplayReader struct {
+	scanner *bufio.Scanner
+	line    int
+}
+
+func (r *tsvReplayReader) Next() (ReplayRecord, error) {
+	for r.scanner.Scan() {
+		r.line++
+		line := r.scanner.Text()
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Split(line, "\t")
+		if len(fields) < 3 {
+			return ReplayRecord{}, fmt.Errorf("line %d: want at least 3 tab separated fields, got %d", r.line, len(fields))
+		}
+		ts, err := time.Parse(time.RFC3339Nano, fields[0])
+		if err != nil {
+			return ReplayRecord{}, fmt.Errorf("line %d: bad timestamp: %v", r.line, err)
+		}
+		rec := ReplayRecord{Time: ts, Tenant: fields[1], SQL: unescapeTSV(fields[2])}
+		if len(fields) > 3 && fields[3] != "" {
+			if rec.Args, err = decodeReplayArgs(fields[3]); err != nil {
+				return ReplayRecord{}, fmt.Errorf("line %d: bad args: %v", r.line, err)
+			}
+		}
+		return rec, nil
+	}
+	if err := r.scanner.Err(); err != nil {
+		return ReplayRecord{}, err
+	}
+	return ReplayRecord{}, io.EOF
+}
+
+// generalLogLine matches "<time> <thread id> <command>\t<argument>" lines of the MySQL general log.
+var generalLogLine = regexp.MustCompile(`^(\d{4}-\d\d-\d\dT\S+)\s+(\d+)\s+([A-Za-z ]+?)\t(.*)$`)
+
+// generalLogConnect extracts the database from a "user@host on db using ..." Connect argument.
+var generalLogConnect = regexp.MustCompile(`\son\s+(\S+)`)
+
+// generalLogUse matches a "USE db" statement.
+var generalLogUse = regexp.MustCompile("(?i)^\\s*use\\s+`?([^`;\\s]+)`?")
+
+// generalLogReplayReader reads a MySQL general query log.
+// Statements of threads whose current database is unknown are skipped.
+type generalLogReplayReader struct {
+	scanner  *bufio.Scanner
+	threadDB map[string]string
+	pending  []string // the next entry line, already read while looking for continuation lines
+}
+
+// nextEntry returns the next log entry with multi-line statements joined back together.
+func (r *generalLogReplayReader) nextEntry() ([]string, bool) {
+	var m []string
+	if r.pending != nil {
+		m, r.pending = r.pending, nil
+	} else {
+		for m == nil && r.scanner.Scan() {
+			m = generalLogLine.FindStringSubmatch(r.scanner.Text())
+		}
+		if m == nil {
+			return nil, false
+		}
+	}
+	for r.scanner.Scan() {
+		line := r.scanner.Text()
+		if next := generalLogLine.FindStringSubmatch(line); next != nil {
+			r.pending = next
+			break
+		}
+		m[4] += "\n" + line
+	}
+	return m, true
+}
+
+func (r *generalLogReplayReader) Next() (ReplayRecord, error) {
+	for {
+		m, ok := r.nextEntry()
+		if !ok {
+			if err := r.scanner.Err(); err != nil {
+				return ReplayRecord{}, err
+			}
+			return ReplayRecord{}, io.EOF
+		}
+		threadID, command, arg := m[2], strings.TrimSpace(m[3]), m[4]
+		switch command {
+		case "Connect":
+			if db := generalLogConnect.FindStringSubmatch(arg); db != nil {
+				r.threadDB[threadID] = db[1]
+			}
+		case "Init DB":
+			r.threadDB[threadID] = strings.TrimSpace(arg)
+		case "Quit":
+			delete(r.threadDB, threadID)
+		case "Query", "Execute":
+			if use := generalLogUse.FindStringSubmatch(arg); use != nil {
+				r.threadDB[threadID] = use[1]
+				continue
+			}
+			db, ok := r.threadDB[threadID]
+			if !ok {
+				continue
+			}
+			ts, err := time.Parse(time.RFC3339Nano, m[1])
+			if err != nil {
+				return ReplayRecord{}, fmt.Errorf("bad general log timestamp %q: %v", m[1], err)
+			}
+			return ReplayRecord{Time: ts, Tenant: db, SQL: arg}, nil
+		}
+	}
+}
+
+// decodeReplayArgs decodes a JSON array of statement arguments, keeping integers as int64.
+func decodeReplayArgs(s string) ([]interface{}, error) {
+	dec := json.NewDecoder(strings.NewReader(s))
+	dec.UseNumber()
+	var raw []interface{}
+	if err := dec.Decode(&raw); err != nil {
+		return nil, err
+	}
+	for i, v := range raw {
+		if n, ok := v.(json.Number); ok {
+			if iv, err := n.Int64(); err == nil {
+				raw[i] = iv
+			} else if fv, err := n.Float64(); err == nil {
+				raw[i] = fv
+			}
+		}
+	}
+	return raw, nil
+}
+
+var tsvEscaper = strings.NewReplacer(`\`, `\\`, "\t", `\t`, "\n", `\n`, "\r", `\r`)
+
+// escapeTSV escapes a value so it can be stored in a single TSV field.
+func escapeTSV(s string) string {
+	return tsvEscaper.Replace(s)
+}
+
+// unescapeTSV reverses escapeTSV.
+func unescapeTSV(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i == len(s)-1 {
+			b.WriteByte(s[i])
+			continue
+		}
+		i++
+		switch s[i] {
+		case 't':
+			b.WriteByte('\t')
+		case 'n':
+			b.WriteByte('\n')
+		case 'r':
+			b.WriteByte('\r')
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+	return b.String()
+}
+
+// replayTenant holds the connection pools and the work queue of one replayed tenant.
+type replayTenant struct {
+	pool  *tenantPool
+	queue chan ReplayRecord
+}
+
+// runReplay replays the statements of a replay file against the tenant databases.
+// Records are dispatched at their original relative time divided by speed (speed <= 0 means as fast as possible),
+// and each tenant executes them on threadsPerDB long connections to the DSN resolved for it.
+func runReplay(path, format string, speed float64, dsns *dsnResolver, threadsPerDB int, exitTime time.Time) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	reader, err := newReplayReader(f, format)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithDeadline(context.Background(), exitTime)
+	defer cancel()
+
+	var (
+		wg       sync.WaitGroup
+		tenants  = make(map[string]*replayTenant)
+		executed int64
+		failed   int64
+		maxLag   time.Duration
+	)
+	defer func() {
+		for _, t := range tenants {
+			close(t.queue)
+		}
+		wg.Wait()
+		for _, t := range tenants {
+			t.pool.Close()
+		}
+		log.Printf("[INFO] replay finished: %d statement(s) executed, %d failed, %d tenant(s), max dispatch lag %v",
+			atomic.LoadInt64(&executed), atomic.LoadInt64(&failed), len(tenants), maxLag)
+	}()
+
+	var firstTime, replayStart time.Time
+	for ctx.Err() == nil {
+		rec, err := reader.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		// Pace the dispatch according to the original timeline.
+		if firstTime.IsZero() {
+			firstTime, replayStart = rec.Time, time.Now()
+		}
+		if speed > 0 {
+			due := replayStart.Add(time.Duration(float64(rec.Time.Sub(firstTime)) / speed))
+			if wait := time.Until(due); wait > 0 {
+				if !sleepUntilExit(ctx, wait, exitTime) {
+					return nil
+				}
+			} else if -wait > maxLag {
+				maxLag = -wait
+			}
+		}
+
+		tenant, ok := tenants[rec.Tenant]
+		if !ok {
+			pool, err := openTenantPool(dsns, rec.Tenant, nil)
+			if err != nil {
+				return fmt.Errorf("open DB %s: %v", rec.Tenant, err)
+			}
+			tenant = &replayTenant{pool: pool, queue: make(chan ReplayRecord, threadsPerDB*16)}
+			tenants[rec.Tenant] = tenant
+			log.Printf("[INFO] replay: starting %d connection(s) for DB %s", threadsPerDB, rec.Tenant)
+			for i := 0; i < threadsPerDB; i++ {
+				wg.Add(1)
+				go func(dbName string, t *replayTenant) {
+					defer wg.Done()
+					runReplayWorker(ctx, t, dbName, &executed, &failed)
+				}(rec.Tenant, tenant)
+			}
+		}
+
+		select {
+		case tenant.queue <- rec:
+		case <-ctx.Done():
+		}
+	}
+	return nil
+}
+
+// runReplayWorker executes queued records of one tenant on dedicated connections.
+// When the tenant's reads are split to their own DSN, read statements run on a connection to it
+// and everything else on a connection to the primary DSN.
+func runReplayWorker(ctx context.Context, t *replayTenant, dbName string, executed, failed *int64) {
+	read, write := &lazyConn{src: t.pool.reads()}, &lazyConn{src: t.pool.writes()}
+	defer read.Close()
+	defer write.Close()
+
+	for rec := range t.queue {
+		if ctx.Err() != nil {
+			// Keep draining so the dispatcher never blocks on this tenant.
+			continue
+		}
+		target := write
+		if t.pool.split() && isReadStatement(rec.SQL) {
+			target = read
+		}
+		conn, err := target.Get(ctx, dbName)
+		if err != nil {
+			continue
+		}
+
+		err = execAndDrain(ctx, conn, rec.SQL, rec.Args...)
+		atomic.AddInt64(executed, 1)
+		if err != nil {
+			atomic.AddInt64(failed, 1)
+			workerLog.Printf(logClass("replay query failed", err), "[ERROR] replay DB=%s query failed: %v", dbName, err)
+			// Replayed statements may fail on their own, so only reconnect when the connection is gone.
+			if conn.PingContext(ctx) != nil {
+				target.Close()
+			}
+		}
+	}
+}
+
+// lazyConn is a dedicated connection taken from src on first use and again after Close.
+type lazyConn struct {
+	src  connSource
+	conn workerConn
+}
+
+// Get returns the connection, establishing it (with retries) if needed.
+func (c *lazyConn) Get(ctx context.Context, dbName string) (workerConn, error) {
+	if c.conn == nil {
+		conn, err := retryMakeActiveConn(c.src, dbName, ctx)
+		if err != nil {
+			return nil, err
+		}
+		c.conn = conn
+	}
+	return c.conn, nil
+}
+
+// Close releases the connection; the next Get establishes a new one.
+func (c *lazyConn) Close() {
+	if c.conn != nil {
+		c.conn.Close()
+		c.conn = nil
+	}
+}
+
+// readStatementPrefix matches statements a read/write splitting proxy would send to a replica.
+var readStatementPrefix = regexp.MustCompile(`(?is)^\s*(?:/\*.*?\*/\s*)*(select|show|describe|desc|explain)\b`)
+
+// lockingRead matches reads that take locks and therefore have to run on the primary.
+var lockingRead = regexp.MustCompile(`(?i)\bfor\s+update\b|\block\s+in\s+share\s+mode\b|\bfor\s+share\b`)
+
+// isReadStatement reports whether a statement can be served by a read replica.
+func isReadStatement(query string) bool {
+	return readStatementPrefix.MatchString(query) && !lockingRead.MatchString(query)
+}
+
+// execAndDrain runs any statement on conn and reads (and discards) all returned rows.
+func execAndDrain(ctx context.Context, conn queryExecutor, query string, args ...interface{}) error {
+	rows, err := conn.QueryContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+	}
+	return rows.Err()
+}
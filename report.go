@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"log"
+	"sort"
+	"time"
+)
+
+// runIntervalReports logs the throughput and latency of every query type over each interval until ctx is done.
+func runIntervalReports(ctx context.Context, stats *statsCollector, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	previous := make(map[string]*queryTypeStats)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		snapshot := stats.Snapshot()
+		current := snapshot.Total().Types
+		all := newQueryTypeStats()
+		for _, name := range sortedTypeNames(current) {
+			q := current[name]
+			prev, ok := previous[name]
+			if !ok {
+				prev = newQueryTypeStats()
+			}
+			d := q.Since(prev)
+			all.merge(d)
+			logIntervalLine(snapshot.ElapsedSeconds, name, d, interval)
+		}
+		logIntervalLine(snapshot.ElapsedSeconds, "all", all, interval)
+		previous = current
+	}
+}
+
+func logIntervalLine(elapsed float64, name string, q *queryTypeStats, interval time.Duration) {
+	log.Printf("[INFO] [%4.0fs] type=%s qps=%.1f errors=%d p50=%v p95=%v p99=%v",
+		elapsed, name, float64(q.Queries)/interval.Seconds(), q.Errors,
+		q.Latency.Quantile(0.50), q.Latency.Quantile(0.95), q.Latency.Quantile(0.99))
+}
+
+// Since returns the queries recorded after prev, an earlier copy of the same statistics.
+func (q *queryTypeStats) Since(prev *queryTypeStats) *queryTypeStats {
+	return &queryTypeStats{
+		Queries: q.Queries - prev.Queries,
+		Errors:  q.Errors - prev.Errors,
+		Latency: q.Latency.Since(prev.Latency),
+	}
+}
+
+// sortedTypeNames returns the query types of a breakdown in sorted order.
+func sortedTypeNames(types map[string]*queryTypeStats) []string {
+	names := make([]string, 0, len(types))
+	for name := range types {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// retryableErrors are the MySQL and TiDB errors after which the statement or transaction can simply run again:
+// deadlocks and lock wait timeouts, TiDB write conflicts, and transient errors of the TiKV regions and PD.
+var retryableErrors = map[uint16]bool{
+	1205: true, // lock wait timeout exceeded
+	1213: true, // deadlock found when trying to get lock
+	8002: true, // SELECT FOR UPDATE write conflict
+	8022: true, // transaction commit failed, safe to retry
+	8028: true, // information schema changed during the transaction
+	9001: true, // PD server timeout
+	9002: true, // TiKV server timeout
+	9003: true, // TiKV server is busy
+	9004: true, // resolve lock timeout
+	9005: true, // region is unavailable
+	9007: true, // write conflict
+}
+
+// retryableError reports whether err is one of the retryable errors. The connection is still good after them.
+func retryableError(err error) bool {
+	var mysqlErr *mysql.MySQLError
+	return errors.As(err, &mysqlErr) && retryableErrors[mysqlErr.Number]
+}
+
+// txnRetry runs a query again after a retryable error, up to a number of retries, like applications retrying
+// deadlocked or conflicting transactions. Transaction scripts roll back on errors, so the whole transaction runs
+// again. Retries are counted apart from the errors, and a query failing with a retryable error after all of them keeps
+// its connection. A nil *txnRetry never retries.
+type txnRetry struct {
+	retries int
+	backoff time.Duration
+}
+
+// newTxnRetry returns nil when retries is 0.
+func newTxnRetry(retries int, backoff time.Duration) (*txnRetry, error) {
+	if retries <= 0 {
+		return nil, nil
+	}
+	if backoff < 0 {
+		return nil, fmt.Errorf("the backoff must not be negative")
+	}
+	return &txnRetry{retries: retries, backoff: backoff}, nil
+}
+
+// Run runs the query, and again after every retryable error, waiting the backoff times the retry number in between.
+// It returns the error of the last run.
+func (r *txnRetry) Run(ctx context.Context, stats *tenantStats, query func() error) error {
+	err := query()
+	if r == nil {
+		return err
+	}
+	for retry := 1; retry <= r.retries && retryableError(err); retry++ {
+		stats.RecordRetry()
+		if !sleepCtx(ctx, r.backoff*time.Duration(retry)) {
+			return err
+		}
+		err = query()
+	}
+	return err
+}
+
+// KeepsConn reports whether the connection of a query that failed with err stays in use instead of being replaced.
+func (r *txnRetry) KeepsConn(err error) bool {
+	return r != nil && retryableError(err)
+}
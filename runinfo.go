@@ -0,0 +1,155 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"runtime/debug"
+	"sort"
+	"strings"
+	"time"
+)
+
+// runMetadata identifies a run in its outputs, so results collected from many runs stay attributable to the run,
+// the build and the configuration that produced them.
+type runMetadata struct {
+	RunID     string    `json:"run_id"`
+	Version   string    `json:"version"`
+	StartTime time.Time `json:"start_time"`
+	// Config is the value of every flag after the environment was applied, defaults included, credentials redacted.
+	Config map[string]string `json:"config"`
+	// Changed are the names of the flags not at their default, sorted.
+	Changed []string `json:"changed"`
+}
+
+// newRunMetadata records the run ID, the build and the resolved flags of fs, starting now.
+func newRunMetadata(runID string, fs *flag.FlagSet) *runMetadata {
+	m := &runMetadata{RunID: runID, Version: buildVersion(), StartTime: time.Now(), Config: make(map[string]string)}
+	fs.VisitAll(func(f *flag.Flag) {
+		value := redactFlagValue(f.Name, f.Value.String())
+		m.Config[f.Name] = value
+		if value != redactFlagValue(f.Name, f.DefValue) {
+			m.Changed = append(m.Changed, f.Name)
+		}
+	})
+	sort.Strings(m.Changed)
+	return m
+}
+
+// ChangedFlags returns the flags not at their default as "-name=value", for the log header.
+func (m *runMetadata) ChangedFlags() string {
+	flags := make([]string, 0, len(m.Changed))
+	for _, name := range m.Changed {
+		flags = append(flags, "-"+name+"="+m.Config[name])
+	}
+	return strings.Join(flags, " ")
+}
+
+// redactFlagValue hides the password of a DSN and the value of a password flag.
+func redactFlagValue(name, value string) string {
+	if strings.Contains(name, "password") && !strings.HasSuffix(name, "-file") && value != "" {
+		return "***"
+	}
+	return dsnCredentials.ReplaceAllString(value, "$1:***@")
+}
+
+// buildVersion returns the VCS revision the binary was built from, with "-dirty" for uncommitted changes, or the
+// module version when the build has no VCS information.
+func buildVersion() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "unknown"
+	}
+	var revision, modified string
+	for _, s := range info.Settings {
+		switch s.Key {
+		case "vcs.revision":
+			revision = s.Value
+		case "vcs.modified":
+			modified = s.Value
+		}
+	}
+	if revision == "" {
+		return info.Main.Version
+	}
+	if len(revision) > 12 {
+		revision = revision[:12]
+	}
+	if modified == "true" {
+		revision += "-dirty"
+	}
+	return revision
+}
+
+// configInputFiles are the flags naming input files whose content is part of the configuration: the tenant mix,
+// rates, schedules and routing of a run live in them rather than in the flags.
+var configInputFiles = []string{
+	"annotation-file", "cache-file", "collation-file", "dsn-map-file", "hint-file", "lifetime-file", "region-file",
+	"scenario-file", "schema-profile-file", "session-init-file", "tier-file",
+}
+
+// configDigestFiles are input files recorded by their digest only: too large to copy, or holding passwords.
+var configDigestFiles = []string{"replay-file", "tenant-user-file"}
+
+// configFile is an input file of the configuration snapshot.
+type configFile struct {
+	Path    string `json:"path"`
+	SHA256  string `json:"sha256"`
+	Content string `json:"content,omitempty"` // DSN passwords redacted
+}
+
+// configSnapshot is the fully resolved configuration of a run: the run metadata with every flag, defaults included,
+// and the input files the flags name.
+type configSnapshot struct {
+	*runMetadata
+	Files map[string]configFile `json:"files,omitempty"`
+}
+
+// configSnapshotPath returns the file the configuration snapshot is written to: path, or else next to the summary
+// file (summary.json gets summary.config.json), "" for none.
+func configSnapshotPath(path, summaryPath string) string {
+	if path != "" || summaryPath == "" {
+		return path
+	}
+	return strings.TrimSuffix(summaryPath, ".json") + ".config.json"
+}
+
+// writeConfigSnapshot writes the run metadata and the input files named by the flags of fs to path as indented JSON.
+func writeConfigSnapshot(path string, run *runMetadata, fs *flag.FlagSet) error {
+	snapshot := configSnapshot{runMetadata: run, Files: make(map[string]configFile)}
+	add := func(name string, content bool) error {
+		f := fs.Lookup(name)
+		if f == nil || f.Value.String() == "" {
+			return nil
+		}
+		data, err := os.ReadFile(f.Value.String())
+		if err != nil {
+			return fmt.Errorf("-%s: %v", name, err)
+		}
+		sum := sha256.Sum256(data)
+		file := configFile{Path: f.Value.String(), SHA256: hex.EncodeToString(sum[:])}
+		if content {
+			file.Content = dsnCredentials.ReplaceAllString(string(data), "$1:***@")
+		}
+		snapshot.Files[name] = file
+		return nil
+	}
+	for _, name := range configInputFiles {
+		if err := add(name, true); err != nil {
+			return err
+		}
+	}
+	for _, name := range configDigestFiles {
+		if err := add(name, false); err != nil {
+			return err
+		}
+	}
+	data, err := json.MarshalIndent(snapshot, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0644)
+}
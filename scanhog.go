@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// scanHog makes selected tenants periodically run unindexed full scans of their big tables next to their normal
+// workload: the classic noisy neighbor, whose impact on the other tenants has to be bounded. The scans are recorded
+// as full_scan queries of the hog tenant. A nil *scanHog is disabled.
+type scanHog struct {
+	tenants     tenantSet
+	interval    time.Duration // between the scans of one scanner
+	concurrency int           // scanners per tenant, each on its own connection
+	tables      int           // the first tables of the tenant are scanned, 0 = all
+}
+
+// newScanHog returns nil when interval is 0.
+func newScanHog(tenants tenantSet, interval time.Duration, concurrency, tables int) (*scanHog, error) {
+	if interval <= 0 {
+		return nil, nil
+	}
+	if concurrency < 1 {
+		return nil, fmt.Errorf("the scan concurrency must be at least 1")
+	}
+	return &scanHog{tenants: tenants, interval: interval, concurrency: concurrency, tables: tables}, nil
+}
+
+// Applies reports whether the tenant is a scan hog.
+func (h *scanHog) Applies(tenant string) bool {
+	return h != nil && h.tenants.Contains(tenant)
+}
+
+// Run starts the scanners of the tenant and waits for them to stop at exitTime or when ctx is done.
+func (h *scanHog) Run(ctx context.Context, pool *tenantPool, dbName string, opts *workloadOptions) {
+	tables := opts.tenantTables(dbName)
+	if h.tables > 0 && len(tables) > h.tables {
+		tables = tables[:h.tables]
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < h.concurrency; i++ {
+		wg.Add(1)
+		go func(scanner int) {
+			defer wg.Done()
+			h.scan(ctx, pool, dbName, scanner, tables, opts)
+		}(i)
+	}
+	wg.Wait()
+}
+
+// scan runs one full scan every interval on a random one of the tables.
+func (h *scanHog) scan(ctx context.Context, pool *tenantPool, dbName string, scanner int, tables []TableInfo, opts *workloadOptions) {
+	conn, err := retryMakeActiveConn(pool.reads(), dbName, ctx)
+	if err != nil {
+		return
+	}
+	defer func() { conn.Close() }()
+
+	stats := opts.stats.Tenant(dbName)
+	qt := queryTypes["full_scan"]
+	rng := newWorkerRand(dbName+"/scan-hog", scanner)
+	for sleepUntilExit(ctx, h.interval, opts.exitTime) {
+		t := tables[rng.IntN(len(tables))]
+		query := opts.tags.Tag(qt.sql(t), dbName, -1, qt.name)
+		args := qt.args(t, opts, rng, nil)
+		volume := resultVolume{BytesOut: statementBytes(query, args)}
+		start := time.Now()
+		err := runQuery(ctx, conn, qt, t, query, args, &volume)
+		opts.observeQuery(stats, dbName, qt.name, start, query, args, time.Since(start), err)
+		stats.RecordVolume(qt.name, volume)
+		if err != nil && ctx.Err() == nil {
+			workerLog.Printf(logClass("query failed", err), "[ERROR] DB=%s table=%s type=%s query failed: %v", dbName, t.Name, qt.name, err)
+			if conn.PingContext(ctx) != nil {
+				conn.Close()
+				newConn, err := retryMakeActiveConn(pool.reads(), dbName, ctx)
+				if err != nil {
+					return
+				}
+				conn = newConn
+			}
+		}
+	}
+}
@@ -0,0 +1,330 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"text/tabwriter"
+	"time"
+)
+
+// scenarioOverride sets the load of some tenants during a phase.
+type scenarioOverride struct {
+	tenants tenantSet // nil = every tenant
+	workers float64   // active workers per tenant, a fraction of the maximum if percent, -1 = unchanged
+	percent bool
+	qps     float64 // QPS per tenant, 0 = unlimited, -1 = unchanged
+}
+
+// scenarioPhase is one named phase of a scenario.
+type scenarioPhase struct {
+	name      string
+	duration  time.Duration
+	overrides []scenarioOverride
+}
+
+// scenarioRunner runs the workload through named phases, e.g. warmup, steady, burst and recovery, each for its duration
+// and with its own load per tenant, and reports every phase on its own. Complex isolation experiments (one tenant
+// bursting while the others keep their pace, a tenant paused and coming back) are declared in one file instead of
+// being scripted around several runs. A nil *scenarioRunner is disabled.
+type scenarioRunner struct {
+	phases     []scenarioPhase
+	maxWorkers int // workers per tenant, -threads-pre-db
+
+	phase int32 // index of the current phase, read by the workers without locking
+
+	mu       sync.Mutex
+	tenants  map[string]*scenarioTenant
+	previous map[string]*tenantStats // statistics at the start of the current phase
+	elapsed  float64                 // elapsed seconds of the run at the start of the current phase
+	results  []scenarioResult
+}
+
+// scenarioTenant is the scenario state of one tenant.
+type scenarioTenant struct {
+	name    string
+	workers int32 // active workers in the current phase
+	limited int32 // 1 when the bucket limits the tenant's QPS in the current phase
+	bucket  *tokenBucket
+}
+
+// scenarioResult is the outcome of one phase.
+type scenarioResult struct {
+	name    string
+	seconds float64
+	tenants map[string]*queryTypeStats
+}
+
+// loadScenarioFile reads a scenario file: a "phase name seconds" line starts a phase, and the
+// "tenants setting..." lines after it override the load of some tenants during the phase, e.g.
+//
+//	phase warmup    60
+//	1-10            workers=25%
+//	phase steady    300
+//	phase burst     60
+//	3               workers=100% qps=0
+//	1-2,4-10        qps=50
+//	phase recovery  120
+//	3               pause
+//
+// tenants are names or 1-based ranges, "*" for every tenant; a tenant gets the settings of the first matching line of
+// the phase. workers=N or N% sets the active workers per tenant (of maxWorkers), qps=N caps the tenant's QPS (0 for no
+// cap) and pause stands for workers=0. Tenants without a matching line run with all workers and no cap.
+// Empty lines and lines starting with "#" are ignored.
+func loadScenarioFile(path string, maxWorkers int) (*scenarioRunner, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	s := &scenarioRunner{maxWorkers: maxWorkers, tenants: make(map[string]*scenarioTenant), previous: make(map[string]*tenantStats)}
+	names := make(map[string]bool)
+	scanner := bufio.NewScanner(f)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if fields[0] == "phase" {
+			if len(fields) != 3 {
+				return nil, fmt.Errorf("%s:%d: want \"phase name seconds\", got %q", path, lineNo, line)
+			}
+			seconds, err := strconv.Atoi(fields[2])
+			if err != nil || seconds <= 0 {
+				return nil, fmt.Errorf("%s:%d: invalid phase length %q (want seconds > 0)", path, lineNo, fields[2])
+			}
+			if names[fields[1]] {
+				return nil, fmt.Errorf("%s:%d: duplicate phase %q", path, lineNo, fields[1])
+			}
+			names[fields[1]] = true
+			s.phases = append(s.phases, scenarioPhase{name: fields[1], duration: time.Duration(seconds) * time.Second})
+			continue
+		}
+		if len(s.phases) == 0 {
+			return nil, fmt.Errorf("%s:%d: tenant settings before the first phase", path, lineNo)
+		}
+		if len(fields) < 2 {
+			return nil, fmt.Errorf("%s:%d: want \"tenants setting...\", got %q", path, lineNo, line)
+		}
+		o := scenarioOverride{workers: -1, qps: -1}
+		if fields[0] != "*" {
+			if o.tenants, err = parseTenantSet(fields[0]); err != nil {
+				return nil, fmt.Errorf("%s:%d: %v", path, lineNo, err)
+			}
+		}
+		for _, setting := range fields[1:] {
+			if err := o.set(setting); err != nil {
+				return nil, fmt.Errorf("%s:%d: %v", path, lineNo, err)
+			}
+		}
+		phase := &s.phases[len(s.phases)-1]
+		phase.overrides = append(phase.overrides, o)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(s.phases) == 0 {
+		return nil, fmt.Errorf("%s: no phases", path)
+	}
+	return s, nil
+}
+
+// set applies one "key=value" setting (or pause) to the override.
+func (o *scenarioOverride) set(setting string) error {
+	if setting == "pause" {
+		o.workers, o.percent = 0, false
+		return nil
+	}
+	key, value, _ := strings.Cut(setting, "=")
+	switch key {
+	case "workers":
+		number, percent := strings.CutSuffix(value, "%")
+		workers, err := strconv.ParseFloat(number, 64)
+		if err != nil || workers < 0 || (percent && workers > 100) {
+			return fmt.Errorf("invalid workers %q (want a count or a percentage)", value)
+		}
+		o.workers, o.percent = workers, percent
+		if percent {
+			o.workers /= 100
+		}
+	case "qps":
+		qps, err := strconv.ParseFloat(value, 64)
+		if err != nil || qps < 0 {
+			return fmt.Errorf("invalid qps %q", value)
+		}
+		o.qps = qps
+	default:
+		return fmt.Errorf("unknown setting %q (want workers=N, workers=N%%, qps=N or pause)", setting)
+	}
+	return nil
+}
+
+// Duration returns the length of all phases together, which replaces the testing time.
+func (s *scenarioRunner) Duration() time.Duration {
+	var d time.Duration
+	for _, p := range s.phases {
+		d += p.duration
+	}
+	return d
+}
+
+// settings returns the active workers and the QPS cap (0 = none) of a tenant in a phase.
+func (s *scenarioRunner) settings(phase int, tenant string) (workers int, qps float64) {
+	workers = s.maxWorkers
+	for _, o := range s.phases[phase].overrides {
+		if !o.tenants.Contains(tenant) {
+			continue
+		}
+		if o.workers >= 0 {
+			workers = int(o.workers)
+			if o.percent {
+				workers = int(math.Ceil(float64(s.maxWorkers) * o.workers))
+			}
+		}
+		if o.qps >= 0 {
+			qps = o.qps
+		}
+		break
+	}
+	return workers, qps
+}
+
+// apply sets the load of a tenant for a phase. The caller must hold s.mu.
+func (s *scenarioRunner) apply(phase int, t *scenarioTenant) {
+	workers, qps := s.settings(phase, t.name)
+	atomic.StoreInt32(&t.workers, int32(workers))
+	if qps > 0 {
+		t.bucket.SetRate(qps, sweepBurst(qps))
+		atomic.StoreInt32(&t.limited, 1)
+	} else {
+		atomic.StoreInt32(&t.limited, 0)
+	}
+}
+
+// Tenant returns the scenario state of a tenant, nil if the scenario is disabled.
+// Workers look their tenant up once and call Admit and Wait on it.
+func (s *scenarioRunner) Tenant(name string) *scenarioTenant {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t, ok := s.tenants[name]
+	if !ok {
+		t = &scenarioTenant{name: name, bucket: newTokenBucket(1, 1)}
+		s.apply(int(atomic.LoadInt32(&s.phase)), t)
+		s.tenants[name] = t
+	}
+	return t
+}
+
+// Admit reports whether the worker-th worker (0-based) of the tenant takes part in the current phase.
+// A nil *scenarioTenant admits every worker.
+func (t *scenarioTenant) Admit(worker int) bool {
+	return t == nil || int32(worker) < atomic.LoadInt32(&t.workers)
+}
+
+// Wait blocks until the tenant's QPS cap of the current phase allows the next query and reports whether ctx is still active.
+func (t *scenarioTenant) Wait(ctx context.Context) bool {
+	if t == nil || atomic.LoadInt32(&t.limited) == 0 {
+		return true
+	}
+	return t.bucket.Wait(ctx)
+}
+
+// Run moves to the next phase at the end of every phase from start until the last phase is reached or ctx is done,
+// recording the statistics of every finished phase. The last phase is recorded by Finish.
+func (s *scenarioRunner) Run(ctx context.Context, stats *statsCollector, start time.Time) {
+	s.logPhase(0)
+	end := start
+	for phase := 1; phase < len(s.phases); phase++ {
+		end = end.Add(s.phases[phase-1].duration)
+		if !sleepCtx(ctx, time.Until(end)) {
+			return
+		}
+		s.record(stats.Snapshot())
+		s.setPhase(phase)
+		s.logPhase(phase)
+	}
+}
+
+// setPhase applies the load of a phase to every tenant.
+func (s *scenarioRunner) setPhase(phase int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	atomic.StoreInt32(&s.phase, int32(phase))
+	for _, t := range s.tenants {
+		s.apply(phase, t)
+	}
+}
+
+func (s *scenarioRunner) logPhase(phase int) {
+	p := s.phases[phase]
+	log.Printf("[INFO] scenario: phase %d/%d %s for %v (%d override(s))", phase+1, len(s.phases), p.name, p.duration, len(p.overrides))
+}
+
+// Finish records the last phase from the final statistics of the run.
+func (s *scenarioRunner) Finish(snapshot *statsSnapshot) {
+	s.record(snapshot)
+}
+
+// record adds the queries of every tenant since the start of the current phase to the results of the phase.
+func (s *scenarioRunner) record(snapshot *statsSnapshot) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	phase := int(atomic.LoadInt32(&s.phase))
+	if len(s.results) > phase {
+		return
+	}
+	r := scenarioResult{name: s.phases[phase].name, seconds: snapshot.ElapsedSeconds - s.elapsed, tenants: make(map[string]*queryTypeStats)}
+	for _, name := range snapshot.TenantNames() {
+		current := snapshot.Tenants[name]
+		prev, ok := s.previous[name]
+		if !ok {
+			prev = newTenantStats()
+		}
+		r.tenants[name] = &queryTypeStats{
+			Queries: current.Queries - prev.Queries,
+			Errors:  current.Errors - prev.Errors,
+			Latency: current.Latency.Since(prev.Latency),
+		}
+		s.previous[name] = current
+	}
+	s.results = append(s.results, r)
+	s.elapsed = snapshot.ElapsedSeconds
+}
+
+// printScenarioReport writes the throughput and latency of every tenant, and of all tenants, in every phase.
+func (s *scenarioRunner) printScenarioReport(w io.Writer) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "PHASE\tTENANT\tSECONDS\tQUERIES\tQPS\tP50(ms)\tP95(ms)\tP99(ms)\tERRORS\t")
+	for _, r := range s.results {
+		row := func(tenant string, q *queryTypeStats) {
+			qps := 0.0
+			if r.seconds > 0 {
+				qps = float64(q.Queries) / r.seconds
+			}
+			l := summarizeLatency(q.Latency)
+			fmt.Fprintf(tw, "%s\t%s\t%.0f\t%d\t%.1f\t%.2f\t%.2f\t%.2f\t%d\t\n", r.name, tenant, r.seconds, q.Queries, qps, l.P50Ms, l.P95Ms, l.P99Ms, q.Errors)
+		}
+		all := newQueryTypeStats()
+		for _, name := range sortedTypeNames(r.tenants) {
+			row(name, r.tenants[name])
+			all.merge(r.tenants[name])
+		}
+		row("all", all)
+	}
+	tw.Flush()
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// How thoroughly the tenant tables are verified before the workers start.
+const (
+	verifyOff    = "off"
+	verifyTables = "tables" // every table exists
+	verifyRows   = "rows"   // every table exists and holds the expected row count, within a factor of verifyRowFactor
+)
+
+// verifyRowFactor is how far the estimated row count of a table may be off before the table counts as not prepared.
+const verifyRowFactor = 10
+
+// mysqlErrBadDB is the error of connecting to a database that doesn't exist (ER_BAD_DB_ERROR).
+const mysqlErrBadDB = 1049
+
+// verifyConcurrency is the number of tenants verified at once.
+const verifyConcurrency = 16
+
+// schemaProblems are the problems found in the tables of one tenant.
+type schemaProblems struct {
+	noDatabase bool
+	missing    []string
+	wrongRow   []string // "table (rows, want ~expected)"
+}
+
+func (p *schemaProblems) empty() bool {
+	return !p.noDatabase && len(p.missing) == 0 && len(p.wrongRow) == 0
+}
+
+// verifySchema checks in information_schema that every table of every tenant exists and, with verifyRows,
+// holds about the number of rows prepare mode loads, and returns an error listing the problems of every tenant.
+// The row counts are the server's estimates (TABLE_ROWS), hence the generous factor. In the row tenancy model
+// the tables are shared, so only their existence is checked. Unreachable tenants are left to
+// -on-tenant-connect-failure, and ClickHouse tenants are not verified.
+func verifySchema(ctx context.Context, dsns *dsnResolver, opts *workloadOptions, tenantNames []string, level string) error {
+	var (
+		mu       sync.Mutex
+		verified int
+		problems = make(map[string]*schemaProblems)
+		wg       sync.WaitGroup
+		sem      = make(chan struct{}, verifyConcurrency)
+	)
+	for _, tenant := range tenantNames {
+		if opts.clickhouse.Applies(tenant) {
+			continue
+		}
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			p, err := verifyTenantSchema(ctx, dsns, opts, tenant, level)
+			var mysqlErr *mysql.MySQLError
+			if errors.As(err, &mysqlErr) && mysqlErr.Number == mysqlErrBadDB {
+				p, err = &schemaProblems{noDatabase: true}, nil
+			}
+			if err != nil {
+				log.Printf("[WARNING] schema check: DB %s not verified: %v", tenant, err)
+				return
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			verified++
+			if !p.empty() {
+				problems[tenant] = p
+			}
+		}()
+	}
+	wg.Wait()
+	if len(problems) == 0 {
+		log.Printf("[INFO] schema check: the tables of %d tenant(s) are in place", verified)
+		return nil
+	}
+	failed := make([]string, 0, len(problems))
+	for tenant := range problems {
+		failed = append(failed, tenant)
+	}
+	sort.Strings(failed)
+	for _, tenant := range failed {
+		p := problems[tenant]
+		if p.noDatabase {
+			log.Printf("[ERROR] schema check: database %s does not exist", opts.tenancy.Database(tenant))
+		}
+		if len(p.missing) > 0 {
+			log.Printf("[ERROR] schema check: DB %s is missing %d table(s): %s", tenant, len(p.missing), abbreviate(p.missing, 5))
+		}
+		if len(p.wrongRow) > 0 {
+			log.Printf("[ERROR] schema check: DB %s has %d table(s) with an unexpected row count: %s", tenant, len(p.wrongRow), abbreviate(p.wrongRow, 5))
+		}
+	}
+	return fmt.Errorf("the tables of %d tenant(s) are missing or not loaded, run -mode prepare with the same table flags (or -verify-schema %s to skip the check)",
+		len(failed), verifyOff)
+}
+
+// verifyTenantSchema compares the tables of one tenant with information_schema.
+func verifyTenantSchema(ctx context.Context, dsns *dsnResolver, opts *workloadOptions, tenant, level string) (*schemaProblems, error) {
+	db, err := sql.Open(dsns.Driver(tenant), dsns.DSN(tenant))
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	query := "SELECT TABLE_NAME, COALESCE(TABLE_ROWS, 0) FROM information_schema.TABLES WHERE TABLE_SCHEMA = ?"
+	args := []interface{}{opts.tenancy.Database(tenant)}
+	if opts.tenancy.kind == "schema" {
+		// The shared database holds the tables of all tenants; only read this tenant's.
+		query += " AND TABLE_NAME LIKE ?"
+		args = append(args, strings.NewReplacer(`\`, `\\`, `_`, `\_`, `%`, `\%`).Replace(tenant+"_")+"%")
+	}
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	found := make(map[string]int64)
+	for rows.Next() {
+		var name string
+		var n int64
+		if err := rows.Scan(&name, &n); err != nil {
+			return nil, err
+		}
+		found[strings.ToLower(name)] = n
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	p := &schemaProblems{}
+	for _, t := range opts.tenantTables(tenant) {
+		n, ok := found[strings.ToLower(t.Name)]
+		switch {
+		case !ok:
+			p.missing = append(p.missing, t.Name)
+		case level == verifyRows && t.TenantID == 0 && !rowCountPlausible(n, int64(t.MaxK)):
+			p.wrongRow = append(p.wrongRow, fmt.Sprintf("%s (%d rows, want ~%d)", t.Name, n, t.MaxK))
+		}
+	}
+	return p, nil
+}
+
+// rowCountPlausible reports whether an estimated row count is within verifyRowFactor of the expected one.
+func rowCountPlausible(rows, want int64) bool {
+	return rows*verifyRowFactor >= want && rows <= want*verifyRowFactor
+}
+
+// abbreviate joins the first n items, noting how many more there are.
+func abbreviate(items []string, n int) string {
+	if len(items) <= n {
+		return strings.Join(items, ", ")
+	}
+	return fmt.Sprintf("%s and %d more", strings.Join(items[:n], ", "), len(items)-n)
+}
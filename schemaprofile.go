@@ -0,0 +1,181 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// maxExtraIndexes is the number of secondary indexes a schema profile can add to the tables of a tenant.
+const maxExtraIndexes = 3
+
+// schemaProfile is the schema of the tenants of one line of a schema profile file. Settings left at -1 keep the
+// schema of the flags.
+type schemaProfile struct {
+	tenants         tenantSet // nil = every tenant
+	cSize, padSize  int
+	extraIndexes    int
+	bigTables       int
+	smallTables     int
+	partitionTables int
+}
+
+// schemaProfiles gives tenant classes their own schema: wider or narrower rows, extra secondary indexes and other
+// table counts, because real multi-tenant fleets never have identical schemas across tenants. Prepare mode creates
+// every tenant's tables by its profile, and the workers pick from and write to them accordingly.
+// A nil *schemaProfiles gives every tenant the schema of the flags.
+type schemaProfiles struct {
+	profiles []schemaProfile
+	// tables returns the tables of the given counts, named and sized like the tables of the flags.
+	tables func(big, small, partition int) []TableInfo
+	counts [3]int // big, small and partition tables of the flags
+}
+
+// loadSchemaProfileFile reads a schema profile file with one "tenants setting..." line per tenant class, e.g.
+//
+//	1-10     c_size=1024 pad_size=512
+//	11-20    extra_indexes=3
+//	21-30    big_tables=2 small_tables=20 partition_tables=0
+//
+// tenants are names or 1-based ranges, "*" for every tenant; a tenant gets the profile of the first matching line.
+// c_size and pad_size set the lengths of c and pad, extra_indexes adds up to 3 secondary indexes (on c, on k and c,
+// and on pad), and big_tables, small_tables and partition_tables set the table counts. Tenants without a matching
+// line get the schema of the flags. Empty lines and lines starting with "#" are ignored.
+func loadSchemaProfileFile(path string, counts [3]int, tables func(big, small, partition int) []TableInfo) (*schemaProfiles, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	p := &schemaProfiles{tables: tables, counts: counts}
+	scanner := bufio.NewScanner(f)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			return nil, fmt.Errorf("%s:%d: want \"tenants setting...\", got %q", path, lineNo, line)
+		}
+		profile := schemaProfile{cSize: -1, padSize: -1, extraIndexes: -1, bigTables: -1, smallTables: -1, partitionTables: -1}
+		if fields[0] != "*" {
+			if profile.tenants, err = parseTenantSet(fields[0]); err != nil {
+				return nil, fmt.Errorf("%s:%d: %v", path, lineNo, err)
+			}
+		}
+		for _, setting := range fields[1:] {
+			if err := profile.set(setting); err != nil {
+				return nil, fmt.Errorf("%s:%d: %v", path, lineNo, err)
+			}
+		}
+		if counts := p.countsOf(&profile); counts[0]+counts[1]+counts[2] == 0 {
+			return nil, fmt.Errorf("%s:%d: the profile leaves no tables", path, lineNo)
+		}
+		p.profiles = append(p.profiles, profile)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(p.profiles) == 0 {
+		return nil, fmt.Errorf("%s: no schema profiles", path)
+	}
+	return p, nil
+}
+
+// set applies one "key=value" setting to the profile.
+func (s *schemaProfile) set(setting string) error {
+	key, value, _ := strings.Cut(setting, "=")
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		return fmt.Errorf("invalid value of %q (want a count)", setting)
+	}
+	switch key {
+	case "c_size", "pad_size":
+		if n < 1 {
+			return fmt.Errorf("invalid %s %d (want at least 1)", key, n)
+		}
+		if key == "c_size" {
+			s.cSize = n
+		} else {
+			s.padSize = n
+		}
+	case "extra_indexes":
+		if n > maxExtraIndexes {
+			return fmt.Errorf("invalid extra_indexes %d (want at most %d)", n, maxExtraIndexes)
+		}
+		s.extraIndexes = n
+	case "big_tables":
+		s.bigTables = n
+	case "small_tables":
+		s.smallTables = n
+	case "partition_tables":
+		s.partitionTables = n
+	default:
+		return fmt.Errorf("unknown setting %q (want c_size, pad_size, extra_indexes, big_tables, small_tables or partition_tables)", key)
+	}
+	return nil
+}
+
+// profile returns the profile of the tenant, nil if it has none.
+func (p *schemaProfiles) profile(tenant string) *schemaProfile {
+	if p == nil {
+		return nil
+	}
+	for i := range p.profiles {
+		if p.profiles[i].tenants.Contains(tenant) {
+			return &p.profiles[i]
+		}
+	}
+	return nil
+}
+
+// countsOf returns the big, small and partition tables of a profile.
+func (p *schemaProfiles) countsOf(s *schemaProfile) [3]int {
+	counts := p.counts
+	for i, n := range []int{s.bigTables, s.smallTables, s.partitionTables} {
+		if n >= 0 {
+			counts[i] = n
+		}
+	}
+	return counts
+}
+
+// Tables returns the tables of the tenant by its profile, tables being the tables of the flags.
+func (p *schemaProfiles) Tables(tenant string, tables []TableInfo) []TableInfo {
+	s := p.profile(tenant)
+	if s == nil {
+		return tables
+	}
+	if counts := p.countsOf(s); counts != p.counts {
+		tables = p.tables(counts[0], counts[1], counts[2])
+	}
+	own := make([]TableInfo, len(tables))
+	for i, t := range tables {
+		if s.cSize > 0 {
+			t.CSize = s.cSize
+		}
+		if s.padSize > 0 {
+			t.PadSize = s.padSize
+		}
+		if s.extraIndexes > 0 {
+			t.Indexes = s.extraIndexes
+		}
+		own[i] = t
+	}
+	return own
+}
+
+// extraIndexesSQL returns the definitions of the extra secondary indexes of a table, each followed by a comma.
+// Payload columns are indexed by a prefix, as TEXT and BLOB columns can't be indexed whole.
+func extraIndexesSQL(t TableInfo, tenantKey string) string {
+	defs := []string{
+		"  KEY `c_1` (" + tenantKey + "`c`(16)),\n",
+		"  KEY `k_c_1` (" + tenantKey + "`k`, `c`(16)),\n",
+		"  KEY `pad_1` (" + tenantKey + "`pad`(16)),\n",
+	}
+	return strings.Join(defs[:t.Indexes], "")
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"log"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// dbPasswordEnv is the environment variable the database password is read from when there is no -password-file.
+const dbPasswordEnv = "WORKLOAD_DB_PASSWORD"
+
+// loadPassword returns the database password from the first line of the file at path, or else from the
+// environment variable dbPasswordEnv, "" when neither is given. Keeping the password out of the DSN flags keeps it
+// out of shell histories and process listings.
+func loadPassword(path string) (string, error) {
+	if path == "" {
+		return os.Getenv(dbPasswordEnv), nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	password, _, _ := strings.Cut(string(data), "\n")
+	return strings.TrimRight(password, "\r"), nil
+}
+
+// dsnCredentials matches the user:password@ part of a DSN, in the driver's and in URL form.
+var dsnCredentials = regexp.MustCompile(`([^\s:@/"'=]+):[^\s@/"']*@`)
+
+// minScrubbedSecret is the length below which registered secrets are not scrubbed as plain text, so that short
+// passwords don't mangle every log line containing the same characters. They are still scrubbed from DSNs.
+const minScrubbedSecret = 4
+
+// scrubWriter removes credentials from the log output before writing it to w: the password of every DSN and
+// the registered secrets wherever they appear.
+type scrubWriter struct {
+	w io.Writer
+
+	mu      sync.Mutex
+	secrets [][]byte
+}
+
+// scrubLogs makes the standard logger, and the MySQL driver's own logger with it, write through a scrubWriter.
+func scrubLogs() *scrubWriter {
+	s := &scrubWriter{w: log.Writer()}
+	log.SetOutput(s)
+	mysql.SetLogger(log.Default())
+	return s
+}
+
+// AddSecret makes the writer replace secret wherever it appears.
+func (s *scrubWriter) AddSecret(secret string) {
+	if len(secret) < minScrubbedSecret {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.secrets = append(s.secrets, []byte(secret))
+}
+
+// Write writes p with its credentials replaced by "***". It reports len(p) written, as the caller's bytes were consumed.
+func (s *scrubWriter) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := dsnCredentials.ReplaceAll(p, []byte("$1:***@"))
+	for _, secret := range s.secrets {
+		out = bytes.ReplaceAll(out, secret, []byte("***"))
+	}
+	if _, err := s.w.Write(out); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// metricsSchemaPrefix marks a TiDB metrics_schema table in the -server-status list.
+const metricsSchemaPrefix = "metrics_schema."
+
+// serverSampler periodically collects selected server-side values through a dedicated connection: status variables
+// of SHOW GLOBAL STATUS, e.g. Threads_running, and on TiDB the current value of metrics_schema tables, e.g.
+// metrics_schema.tidb_qps, summed over their instances and labels. The time series writer adds the latest values to
+// every row, so one file correlates the client and the server view of the run. A nil *serverSampler samples nothing.
+type serverSampler struct {
+	db       *sql.DB
+	names    []string       // columns of the time series, in the order of the flag
+	status   map[string]int // lower-case status variable name -> column
+	interval time.Duration
+
+	mu     sync.Mutex
+	values []string
+	failed map[string]bool // kinds of samples that failed, logged once
+}
+
+// newServerSampler opens the dedicated connection. It returns nil when spec is "".
+func newServerSampler(driverName, dsn, spec string, interval time.Duration) (*serverSampler, error) {
+	if strings.TrimSpace(spec) == "" {
+		return nil, nil
+	}
+	if driverName == "clickhouse" {
+		return nil, fmt.Errorf("server status is sampled from MySQL or TiDB only")
+	}
+	s := &serverSampler{status: make(map[string]int), interval: interval, failed: make(map[string]bool)}
+	for _, name := range strings.Split(spec, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		if table, ok := strings.CutPrefix(name, metricsSchemaPrefix); ok {
+			if table == "" || strings.ContainsAny(table, " `;.") {
+				return nil, fmt.Errorf("invalid metrics_schema table %q", table)
+			}
+		} else {
+			s.status[strings.ToLower(name)] = len(s.names)
+		}
+		s.names = append(s.names, name)
+	}
+	s.values = make([]string, len(s.names))
+	db, err := sql.Open(driverName, dsn)
+	if err != nil {
+		return nil, err
+	}
+	db.SetMaxOpenConns(1)
+	s.db = db
+	return s, nil
+}
+
+// Columns returns the names of the sampled values, the extra columns of the time series.
+func (s *serverSampler) Columns() []string {
+	if s == nil {
+		return nil
+	}
+	return s.names
+}
+
+// Values returns the latest sampled values in the order of Columns, "" for the values not sampled yet.
+func (s *serverSampler) Values() []string {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.values...)
+}
+
+// Run samples every interval until ctx is done, then closes the connection.
+func (s *serverSampler) Run(ctx context.Context) {
+	defer s.db.Close()
+	for {
+		s.sample(ctx)
+		if !sleepCtx(ctx, s.interval) {
+			return
+		}
+	}
+}
+
+// sample collects all values once. A failure keeps the previous values and is logged once per kind.
+func (s *serverSampler) sample(ctx context.Context) {
+	if len(s.status) > 0 {
+		if err := s.sampleStatus(ctx); err != nil {
+			s.logFailure(ctx, "SHOW GLOBAL STATUS", err)
+		}
+	}
+	for i, name := range s.names {
+		table, ok := strings.CutPrefix(name, metricsSchemaPrefix)
+		if !ok {
+			continue
+		}
+		var value sql.NullFloat64
+		err := s.db.QueryRowContext(ctx, "SELECT SUM(value) FROM "+metricsSchemaPrefix+table+" WHERE time = NOW()").Scan(&value)
+		if err != nil {
+			s.logFailure(ctx, name, err)
+			continue
+		}
+		s.set(i, strconv.FormatFloat(value.Float64, 'f', -1, 64))
+	}
+}
+
+// sampleStatus reads the status variables of the list.
+func (s *serverSampler) sampleStatus(ctx context.Context) error {
+	rows, err := s.db.QueryContext(ctx, "SHOW GLOBAL STATUS")
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	if columns, err := rows.Columns(); err != nil || len(columns) != 2 {
+		return fmt.Errorf("unexpected columns %v", columns)
+	}
+	for rows.Next() {
+		var name, value sql.NullString
+		if err := rows.Scan(&name, &value); err != nil {
+			return err
+		}
+		if i, ok := s.status[strings.ToLower(name.String)]; ok {
+			s.set(i, value.String)
+		}
+	}
+	return rows.Err()
+}
+
+func (s *serverSampler) set(i int, value string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.values[i] = value
+}
+
+func (s *serverSampler) logFailure(ctx context.Context, kind string, err error) {
+	if ctx.Err() != nil || s.failed[kind] {
+		return
+	}
+	s.failed[kind] = true
+	log.Printf("[WARNING] server status: %s failed (logged once): %v", kind, err)
+}
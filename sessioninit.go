@@ -0,0 +1,105 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// sessionInitRule runs one statement on the new connections of some tenants.
+type sessionInitRule struct {
+	tenants   tenantSet // nil = every tenant
+	statement string
+}
+
+// sessionInit holds the statements run on every connection a worker takes from its pool, like the session setup of
+// an application's connection pool (sql_mode, time_zone, TiDB variables), so tenants can run with realistic and
+// differing session configurations. A nil *sessionInit runs nothing.
+type sessionInit struct {
+	rules []sessionInitRule
+}
+
+// connSessionInit is the session initialization of every tenant connection, set by -session-init and
+// -session-init-file. It is nil when connections are used as the driver opens them.
+var connSessionInit *sessionInit
+
+// newSessionInit returns the statements run on the connections of every tenant followed by the entries of the file,
+// or nil when there are none. The file has one "tenants statement" entry per line, e.g.
+//
+//	1-3  SET SESSION sql_mode = 'STRICT_TRANS_TABLES,NO_ZERO_DATE'
+//	4-6  SET SESSION tidb_distsql_scan_concurrency = 5
+//	*    SET SESSION time_zone = '+00:00'
+//
+// tenants are names or 1-based ranges, "*" for every tenant. Empty lines and lines starting with "#" are ignored.
+func newSessionInit(statements []string, path string) (*sessionInit, error) {
+	s := &sessionInit{}
+	for _, stmt := range statements {
+		if stmt = strings.TrimSpace(stmt); stmt != "" {
+			s.rules = append(s.rules, sessionInitRule{statement: stmt})
+		}
+	}
+	if path != "" {
+		if err := s.loadFile(path); err != nil {
+			return nil, err
+		}
+	}
+	if len(s.rules) == 0 {
+		return nil, nil
+	}
+	return s, nil
+}
+
+func (s *sessionInit) loadFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		spec := strings.Fields(line)[0]
+		stmt := strings.TrimSpace(line[len(spec):])
+		if stmt == "" {
+			return fmt.Errorf("%s:%d: want \"tenants statement\", got %q", path, lineNo, line)
+		}
+		rule := sessionInitRule{statement: stmt}
+		if spec != "*" {
+			if rule.tenants, err = parseTenantSet(spec); err != nil {
+				return fmt.Errorf("%s:%d: %v", path, lineNo, err)
+			}
+		}
+		s.rules = append(s.rules, rule)
+	}
+	return scanner.Err()
+}
+
+// Statements returns the statements run on the connections of the tenant, in order.
+func (s *sessionInit) Statements(tenant string) []string {
+	if s == nil {
+		return nil
+	}
+	var statements []string
+	for _, rule := range s.rules {
+		if rule.tenants.Contains(tenant) {
+			statements = append(statements, rule.statement)
+		}
+	}
+	return statements
+}
+
+// Run runs the statements of the tenant on the connection and stops at the first failing one.
+func (s *sessionInit) Run(ctx context.Context, conn queryExecutor, tenant string) error {
+	for _, stmt := range s.Statements(tenant) {
+		if _, err := conn.ExecContext(ctx, stmt); err != nil {
+			return fmt.Errorf("session init %q: %v", stmt, err)
+		}
+	}
+	return nil
+}
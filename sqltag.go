@@ -0,0 +1,44 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// sqlTagger prepends a comment like /* run=3f9a1c tenant=test0007 worker=12 qtype=point_select */ to every workload
+// statement, so the statement summary, slow log and Top SQL views of the server can attribute load to the simulator's
+// tenants, workers and query types. A nil *sqlTagger leaves statements alone.
+type sqlTagger struct {
+	runID string
+}
+
+// newSQLTagger returns nil when tagging is disabled.
+func newSQLTagger(enabled bool, runID string) *sqlTagger {
+	if !enabled {
+		return nil
+	}
+	return &sqlTagger{runID: runID}
+}
+
+// Tag returns the query with the comment of the tenant, worker and query type prepended.
+func (t *sqlTagger) Tag(query, tenant string, worker int, queryType string) string {
+	if t == nil {
+		return query
+	}
+	return fmt.Sprintf("/* run=%s tenant=%s worker=%d qtype=%s */ %s",
+		commentSafe(t.runID), commentSafe(tenant), worker, commentSafe(queryType), query)
+}
+
+// commentSafe keeps a value from ending the comment it is put into.
+func commentSafe(s string) string {
+	return strings.ReplaceAll(s, "*/", "* /")
+}
+
+// newRunID returns a random identifier of a run, e.g. 3f9a1c2b.
+func newRunID() string {
+	b := make([]byte, 4)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
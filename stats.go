@@ -0,0 +1,475 @@
+package main
+
+import (
+	"database/sql"
+	"log"
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// histGrowth is the relative width of a histogram bucket (~5% precision).
+	histGrowth = 1.05
+	// histBuckets covers latencies from 1us up to well over one hour.
+	histBuckets = 460
+)
+
+var logHistGrowth = math.Log(histGrowth)
+
+// latencyHistogram is a mergeable log-scale histogram of latencies in microseconds.
+type latencyHistogram struct {
+	Buckets []uint64 `json:"buckets"`
+	Count   uint64   `json:"count"`
+	SumUs   uint64   `json:"sum_us"`
+	MaxUs   uint64   `json:"max_us"`
+}
+
+func newLatencyHistogram() *latencyHistogram {
+	return &latencyHistogram{Buckets: make([]uint64, histBuckets)}
+}
+
+// histBucket returns the bucket index of a latency in microseconds.
+func histBucket(us uint64) int {
+	if us <= 1 {
+		return 0
+	}
+	b := int(math.Log(float64(us))/logHistGrowth) + 1
+	if b >= histBuckets {
+		b = histBuckets - 1
+	}
+	return b
+}
+
+// Record adds one latency sample.
+func (h *latencyHistogram) Record(d time.Duration) {
+	us := uint64(0)
+	if d > 0 {
+		us = uint64(d.Microseconds())
+	}
+	h.Buckets[histBucket(us)]++
+	h.Count++
+	h.SumUs += us
+	if us > h.MaxUs {
+		h.MaxUs = us
+	}
+}
+
+// Merge adds all samples of o to h.
+func (h *latencyHistogram) Merge(o *latencyHistogram) {
+	if o == nil {
+		return
+	}
+	for i, c := range o.Buckets {
+		if i < len(h.Buckets) {
+			h.Buckets[i] += c
+		}
+	}
+	h.Count += o.Count
+	h.SumUs += o.SumUs
+	if o.MaxUs > h.MaxUs {
+		h.MaxUs = o.MaxUs
+	}
+}
+
+// Since returns the samples recorded after prev, an earlier copy of the same histogram.
+// MaxUs is that of the whole histogram, so only the quantiles of the result are meaningful.
+func (h *latencyHistogram) Since(prev *latencyHistogram) *latencyHistogram {
+	d := newLatencyHistogram()
+	for i, c := range h.Buckets {
+		if i < len(prev.Buckets) {
+			c -= prev.Buckets[i]
+		}
+		d.Buckets[i] = c
+	}
+	d.Count = h.Count - prev.Count
+	d.SumUs = h.SumUs - prev.SumUs
+	d.MaxUs = h.MaxUs
+	return d
+}
+
+// Quantile returns the latency below which the fraction q of the samples fall.
+func (h *latencyHistogram) Quantile(q float64) time.Duration {
+	if h.Count == 0 {
+		return 0
+	}
+	rank := uint64(math.Ceil(q * float64(h.Count)))
+	if rank < 1 {
+		rank = 1
+	}
+	var seen uint64
+	for i, c := range h.Buckets {
+		seen += c
+		if seen >= rank {
+			upper := uint64(math.Pow(histGrowth, float64(i)))
+			if upper > h.MaxUs {
+				upper = h.MaxUs
+			}
+			return time.Duration(upper) * time.Microsecond
+		}
+	}
+	return time.Duration(h.MaxUs) * time.Microsecond
+}
+
+// Mean returns the average latency.
+func (h *latencyHistogram) Mean() time.Duration {
+	if h.Count == 0 {
+		return 0
+	}
+	return time.Duration(h.SumUs/h.Count) * time.Microsecond
+}
+
+// tenantStats accumulates the query results of one tenant.
+type tenantStats struct {
+	mu            sync.Mutex
+	Queries       uint64            `json:"queries"`
+	Errors        uint64            `json:"errors"`
+	Reconnects    uint64            `json:"reconnects"`
+	Kills         uint64            `json:"kills"`
+	Recycles      uint64            `json:"recycles"`
+	Connects      uint64            `json:"connects"`
+	ConnectErrors uint64            `json:"connect_errors"`
+	DDLs          uint64            `json:"ddls"`
+	DDLErrors     uint64            `json:"ddl_errors"`
+	CacheHits     uint64            `json:"cache_hits"`
+	Retries       uint64            `json:"retries"`
+	Rows          uint64            `json:"rows"`
+	BytesIn       uint64            `json:"bytes_in"`
+	BytesOut      uint64            `json:"bytes_out"`
+	Latency       *latencyHistogram `json:"latency"`
+	ReconnectTime *latencyHistogram `json:"reconnect_time"`
+	DDLTime       *latencyHistogram `json:"ddl_time"`
+	RecycleTime   *latencyHistogram `json:"recycle_time"`
+	ConnectTime   *latencyHistogram `json:"connect_time"`
+	// CorrectedLatency is Latency corrected for coordinated omission, see coCorrection.
+	CorrectedLatency *latencyHistogram `json:"corrected_latency"`
+	// Types breaks the queries down by query type (point_select, join, ...).
+	Types map[string]*queryTypeStats `json:"types,omitempty"`
+	// Windows holds the queries per time window since the start of the run, for the interference report.
+	Windows []*statsWindow `json:"windows,omitempty"`
+
+	start  time.Time     // start of the run, windows are counted from here
+	window time.Duration // window length, 0 = no windows are recorded
+}
+
+func newTenantStats() *tenantStats {
+	return &tenantStats{Latency: newLatencyHistogram(), ReconnectTime: newLatencyHistogram(), DDLTime: newLatencyHistogram(),
+		RecycleTime: newLatencyHistogram(), ConnectTime: newLatencyHistogram(), CorrectedLatency: newLatencyHistogram()}
+}
+
+// queryTypeStats accumulates the queries of one query type.
+type queryTypeStats struct {
+	Queries  uint64            `json:"queries"`
+	Errors   uint64            `json:"errors"`
+	Rows     uint64            `json:"rows"`
+	BytesIn  uint64            `json:"bytes_in"`
+	BytesOut uint64            `json:"bytes_out"`
+	Latency  *latencyHistogram `json:"latency"`
+}
+
+func newQueryTypeStats() *queryTypeStats {
+	return &queryTypeStats{Latency: newLatencyHistogram()}
+}
+
+// merge adds the counters of o to q.
+func (q *queryTypeStats) merge(o *queryTypeStats) {
+	q.Queries += o.Queries
+	q.Errors += o.Errors
+	q.Rows += o.Rows
+	q.BytesIn += o.BytesIn
+	q.BytesOut += o.BytesOut
+	q.Latency.Merge(o.Latency)
+}
+
+// typeStats returns the statistics of a query type, creating them on first use. The caller must hold t.mu if t is shared.
+func (t *tenantStats) typeStats(queryType string) *queryTypeStats {
+	if t.Types == nil {
+		t.Types = make(map[string]*queryTypeStats)
+	}
+	q, ok := t.Types[queryType]
+	if !ok {
+		q = newQueryTypeStats()
+		t.Types[queryType] = q
+	}
+	return q
+}
+
+// RecordQuery counts one executed query of the given type. sql.ErrNoRows is not an error for the workload.
+func (t *tenantStats) RecordQuery(queryType string, latency time.Duration, err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.Queries++
+	q := t.typeStats(queryType)
+	q.Queries++
+	w := t.currentWindow()
+	if w != nil {
+		w.Queries++
+	}
+	if err != nil && err != sql.ErrNoRows {
+		t.Errors++
+		q.Errors++
+		return
+	}
+	t.Latency.Record(latency)
+	q.Latency.Record(latency)
+	if w != nil {
+		w.Record(latency)
+	}
+}
+
+// RecordVolume adds the rows and bytes of one query of the given type.
+func (t *tenantStats) RecordVolume(queryType string, v resultVolume) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	q := t.typeStats(queryType)
+	t.Rows += v.Rows
+	t.BytesIn += v.BytesIn
+	t.BytesOut += v.BytesOut
+	q.Rows += v.Rows
+	q.BytesIn += v.BytesIn
+	q.BytesOut += v.BytesOut
+}
+
+// RecordCorrected records the latency of a query corrected for coordinated omission: the latency itself and, for
+// every start the worker intended every interval during the query, the time from that start to the end of the query.
+// Failed queries are not recorded, like in RecordQuery.
+func (t *tenantStats) RecordCorrected(latency, interval time.Duration, err error) {
+	if err != nil && err != sql.ErrNoRows {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.CorrectedLatency.Record(latency)
+	for missed := latency - interval; missed > 0; missed -= interval {
+		t.CorrectedLatency.Record(missed)
+	}
+}
+
+// RecordReconnect counts one re-established connection and how long re-establishing it took.
+func (t *tenantStats) RecordReconnect(took time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.Reconnects++
+	t.ReconnectTime.Record(took)
+}
+
+// RecordConnect counts one connection established by a tenant pool and how long establishing it took.
+func (t *tenantStats) RecordConnect(took time.Duration, err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.Connects++
+	if err != nil {
+		t.ConnectErrors++
+		return
+	}
+	t.ConnectTime.Record(took)
+}
+
+// RecordRecycle counts one connection recycled after its lifetime and how long establishing its successor took.
+func (t *tenantStats) RecordRecycle(took time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.Recycles++
+	t.RecycleTime.Record(took)
+}
+
+// RecordDDL counts one DDL statement of the DDL churn tenant. DDL is kept out of the query statistics.
+func (t *tenantStats) RecordDDL(took time.Duration, err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.DDLs++
+	if err != nil {
+		t.DDLErrors++
+		return
+	}
+	t.DDLTime.Record(took)
+}
+
+// RecordCacheHit counts one read served by the tenant's simulated client-side cache instead of the database.
+func (t *tenantStats) RecordCacheHit() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.CacheHits++
+}
+
+// RecordRetry counts one query run again after a retryable error.
+func (t *tenantStats) RecordRetry() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.Retries++
+}
+
+// RecordKill counts one connection killed by the chaos mode.
+func (t *tenantStats) RecordKill() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.Kills++
+}
+
+// merge adds the counters of o to t. The caller must hold t.mu if t is shared.
+func (t *tenantStats) merge(o *tenantStats) {
+	t.Queries += o.Queries
+	t.Errors += o.Errors
+	t.Reconnects += o.Reconnects
+	t.Kills += o.Kills
+	t.Recycles += o.Recycles
+	t.Connects += o.Connects
+	t.ConnectErrors += o.ConnectErrors
+	t.DDLs += o.DDLs
+	t.DDLErrors += o.DDLErrors
+	t.CacheHits += o.CacheHits
+	t.Retries += o.Retries
+	t.Rows += o.Rows
+	t.BytesIn += o.BytesIn
+	t.BytesOut += o.BytesOut
+	t.Latency.Merge(o.Latency)
+	t.ReconnectTime.Merge(o.ReconnectTime)
+	t.DDLTime.Merge(o.DDLTime)
+	t.RecycleTime.Merge(o.RecycleTime)
+	t.ConnectTime.Merge(o.ConnectTime)
+	t.CorrectedLatency.Merge(o.CorrectedLatency)
+	for name, q := range o.Types {
+		t.typeStats(name).merge(q)
+	}
+	for i, w := range o.Windows {
+		t.windowAt(i).Merge(w)
+	}
+}
+
+// copy returns a consistent copy of t.
+func (t *tenantStats) copy() *tenantStats {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	c := newTenantStats()
+	c.merge(t)
+	return c
+}
+
+// statsCollector collects per-tenant statistics of a run.
+// A nil *statsCollector is valid and records nothing.
+type statsCollector struct {
+	mu      sync.Mutex
+	start   time.Time
+	end     time.Time // zero while collecting, see Stop
+	window  time.Duration
+	tenants map[string]*tenantStats
+}
+
+// newStatsCollector starts collecting now. With a window > 0 the queries of each tenant are also
+// recorded per time window of that length.
+func newStatsCollector(window time.Duration) *statsCollector {
+	return &statsCollector{start: time.Now(), window: window, tenants: make(map[string]*tenantStats)}
+}
+
+// Tenant returns the statistics of a tenant, creating them on first use.
+// Workers look their tenant up once and record into it directly.
+func (c *statsCollector) Tenant(name string) *tenantStats {
+	if c == nil {
+		return newTenantStats()
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	t, ok := c.tenants[name]
+	if !ok {
+		t = newTenantStats()
+		t.start, t.window = c.start, c.window
+		c.tenants[name] = t
+	}
+	return t
+}
+
+// Stop ends the elapsed time of later snapshots now, for statistics reported after their part of the run is over.
+func (c *statsCollector) Stop() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.end = time.Now()
+}
+
+// Snapshot returns a copy of the current statistics.
+func (c *statsCollector) Snapshot() *statsSnapshot {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	end := c.end
+	if end.IsZero() {
+		end = time.Now()
+	}
+	s := &statsSnapshot{ElapsedSeconds: end.Sub(c.start).Seconds(), Tenants: make(map[string]*tenantStats, len(c.tenants))}
+	for name, t := range c.tenants {
+		s.Tenants[name] = t.copy()
+	}
+	return s
+}
+
+// statsSnapshot is a point-in-time copy of the statistics, which can be merged across nodes.
+type statsSnapshot struct {
+	ElapsedSeconds float64                 `json:"elapsed_seconds"`
+	Tenants        map[string]*tenantStats `json:"tenants"`
+}
+
+// Merge adds the tenants of o to s. The elapsed time is the longest of both.
+func (s *statsSnapshot) Merge(o *statsSnapshot) {
+	if o.ElapsedSeconds > s.ElapsedSeconds {
+		s.ElapsedSeconds = o.ElapsedSeconds
+	}
+	for name, t := range o.Tenants {
+		if mine, ok := s.Tenants[name]; ok {
+			mine.merge(t)
+		} else {
+			c := newTenantStats()
+			c.merge(t)
+			s.Tenants[name] = c
+		}
+	}
+}
+
+// Total returns the statistics of all tenants combined.
+func (s *statsSnapshot) Total() *tenantStats {
+	total := newTenantStats()
+	for _, t := range s.Tenants {
+		total.merge(t)
+	}
+	return total
+}
+
+// TenantNames returns the tenant names in sorted order.
+func (s *statsSnapshot) TenantNames() []string {
+	names := make([]string, 0, len(s.Tenants))
+	for name := range s.Tenants {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// logSummary logs the overall totals of a snapshot.
+func logSummary(s *statsSnapshot) {
+	total := s.Total()
+	qps := 0.0
+	if s.ElapsedSeconds > 0 {
+		qps = float64(total.Queries) / s.ElapsedSeconds
+	}
+	log.Printf("[INFO] Summary: tenants=%d queries=%d errors=%d reconnects=%d qps=%.1f avg=%v p50=%v p95=%v p99=%v max=%v",
+		len(s.Tenants), total.Queries, total.Errors, total.Reconnects, qps,
+		total.Latency.Mean(), total.Latency.Quantile(0.50), total.Latency.Quantile(0.95), total.Latency.Quantile(0.99),
+		time.Duration(total.Latency.MaxUs)*time.Microsecond)
+	for _, name := range sortedTypeNames(total.Types) {
+		q := total.Types[name]
+		log.Printf("[INFO] Summary: type=%s queries=%d errors=%d qps=%.1f avg=%v p50=%v p95=%v p99=%v max=%v",
+			name, q.Queries, q.Errors, float64(q.Queries)/math.Max(s.ElapsedSeconds, 1e-9),
+			q.Latency.Mean(), q.Latency.Quantile(0.50), q.Latency.Quantile(0.95), q.Latency.Quantile(0.99),
+			time.Duration(q.Latency.MaxUs)*time.Microsecond)
+	}
+	if c := total.CorrectedLatency; c.Count > 0 {
+		log.Printf("[INFO] Summary: corrected for coordinated omission samples=%d avg=%v p50=%v p95=%v p99=%v max=%v",
+			c.Count, c.Mean(), c.Quantile(0.50), c.Quantile(0.95), c.Quantile(0.99), time.Duration(c.MaxUs)*time.Microsecond)
+	}
+	if total.Retries > 0 {
+		log.Printf("[INFO] Summary: retries=%d after retryable transaction errors", total.Retries)
+	}
+	if total.DDLs > 0 {
+		log.Printf("[INFO] Summary: ddls=%d ddl_errors=%d ddl avg=%v p99=%v max=%v",
+			total.DDLs, total.DDLErrors, total.DDLTime.Mean(), total.DDLTime.Quantile(0.99),
+			time.Duration(total.DDLTime.MaxUs)*time.Microsecond)
+	}
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"text/tabwriter"
+	"time"
+)
+
+// latencySummary is the condensed form of a latency histogram used in reports, in milliseconds.
+type latencySummary struct {
+	AvgMs float64 `json:"avg_ms"`
+	P50Ms float64 `json:"p50_ms"`
+	P95Ms float64 `json:"p95_ms"`
+	P99Ms float64 `json:"p99_ms"`
+	MaxMs float64 `json:"max_ms"`
+}
+
+func summarizeLatency(h *latencyHistogram) latencySummary {
+	ms := func(d time.Duration) float64 { return float64(d) / float64(time.Millisecond) }
+	return latencySummary{
+		AvgMs: ms(h.Mean()),
+		P50Ms: ms(h.Quantile(0.50)),
+		P95Ms: ms(h.Quantile(0.95)),
+		P99Ms: ms(h.Quantile(0.99)),
+		MaxMs: ms(time.Duration(h.MaxUs) * time.Microsecond),
+	}
+}
+
+// queryTypeSummary is the summary of one query type of a tenant.
+type queryTypeSummary struct {
+	Queries uint64         `json:"queries"`
+	QPS     float64        `json:"qps"`
+	Errors  uint64         `json:"errors"`
+	Latency latencySummary `json:"latency"`
+	volumeSummary
+}
+
+// volumeSummary is the data volume of the queries of a tenant or query type, see resultVolume.
+type volumeSummary struct {
+	Rows     uint64 `json:"rows"`
+	BytesIn  uint64 `json:"bytes_in"`
+	BytesOut uint64 `json:"bytes_out"`
+}
+
+// tenantSummary is the summary of one tenant (or of all tenants).
+type tenantSummary struct {
+	Tenant     string         `json:"tenant,omitempty"`
+	Queries    uint64         `json:"queries"`
+	QPS        float64        `json:"qps"`
+	Errors     uint64         `json:"errors"`
+	Reconnects uint64         `json:"reconnects"`
+	Latency    latencySummary `json:"latency"`
+	volumeSummary
+	// Connects are the connections established by the tenant's pools, timed apart from the queries.
+	Connects      uint64         `json:"connects"`
+	ConnectErrors uint64         `json:"connect_errors"`
+	Connect       latencySummary `json:"connect"`
+	// CorrectedLatency is the latency corrected for coordinated omission, with -co-correct only.
+	CorrectedLatency *latencySummary             `json:"corrected_latency,omitempty"`
+	QueryTypes       map[string]queryTypeSummary `json:"query_types,omitempty"`
+	// CacheHits are the reads served by the simulated client-side cache, not part of Queries.
+	CacheHits uint64 `json:"cache_hits,omitempty"`
+	// Retries are the queries run again after retryable transaction errors with -txn-retries, not part of Queries.
+	Retries uint64 `json:"retries,omitempty"`
+	// PrimaryKey is the primary key kind of the tenant's tables set by -clustered-tenants or -nonclustered-tenants.
+	PrimaryKey string `json:"primary_key,omitempty"`
+	// Region is the region of the tenant set by -region-file.
+	Region string `json:"region,omitempty"`
+}
+
+// runSummary is the final report of a run, as exported by -summary-json-file.
+type runSummary struct {
+	// Run identifies the run, its build and its configuration.
+	Run            *runMetadata    `json:"run,omitempty"`
+	ElapsedSeconds float64         `json:"elapsed_seconds"`
+	Total          tenantSummary   `json:"total"`
+	Tenants        []tenantSummary `json:"tenants"`
+	// Targets are the achieved-vs-target QPS of the rate limited tenants.
+	Targets []targetSummary `json:"targets,omitempty"`
+	// Regions are the summaries of the tenants of every region, with -region-file.
+	Regions []regionSummary `json:"regions,omitempty"`
+}
+
+func summarizeTenant(name string, t *tenantStats, elapsed float64) tenantSummary {
+	rate := func(n uint64) float64 {
+		if elapsed <= 0 {
+			return 0
+		}
+		return float64(n) / elapsed
+	}
+	s := tenantSummary{
+		Tenant:        name,
+		Queries:       t.Queries,
+		QPS:           rate(t.Queries),
+		Errors:        t.Errors,
+		Reconnects:    t.Reconnects,
+		CacheHits:     t.CacheHits,
+		Retries:       t.Retries,
+		Latency:       summarizeLatency(t.Latency),
+		Connects:      t.Connects,
+		ConnectErrors: t.ConnectErrors,
+		Connect:       summarizeLatency(t.ConnectTime),
+		volumeSummary: volumeSummary{Rows: t.Rows, BytesIn: t.BytesIn, BytesOut: t.BytesOut},
+	}
+	if t.CorrectedLatency.Count > 0 {
+		corrected := summarizeLatency(t.CorrectedLatency)
+		s.CorrectedLatency = &corrected
+	}
+	if len(t.Types) > 0 {
+		s.QueryTypes = make(map[string]queryTypeSummary, len(t.Types))
+		for name, q := range t.Types {
+			s.QueryTypes[name] = queryTypeSummary{Queries: q.Queries, QPS: rate(q.Queries), Errors: q.Errors, Latency: summarizeLatency(q.Latency),
+				volumeSummary: volumeSummary{Rows: q.Rows, BytesIn: q.BytesIn, BytesOut: q.BytesOut}}
+		}
+	}
+	return s
+}
+
+// summarize builds the final report of a snapshot.
+func summarize(s *statsSnapshot) *runSummary {
+	r := &runSummary{ElapsedSeconds: s.ElapsedSeconds, Total: summarizeTenant("", s.Total(), s.ElapsedSeconds)}
+	for _, name := range s.TenantNames() {
+		r.Tenants = append(r.Tenants, summarizeTenant(name, s.Tenants[name], s.ElapsedSeconds))
+	}
+	return r
+}
+
+// printTenantTable writes the per-tenant table of the report, each tenant followed by its query types.
+// The connections established by a tenant and their p99 are on the tenant's row.
+func printTenantTable(w io.Writer, r *runSummary) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "TENANT\tTYPE\tQUERIES\tQPS\tP50(ms)\tP95(ms)\tP99(ms)\tERRORS\tROWS\tKB IN\tKB OUT\tRECONNECTS\tCONNECTS\tCONNECT P99(ms)\t")
+	row := func(tenant, queryType string, queries uint64, qps float64, l latencySummary, errors uint64, v volumeSummary, reconnects, connects, connectP99 string) {
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%.1f\t%.2f\t%.2f\t%.2f\t%d\t%d\t%.1f\t%.1f\t%s\t%s\t%s\t\n",
+			tenant, queryType, queries, qps, l.P50Ms, l.P95Ms, l.P99Ms, errors, v.Rows, float64(v.BytesIn)/1024, float64(v.BytesOut)/1024,
+			reconnects, connects, connectP99)
+	}
+	for _, t := range append(r.Tenants, r.Total) {
+		tenant := t.Tenant
+		if tenant == "" {
+			tenant = "TOTAL"
+		}
+		row(tenant, "all", t.Queries, t.QPS, t.Latency, t.Errors, t.volumeSummary, fmt.Sprint(t.Reconnects), fmt.Sprint(t.Connects), fmt.Sprintf("%.2f", t.Connect.P99Ms))
+		types := make([]string, 0, len(t.QueryTypes))
+		for name := range t.QueryTypes {
+			types = append(types, name)
+		}
+		sort.Strings(types)
+		for _, name := range types {
+			q := t.QueryTypes[name]
+			row("", name, q.Queries, q.QPS, q.Latency, q.Errors, q.volumeSummary, "", "", "")
+		}
+	}
+	tw.Flush()
+}
+
+// writeSummaryJSON writes the report to path as indented JSON.
+func writeSummaryJSON(path string, r *runSummary) error {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0644)
+}
@@ -0,0 +1,255 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"text/tabwriter"
+	"time"
+)
+
+// What a sweep steps through.
+const (
+	// sweepConcurrency runs a percentage of every tenant's workers per step.
+	sweepConcurrency = "concurrency"
+	// sweepQPS holds every tenant to a percentage of -sweep-max-qps per step.
+	sweepQPS = "qps"
+)
+
+// sweepController repeats the workload at increasing load steps, e.g. 10%, 25%, 50% and 100% of the configured
+// maximum, for a fixed time each, and records the throughput and latency of every step. The steps together
+// form a throughput-vs-latency curve, replacing many manual runs with one invocation.
+// A nil *sweepController is disabled.
+type sweepController struct {
+	by           string
+	steps        []float64 // fractions of the maximum, in run order
+	stepDuration time.Duration
+	maxWorkers   int     // workers per tenant at 100%
+	maxQPS       float64 // QPS per tenant at 100%, sweepQPS only
+
+	step    int32 // index of the current step, read by the workers without locking
+	workers int32 // active workers per tenant in the current step, sweepConcurrency only
+
+	mu       sync.Mutex
+	tenants  map[string]*sweepTenant
+	previous *tenantStats // totals at the start of the current step
+	elapsed  float64      // elapsed seconds of the run at the start of the current step
+	results  []sweepStep
+}
+
+// sweepTenant is the sweep state of one tenant.
+type sweepTenant struct {
+	sweep  *sweepController
+	bucket *tokenBucket // sweepQPS only
+}
+
+// sweepStep is the outcome of one step of the curve.
+type sweepStep struct {
+	Percent float64
+	Target  float64 // workers or QPS per tenant
+	Seconds float64
+	Queries uint64
+	Errors  uint64
+	Latency latencySummary
+}
+
+// newSweepController parses steps, a comma-separated list of percentages such as "10,25,50,100".
+// It returns nil when steps is "".
+func newSweepController(by, steps string, stepDuration time.Duration, maxWorkers int, maxQPS float64) (*sweepController, error) {
+	if steps == "" {
+		return nil, nil
+	}
+	switch by {
+	case sweepConcurrency:
+	case sweepQPS:
+		if maxQPS <= 0 {
+			return nil, fmt.Errorf("a %s sweep needs a positive maximum QPS per tenant", sweepQPS)
+		}
+	default:
+		return nil, fmt.Errorf("unknown sweep dimension %q (want %s or %s)", by, sweepConcurrency, sweepQPS)
+	}
+	if stepDuration <= 0 {
+		return nil, fmt.Errorf("sweep step length must be positive")
+	}
+	s := &sweepController{by: by, stepDuration: stepDuration, maxWorkers: maxWorkers, maxQPS: maxQPS,
+		tenants: make(map[string]*sweepTenant), previous: newTenantStats()}
+	for _, field := range strings.Split(steps, ",") {
+		percent, err := strconv.ParseFloat(strings.TrimSpace(field), 64)
+		if err != nil || percent <= 0 || percent > 100 {
+			return nil, fmt.Errorf("invalid sweep step %q (want a percentage in (0, 100])", field)
+		}
+		s.steps = append(s.steps, percent/100)
+	}
+	s.setStep(0)
+	return s, nil
+}
+
+// Duration returns the length of the whole sweep, which replaces the testing time.
+func (s *sweepController) Duration() time.Duration {
+	return time.Duration(len(s.steps)) * s.stepDuration
+}
+
+// target returns the workers or QPS per tenant of a step.
+func (s *sweepController) target(step int) float64 {
+	if s.by == sweepQPS {
+		return s.maxQPS * s.steps[step]
+	}
+	// At least one worker per tenant, so no step is empty.
+	return math.Max(1, math.Ceil(float64(s.maxWorkers)*s.steps[step]))
+}
+
+// setStep applies the load of a step to every tenant.
+func (s *sweepController) setStep(step int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	atomic.StoreInt32(&s.step, int32(step))
+	if s.by == sweepQPS {
+		for _, t := range s.tenants {
+			t.bucket.SetRate(s.target(step), sweepBurst(s.target(step)))
+		}
+		return
+	}
+	atomic.StoreInt32(&s.workers, int32(s.target(step)))
+}
+
+// sweepBurst lets up to 100ms worth of queries through at once.
+func sweepBurst(qps float64) int {
+	return int(qps / 10)
+}
+
+// Tenant returns the sweep state of a tenant, nil if the sweep is disabled.
+// Workers look their tenant up once and call Admit and Wait on it.
+func (s *sweepController) Tenant(name string) *sweepTenant {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t, ok := s.tenants[name]
+	if !ok {
+		t = &sweepTenant{sweep: s}
+		if s.by == sweepQPS {
+			qps := s.target(int(atomic.LoadInt32(&s.step)))
+			t.bucket = newTokenBucket(qps, sweepBurst(qps))
+		}
+		s.tenants[name] = t
+	}
+	return t
+}
+
+// Admit reports whether the worker-th worker (0-based) of the tenant takes part in the current step.
+// A nil *sweepTenant admits every worker.
+func (t *sweepTenant) Admit(worker int) bool {
+	return t == nil || t.bucket != nil || int32(worker) < atomic.LoadInt32(&t.sweep.workers)
+}
+
+// Wait blocks until the tenant's QPS of the current step allows the next query and reports whether ctx is still active.
+func (t *sweepTenant) Wait(ctx context.Context) bool {
+	if t == nil || t.bucket == nil {
+		return true
+	}
+	return t.bucket.Wait(ctx)
+}
+
+// Run moves to the next step every step length from start until the last step is reached or ctx is done,
+// recording the statistics of every finished step. The last step is recorded by Finish.
+func (s *sweepController) Run(ctx context.Context, stats *statsCollector, start time.Time) {
+	s.logStep(0)
+	for step := 1; step < len(s.steps); step++ {
+		if !sleepCtx(ctx, time.Until(start.Add(time.Duration(step)*s.stepDuration))) {
+			return
+		}
+		s.record(stats.Snapshot())
+		s.setStep(step)
+		s.logStep(step)
+	}
+}
+
+func (s *sweepController) logStep(step int) {
+	log.Printf("[INFO] sweep: step %d/%d at %g%%, %s per tenant=%g", step+1, len(s.steps), s.steps[step]*100, s.unit(), s.target(step))
+}
+
+// Finish records the last step from the final statistics of the run.
+func (s *sweepController) Finish(snapshot *statsSnapshot) {
+	s.record(snapshot)
+}
+
+// record adds the results of the current step, the queries after the previous snapshot, to the curve.
+func (s *sweepController) record(snapshot *statsSnapshot) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	step := int(atomic.LoadInt32(&s.step))
+	if len(s.results) > step {
+		return
+	}
+	total := snapshot.Total()
+	s.results = append(s.results, sweepStep{
+		Percent: s.steps[step] * 100,
+		Target:  s.target(step),
+		Seconds: snapshot.ElapsedSeconds - s.elapsed,
+		Queries: total.Queries - s.previous.Queries,
+		Errors:  total.Errors - s.previous.Errors,
+		Latency: summarizeLatency(total.Latency.Since(s.previous.Latency)),
+	})
+	s.previous, s.elapsed = total, snapshot.ElapsedSeconds
+}
+
+// QPS returns the throughput of all tenants during the step.
+func (r sweepStep) QPS() float64 {
+	if r.Seconds <= 0 {
+		return 0
+	}
+	return float64(r.Queries) / r.Seconds
+}
+
+// printSweepCurve writes the throughput-vs-latency curve, one row per step.
+func (s *sweepController) printSweepCurve(w io.Writer) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintf(tw, "STEP(%%)\t%s/TENANT\tQPS\tP50(ms)\tP95(ms)\tP99(ms)\tERRORS\t\n", strings.ToUpper(s.unit()))
+	for _, r := range s.results {
+		fmt.Fprintf(tw, "%g\t%g\t%.1f\t%.2f\t%.2f\t%.2f\t%d\t\n",
+			r.Percent, r.Target, r.QPS(), r.Latency.P50Ms, r.Latency.P95Ms, r.Latency.P99Ms, r.Errors)
+	}
+	tw.Flush()
+}
+
+// writeSweepCSV writes the curve to path as CSV, for plotting.
+func (s *sweepController) writeSweepCSV(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	w := csv.NewWriter(f)
+	w.Write([]string{"percent", s.unit() + "_per_tenant", "seconds", "queries", "qps", "errors", "p50_ms", "p95_ms", "p99_ms"})
+	for _, r := range s.results {
+		format := func(v float64, prec int) string { return strconv.FormatFloat(v, 'f', prec, 64) }
+		w.Write([]string{format(r.Percent, -1), format(r.Target, -1), format(r.Seconds, 1),
+			strconv.FormatUint(r.Queries, 10), format(r.QPS(), 1), strconv.FormatUint(r.Errors, 10),
+			format(r.Latency.P50Ms, 3), format(r.Latency.P95Ms, 3), format(r.Latency.P99Ms, 3)})
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func (s *sweepController) unit() string {
+	if s.by == sweepQPS {
+		return "qps"
+	}
+	return "workers"
+}
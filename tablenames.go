@@ -0,0 +1,46 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// tableNameVerb matches the integer verb of a table name template, e.g. %d or %03d.
+var tableNameVerb = regexp.MustCompile(`%0?[0-9]*d`)
+
+// tableNameTemplate names the workload tables by their 1-based index, e.g. "sbtest%d" (sbtest1, sbtest2, ...)
+// or "sbtest%03d" (sbtest001, ...) for data prepared by a sysbench that pads the table numbers.
+type tableNameTemplate struct {
+	format string
+	prefix string // text before the verb
+	suffix string // text after the verb
+}
+
+// parseTableNameTemplate accepts a template with exactly one integer verb and no other verbs.
+func parseTableNameTemplate(format string) (tableNameTemplate, error) {
+	verbs := tableNameVerb.FindAllStringIndex(format, -1)
+	if len(verbs) != 1 || strings.Count(format, "%") != 1 {
+		return tableNameTemplate{}, fmt.Errorf("table name template %q must contain exactly one %%d verb, e.g. sbtest%%d or sbtest%%03d", format)
+	}
+	return tableNameTemplate{format: format, prefix: format[:verbs[0][0]], suffix: format[verbs[0][1]:]}, nil
+}
+
+// Name returns the name of the i-th table (1-based).
+func (t tableNameTemplate) Name(i int) string {
+	return fmt.Sprintf(t.format, i)
+}
+
+// Match reports whether name is one of the template's table names.
+func (t tableNameTemplate) Match(name string) bool {
+	digits, ok := strings.CutPrefix(name, t.prefix)
+	if !ok {
+		return false
+	}
+	if digits, ok = strings.CutSuffix(digits, t.suffix); !ok || digits == "" {
+		return false
+	}
+	i, err := strconv.Atoi(digits)
+	return err == nil && i > 0 && t.Name(i) == name
+}
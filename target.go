@@ -0,0 +1,214 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// targetInterval is how often the achieved QPS of every tenant is compared to its target.
+const targetInterval = time.Second
+
+// targetBehindIntervals is how many intervals in a row a tenant has to miss its target before it is reported as
+// behind, so the odd slow second doesn't flood the log.
+const targetBehindIntervals = 3
+
+// targetTracker compares the achieved QPS of every rate limited tenant to its target, the lowest of the QPS ceilings
+// of its tier, of the current sweep step (-sweep-by=qps) and of the current scenario phase, and keeps the backlog,
+// the queries the tenant fell short of its target. A tenant that can't reach its target saturates: its workers, or
+// the cluster, can't keep up with the admitted rate, which its plain QPS hides as it looks like the configured limit
+// at work. A nil *targetTracker is disabled.
+type targetTracker struct {
+	tolerance float64 // a tenant misses its target in an interval when it achieves less than target * (1 - tolerance)
+	tiers     *tierScheduler
+	sweep     *sweepController
+	scenario  *scenarioRunner
+
+	mu      sync.Mutex
+	tenants map[string]*targetTenant
+}
+
+// targetTenant is the tracked state of one tenant.
+type targetTenant struct {
+	target    float64 // target QPS of the last interval, 0 = none
+	seconds   float64 // seconds with a target
+	expected  float64 // queries the targets asked for
+	achieved  uint64  // queries run while there was a target
+	backlog   float64 // queries short of the targets, summed over the intervals
+	missed    int     // intervals below the target
+	streak    int     // intervals below the target in a row
+	behindFor float64 // seconds of the current streak
+	previous  *tenantStats
+}
+
+// newTargetTracker returns nil when nothing limits the QPS of the tenants.
+func newTargetTracker(tolerance float64, tiers *tierScheduler, sweep *sweepController, scenario *scenarioRunner) (*targetTracker, error) {
+	if tiers == nil && (sweep == nil || sweep.by != sweepQPS) && scenario == nil {
+		return nil, nil
+	}
+	if tolerance < 0 || tolerance >= 1 {
+		return nil, fmt.Errorf("the target tolerance must be in [0, 1)")
+	}
+	return &targetTracker{tolerance: tolerance, tiers: tiers, sweep: sweep, scenario: scenario, tenants: make(map[string]*targetTenant)}, nil
+}
+
+// TargetQPS returns the QPS ceiling of the tenant's tier, 0 for none.
+func (l *tenantLimiter) TargetQPS() float64 {
+	if l == nil || l.bucket == nil {
+		return 0
+	}
+	return l.bucket.Rate()
+}
+
+// TargetQPS returns the QPS of the tenant in the current sweep step, 0 unless the sweep is by QPS.
+func (t *sweepTenant) TargetQPS() float64 {
+	if t == nil || t.bucket == nil {
+		return 0
+	}
+	return t.bucket.Rate()
+}
+
+// TargetQPS returns the QPS cap of the tenant in the current scenario phase, 0 for none or when the tenant is paused.
+func (t *scenarioTenant) TargetQPS() float64 {
+	if t == nil || atomic.LoadInt32(&t.limited) == 0 || atomic.LoadInt32(&t.workers) == 0 {
+		return 0
+	}
+	return t.bucket.Rate()
+}
+
+// targetQPS returns the current target of a tenant, the lowest of its QPS ceilings, 0 for none.
+func (tr *targetTracker) targetQPS(tenant string) float64 {
+	target := 0.0
+	for _, qps := range []float64{tr.tiers.Limiter(tenant).TargetQPS(), tr.sweep.Tenant(tenant).TargetQPS(), tr.scenario.Tenant(tenant).TargetQPS()} {
+		if qps > 0 && (target == 0 || qps < target) {
+			target = qps
+		}
+	}
+	return target
+}
+
+// Run compares the QPS of every tenant to its target every targetInterval until ctx is done, logging the tenants
+// falling behind their target and catching up again.
+func (tr *targetTracker) Run(ctx context.Context, stats *statsCollector) {
+	ticker := time.NewTicker(targetInterval)
+	defer ticker.Stop()
+	elapsed := 0.0
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		snapshot := stats.Snapshot()
+		seconds := snapshot.ElapsedSeconds - elapsed
+		elapsed = snapshot.ElapsedSeconds
+		if seconds <= 0 {
+			continue
+		}
+		for _, name := range snapshot.TenantNames() {
+			tr.observe(name, snapshot.Tenants[name], seconds)
+		}
+	}
+}
+
+// observe accounts the queries of a tenant in the last interval of the given seconds against its target.
+func (tr *targetTracker) observe(name string, current *tenantStats, seconds float64) {
+	target := tr.targetQPS(name)
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+	t, ok := tr.tenants[name]
+	if !ok {
+		t = &targetTenant{previous: newTenantStats()}
+		tr.tenants[name] = t
+	}
+	queries := current.Queries - t.previous.Queries
+	t.previous = current
+	t.target = target
+	if target == 0 {
+		t.streak, t.behindFor = 0, 0
+		return
+	}
+	expected := target * seconds
+	t.seconds += seconds
+	t.expected += expected
+	t.achieved += queries
+	if float64(queries) < expected*(1-tr.tolerance) {
+		t.backlog += expected - float64(queries)
+		t.missed++
+		t.streak++
+		t.behindFor += seconds
+		if t.streak == targetBehindIntervals {
+			log.Printf("[WARNING] target: DB=%s behind its target for %.0fs: %.1f of %g qps, backlog %.0f queries",
+				name, t.behindFor, float64(queries)/seconds, target, t.backlog)
+		}
+		return
+	}
+	if t.streak >= targetBehindIntervals {
+		log.Printf("[INFO] target: DB=%s back on its target of %g qps after %.0fs", name, target, t.behindFor)
+	}
+	t.streak, t.behindFor = 0, 0
+}
+
+// targetSummary is the achieved-vs-target QPS of one tenant, as exported by -summary-json-file.
+type targetSummary struct {
+	Tenant      string  `json:"tenant"`
+	TargetQPS   float64 `json:"target_qps"`   // average target over the seconds with a target
+	AchievedQPS float64 `json:"achieved_qps"` // over the same seconds
+	Backlog     float64 `json:"backlog"`      // queries short of the target
+	MissedRatio float64 `json:"missed_ratio"` // fraction of the intervals below the target
+	Saturated   bool    `json:"saturated"`    // the tenant couldn't meet its target over the run
+}
+
+// Summaries returns the achieved-vs-target QPS of every tenant that had a target, by tenant name.
+func (tr *targetTracker) Summaries() []targetSummary {
+	if tr == nil {
+		return nil
+	}
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+	names := make([]string, 0, len(tr.tenants))
+	for name := range tr.tenants {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var summaries []targetSummary
+	for _, name := range names {
+		t := tr.tenants[name]
+		if t.seconds == 0 {
+			continue
+		}
+		intervals := math.Max(1, math.Round(t.seconds/targetInterval.Seconds()))
+		summaries = append(summaries, targetSummary{
+			Tenant:      name,
+			TargetQPS:   t.expected / t.seconds,
+			AchievedQPS: float64(t.achieved) / t.seconds,
+			Backlog:     t.backlog,
+			MissedRatio: math.Min(1, float64(t.missed)/intervals),
+			Saturated:   float64(t.achieved) < t.expected*(1-tr.tolerance),
+		})
+	}
+	return summaries
+}
+
+// logTargetSummary logs the achieved-vs-target QPS of every tenant that had a target and flags the tenants that
+// couldn't meet it.
+func (tr *targetTracker) logTargetSummary() {
+	saturated := 0
+	for _, s := range tr.Summaries() {
+		level, flag := "[INFO]", ""
+		if s.Saturated {
+			level, flag = "[WARNING]", " SATURATED"
+			saturated++
+		}
+		log.Printf("%s target: DB=%s target=%.1f achieved=%.1f qps (%.0f%%) backlog=%.0f queries missed=%.0f%% of the time%s",
+			level, s.Tenant, s.TargetQPS, s.AchievedQPS, 100*s.AchievedQPS/s.TargetQPS, s.Backlog, 100*s.MissedRatio, flag)
+	}
+	if saturated > 0 {
+		log.Printf("[WARNING] target: %d tenant(s) couldn't meet their QPS target", saturated)
+	}
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"context"
+	"strings"
+)
+
+// tempTableRows is the number of rows the temp_table_report query type inserts into its temporary table.
+const tempTableRows = 200
+
+// runTempTableReport runs the script of the temp_table_report query type on the connection, like a reporting job:
+// it creates a session temporary table, inserts the ids of the report's range into it, runs the report query joining
+// it with the workload table and drops the temporary table again. The report query's last two arguments are the range.
+func runTempTableReport(ctx context.Context, conn queryExecutor, t TableInfo, query string, args []interface{}) error {
+	if _, err := conn.ExecContext(ctx, "CREATE TEMPORARY TABLE tmp_report (id INT NOT NULL, weight INT NOT NULL, KEY (id))"); err != nil {
+		return err
+	}
+	defer conn.ExecContext(context.Background(), "DROP TEMPORARY TABLE IF EXISTS tmp_report")
+
+	lo := args[len(args)-2].(int)
+	values := make([]interface{}, 0, tempTableRows*2)
+	for id := lo; id < lo+tempTableRows; id++ {
+		values = append(values, id, id%100)
+	}
+	insert := "INSERT INTO tmp_report (id, weight) VALUES " + strings.TrimSuffix(strings.Repeat("(?,?),", tempTableRows), ",")
+	if _, err := conn.ExecContext(ctx, insert, values...); err != nil {
+		return err
+	}
+	return execAndDrain(ctx, conn, query, args...)
+}
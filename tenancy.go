@@ -0,0 +1,97 @@
+package main
+
+import (
+	"fmt"
+	"hash/fnv"
+)
+
+// tenancyModel decides where the data of each tenant lives, to compare the isolation of common SaaS designs:
+//
+//	db:     every tenant has its own database (test0001.sbtest1, test0002.sbtest1, ...)
+//	schema: all tenants share one database, every tenant has its own tables (tenants.test0001_sbtest1, ...)
+//	row:    all tenants share one database and its tables, rows carry a tenant_id column (tenants.sbtest1)
+type tenancyModel struct {
+	kind     string
+	sharedDB string // database of the schema and row models
+}
+
+// newTenancyModel returns the tenancy model of the given kind.
+func newTenancyModel(kind, sharedDB string) (*tenancyModel, error) {
+	switch kind {
+	case "db":
+	case "schema", "row":
+		if sharedDB == "" {
+			return nil, fmt.Errorf("the %s tenancy model needs a shared database name", kind)
+		}
+	default:
+		return nil, fmt.Errorf("unknown tenancy model %q (want db, schema or row)", kind)
+	}
+	return &tenancyModel{kind: kind, sharedDB: sharedDB}, nil
+}
+
+// Database returns the database holding the tenant's data.
+func (m *tenancyModel) Database(tenant string) string {
+	if m.kind == "db" {
+		return tenant
+	}
+	return m.sharedDB
+}
+
+// TableName returns the name of one of the tenant's tables, e.g. sbtest1 or test0001_sbtest1.
+func (m *tenancyModel) TableName(tenant, table string) string {
+	if m.kind == "schema" {
+		return tenant + "_" + table
+	}
+	return table
+}
+
+// Tables returns the tables as seen by the tenant: renamed in the schema model,
+// and tagged with the tenant's id in the row model.
+func (m *tenancyModel) Tables(tenant string, tables []TableInfo) []TableInfo {
+	if m.kind == "db" {
+		return tables
+	}
+	own := make([]TableInfo, len(tables))
+	for i, t := range tables {
+		t.Name = m.TableName(tenant, t.Name)
+		if m.kind == "row" {
+			t.TenantID = tenantID(tenant)
+		}
+		own[i] = t
+	}
+	return own
+}
+
+// tenantID returns the tenant_id of a tenant in the row model: its 1-based index for the
+// generated names (test0007 = 7), a stable hash for any other name.
+func tenantID(tenant string) int {
+	var i int
+	if n, err := fmt.Sscanf(tenant, "test%d", &i); err == nil && n == 1 && tenantName(i) == tenant {
+		return i
+	}
+	h := fnv.New32a()
+	h.Write([]byte(tenant))
+	return int(h.Sum32()&0x3fffffff) + 1
+}
+
+// where returns a WHERE condition and its arguments, restricted to the tenant's rows in the row model.
+func (t TableInfo) where(cond string, args ...interface{}) (string, []interface{}) {
+	return t.whereSQL(cond), append(t.appendWhereArgs(nil), args...)
+}
+
+// whereSQL returns a WHERE condition restricted to the tenant's rows in the row model.
+// Its arguments start with those appended by appendWhereArgs.
+func (t TableInfo) whereSQL(cond string) string {
+	if t.TenantID == 0 {
+		return cond
+	}
+	return "tenant_id=? AND " + cond
+}
+
+// appendWhereArgs appends the leading arguments of a condition returned by whereSQL to args.
+func (t TableInfo) appendWhereArgs(args []interface{}) []interface{} {
+	if t.TenantID == 0 {
+		return args
+	}
+	return append(args, t.TenantID)
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// tenantClass is the workload the workers of a tenant run: the -query-mix on the tenant's tables, or the mix and
+// tables of the class of tenants running their own workload.
+type tenantClass struct {
+	mix     *queryMix
+	tables  []TableInfo
+	limiter *tokenBucket // GC pressure: the rewrite rate, which paces the workers instead of -sleep-ms
+	pages   *pageCursor  // pagination: the worker's cursor over the tables
+}
+
+// tenantClasses returns the names of the classes the tenant is in, in order of the flags.
+func (o *workloadOptions) tenantClasses(tenant string) []string {
+	var classes []string
+	for _, class := range []struct {
+		name string
+		in   bool
+	}{
+		{"ClickHouse", o.clickhouse.Applies(tenant)},
+		{"GC pressure", o.gcPressure.Applies(tenant)},
+		{"bank", o.bank.Applies(tenant)},
+		{"order entry", o.orderEntry.Applies(tenant)},
+		{"ingestion", o.ingest.Applies(tenant)},
+		{"TTL", o.ttl.Applies(tenant)},
+		{"pagination", o.pagination.Applies(tenant)},
+	} {
+		if class.in {
+			classes = append(classes, class.name)
+		}
+	}
+	return classes
+}
+
+// checkTenantClasses returns an error if a tenant is in more than one class, since its workers can only run one mix.
+func (o *workloadOptions) checkTenantClasses(tenantNames []string) error {
+	for _, tenant := range tenantNames {
+		if classes := o.tenantClasses(tenant); len(classes) > 1 {
+			return fmt.Errorf("DB %s is in the %s tenants, a tenant can only be in one of them", tenant, strings.Join(classes, " and "))
+		}
+	}
+	return nil
+}
+
+// tenantClass resolves the workload of a worker of the tenant; checkTenantClasses made sure the tenant is in at most
+// one class. Every call returns a new pagination cursor.
+func (o *workloadOptions) tenantClass(tenant string) tenantClass {
+	tables := o.tenantTables(tenant)
+	switch {
+	case o.clickhouse.Applies(tenant):
+		return tenantClass{mix: o.clickhouse.mix, tables: tables}
+	case o.gcPressure.Applies(tenant):
+		// A GC-pressure tenant only rewrites the hot key range of its first tables, at its own rate.
+		return tenantClass{mix: o.gcPressure.mix, tables: o.gcPressure.Tables(tables),
+			limiter: o.gcPressure.Limiter(tenant)}
+	case o.bank.Applies(tenant):
+		// A bank tenant only transfers balance between the rows of its first tables.
+		return tenantClass{mix: o.bank.mix, tables: o.bank.Tables(tables)}
+	case o.orderEntry.Applies(tenant):
+		// An order-entry tenant only runs the transactions of its order-entry schema.
+		return tenantClass{mix: o.orderEntry.mix, tables: o.orderEntry.Tables(o.tenancy, tenant)}
+	case o.ingest.Applies(tenant):
+		// An ingestion tenant only appends to its events table and aggregates its recent window.
+		return tenantClass{mix: o.ingest.mix, tables: o.ingest.Tables(o.tenancy, tenant)}
+	case o.ttl.Applies(tenant):
+		// A TTL tenant only inserts expiring rows into its TTL tables.
+		return tenantClass{mix: o.ttl.mix, tables: o.ttl.Tables(o.tenancy, tenant)}
+	case o.pagination.Applies(tenant):
+		// A pagination tenant walks its tables page by page.
+		return tenantClass{mix: o.pagination.mix, tables: tables, pages: o.pagination.Cursor()}
+	}
+	return tenantClass{mix: o.mix, tables: tables}
+}
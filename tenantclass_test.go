@@ -0,0 +1,50 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestTenantClass resolves the mix of tenants in a class and of the other tenants, and rejects a tenant in two classes.
+func TestTenantClass(t *testing.T) {
+	options := func(bankTenants, pageTenants string) *workloadOptions {
+		bankSet, err := parseTenantSet(bankTenants)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pageSet, err := parseTenantSet(pageTenants)
+		if err != nil {
+			t.Fatal(err)
+		}
+		opts := &workloadOptions{tenancy: &tenancyModel{kind: "db"}, tables: []TableInfo{testTable, testTable},
+			mix: &queryMix{}}
+		if opts.bank, err = newBankTransfer(bankSet, 1, 100, time.Second); err != nil {
+			t.Fatal(err)
+		}
+		if opts.pagination, err = newPagination(pageSet, "offset", 100); err != nil {
+			t.Fatal(err)
+		}
+		return opts
+	}
+
+	opts := options("1", "2")
+	tenants := []string{"test0001", "test0002", "test0003"}
+	if err := opts.checkTenantClasses(tenants); err != nil {
+		t.Fatal(err)
+	}
+	if class := opts.tenantClass("test0001"); class.mix != opts.bank.mix || len(class.tables) != 1 || class.pages != nil {
+		t.Errorf("bank tenant: %+v, want the bank mix on its first table", class)
+	}
+	if class := opts.tenantClass("test0002"); class.mix != opts.pagination.mix || len(class.tables) != 2 || class.pages == nil {
+		t.Errorf("pagination tenant: %+v, want the pagination mix with a cursor", class)
+	}
+	if class := opts.tenantClass("test0003"); class.mix != opts.mix || len(class.tables) != 2 {
+		t.Errorf("other tenant: %+v, want the -query-mix on all tables", class)
+	}
+
+	err := options("1-2", "2-3").checkTenantClasses(tenants)
+	if err == nil || !strings.Contains(err.Error(), "test0002 is in the bank and pagination tenants") {
+		t.Errorf("overlapping tenant sets: error %v, want one naming test0002 and both classes", err)
+	}
+}
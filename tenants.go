@@ -0,0 +1,127 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// tenantName returns the database name of the i-th tenant (1-based), e.g. test0001.
+func tenantName(i int) string {
+	return fmt.Sprintf("test%04d", i)
+}
+
+// tenantSet is a set of tenant names. A nil tenantSet contains every tenant.
+type tenantSet map[string]bool
+
+// parseTenantSet parses a comma separated list of tenant names and 1-based index ranges,
+// e.g. "test0001,test0004" or "1-3,7". An empty spec selects every tenant.
+func parseTenantSet(spec string) (tenantSet, error) {
+	spec = strings.TrimSpace(spec)
+	if spec == "" {
+		return nil, nil
+	}
+	set := make(tenantSet)
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		from, to, isRange := strings.Cut(item, "-")
+		if lo, err := strconv.Atoi(from); err == nil {
+			hi := lo
+			if isRange {
+				if hi, err = strconv.Atoi(to); err != nil || hi < lo {
+					return nil, fmt.Errorf("invalid tenant range %q", item)
+				}
+			}
+			for i := lo; i <= hi; i++ {
+				set[tenantName(i)] = true
+			}
+			continue
+		}
+		set[item] = true
+	}
+	return set, nil
+}
+
+// Contains reports whether the tenant is in the set.
+func (s tenantSet) Contains(name string) bool {
+	return s == nil || s[name]
+}
+
+// Select returns the names contained in the set, in their order, and the names of the set not among names.
+func (s tenantSet) Select(names []string) (selected, unknown []string) {
+	known := make(map[string]bool, len(names))
+	for _, name := range names {
+		known[name] = true
+		if s.Contains(name) {
+			selected = append(selected, name)
+		}
+	}
+	for name := range s {
+		if !known[name] {
+			unknown = append(unknown, name)
+		}
+	}
+	sort.Strings(unknown)
+	return selected, unknown
+}
+
+// tenantRule is one entry of a tenant rule file: the tenants it applies to and its value.
+type tenantRule[T any] struct {
+	tenants tenantSet // nil = every tenant
+	value   T
+}
+
+// parseTenantRules reads a file of "tenants value" entries, one per line, e.g. "1-5 60s". tenants are names or
+// 1-based ranges, "*" for every tenant; the value is parsed by parseValue and called valueName in errors. Empty lines
+// and lines starting with "#" are ignored. The rules are returned in file order, for the first match to apply.
+func parseTenantRules[T any](path, valueName string, parseValue func(string) (T, error)) ([]tenantRule[T], error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var rules []tenantRule[T]
+	scanner := bufio.NewScanner(f)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("%s:%d: want \"tenants %s\", got %q", path, lineNo, valueName, line)
+		}
+		var rule tenantRule[T]
+		if fields[0] != "*" {
+			if rule.tenants, err = parseTenantSet(fields[0]); err != nil {
+				return nil, fmt.Errorf("%s:%d: %v", path, lineNo, err)
+			}
+		}
+		if rule.value, err = parseValue(fields[1]); err != nil {
+			return nil, fmt.Errorf("%s:%d: invalid %s %q", path, lineNo, valueName, fields[1])
+		}
+		rules = append(rules, rule)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+// matchTenantRule returns the value of the first rule containing the tenant, if any.
+func matchTenantRule[T any](rules []tenantRule[T], tenant string) (T, bool) {
+	for _, rule := range rules {
+		if rule.tenants.Contains(tenant) {
+			return rule.value, true
+		}
+	}
+	var zero T
+	return zero, false
+}
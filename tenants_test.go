@@ -0,0 +1,64 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestParseTenantRules reads a rule file of offsets with comments, blank lines, ranges, names and "*", and rejects
+// files with bad entries.
+func TestParseTenantRules(t *testing.T) {
+	write := func(content string) string {
+		path := filepath.Join(t.TempDir(), "rules")
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	rules, err := parseTenantRules(write(`
+# tenants  offset
+1-2        90s
+
+test0004   5m
+  # indented comment
+*          30
+`), "offset", parseOffset)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rules) != 3 {
+		t.Fatalf("parsed %d rules, want 3", len(rules))
+	}
+	for tenant, want := range map[string]time.Duration{
+		"test0001": 90 * time.Second,
+		"test0002": 90 * time.Second,
+		"test0003": 30 * time.Second, // "*"
+		"test0004": 5 * time.Minute,
+	} {
+		if got, ok := matchTenantRule(rules, tenant); !ok || got != want {
+			t.Errorf("%s: got %v (matched %v), want %v", tenant, got, ok, want)
+		}
+	}
+	if _, ok := matchTenantRule(rules[:2], "test0003"); ok {
+		t.Error("test0003 matched without the \"*\" rule")
+	}
+
+	for content, want := range map[string]string{
+		"1-2 soon\n":   `:1: invalid offset "soon"`,
+		"\n1 60s\n3\n": `:3: want "tenants offset"`,
+		"1 60s 90s\n":  `:1: want "tenants offset"`,
+		"3-1 60s\n":    `:1: invalid tenant range "3-1"`,
+	} {
+		_, err := parseTenantRules(write(content), "offset", parseOffset)
+		switch {
+		case err == nil:
+			t.Errorf("%q: no error, want one containing %q", content, want)
+		case !strings.Contains(err.Error(), want):
+			t.Errorf("%q: error %q, want one containing %q", content, err, want)
+		}
+	}
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// tier is a class of tenants (e.g. gold/silver/bronze) sharing the same limits.
+type tier struct {
+	name     string
+	qps      float64 // per-tenant QPS ceiling, 0 = unlimited
+	burst    int     // queries a tenant may run at once above its ceiling after being idle
+	priority int     // lower is served first by the platform-wide admission
+	tenants  tenantSet
+	rest     bool // "*": every tenant not listed in another tier
+}
+
+// loadTierFile reads a tier file with one "name qps burst priority tenants" entry per line, e.g.
+//
+//	gold   500 100 0 1-2
+//	silver 200  50 1 3-5,test0009
+//	bronze  50  10 2 *
+//
+// tenants are names or 1-based ranges; "*" matches every tenant not listed in another tier.
+// Tenants in no tier are not limited. Empty lines and lines starting with "#" are ignored.
+func loadTierFile(path string) ([]*tier, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var tiers []*tier
+	scanner := bufio.NewScanner(f)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 5 {
+			return nil, fmt.Errorf("%s:%d: want \"name qps burst priority tenants\", got %q", path, lineNo, line)
+		}
+		t := &tier{name: fields[0]}
+		if t.qps, err = strconv.ParseFloat(fields[1], 64); err != nil || t.qps < 0 {
+			return nil, fmt.Errorf("%s:%d: invalid qps %q", path, lineNo, fields[1])
+		}
+		if t.burst, err = strconv.Atoi(fields[2]); err != nil || t.burst < 0 {
+			return nil, fmt.Errorf("%s:%d: invalid burst %q", path, lineNo, fields[2])
+		}
+		if t.priority, err = strconv.Atoi(fields[3]); err != nil || t.priority < 0 {
+			return nil, fmt.Errorf("%s:%d: invalid priority %q", path, lineNo, fields[3])
+		}
+		if fields[4] == "*" {
+			t.rest = true
+		} else if t.tenants, err = parseTenantSet(fields[4]); err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", path, lineNo, err)
+		}
+		tiers = append(tiers, t)
+	}
+	return tiers, scanner.Err()
+}
+
+// tierScheduler enforces the tiers: every tenant is held to its tier's QPS ceiling and burst,
+// and with a platform-wide admission rate the queries of higher priority tiers are admitted first.
+// A nil *tierScheduler limits nothing.
+type tierScheduler struct {
+	tiers     []*tier
+	admission *priorityAdmission // nil = no platform-wide limit
+
+	mu       sync.Mutex
+	limiters map[string]*tenantLimiter
+}
+
+// newTierScheduler returns nil when there are no tiers and no admission rate.
+func newTierScheduler(ctx context.Context, tiers []*tier, admissionQPS float64) *tierScheduler {
+	if len(tiers) == 0 && admissionQPS <= 0 {
+		return nil
+	}
+	s := &tierScheduler{tiers: tiers, limiters: make(map[string]*tenantLimiter)}
+	if admissionQPS > 0 {
+		s.admission = newPriorityAdmission(ctx, admissionQPS)
+	}
+	return s
+}
+
+// tierOf returns the tier of a tenant, nil if it has none.
+func (s *tierScheduler) tierOf(tenant string) *tier {
+	var rest *tier
+	for _, t := range s.tiers {
+		if t.rest {
+			if rest == nil {
+				rest = t
+			}
+			continue
+		}
+		if t.tenants.Contains(tenant) {
+			return t
+		}
+	}
+	return rest
+}
+
+// tenantLimiter holds the limits of one tenant. A nil *tenantLimiter limits nothing.
+type tenantLimiter struct {
+	tier      *tier
+	bucket    *tokenBucket // nil = no QPS ceiling
+	admission *priorityAdmission
+}
+
+// Limiter returns the limiter shared by the workers of a tenant.
+func (s *tierScheduler) Limiter(tenant string) *tenantLimiter {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	l, ok := s.limiters[tenant]
+	if !ok {
+		l = &tenantLimiter{tier: s.tierOf(tenant), admission: s.admission}
+		if l.tier != nil && l.tier.qps > 0 {
+			l.bucket = newTokenBucket(l.tier.qps, l.tier.burst)
+		}
+		s.limiters[tenant] = l
+	}
+	return l
+}
+
+// Wait blocks until the tenant may run its next query and reports whether ctx is still active.
+func (l *tenantLimiter) Wait(ctx context.Context) bool {
+	if l == nil {
+		return ctx.Err() == nil
+	}
+	if l.bucket != nil && !l.bucket.Wait(ctx) {
+		return false
+	}
+	if l.admission != nil {
+		priority := int(^uint(0) >> 1) // tenants without a tier come last
+		if l.tier != nil {
+			priority = l.tier.priority
+		}
+		return l.admission.Wait(ctx, priority)
+	}
+	return true
+}
+
+// logTierSummary logs the queries and QPS of every tier.
+func (s *tierScheduler) logTierSummary(snapshot *statsSnapshot) {
+	type tierTotal struct {
+		tenants int
+		queries uint64
+		latency *latencyHistogram
+	}
+	totals := make(map[string]*tierTotal)
+	for _, name := range snapshot.TenantNames() {
+		tierName := "(none)"
+		if t := s.tierOf(name); t != nil {
+			tierName = t.name
+		}
+		total, ok := totals[tierName]
+		if !ok {
+			total = &tierTotal{latency: newLatencyHistogram()}
+			totals[tierName] = total
+		}
+		total.tenants++
+		total.queries += snapshot.Tenants[name].Queries
+		total.latency.Merge(snapshot.Tenants[name].Latency)
+	}
+	names := make([]string, 0, len(totals))
+	for name := range totals {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		t := totals[name]
+		qps := 0.0
+		if snapshot.ElapsedSeconds > 0 {
+			qps = float64(t.queries) / snapshot.ElapsedSeconds
+		}
+		log.Printf("[INFO] tier=%s tenants=%d queries=%d qps=%.1f p99=%v", name, t.tenants, t.queries, qps, t.latency.Quantile(0.99))
+	}
+}
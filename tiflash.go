@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Ways of sending the reads of a tenant to its storage engine.
+const (
+	// tiflashHint adds a READ_FROM_STORAGE hint to the analytical queries.
+	tiflashHint = "hint"
+	// tiflashSession sets tidb_isolation_read_engines on every connection of the tenant.
+	tiflashSession = "session"
+)
+
+// tiflashIsolation separates analytical (AP) tenants from transactional (TP) tenants on TiDB like HTAP setups do:
+// AP tenants read from TiFlash, all other tenants from TiKV. ClickHouse tenants are not affected.
+// A nil *tiflashIsolation leaves the storage engine to the optimizer.
+type tiflashIsolation struct {
+	apTenants tenantSet
+	mode      string
+}
+
+// newTiFlashIsolation returns nil when no AP tenants are given.
+func newTiFlashIsolation(spec, mode string) (*tiflashIsolation, error) {
+	if strings.TrimSpace(spec) == "" {
+		return nil, nil
+	}
+	if mode != tiflashHint && mode != tiflashSession {
+		return nil, fmt.Errorf("unknown TiFlash isolation mode %q (want %s or %s)", mode, tiflashHint, tiflashSession)
+	}
+	tenants, err := parseTenantSet(spec)
+	if err != nil {
+		return nil, err
+	}
+	return &tiflashIsolation{apTenants: tenants, mode: mode}, nil
+}
+
+// Applies reports whether the tenant is an AP tenant reading from TiFlash.
+func (i *tiflashIsolation) Applies(tenant string) bool {
+	return i != nil && i.apTenants.Contains(tenant)
+}
+
+// engine returns the storage engine the tenant reads from.
+func (i *tiflashIsolation) engine(tenant string) string {
+	if i.Applies(tenant) {
+		return "tiflash"
+	}
+	return "tikv"
+}
+
+// SessionParam returns the DSN parameter restricting the tenant's connections to its engine, "" in hint mode.
+// The driver sets it as a session variable on every new connection, reconnects included.
+func (i *tiflashIsolation) SessionParam(tenant string) string {
+	if i == nil || i.mode != tiflashSession {
+		return ""
+	}
+	// TiDB itself stays readable for the system tables.
+	return "tidb_isolation_read_engines='" + i.engine(tenant) + ",tidb'"
+}
+
+// Hint adds the READ_FROM_STORAGE hint of the tenant's engine to an analytical query; other queries are returned as they are.
+func (i *tiflashIsolation) Hint(tenant string, qt *queryType, t TableInfo, query string) string {
+	if i == nil || i.mode != tiflashHint || !qt.analytic || t.Dialect == dialectClickHouse {
+		return query
+	}
+	return addHint(query, fmt.Sprintf("READ_FROM_STORAGE(%s[%s])", strings.ToUpper(i.engine(tenant)), t.Name))
+}
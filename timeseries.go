@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"time"
+)
+
+// timeSeriesWriter appends the QPS, latency and errors of every tenant per interval (one second by default) to a CSV file,
+// so the client view of a run can be lined up with server metrics at fine granularity, e.g. in pandas.
+type timeSeriesWriter struct {
+	file     *os.File
+	csv      *csv.Writer
+	interval time.Duration
+	server   *serverSampler // nil when no server values are added
+}
+
+// newTimeSeriesWriter creates (or truncates) the CSV file and writes its header, with a column per value of the server
+// sampler. It returns nil when path is "".
+func newTimeSeriesWriter(path string, interval time.Duration, server *serverSampler) (*timeSeriesWriter, error) {
+	if path == "" {
+		return nil, nil
+	}
+	if interval <= 0 {
+		return nil, fmt.Errorf("time series interval must be positive")
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	w := &timeSeriesWriter{file: f, csv: csv.NewWriter(f), interval: interval, server: server}
+	w.csv.Write(append([]string{"timestamp", "elapsed_s", "tenant", "queries", "qps", "errors", "p50_ms", "p95_ms", "p99_ms"}, server.Columns()...))
+	return w, nil
+}
+
+// Run appends one row per tenant every interval until ctx is done, then closes the file.
+// Rows are flushed every interval, so the file can be followed while the run goes on.
+func (w *timeSeriesWriter) Run(ctx context.Context, stats *statsCollector) {
+	defer func() {
+		w.csv.Flush()
+		if err := w.file.Close(); err != nil {
+			log.Printf("[ERROR] Failed to close time series file: %v", err)
+		}
+	}()
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+	previous := make(map[string]*tenantStats)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		snapshot := stats.Snapshot()
+		timestamp := time.Now().UTC().Format(time.RFC3339Nano)
+		elapsed := strconv.FormatFloat(snapshot.ElapsedSeconds, 'f', 1, 64)
+		server := w.server.Values()
+		for _, tenant := range snapshot.TenantNames() {
+			current := snapshot.Tenants[tenant]
+			prev, ok := previous[tenant]
+			if !ok {
+				prev = newTenantStats()
+			}
+			queries, errors := current.Queries-prev.Queries, current.Errors-prev.Errors
+			latency := current.Latency.Since(prev.Latency)
+			w.csv.Write(append([]string{timestamp, elapsed, tenant,
+				strconv.FormatUint(queries, 10),
+				strconv.FormatFloat(float64(queries)/w.interval.Seconds(), 'f', 1, 64),
+				strconv.FormatUint(errors, 10),
+				durationMs(latency.Quantile(0.50)), durationMs(latency.Quantile(0.95)), durationMs(latency.Quantile(0.99)),
+			}, server...))
+			previous[tenant] = current
+		}
+		w.csv.Flush()
+		if err := w.csv.Error(); err != nil {
+			log.Printf("[ERROR] Failed to write time series: %v", err)
+		}
+	}
+}
+
+// durationMs formats a duration in milliseconds with microsecond precision.
+func durationMs(d time.Duration) string {
+	return strconv.FormatFloat(float64(d.Microseconds())/1000, 'f', 3, 64)
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"math/rand/v2"
+	"strconv"
+	"time"
+)
+
+// defaultTTLLifetime is the lifetime of the rows inserted by ttl_insert when it runs without TTL settings.
+const defaultTTLLifetime = 10 * time.Minute
+
+// ttlTables makes selected tenants write to TiDB TTL tables: prepare mode creates tables whose rows TiDB deletes
+// once their expires_at column has passed, and the workers of the tenants only insert rows expiring after a random
+// lifetime. The TTL jobs TiDB runs in the background to delete the expired rows scan and delete next to the
+// foreground tenants, whose interference can be evaluated this way. A nil *ttlTables is disabled.
+type ttlTables struct {
+	tenants     tenantSet
+	tables      int           // TTL tables per tenant, ttl1..ttlN
+	lifetime    time.Duration // mean lifetime of the inserted rows, spread over ±50%
+	jobInterval string        // TTL_JOB_INTERVAL of the tables, e.g. 1h
+	mix         *queryMix
+}
+
+// newTTLTables returns nil when no tenant is selected.
+func newTTLTables(tenants tenantSet, tables int, lifetime time.Duration, jobInterval string) (*ttlTables, error) {
+	if tenants == nil {
+		return nil, nil
+	}
+	if tables < 1 {
+		return nil, fmt.Errorf("the TTL tables per tenant must be at least 1")
+	}
+	if lifetime < time.Second {
+		return nil, fmt.Errorf("the row lifetime must be at least one second")
+	}
+	if !validTTLInterval(jobInterval) {
+		return nil, fmt.Errorf("invalid TTL job interval %q (want a number and a unit of m, h or d, e.g. 1h)", jobInterval)
+	}
+	mix, err := parseQueryMix("ttl_insert")
+	if err != nil {
+		return nil, err
+	}
+	return &ttlTables{tenants: tenants, tables: tables, lifetime: lifetime, jobInterval: jobInterval, mix: mix}, nil
+}
+
+// validTTLInterval reports whether s is an interval TiDB accepts as TTL_JOB_INTERVAL, like 10m, 1h or 1d.
+func validTTLInterval(s string) bool {
+	if len(s) < 2 {
+		return false
+	}
+	switch s[len(s)-1] {
+	case 'm', 'h', 'd':
+	default:
+		return false
+	}
+	n, err := strconv.Atoi(s[:len(s)-1])
+	return err == nil && n > 0
+}
+
+// Applies reports whether the tenant writes to TTL tables.
+func (l *ttlTables) Applies(tenant string) bool {
+	return l != nil && l.tenants.Contains(tenant)
+}
+
+// Tables returns the TTL tables of a tenant, the tables the workers of the tenant pick.
+func (l *ttlTables) Tables(tenancy *tenancyModel, tenant string) []TableInfo {
+	tables := make([]TableInfo, l.tables)
+	for i := range tables {
+		tables[i] = TableInfo{Name: "ttl" + strconv.Itoa(i+1)}
+	}
+	return tenancy.Tables(tenant, tables)
+}
+
+// insertArgs appends the arguments of a ttl_insert: the tenant_id in the row tenancy model, k, c and the lifetime of
+// the row in seconds.
+func (l *ttlTables) insertArgs(t TableInfo, rng *rand.Rand, args []interface{}) []interface{} {
+	lifetime := defaultTTLLifetime
+	if l != nil {
+		lifetime = l.lifetime
+	}
+	seconds := int(lifetime.Seconds() * (0.5 + rng.Float64()))
+	return append(t.appendWhereArgs(args), rng.IntN(1<<20), sysbenchString(rng, 120), seconds)
+}
+
+// ttlInsertSQL returns the ttl_insert statement of the table.
+func ttlInsertSQL(t TableInfo) string {
+	if t.TenantID != 0 {
+		return "INSERT INTO " + t.Name + " (tenant_id, k, c, expires_at) VALUES (?, ?, ?, NOW() + INTERVAL ? SECOND)"
+	}
+	return "INSERT INTO " + t.Name + " (k, c, expires_at) VALUES (?, ?, ?, NOW() + INTERVAL ? SECOND)"
+}
+
+// createTTLTableSQL returns the CREATE TABLE statement of a TTL table: TiDB deletes a row once its expires_at has
+// passed, in TTL jobs run every jobInterval.
+func createTTLTableSQL(t TableInfo, jobInterval, tableOptions string) string {
+	tenantColumn, tenantKey := "", ""
+	if t.TenantID != 0 {
+		tenantColumn, tenantKey = "  tenant_id  INT NOT NULL,\n", "`tenant_id`,"
+	}
+	return fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (\n  id         BIGINT NOT NULL AUTO_INCREMENT,\n%s  k          INT NOT NULL DEFAULT '0',\n"+
+		"  c          CHAR(120) NOT NULL DEFAULT '',\n  expires_at DATETIME NOT NULL,\n  PRIMARY KEY (`id`),\n  KEY `expires_1` (%s`expires_at`)\n)%s\n"+
+		"TTL = `expires_at` + INTERVAL 0 SECOND TTL_ENABLE = 'ON' TTL_JOB_INTERVAL = '%s'",
+		t.Name, tenantColumn, tenantKey, tableOptions, jobInterval)
+}
+
+// prepareTTL runs a prepare job of a TTL table: it creates the table, which starts empty.
+func prepareTTL(ctx context.Context, db *sql.DB, job prepareJob, schema *schemaOptions, batchRows int, rng *rand.Rand) error {
+	if _, err := db.ExecContext(ctx, createTTLTableSQL(job.table, job.ttl.jobInterval, job.options)); err != nil {
+		return err
+	}
+	log.Printf("[INFO] prepare: %s.%s ready, rows expire by TTL every %s", job.tenant, job.table.Name, job.ttl.jobInterval)
+	return nil
+}
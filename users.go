@@ -0,0 +1,195 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// tenantCredentials are the user name and password a tenant connects with.
+type tenantCredentials struct {
+	user     string
+	password string
+}
+
+// tenantUsers gives MySQL/TiDB tenants their own database user, or a pool of accounts whose connections are
+// distributed among them, so per-user connection limits and privileges are part of the run. Users come from the user
+// file, or else from templates where "{tenant}" is replaced by the tenant name (e.g. "{tenant}_app") and "{n}" by the
+// 1-based number of the account. Tenants listed in the DSN mapping file keep the credentials of their DSN.
+// A nil *tenantUsers leaves the credentials of the DSNs alone.
+type tenantUsers struct {
+	user      string // user name template, "" = only the tenants of the user file get their own user
+	password  string // password template
+	accounts  int    // accounts of every tenant of the templates
+	perTenant map[string][]tenantCredentials
+	// maxConnections is the MAX_USER_CONNECTIONS of the users created in prepare mode, 0 = unlimited.
+	maxConnections int
+	provision      bool // prepare mode creates the users, cleanup mode drops them
+}
+
+// newTenantUsers returns nil when neither a user template nor a user file is given.
+func newTenantUsers(user, password, file string, accounts, maxConnections int, provision bool) (*tenantUsers, error) {
+	if user == "" && file == "" {
+		return nil, nil
+	}
+	if accounts < 1 {
+		return nil, fmt.Errorf("every tenant needs at least 1 account")
+	}
+	u := &tenantUsers{user: user, password: password, accounts: accounts, perTenant: make(map[string][]tenantCredentials),
+		maxConnections: maxConnections, provision: provision}
+	if file != "" {
+		if err := u.loadFile(file); err != nil {
+			return nil, err
+		}
+	}
+	return u, nil
+}
+
+// loadFile reads a user file with one "tenant user [password]" entry per line; the entries of a tenant are its
+// accounts. Empty lines and lines starting with "#" are ignored.
+func (u *tenantUsers) loadFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 2 && len(fields) != 3 {
+			return fmt.Errorf("%s:%d: want \"tenant user [password]\", got %q", path, lineNo, line)
+		}
+		creds := tenantCredentials{user: fields[1]}
+		if len(fields) == 3 {
+			creds.password = fields[2]
+		}
+		u.perTenant[fields[0]] = append(u.perTenant[fields[0]], creds)
+	}
+	return scanner.Err()
+}
+
+// Applies reports whether the tenant connects as its own user.
+func (u *tenantUsers) Applies(tenant string) bool {
+	if u == nil {
+		return false
+	}
+	_, listed := u.perTenant[tenant]
+	return listed || u.user != ""
+}
+
+// Provisions reports whether prepare and cleanup mode manage the tenant's user.
+func (u *tenantUsers) Provisions(tenant string) bool {
+	return u.Applies(tenant) && u.provision
+}
+
+// provisionedUser reports whether prepare and cleanup mode manage the user of a tenant:
+// ClickHouse tenants and tenants in the DSN mapping file have no user of their own.
+func provisionedUser(dsns *dsnResolver, users *tenantUsers, tenant string) bool {
+	_, mapped := dsns.perTenant[tenant]
+	return users.Provisions(tenant) && !mapped && !dsns.clickhouse.Applies(tenant)
+}
+
+// Accounts returns the number of accounts of a tenant.
+func (u *tenantUsers) Accounts(tenant string) int {
+	if creds, ok := u.perTenant[tenant]; ok {
+		return len(creds)
+	}
+	return u.accounts
+}
+
+// User returns the user name of an account (0-based) of a tenant. The templates without "{n}" get "_<n>" appended
+// when the tenant has several accounts.
+func (u *tenantUsers) User(tenant string, account int) string {
+	if creds, ok := u.perTenant[tenant]; ok {
+		return creds[account].user
+	}
+	user := strings.ReplaceAll(u.user, "{tenant}", tenant)
+	n := strconv.Itoa(account + 1)
+	if u.accounts > 1 && !strings.Contains(user, "{n}") {
+		return user + "_" + n
+	}
+	return strings.ReplaceAll(user, "{n}", n)
+}
+
+// Password returns the password of an account (0-based) of a tenant.
+func (u *tenantUsers) Password(tenant string, account int) string {
+	if creds, ok := u.perTenant[tenant]; ok {
+		return creds[account].password
+	}
+	return strings.NewReplacer("{tenant}", tenant, "{n}", strconv.Itoa(account+1)).Replace(u.password)
+}
+
+// createTenantUser creates (or updates) the accounts of the tenant on the server of dsn and grants them
+// all privileges on the tenant's database, and nothing else but reading the common database.
+func createTenantUser(ctx context.Context, driverName, dsn string, users *tenantUsers, tenant, database string) error {
+	dsn, err := serverDSN(dsn)
+	if err != nil {
+		return err
+	}
+	db, err := sql.Open(driverName, dsn)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	limit := ""
+	if users.maxConnections > 0 {
+		limit = fmt.Sprintf(" WITH MAX_USER_CONNECTIONS %d", users.maxConnections)
+	}
+	for i := 0; i < users.Accounts(tenant); i++ {
+		account := fmt.Sprintf("'%s'@'%%'", sqlQuote(users.User(tenant, i)))
+		identified := fmt.Sprintf(" IDENTIFIED BY '%s'", sqlQuote(users.Password(tenant, i)))
+		for _, stmt := range []string{
+			"CREATE USER IF NOT EXISTS " + account + identified + limit,
+			// Keep the password and limit of users left over from an earlier prepare in sync with the flags.
+			"ALTER USER " + account + identified + limit,
+			fmt.Sprintf("GRANT ALL PRIVILEGES ON `%s`.* TO %s", database, account),
+		} {
+			if _, err := db.ExecContext(ctx, stmt); err != nil {
+				return fmt.Errorf("%s: %v", users.User(tenant, i), err)
+			}
+		}
+		if commonDB != "" {
+			// Tenants only read the shared reference tables.
+			if _, err := db.ExecContext(ctx, fmt.Sprintf("GRANT SELECT ON `%s`.* TO %s", commonDB, account)); err != nil {
+				return fmt.Errorf("%s: %v", users.User(tenant, i), err)
+			}
+		}
+		log.Printf("[INFO] prepare: user %s can access database %s", users.User(tenant, i), database)
+	}
+	return nil
+}
+
+// dropTenantUser drops the accounts of the tenant from the server of dsn.
+func dropTenantUser(ctx context.Context, driverName, dsn string, users *tenantUsers, tenant string) error {
+	dsn, err := serverDSN(dsn)
+	if err != nil {
+		return err
+	}
+	db, err := sql.Open(driverName, dsn)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	for i := 0; i < users.Accounts(tenant); i++ {
+		if _, err := db.ExecContext(ctx, fmt.Sprintf("DROP USER IF EXISTS '%s'@'%%'", sqlQuote(users.User(tenant, i)))); err != nil {
+			return fmt.Errorf("%s: %v", users.User(tenant, i), err)
+		}
+		log.Printf("[INFO] cleanup: dropped user %s", users.User(tenant, i))
+	}
+	return nil
+}
+
+// sqlQuote escapes a string for a single-quoted SQL literal.
+func sqlQuote(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `'`, `''`).Replace(s)
+}
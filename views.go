@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+)
+
+// joinViewName is the base name of the join view of a tenant, over its tables 1 ~ 4 like the join query.
+const joinViewName = "sbtest_join_v"
+
+// viewName returns the name of the view over a table, e.g. sbtest1_v.
+func viewName(table string) string {
+	return table + "_v"
+}
+
+// createViewSQL returns the CREATE VIEW statement of the view over a table, showing all of its columns.
+func createViewSQL(t TableInfo) string {
+	return fmt.Sprintf("CREATE OR REPLACE VIEW %s AS SELECT * FROM %s", viewName(t.Name), t.Name)
+}
+
+// createJoinViewSQL returns the CREATE VIEW statement of the join view of a tenant: the left join of its tables 1 ~ 4
+// on id, with the columns of the join query. In the row tenancy model it joins on tenant_id too and shows it.
+func createJoinViewSQL(tenancy *tenancyModel, names tableNameTemplate, tenant string) string {
+	table := func(i int) string {
+		return fmt.Sprintf("%s AS sbtest%d", tenancy.TableName(tenant, names.Name(i)), i)
+	}
+	columns, joinTenant := "sbtest1.id AS id", ""
+	if tenancy.kind == "row" {
+		columns, joinTenant = "sbtest1.tenant_id AS tenant_id, "+columns, " AND sbtest1.tenant_id = sbtest%[2]d.tenant_id"
+	}
+	join := func(i int) string {
+		return fmt.Sprintf("\nLEFT JOIN %s ON sbtest1.id = sbtest%d.id"+joinTenant, table(i), i)
+	}
+	return fmt.Sprintf("CREATE OR REPLACE VIEW %s AS\nSELECT %s, sbtest2.k AS k, sbtest3.c AS c, sbtest4.pad AS pad\nFROM %s%s%s%s",
+		tenancy.TableName(tenant, joinViewName), columns, table(1), join(2), join(3), join(4))
+}
+
+// prepareJoinView creates the join view of a tenant once its tables exist. Tenants with fewer than 4 tables have none.
+func prepareJoinView(ctx context.Context, db *sql.DB, opts *workloadOptions, tenant string) error {
+	if len(opts.tables) < 4 || opts.clickhouse.Applies(tenant) {
+		return nil
+	}
+	if _, err := db.ExecContext(ctx, createJoinViewSQL(opts.tenancy, opts.tableNames, tenant)); err != nil {
+		return fmt.Errorf("%s.%s: %v", tenant, opts.tenancy.TableName(tenant, joinViewName), err)
+	}
+	return nil
+}
+
+// viewQueries makes tenants read through the views created by prepare mode with -views instead of the base tables,
+// like tenants whose applications only see views. Writes, transaction scripts and stored procedure calls keep using
+// the base tables, as views are not updatable in TiDB. A nil *viewQueries reads the base tables.
+type viewQueries struct{}
+
+// newViewQueries returns nil when queries don't go through the views.
+func newViewQueries(enabled bool) *viewQueries {
+	if !enabled {
+		return nil
+	}
+	log.Printf("[INFO] views: reads go through the views of the tables and the join view")
+	return &viewQueries{}
+}
+
+// Table returns the table a query of the type reads: the view over t for plain reads of the sbtest tables, else t.
+func (v *viewQueries) Table(qt *queryType, t TableInfo) TableInfo {
+	if v == nil || qt.write || qt.run != nil || qt.procedure || qt.orderEntry || qt.ingest || qt.ttl || t.Dialect == dialectClickHouse {
+		return t
+	}
+	t.Name = viewName(t.Name)
+	return t
+}
+
+// JoinQuery returns the join query through the join view of the tenant, with the rows, columns and arguments of
+// joinSelectQuery.
+func (v *viewQueries) JoinQuery(tenancy *tenancyModel, tenant string, randID uint64) (string, []interface{}) {
+	where, args := "", []interface{}{randID}
+	if tenancy.kind == "row" {
+		where, args = "tenant_id = ? AND ", []interface{}{tenantID(tenant), randID}
+	}
+	return "SELECT id, k, c, pad FROM " + tenancy.TableName(tenant, joinViewName) + " WHERE " + where + "id >= ? LIMIT 100", args
+}
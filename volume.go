@@ -0,0 +1,86 @@
+package main
+
+import (
+	"database/sql"
+	"sync"
+)
+
+// resultVolume is the data volume of one query: the rows it returned, and the approximate bytes of its result
+// received and of its statement and arguments sent. Bytes are counted as the length of the values, without the
+// framing of the protocol, so they tell data-volume heavy tenants apart from chatty ones rather than match the network.
+type resultVolume struct {
+	Rows     uint64
+	BytesIn  uint64
+	BytesOut uint64
+}
+
+// statementBytes returns the approximate bytes of a statement and its arguments: the length of the text and of
+// string and []byte arguments, 8 for the others.
+func statementBytes(query string, args []interface{}) uint64 {
+	n := uint64(len(query))
+	for _, arg := range args {
+		switch v := arg.(type) {
+		case string:
+			n += uint64(len(v))
+		case []byte:
+			n += uint64(len(v))
+		default:
+			n += 8
+		}
+	}
+	return n
+}
+
+// scanBuffer is the destination of Scan for the rows of one result: a value per column and pointers to them.
+type scanBuffer struct {
+	values []sql.RawBytes
+	dest   []interface{}
+}
+
+// scanBuffers are reused by all workers, so draining a result doesn't allocate once the pool is warm.
+var scanBuffers = sync.Pool{New: func() interface{} { return new(scanBuffer) }}
+
+// getScanBuffer returns a pooled buffer for columns values, to be put back with putScanBuffer.
+func getScanBuffer(columns int) *scanBuffer {
+	b := scanBuffers.Get().(*scanBuffer)
+	if cap(b.values) < columns {
+		b.values = make([]sql.RawBytes, columns)
+		b.dest = make([]interface{}, columns)
+	}
+	b.values, b.dest = b.values[:columns], b.dest[:columns]
+	for i := range b.values {
+		b.dest[i] = &b.values[i]
+	}
+	return b
+}
+
+// putScanBuffer drops the references to the driver's memory held by the values and returns b to the pool.
+func putScanBuffer(b *scanBuffer) {
+	clear(b.values)
+	scanBuffers.Put(b)
+}
+
+// drainRows reads (and discards) all rows, adding them and the length of their values to v unless v is nil.
+func drainRows(rows *sql.Rows, v *resultVolume) error {
+	if v == nil {
+		for rows.Next() {
+		}
+		return rows.Err()
+	}
+	columns, err := rows.Columns()
+	if err != nil {
+		return err
+	}
+	buf := getScanBuffer(len(columns))
+	defer putScanBuffer(buf)
+	for rows.Next() {
+		if err := rows.Scan(buf.dest...); err != nil {
+			return err
+		}
+		v.Rows++
+		for _, value := range buf.values {
+			v.BytesIn += uint64(len(value))
+		}
+	}
+	return rows.Err()
+}